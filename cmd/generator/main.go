@@ -1,71 +1,89 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
 
 	"github.com/nihei9/maleeni/ucd"
 )
 
+// defaultUnicodeVersion is the version of the Unicode Character Database that the generator uses when neither
+// the -version flag nor the MALEENI_UCD_VERSION environment variable is specified.
+const defaultUnicodeVersion = "14.0.0"
+
 func main() {
-	err := gen()
+	version := flag.String("version", "", fmt.Sprintf("Unicode version, such as 14.0.0 (default $MALEENI_UCD_VERSION or %v)", defaultUnicodeVersion))
+	ucdDir := flag.String("ucd-dir", "", "directory containing the UCD files of the version (default: download them from unicode.org)")
+	flag.Parse()
+	if *version == "" {
+		*version = os.Getenv("MALEENI_UCD_VERSION")
+	}
+	if *version == "" {
+		*version = defaultUnicodeVersion
+	}
+
+	err := gen(*version, *ucdDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 }
 
-func gen() error {
+func gen(version, ucdDir string) error {
 	var propValAliases *ucd.PropertyValueAliases
-	{
-		resp, err := http.Get("https://www.unicode.org/Public/13.0.0/ucd/PropertyValueAliases.txt")
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		propValAliases, err = ucd.ParsePropertyValueAliases(resp.Body)
-		if err != nil {
-			return err
-		}
+	err := fetch(version, ucdDir, "PropertyValueAliases.txt", func(r io.Reader) error {
+		var err error
+		propValAliases, err = ucd.ParsePropertyValueAliases(r)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 	var unicodeData *ucd.UnicodeData
-	{
-		resp, err := http.Get("https://www.unicode.org/Public/13.0.0/ucd/UnicodeData.txt")
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		unicodeData, err = ucd.ParseUnicodeData(resp.Body, propValAliases)
-		if err != nil {
-			return err
-		}
+	err = fetch(version, ucdDir, "UnicodeData.txt", func(r io.Reader) error {
+		var err error
+		unicodeData, err = ucd.ParseUnicodeData(r, propValAliases)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 	var scripts *ucd.Scripts
-	{
-		resp, err := http.Get("https://www.unicode.org/Public/13.0.0/ucd/Scripts.txt")
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		scripts, err = ucd.ParseScripts(resp.Body, propValAliases)
-		if err != nil {
-			return err
-		}
+	err = fetch(version, ucdDir, "Scripts.txt", func(r io.Reader) error {
+		var err error
+		scripts, err = ucd.ParseScripts(r, propValAliases)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 	var propList *ucd.PropList
-	{
-		resp, err := http.Get("https://www.unicode.org/Public/13.0.0/ucd/PropList.txt")
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		propList, err = ucd.ParsePropList(resp.Body)
-		if err != nil {
-			return err
-		}
+	err = fetch(version, ucdDir, "PropList.txt", func(r io.Reader) error {
+		var err error
+		propList, err = ucd.ParsePropList(r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	var derivedAge *ucd.DerivedAge
+	err = fetch(version, ucdDir, "DerivedAge.txt", func(r io.Reader) error {
+		var err error
+		derivedAge, err = ucd.ParseDerivedAge(r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = checkCoverage(version, unicodeData, derivedAge)
+	if err != nil {
+		return err
 	}
 	tmpl, err := template.ParseFiles("../ucd/codepoint.go.tmpl")
 	if err != nil {
@@ -74,12 +92,14 @@ func gen() error {
 	var b strings.Builder
 	err = tmpl.Execute(&b, struct {
 		GeneratorName        string
+		UnicodeVersion       string
 		UnicodeData          *ucd.UnicodeData
 		Scripts              *ucd.Scripts
 		PropList             *ucd.PropList
 		PropertyValueAliases *ucd.PropertyValueAliases
 	}{
 		GeneratorName:        "generator/main.go",
+		UnicodeVersion:       version,
 		UnicodeData:          unicodeData,
 		Scripts:              scripts,
 		PropList:             propList,
@@ -96,3 +116,70 @@ func gen() error {
 	fmt.Fprint(f, b.String())
 	return nil
 }
+
+// fetch reads a UCD file from ucdDir. When ucdDir is empty, fetch downloads the file from unicode.org instead.
+func fetch(version, ucdDir, fileName string, parse func(r io.Reader) error) error {
+	if ucdDir != "" {
+		path := filepath.Join(ucdDir, fileName)
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		err = parse(f)
+		if err != nil {
+			return fmt.Errorf("failed to parse %v: %w", path, err)
+		}
+		return nil
+	}
+
+	url := fmt.Sprintf("https://www.unicode.org/Public/%v/ucd/%v", version, fileName)
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %v: %v", url, resp.Status)
+	}
+	err = parse(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to parse %v: %w", url, err)
+	}
+	return nil
+}
+
+// checkCoverage verifies that the general category table covers all code points assigned in the selected version.
+// Since the other tables are generated from the same UCD, this check detects data files the parsers don't support.
+func checkCoverage(version string, unicodeData *ucd.UnicodeData, derivedAge *ucd.DerivedAge) error {
+	// DerivedAge.txt represents ages in the form `major.minor`.
+	age := version
+	if vs := strings.Split(version, "."); len(vs) >= 2 {
+		age = strings.Join(vs[:2], ".")
+	}
+	newCPs, ok := derivedAge.Age[age]
+	if !ok {
+		return fmt.Errorf("DerivedAge.txt has no code points introduced in version %v", version)
+	}
+
+	covered := map[rune]struct{}{}
+	for _, cps := range unicodeData.GeneralCategory {
+		for _, cp := range cps {
+			for c := cp.From; c <= cp.To; c++ {
+				covered[c] = struct{}{}
+			}
+		}
+	}
+	for _, cp := range newCPs {
+		for c := cp.From; c <= cp.To; c++ {
+			// Noncharacters have ages but don't appear in UnicodeData.txt.
+			if c >= 0xFDD0 && c <= 0xFDEF || c&0xFFFE == 0xFFFE {
+				continue
+			}
+			if _, ok := covered[c]; !ok {
+				return fmt.Errorf("the general category table doesn't cover U+%04X introduced in version %v", c, version)
+			}
+		}
+	}
+	return nil
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	_ "embed"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/nihei9/maleeni/driver"
 	"github.com/nihei9/maleeni/spec"
@@ -23,8 +26,14 @@ func Execute() error {
 }
 
 var generateFlags = struct {
-	pkgName *string
-	output  *string
+	pkgName    *string
+	output     *string
+	streaming  *bool
+	benchInput *string
+	buildTags  *string
+	headerFile *string
+	lineDirs   *bool
+	matcher    *bool
 }{}
 
 var generateCmd = &cobra.Command{
@@ -41,25 +50,65 @@ var generateCmd = &cobra.Command{
 func init() {
 	generateFlags.pkgName = generateCmd.Flags().StringP("package", "p", "main", "package name")
 	generateFlags.output = generateCmd.Flags().StringP("output", "o", "", "output file path")
+	generateFlags.streaming = generateCmd.Flags().Bool("streaming", false, "generate a lexer that reads the source incrementally instead of reading it all at once")
+	generateFlags.buildTags = generateCmd.Flags().String("build-tags", "", "build constraint expression written in a //go:build line of the generated files, such as 'linux && !cgo'")
+	generateFlags.headerFile = generateCmd.Flags().String("header-file", "", "file containing a comment, such as a license notice, put at the top of the generated files")
+	generateFlags.lineDirs = generateCmd.Flags().Bool("line-directives", false, "put //line directives in the generated lexer so that stack traces of panics in the driver code refer to lines of driver/lexer.go")
+	generateFlags.matcher = generateCmd.Flags().Bool("matcher-table", false, "generate only the transition tables and functions stepping through them instead of a lexer")
+	generateFlags.benchInput = generateCmd.Flags().String("bench-input", "", "sample input file; when specified, maleeni-go also generates a benchmark (*_bench_test.go) tokenizing it")
 }
 
 func runGenerate(cmd *cobra.Command, args []string) (retErr error) {
+	if *generateFlags.matcher && (*generateFlags.streaming || *generateFlags.lineDirs || *generateFlags.benchInput != "") {
+		return fmt.Errorf("--matcher-table option cannot be used with --streaming, --line-directives, and --bench-input options")
+	}
+
 	clspec, err := readCompiledLexSpec(args[0])
 	if err != nil {
 		return fmt.Errorf("Cannot read a compiled lexical specification: %w", err)
 	}
 
-	b, err := driver.GenLexer(clspec, *generateFlags.pkgName)
-	if err != nil {
-		return fmt.Errorf("Failed to generate a lexer: %v", err)
+	var fileOpts []driver.GenLexerOption
+	if *generateFlags.buildTags != "" {
+		fileOpts = append(fileOpts, driver.GenBuildConstraint(*generateFlags.buildTags))
+	}
+	if *generateFlags.headerFile != "" {
+		header, err := ioutil.ReadFile(*generateFlags.headerFile)
+		if err != nil {
+			return fmt.Errorf("Cannot read a header file: %w", err)
+		}
+		fileOpts = append(fileOpts, driver.GenHeader(string(header)))
+	}
+
+	opts := append([]driver.GenLexerOption{}, fileOpts...)
+	if *generateFlags.streaming {
+		opts = append(opts, driver.GenStreamingLexer())
 	}
 
 	var filePath string
 	if *generateFlags.output != "" {
 		filePath = *generateFlags.output
+	} else if *generateFlags.matcher {
+		filePath = fmt.Sprintf("%v_matcher.go", clspec.Name)
 	} else {
 		filePath = fmt.Sprintf("%v_lexer.go", clspec.Name)
 	}
+	if *generateFlags.lineDirs {
+		opts = append(opts, driver.GenLineDirectives(filepath.Base(filePath)))
+	}
+
+	var b []byte
+	if *generateFlags.matcher {
+		b, err = driver.GenMatcherTable(clspec, *generateFlags.pkgName, opts...)
+		if err != nil {
+			return fmt.Errorf("Failed to generate a matcher table: %v", err)
+		}
+	} else {
+		b, err = driver.GenLexer(clspec, *generateFlags.pkgName, opts...)
+		if err != nil {
+			return fmt.Errorf("Failed to generate a lexer: %v", err)
+		}
+	}
 
 	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
@@ -72,6 +121,29 @@ func runGenerate(cmd *cobra.Command, args []string) (retErr error) {
 		return fmt.Errorf("Failed to write lexer source code: %v", err)
 	}
 
+	if *generateFlags.benchInput != "" {
+		err := writeBenchmark(*generateFlags.benchInput, strings.TrimSuffix(filePath, ".go")+"_bench_test.go", fileOpts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeBenchmark(inputPath, filePath string, opts []driver.GenLexerOption) error {
+	input, err := ioutil.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("Cannot read a sample input: %w", err)
+	}
+	b, err := driver.GenBenchmark(*generateFlags.pkgName, input, opts...)
+	if err != nil {
+		return fmt.Errorf("Failed to generate a benchmark: %v", err)
+	}
+	err = ioutil.WriteFile(filePath, b, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write a benchmark: %v", err)
+	}
 	return nil
 }
 
@@ -84,8 +156,21 @@ func readCompiledLexSpec(path string) (*spec.CompiledLexSpec, error) {
 	if err != nil {
 		return nil, err
 	}
-	clspec := &spec.CompiledLexSpec{}
-	err = json.Unmarshal(data, clspec)
+	// A compiled lexical specification is written in JSON or gob format. See `maleeni compile --format`.
+	var clspec *spec.CompiledLexSpec
+	if !json.Valid(data) {
+		clspec, err = spec.DecodeCompiledLexSpecGob(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("the file is neither JSON nor gob format: %w", err)
+		}
+	} else {
+		clspec = &spec.CompiledLexSpec{}
+		err = json.Unmarshal(data, clspec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = clspec.ValidateFormatVersion()
 	if err != nil {
 		return nil, err
 	}
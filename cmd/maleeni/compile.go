@@ -6,60 +6,142 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/nihei9/maleeni/compiler"
 	"github.com/nihei9/maleeni/spec"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 var compileFlags = struct {
-	debug  *bool
-	compLv *int
-	output *string
+	debug                *bool
+	compLv               *int
+	output               *string
+	format               *string
+	cache                *string
+	maxFragmentExpansion *int
+	keepUncompressed     *bool
+	dupPatternsAsErrors  *bool
+	foldContBytes        *bool
+	minimize             *bool
+	spelling             *string
+	stats                *bool
+	checkOnly            *bool
 }{}
 
 func init() {
 	cmd := &cobra.Command{
 		Use:   "compile",
 		Short: "Compile a lexical specification into a DFA",
-		Long:  `compile takes a lexical specification and generates a DFA accepting the tokens described in the specification.`,
+		Long: `compile takes a lexical specification and generates a DFA accepting the tokens described in the specification.
+The specification is written in JSON or YAML. When the file extension is .yaml or .yml, compile reads the file as YAML.
+When the file extension is .jsonc, compile reads the file as JSON with // and /* */ comments.
+Otherwise, it reads the file as JSON.
+When you pass multiple files, compile merges their entries and macros into one specification named after the first file.`,
 		Example: `  Read from/Write to the specified file:
     maleeni compile lexspec.json -o clexspec.json
+  Read a YAML file:
+    maleeni compile lexspec.yaml -o clexspec.json
+  Merge multiple files into one compiled specification:
+    maleeni compile keywords.json literals.json -o clexspec.json
   Read from stdin and write to stdout:
-    cat lexspec.json | maleeni compile`,
-		Args: cobra.MaximumNArgs(1),
+    cat lexspec.json | maleeni compile
+  Write in gob format:
+    maleeni compile lexspec.json -o clexspec.gob --format gob
+  Check that a specification compiles without writing the result:
+    maleeni compile lexspec.json --check-only
+  Rebuild only the modes that changed since the last compilation:
+    maleeni compile lexspec.json -o clexspec.json --cache clexspec.json`,
 		RunE: runCompile,
 	}
 	compileFlags.compLv = cmd.Flags().Int("compression-level", compiler.CompressionLevelMax, "compression level")
 	compileFlags.output = cmd.Flags().StringP("output", "o", "", "output file path (default stdout)")
+	compileFlags.format = cmd.Flags().StringP("format", "f", "json", "output format: json or gob")
+	compileFlags.cache = cmd.Flags().String("cache", "", "compiled lexical specification whose unchanged modes are reused")
+	compileFlags.maxFragmentExpansion = cmd.Flags().Int("max-fragment-expansion", compiler.DefaultMaxFragmentExpansion, "maximum number of nodes a pattern can consist of after expanding fragments (0 means no limit)")
+	compileFlags.keepUncompressed = cmd.Flags().Bool("keep-uncompressed", false, "keep the uncompressed transition table along with the compressed one")
+	compileFlags.foldContBytes = cmd.Flags().Bool("fold-continuation-bytes", false, "share trailing UTF-8 continuation bytes among code point ranges to reduce states")
+	compileFlags.minimize = cmd.Flags().Bool("minimize", false, "merge equivalent states of the DFAs to make the transition tables smaller")
+	compileFlags.dupPatternsAsErrors = cmd.Flags().Bool("error-on-duplicate-patterns", false, "report entries having the same pattern as another entry in the same mode as errors instead of warnings")
+	compileFlags.spelling = cmd.Flags().String("spelling-inconsistencies", "error", "how to report kind names or mode names spelled the same in UpperCamelCase: error, warning, or ignore")
+	compileFlags.stats = cmd.Flags().Bool("stats", false, "print the sizes of the DFA of each mode and the elapsed time to stderr")
+	compileFlags.checkOnly = cmd.Flags().Bool("check-only", false, "compile the specification but write nothing (cannot be used with --output)")
 	rootCmd.AddCommand(cmd)
 }
 
 func runCompile(cmd *cobra.Command, args []string) (retErr error) {
-	var path string
-	if len(args) > 0 {
-		path = args[0]
+	if *compileFlags.format != "json" && *compileFlags.format != "gob" {
+		return fmt.Errorf("invalid output format: %v (json or gob is available)", *compileFlags.format)
 	}
-	lspec, err := readLexSpec(path)
+	if *compileFlags.checkOnly && *compileFlags.output != "" {
+		return fmt.Errorf("--check-only and --output cannot be used together")
+	}
+
+	lspec, err := readLexSpecs(args)
 	if err != nil {
 		return fmt.Errorf("Cannot read a lexical specification: %w", err)
 	}
 
-	clspec, err, cerrs := compiler.Compile(lspec, compiler.CompressionLevel(*compileFlags.compLv))
+	opts := []compiler.CompilerOption{
+		compiler.CompressionLevel(*compileFlags.compLv),
+		compiler.MaxFragmentExpansion(*compileFlags.maxFragmentExpansion),
+	}
+	if *compileFlags.keepUncompressed {
+		opts = append(opts, compiler.KeepUncompressedTransition())
+	}
+	if *compileFlags.foldContBytes {
+		opts = append(opts, compiler.FoldContinuationBytes())
+	}
+	if *compileFlags.minimize {
+		opts = append(opts, compiler.Minimize())
+	}
+	if *compileFlags.dupPatternsAsErrors {
+		opts = append(opts, compiler.DuplicatePatternsAsErrors())
+	}
+	spellingSeverity, err := parseSeverity(*compileFlags.spelling)
 	if err != nil {
-		if len(cerrs) > 0 {
+		return err
+	}
+	opts = append(opts, compiler.SpellingInconsistencies(spellingSeverity))
+	if *compileFlags.cache != "" {
+		// The compiler ignores a cache in another format version, so the command doesn't validate the version.
+		cache, err := decodeCompiledLexSpec(*compileFlags.cache)
+		if err != nil {
+			return fmt.Errorf("Cannot read a cache: %w", err)
+		}
+		opts = append(opts, compiler.Cache(cache))
+	}
+
+	start := time.Now()
+	r := compiler.CompileWithResult(lspec, opts...)
+	elapsed := time.Since(start)
+	for _, w := range r.Warnings {
+		writeCompileWarning(os.Stderr, w)
+	}
+	if r.Err != nil {
+		if len(r.Errors) > 0 {
 			var b strings.Builder
-			writeCompileError(&b, cerrs[0])
-			for _, cerr := range cerrs[1:] {
+			writeCompileError(&b, r.Errors[0])
+			for _, cerr := range r.Errors[1:] {
 				fmt.Fprintf(&b, "\n")
 				writeCompileError(&b, cerr)
 			}
 			return fmt.Errorf(b.String())
 		}
-		return err
+		return r.Err
 	}
-	err = writeCompiledLexSpec(clspec, *compileFlags.output)
+	if *compileFlags.stats {
+		writeCompileStats(os.Stderr, r.Spec, r.RemovedStates, elapsed)
+	}
+	if *compileFlags.checkOnly {
+		return nil
+	}
+	err = writeCompiledLexSpec(r.Spec, *compileFlags.output, *compileFlags.format)
 	if err != nil {
 		return fmt.Errorf("Cannot write a compiled lexical specification: %w", err)
 	}
@@ -71,13 +153,249 @@ func writeCompileError(w io.Writer, cerr *compiler.CompileError) {
 	if cerr.Fragment {
 		fmt.Fprintf(w, "fragment ")
 	}
-	fmt.Fprintf(w, "%v: %v", cerr.Kind, cerr.Cause)
+	fmt.Fprintf(w, "%v: ", cerr.Kind)
+	if cerr.Col > 0 {
+		fmt.Fprintf(w, "column %v: ", cerr.Col)
+	}
+	fmt.Fprintf(w, "%v", cerr.Cause)
 	if cerr.Detail != "" {
 		fmt.Fprintf(w, ": %v", cerr.Detail)
 	}
 }
 
+// parseSeverity converts a value of a command-line option into a severity of the compiler.
+func parseSeverity(s string) (compiler.Severity, error) {
+	switch s {
+	case "error":
+		return compiler.SeverityError, nil
+	case "warning":
+		return compiler.SeverityWarning, nil
+	case "ignore":
+		return compiler.SeverityIgnore, nil
+	}
+	return 0, fmt.Errorf("invalid severity: %v (error, warning, or ignore is available)", s)
+}
+
+func writeCompileWarning(w io.Writer, cwarn *compiler.CompileWarning) {
+	fmt.Fprintf(w, "warning: ")
+	if cwarn.Fragment {
+		fmt.Fprintf(w, "fragment ")
+	}
+	if cwarn.Kind != "" {
+		fmt.Fprintf(w, "%v: ", cwarn.Kind)
+	}
+	fmt.Fprintf(w, "%v", cwarn.Cause)
+	if cwarn.Detail != "" {
+		fmt.Fprintf(w, ": %v", cwarn.Detail)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// writeCompileStats prints the number of kinds and states and the size of the transition table of each mode. The sizes
+// are in bytes. The uncompressed size is the size of the table before the compression. When modes share transition
+// rows, the compressed size of each mode counts only its row numbers, and the shared table is printed separately.
+// When removedStates isn't nil, the stats also contain the number of states the minimization removed from each mode.
+func writeCompileStats(w io.Writer, clspec *spec.CompiledLexSpec, removedStates []int, elapsed time.Duration) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "mode\tkinds\tstates\tuncompressed size\tcompressed size")
+	if removedStates != nil {
+		fmt.Fprintf(tw, "\tremoved states")
+	}
+	fmt.Fprintf(tw, "\n")
+	totalUncomp := 0
+	totalComp := 0
+	totalRemoved := 0
+	for id, modeSpec := range clspec.Specs {
+		if id == spec.LexModeIDNil.Int() {
+			continue
+		}
+		uncomp := modeSpec.DFA.RowCount * modeSpec.DFA.ColCount * (strconv.IntSize / 8)
+		comp := modeSpec.TransitionByteSize()
+		totalUncomp += uncomp
+		totalComp += comp
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v", clspec.ModeNames[id], len(modeSpec.KindNames)-1, modeSpec.StateCount(), uncomp, comp)
+		if removedStates != nil {
+			totalRemoved += removedStates[id]
+			fmt.Fprintf(tw, "\t%v", removedStates[id])
+		}
+		fmt.Fprintf(tw, "\n")
+	}
+	if clspec.SharedTransition != nil {
+		comp := clspec.SharedTransition.ByteSize()
+		totalComp += comp
+		fmt.Fprintf(tw, "(shared rows)\t\t\t\t%v\n", comp)
+	}
+	fmt.Fprintf(tw, "(total)\t%v\t\t%v\t%v", len(clspec.KindNames)-1, totalUncomp, totalComp)
+	if removedStates != nil {
+		fmt.Fprintf(tw, "\t%v", totalRemoved)
+	}
+	fmt.Fprintf(tw, "\n")
+	tw.Flush()
+	fmt.Fprintf(w, "compression level: %v\n", clspec.CompressionLevel)
+	fmt.Fprintf(w, "elapsed time: %v\n", elapsed)
+}
+
+// readLexSpec reads a lexical specification from a file or stdin when `path` is empty. The entries and macros of
+// the files that the specification includes are merged into it.
 func readLexSpec(path string) (*spec.LexSpec, error) {
+	if path == "" {
+		return readLexSpecs(nil)
+	}
+	return readLexSpecs([]string{path})
+}
+
+// readLexSpecs reads lexical specifications from files or stdin when `paths` is empty and merges them into the first
+// one in the same way as includes. Hence, the entries of a file have higher priorities than the ones of the following
+// files, and the fields other than the entries and macros, such as the name, come from the first file. A file that
+// another file has already included or that appears twice is merged only once.
+func readLexSpecs(paths []string) (*spec.LexSpec, error) {
+	r := &includeResolver{
+		visiting: map[string]struct{}{},
+		included: map[string]struct{}{},
+	}
+	if len(paths) == 0 {
+		lspec, err := decodeLexSpec("")
+		if err != nil {
+			return nil, err
+		}
+		err = r.resolve(lspec, "")
+		if err != nil {
+			return nil, err
+		}
+		return lspec, nil
+	}
+
+	var merged *spec.LexSpec
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := r.included[abs]; ok {
+			continue
+		}
+		r.included[abs] = struct{}{}
+
+		lspec, err := decodeLexSpec(path)
+		if err != nil {
+			return nil, err
+		}
+		r.visiting[abs] = struct{}{}
+		err = r.resolve(lspec, path)
+		delete(r.visiting, abs)
+		if err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			merged = lspec
+			continue
+		}
+		err = mergeLexSpec(merged, lspec)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot merge %v: %w", path, err)
+		}
+	}
+	return merged, nil
+}
+
+// includeResolver merges the specifications that a specification includes into it. Each file is merged only once,
+// even when several files include it.
+type includeResolver struct {
+	// visiting holds the absolute paths of the files being resolved to detect include cycles.
+	visiting map[string]struct{}
+	included map[string]struct{}
+}
+
+func (r *includeResolver) resolve(lspec *spec.LexSpec, path string) error {
+	dir := "."
+	if path != "" {
+		dir = filepath.Dir(path)
+	}
+	includes := lspec.Include
+	lspec.Include = nil
+	for _, inc := range includes {
+		incPath := inc
+		if !filepath.IsAbs(incPath) {
+			incPath = filepath.Join(dir, incPath)
+		}
+		abs, err := filepath.Abs(incPath)
+		if err != nil {
+			return err
+		}
+		if _, ok := r.visiting[abs]; ok {
+			return fmt.Errorf("include cycle detected: %v includes %v", displayPath(path), incPath)
+		}
+		if _, ok := r.included[abs]; ok {
+			continue
+		}
+		r.included[abs] = struct{}{}
+
+		ispec, err := decodeLexSpec(incPath)
+		if err != nil {
+			return fmt.Errorf("Cannot read an included file %v: %w", incPath, err)
+		}
+		r.visiting[abs] = struct{}{}
+		err = r.resolve(ispec, incPath)
+		delete(r.visiting, abs)
+		if err != nil {
+			return err
+		}
+		err = mergeLexSpec(lspec, ispec)
+		if err != nil {
+			return fmt.Errorf("Cannot include %v: %w", incPath, err)
+		}
+	}
+	return nil
+}
+
+// mergeLexSpec appends the entries of `src` to `dst` and adds the macros of `src` to `dst`. Because the entries of
+// the included file follow the ones of the including file, they have lower priorities by default. The other fields
+// of `src`, such as the name, are ignored.
+func mergeLexSpec(dst, src *spec.LexSpec) error {
+	for name, pattern := range src.Macros {
+		if p, ok := dst.Macros[name]; ok {
+			if p != pattern {
+				return fmt.Errorf("macro `%v` is defined differently", name)
+			}
+			continue
+		}
+		if dst.Macros == nil {
+			dst.Macros = map[spec.LexMacroName]spec.LexPattern{}
+		}
+		dst.Macros[name] = pattern
+	}
+
+	// Fragments and the other kinds have separate namespaces, and an emitted fragment belongs to both. See also
+	// LexSpec.Validate.
+	kinds := map[spec.LexKindName]struct{}{}
+	frags := map[spec.LexKindName]struct{}{}
+	for _, e := range dst.Entries {
+		if e.Fragment {
+			frags[e.Kind] = struct{}{}
+		}
+		if !e.Fragment || e.Emit {
+			kinds[e.Kind] = struct{}{}
+		}
+	}
+	for _, e := range src.Entries {
+		_, dupFrag := frags[e.Kind]
+		_, dupKind := kinds[e.Kind]
+		if (e.Fragment && dupFrag) || ((!e.Fragment || e.Emit) && dupKind) {
+			return fmt.Errorf("kind `%v` is already defined", e.Kind)
+		}
+	}
+	dst.Entries = append(dst.Entries, src.Entries...)
+	return nil
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "stdin"
+	}
+	return path
+}
+
+func decodeLexSpec(path string) (*spec.LexSpec, error) {
 	r := os.Stdin
 	if path != "" {
 		f, err := os.Open(path)
@@ -92,18 +410,81 @@ func readLexSpec(path string) (*spec.LexSpec, error) {
 		return nil, err
 	}
 	lspec := &spec.LexSpec{}
-	err = json.Unmarshal(data, lspec)
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, lspec)
+	case ".jsonc":
+		data, err = stripJSONComments(data)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(data, lspec)
+	default:
+		err = json.Unmarshal(data, lspec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Each file enables start conditions for its own entries, so extract them before merging the file into another.
+	err = lspec.ExtractStartConditions()
 	if err != nil {
 		return nil, err
 	}
 	return lspec, nil
 }
 
-func writeCompiledLexSpec(clspec *spec.CompiledLexSpec, path string) error {
-	out, err := json.Marshal(clspec)
-	if err != nil {
-		return err
+// stripJSONComments replaces `//` and `/* */` comments in JSON with spaces. It keeps line breaks in comments so that
+// the positions in error messages of the JSON decoder still point to the original lines.
+func stripJSONComments(data []byte) ([]byte, error) {
+	b := make([]byte, len(data))
+	copy(b, data)
+	inStr := false
+	for i := 0; i < len(b); i++ {
+		if inStr {
+			switch b[i] {
+			case '\\':
+				i++
+			case '"':
+				inStr = false
+			}
+			continue
+		}
+		if b[i] == '"' {
+			inStr = true
+			continue
+		}
+		if b[i] != '/' || i+1 >= len(b) {
+			continue
+		}
+		switch b[i+1] {
+		case '/':
+			for ; i < len(b) && b[i] != '\n'; i++ {
+				b[i] = ' '
+			}
+		case '*':
+			start := i
+			b[i] = ' '
+			b[i+1] = ' '
+			for i += 2; ; i++ {
+				if i+1 >= len(b) {
+					return nil, fmt.Errorf("unclosed comment at offset %v", start)
+				}
+				if b[i] == '*' && b[i+1] == '/' {
+					b[i] = ' '
+					b[i+1] = ' '
+					i++
+					break
+				}
+				if b[i] != '\n' && b[i] != '\r' {
+					b[i] = ' '
+				}
+			}
+		}
 	}
+	return b, nil
+}
+
+func writeCompiledLexSpec(clspec *spec.CompiledLexSpec, path string, format string) error {
 	w := os.Stdout
 	if path != "" {
 		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
@@ -113,6 +494,13 @@ func writeCompiledLexSpec(clspec *spec.CompiledLexSpec, path string) error {
 		defer f.Close()
 		w = f
 	}
+	if format == "gob" {
+		return spec.EncodeCompiledLexSpecGob(w, clspec)
+	}
+	out, err := json.Marshal(clspec)
+	if err != nil {
+		return err
+	}
 	fmt.Fprintf(w, "%v\n", string(out))
 	return nil
 }
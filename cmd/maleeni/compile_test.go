@@ -0,0 +1,671 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nihei9/maleeni/compiler"
+	"github.com/nihei9/maleeni/spec"
+)
+
+func TestReadLexSpec_JSONC(t *testing.T) {
+	src := `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "word",
+            "pattern": "[a-z]+"
+        },
+        {
+            "kind": "comment",
+            "pattern": "//[^\\u{000A}]*"
+        },
+        {
+            "kind": "slash_star",
+            "pattern": "/\\*"
+        }
+    ]
+}
+`
+	srcWithComments := `
+// A specification for testing.
+{
+    "name": "test", // The name is used as the package name.
+    "entries": [
+        /*
+         * Words consist of lower-case letters.
+         */
+        {
+            "kind": "word",
+            "pattern": "[a-z]+"
+        },
+        {
+            "kind": "comment",
+            "pattern": "//[^\\u{000A}]*" // The pattern contains "//".
+        },
+        {
+            "kind": /* kind */ "slash_star",
+            "pattern": "/\\*"
+        }
+    ]
+}
+`
+	dir := t.TempDir()
+	compile := func(name, src string) []byte {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lspec, err := readLexSpec(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clspec, err, _ := compiler.Compile(lspec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(clspec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	expected := compile("lexspec.json", src)
+	actual := compile("lexspec.jsonc", srcWithComments)
+	if string(actual) != string(expected) {
+		t.Fatalf("unexpected output:\nwant: %v\ngot: %v", string(expected), string(actual))
+	}
+
+	// A .json file must not contain comments.
+	path := filepath.Join(dir, "commented.json")
+	err := os.WriteFile(path, []byte(srcWithComments), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readLexSpec(path)
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
+
+func TestReadLexSpec_Include(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles := func(t *testing.T, files map[string]string) {
+		t.Helper()
+		for name, src := range files {
+			path := filepath.Join(dir, name)
+			err := os.MkdirAll(filepath.Dir(path), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(path, []byte(src), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// A shared fragment library is included by two specifications, which are included by the main one.
+	writeFiles(t, map[string]string{
+		"lib/common.json": `{
+    "name": "common",
+    "macros": {
+        "digit": "[0-9]"
+    },
+    "entries": [
+        {"kind": "letter", "pattern": "[A-Za-z_]", "fragment": true},
+        {"kind": "white_space", "pattern": "[\\u{0009}\\u{0020}]+"}
+    ]
+}`,
+		"lib/ident.yaml": `name: ident
+include:
+  - common.json
+entries:
+  - kind: identifier
+    pattern: \f{letter}(\f{letter}|${digit})*
+`,
+		"lib/number.json": `{
+    "name": "number",
+    "include": ["common.json"],
+    "entries": [
+        {"kind": "integer", "pattern": "${digit}+"}
+    ]
+}`,
+		"main.json": `{
+    "name": "test",
+    "include": ["lib/ident.yaml", "lib/number.json"],
+    "entries": [
+        {"kind": "kw_if", "pattern": "if"}
+    ]
+}`,
+	})
+	lspec, err := readLexSpec(filepath.Join(dir, "main.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lspec.Name != "test" || lspec.Include != nil {
+		t.Fatalf("unexpected specification: %+v", lspec)
+	}
+	var kinds []spec.LexKindName
+	for _, e := range lspec.Entries {
+		kinds = append(kinds, e.Kind)
+	}
+	// The entries of the including file come first so that they have higher priorities.
+	expectedKinds := []spec.LexKindName{"kw_if", "identifier", "letter", "white_space", "integer"}
+	if fmt.Sprint(kinds) != fmt.Sprint(expectedKinds) {
+		t.Fatalf("unexpected entries; want: %v, got: %v", expectedKinds, kinds)
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clspec.KindNames) != 5 {
+		t.Fatalf("unexpected kinds: %v", clspec.KindNames)
+	}
+
+	tests := []struct {
+		caption string
+		files   map[string]string
+		err     string
+	}{
+		{
+			caption: "a file cannot include itself",
+			files: map[string]string{
+				"self.json": `{"name": "test", "include": ["self.json"], "entries": [{"kind": "a", "pattern": "a"}]}`,
+			},
+			err: "include cycle",
+		},
+		{
+			caption: "files cannot include each other",
+			files: map[string]string{
+				"cycle_a.json": `{"name": "test", "include": ["cycle_b.json"], "entries": [{"kind": "a", "pattern": "a"}]}`,
+				"cycle_b.json": `{"name": "test", "include": ["cycle_a.json"], "entries": [{"kind": "b", "pattern": "b"}]}`,
+			},
+			err: "include cycle",
+		},
+		{
+			caption: "an included kind cannot duplicate a kind of the including file",
+			files: map[string]string{
+				"dup_a.json": `{"name": "test", "include": ["dup_b.json"], "entries": [{"kind": "a", "pattern": "a"}]}`,
+				"dup_b.json": `{"name": "test", "entries": [{"kind": "a", "pattern": "b"}]}`,
+			},
+			err: "kind `a` is already defined",
+		},
+		{
+			caption: "an included macro cannot redefine a macro of the including file",
+			files: map[string]string{
+				"macro_a.json": `{"name": "test", "include": ["macro_b.json"], "macros": {"m": "a"}, "entries": [{"kind": "a", "pattern": "${m}"}]}`,
+				"macro_b.json": `{"name": "test", "macros": {"m": "b"}, "entries": [{"kind": "b", "pattern": "b"}]}`,
+			},
+			err: "macro `m` is defined differently",
+		},
+		{
+			caption: "an included file must exist",
+			files: map[string]string{
+				"missing.json": `{"name": "test", "include": ["nothing.json"], "entries": [{"kind": "a", "pattern": "a"}]}`,
+			},
+			err: "nothing.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			writeFiles(t, tt.files)
+			var root string
+			for name := range tt.files {
+				if root == "" || name < root {
+					root = name
+				}
+			}
+			_, err := readLexSpec(filepath.Join(dir, root))
+			if err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestReadLexSpecs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles := func(t *testing.T, files map[string]string) {
+		t.Helper()
+		for name, src := range files {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Both files include the same fragment library, which is merged only once.
+	writeFiles(t, map[string]string{
+		"common.json": `{
+    "name": "common",
+    "entries": [
+        {"kind": "letter", "pattern": "[a-z]", "fragment": true},
+        {"kind": "white_space", "pattern": "[\\u{0009}\\u{0020}]+"}
+    ]
+}`,
+		"keywords.json": `{
+    "name": "test",
+    "include": ["common.json"],
+    "macros": {
+        "digit": "[0-9]"
+    },
+    "entries": [
+        {"kind": "kw_if", "pattern": "if"},
+        {"kind": "integer", "pattern": "${digit}+"}
+    ]
+}`,
+		"identifiers.yaml": `name: identifiers
+include:
+  - common.json
+entries:
+  - kind: identifier
+    pattern: \f{letter}+
+`,
+	})
+	lspec, err := readLexSpecs([]string{
+		filepath.Join(dir, "keywords.json"),
+		filepath.Join(dir, "identifiers.yaml"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lspec.Name != "test" {
+		t.Fatalf("unexpected name: %v", lspec.Name)
+	}
+	var kinds []spec.LexKindName
+	for _, e := range lspec.Entries {
+		kinds = append(kinds, e.Kind)
+	}
+	// The entries of the first file come first so that they have higher priorities.
+	expectedKinds := []spec.LexKindName{"kw_if", "integer", "letter", "white_space", "identifier"}
+	if fmt.Sprint(kinds) != fmt.Sprint(expectedKinds) {
+		t.Fatalf("unexpected entries; want: %v, got: %v", expectedKinds, kinds)
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clspec.KindNames) != 5 {
+		t.Fatalf("unexpected kinds: %v", clspec.KindNames)
+	}
+
+	tests := []struct {
+		caption string
+		files   map[string]string
+		err     string
+	}{
+		{
+			caption: "files cannot define the same kind",
+			files: map[string]string{
+				"dup_a.json": `{"name": "test", "entries": [{"kind": "a", "pattern": "a"}]}`,
+				"dup_b.json": `{"name": "test", "entries": [{"kind": "a", "pattern": "b"}]}`,
+			},
+			err: "kind `a` is already defined",
+		},
+		{
+			caption: "files cannot define the same macro differently",
+			files: map[string]string{
+				"macro_a.json": `{"name": "test", "macros": {"m": "a"}, "entries": [{"kind": "a", "pattern": "${m}"}]}`,
+				"macro_b.json": `{"name": "test", "macros": {"m": "b"}, "entries": [{"kind": "b", "pattern": "${m}"}]}`,
+			},
+			err: "macro `m` is defined differently",
+		},
+		{
+			caption: "kind names of different files must be spelled consistently",
+			files: map[string]string{
+				"spell_a.json": `{"name": "test", "entries": [{"kind": "utf_8", "pattern": "a"}]}`,
+				"spell_b.json": `{"name": "test", "entries": [{"kind": "utf8", "pattern": "b"}]}`,
+			},
+			err: "utf8, utf_8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			writeFiles(t, tt.files)
+			var paths []string
+			for name := range tt.files {
+				paths = append(paths, filepath.Join(dir, name))
+			}
+			sort.Strings(paths)
+			lspec, err := readLexSpecs(paths)
+			if err == nil {
+				_, err, _ = compiler.Compile(lspec)
+			}
+			if err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestReadLexSpec_StartConditions(t *testing.T) {
+	explicit := `{
+    "name": "test",
+    "entries": [
+        {"kind": "white_space", "pattern": "[\\u{0009}\\u{0020}]+", "modes": ["default", "string"]},
+        {"kind": "quote_open", "pattern": "\"", "push": "string"},
+        {"kind": "char_seq", "pattern": "[^\"]+", "modes": ["string"]},
+        {"kind": "quote_close", "pattern": "\"", "modes": ["string"], "pop": true},
+        {"kind": "le", "pattern": "<="}
+    ]
+}`
+	sugared := `name: test
+start_conditions: true
+entries:
+  - kind: white_space
+    pattern: <default,string>[\u{0009}\u{0020}]+
+  - kind: quote_open
+    pattern: '"'
+    push: string
+  - kind: char_seq
+    pattern: <string>[^"]+
+  - kind: quote_close
+    pattern: <string>"
+    pop: true
+  - kind: le
+    pattern: <=
+`
+	dir := t.TempDir()
+	compile := func(name, src string) []byte {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lspec, err := readLexSpec(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clspec, err, _ := compiler.Compile(lspec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(clspec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	expected := compile("explicit.json", explicit)
+	actual := compile("sugared.yaml", sugared)
+	if string(actual) != string(expected) {
+		t.Fatalf("unexpected output:\nwant: %v\ngot: %v", string(expected), string(actual))
+	}
+}
+
+func TestRunCompile_CheckOnly(t *testing.T) {
+	checkOnly := *compileFlags.checkOnly
+	output := *compileFlags.output
+	defer func() {
+		*compileFlags.checkOnly = checkOnly
+		*compileFlags.output = output
+	}()
+	*compileFlags.checkOnly = true
+	*compileFlags.output = ""
+
+	tests := []struct {
+		caption string
+		src     string
+		err     bool
+	}{
+		{
+			caption: "a valid specification",
+			src:     `{"name": "test", "entries": [{"kind": "word", "pattern": "[a-z]+"}]}`,
+		},
+		{
+			caption: "a pattern containing a syntax error",
+			src:     `{"name": "test", "entries": [{"kind": "word", "pattern": "[a-"}]}`,
+			err:     true,
+		},
+		{
+			caption: "fragments referring to each other",
+			src: `{"name": "test", "entries": [
+    {"kind": "a", "pattern": "a\\f{b}", "fragment": true},
+    {"kind": "b", "pattern": "b\\f{a}", "fragment": true},
+    {"kind": "word", "pattern": "\\f{a}"}
+]}`,
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "lexspec.json")
+			err := os.WriteFile(path, []byte(tt.src), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// `maleeni validate` doesn't parse patterns, so it accepts all the specifications.
+			err = runValidate(nil, []string{path})
+			if err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+
+			stdout, err := os.Create(filepath.Join(dir, "stdout"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdout.Close()
+			orig := os.Stdout
+			os.Stdout = stdout
+			err = runCompile(nil, []string{path})
+			os.Stdout = orig
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error didn't occur")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error occurred: %v", err)
+				}
+			}
+			info, err := stdout.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != 0 {
+				t.Fatalf("compile must write nothing")
+			}
+		})
+	}
+
+	*compileFlags.output = filepath.Join(t.TempDir(), "clexspec.json")
+	err := runCompile(nil, nil)
+	if err == nil {
+		t.Fatal("--check-only and --output must not be used together")
+	}
+}
+
+func TestRunCompile_CacheFormatVersion(t *testing.T) {
+	cache := *compileFlags.cache
+	output := *compileFlags.output
+	defer func() {
+		*compileFlags.cache = cache
+		*compileFlags.output = output
+	}()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lexspec.json")
+	err := os.WriteFile(path, []byte(`{"name": "test", "entries": [{"kind": "word", "pattern": "[a-z]+"}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lspec, err := readLexSpecs([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cache is only an optimization, so a cache in an old format version doesn't make the compilation fail.
+	clspec.FormatVersion = spec.CompiledLexSpecFormatVersion - 1
+	*compileFlags.cache = filepath.Join(dir, "cache.json")
+	err = writeCompiledLexSpec(clspec, *compileFlags.cache, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	*compileFlags.output = filepath.Join(dir, "clexspec.json")
+	err = runCompile(nil, []string{path})
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+	compiled, err := readCompiledLexSpec(*compileFlags.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compiled.FormatVersion != spec.CompiledLexSpecFormatVersion {
+		t.Fatalf("unexpected format version; want: %v, got: %v", spec.CompiledLexSpecFormatVersion, compiled.FormatVersion)
+	}
+}
+
+func TestStripJSONComments(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+		err      bool
+	}{
+		{
+			src:      `{"a": "b"} // c`,
+			expected: `{"a": "b"}     `,
+		},
+		{
+			src:      "{/* a\nb */\"c\": 1}",
+			expected: "{    \n    \"c\": 1}",
+		},
+		{
+			src:      `{"a": "/* \" // */"}`,
+			expected: `{"a": "/* \" // */"}`,
+		},
+		{
+			src: `{"a": 1} /* b`,
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			actual, err := stripJSONComments([]byte(tt.src))
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error didn't occur")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+			if string(actual) != tt.expected {
+				t.Fatalf("unexpected output; want: %q, got: %q", tt.expected, string(actual))
+			}
+		})
+	}
+}
+
+func TestWriteCompileStats(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: "[a-z]+",
+			},
+			{
+				Kind:    "quote_open",
+				Pattern: `"`,
+				Push:    "string",
+			},
+			{
+				Modes:   []spec.LexModeName{"string"},
+				Kind:    "char_seq",
+				Pattern: `[^"]+`,
+			},
+			{
+				Modes:   []spec.LexModeName{"string"},
+				Kind:    "quote_close",
+				Pattern: `"`,
+				Pop:     true,
+			},
+		},
+	}
+	for _, compLv := range []int{0, 1, 2} {
+		clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compLv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		writeCompileStats(&b, clspec, nil, 123*time.Millisecond)
+		out := b.String()
+		lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		if len(lines) < 5 {
+			t.Fatalf("unexpected output:\n%v", out)
+		}
+		for _, field := range []string{"mode", "kinds", "states", "uncompressed size", "compressed size"} {
+			if !strings.Contains(lines[0], field) {
+				t.Fatalf("the header lacks %v:\n%v", field, out)
+			}
+		}
+		// Each mode has 2 kinds.
+		if fs := strings.Fields(lines[1]); fs[0] != "default" || fs[1] != "2" {
+			t.Fatalf("unexpected stats of the default mode:\n%v", out)
+		}
+		if fs := strings.Fields(lines[2]); fs[0] != "string" || fs[1] != "2" {
+			t.Fatalf("unexpected stats of the string mode:\n%v", out)
+		}
+		if !strings.Contains(out, fmt.Sprintf("compression level: %v\n", compLv)) {
+			t.Fatalf("the output lacks the compression level:\n%v", out)
+		}
+		if !strings.HasSuffix(out, "elapsed time: 123ms\n") {
+			t.Fatalf("the output lacks the elapsed time:\n%v", out)
+		}
+	}
+}
+
+func TestWriteCompileStats_Minimize(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: "ab|cb",
+			},
+		},
+	}
+	r := compiler.CompileWithResult(lspec, compiler.Minimize())
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	var b strings.Builder
+	writeCompileStats(&b, r.Spec, r.RemovedStates, 123*time.Millisecond)
+	out := b.String()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if !strings.HasSuffix(lines[0], "removed states") {
+		t.Fatalf("the header lacks the removed states:\n%v", out)
+	}
+	// The states after reading `a` and `c` are merged.
+	if fs := strings.Fields(lines[1]); fs[0] != "default" || fs[len(fs)-1] != "1" {
+		t.Fatalf("unexpected stats of the default mode:\n%v", out)
+	}
+	if fs := strings.Fields(lines[2]); fs[0] != "(total)" || fs[len(fs)-1] != "1" {
+		t.Fatalf("unexpected total:\n%v", out)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nihei9/maleeni/driver"
+	"github.com/nihei9/maleeni/spec"
+	"github.com/spf13/cobra"
+)
+
+var dotFlags = struct {
+	mode   *string
+	output *string
+}{}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "dot clexspec",
+		Short: "Print a DFA in the DOT language",
+		Long: `dot takes a compiled lexical specification and prints a DFA of a lex mode in the DOT language.
+Accepting states are labeled with kind names. You can render the output using Graphviz.`,
+		Example: `  maleeni dot clexspec.json --mode default | dot -Tsvg -o dfa.svg`,
+		Args:    cobra.ExactArgs(1),
+		RunE:    runDot,
+	}
+	dotFlags.mode = cmd.Flags().StringP("mode", "m", spec.LexModeNameDefault.String(), "mode name")
+	dotFlags.output = cmd.Flags().StringP("output", "o", "", "output file path (default stdout)")
+	rootCmd.AddCommand(cmd)
+}
+
+func runDot(cmd *cobra.Command, args []string) error {
+	clspec, err := readCompiledLexSpec(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot read a compiled lexical specification: %w", err)
+	}
+
+	mode := spec.LexModeIDNil
+	for id, name := range clspec.ModeNames {
+		if id == spec.LexModeIDNil.Int() {
+			continue
+		}
+		if name.String() == *dotFlags.mode {
+			mode = spec.LexModeID(id)
+			break
+		}
+	}
+	if mode.IsNil() {
+		return fmt.Errorf("mode `%v` is undefined", *dotFlags.mode)
+	}
+
+	w := os.Stdout
+	if *dotFlags.output != "" {
+		f, err := os.OpenFile(*dotFlags.output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return fmt.Errorf("Cannot open the output file %s: %w", *dotFlags.output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	writeDFAInDot(w, clspec, mode)
+
+	return nil
+}
+
+// writeDFAInDot writes a DFA of a mode in the DOT language. Edges between the same pair of states are merged into one
+// edge, and its label shows the byte ranges of the transitions, such as `30-39, 61-7A`.
+func writeDFAInDot(w io.Writer, clspec *spec.CompiledLexSpec, mode spec.LexModeID) {
+	lspec := driver.NewLexSpec(clspec)
+	modeSpec := clspec.Specs[mode]
+	m := driver.ModeID(mode.Int())
+
+	fmt.Fprintf(w, "digraph %v {\n", clspec.ModeNames[mode])
+	fmt.Fprintf(w, "    rankdir=LR;\n")
+	fmt.Fprintf(w, "    node [shape=circle];\n")
+	fmt.Fprintf(w, "    start [shape=point];\n")
+	fmt.Fprintf(w, "    start -> %v;\n", lspec.InitialState(m))
+
+	// The row count includes the nil state.
+	for s := spec.StateIDMin.Int(); s < modeSpec.DFA.RowCount; s++ {
+		state := driver.StateID(s)
+		if modeKind, ok := lspec.Accept(m, state); ok {
+			_, kindName := lspec.KindIDAndName(m, modeKind)
+			fmt.Fprintf(w, "    %v [shape=doublecircle, label=\"%v\\n%v\"];\n", state, state, kindName)
+		}
+
+		ranges := map[driver.StateID][]string{}
+		for v := 0; v < 256; {
+			next, ok := lspec.NextState(m, state, v)
+			if !ok {
+				v++
+				continue
+			}
+			from := v
+			for v++; v < 256; v++ {
+				n, ok := lspec.NextState(m, state, v)
+				if !ok || n != next {
+					break
+				}
+			}
+			to := v - 1
+			if from == to {
+				ranges[next] = append(ranges[next], fmt.Sprintf("%02X", from))
+			} else {
+				ranges[next] = append(ranges[next], fmt.Sprintf("%02X-%02X", from, to))
+			}
+		}
+
+		var nexts []driver.StateID
+		for next := range ranges {
+			nexts = append(nexts, next)
+		}
+		sort.Slice(nexts, func(i, j int) bool {
+			return nexts[i] < nexts[j]
+		})
+		for _, next := range nexts {
+			fmt.Fprintf(w, "    %v -> %v [label=\"%v\"];\n", state, next, strings.Join(ranges[next], ", "))
+		}
+	}
+
+	fmt.Fprintf(w, "}\n")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nihei9/maleeni/compiler"
+	"github.com/nihei9/maleeni/spec"
+)
+
+func TestWriteDFAInDot(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "hex",
+				Pattern: `[0-9a-f]+`,
+			},
+			{
+				Kind:    "x",
+				Pattern: `x`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	writeDFAInDot(&b, clspec, clspec.InitialModeID)
+	// The label of an edge merges the byte ranges of transitions to the same state, and the label of an accepting
+	// state shows its kind name.
+	output := `digraph default {
+    rankdir=LR;
+    node [shape=circle];
+    start [shape=point];
+    start -> 1;
+    1 -> 2 [label="30-39, 61-66"];
+    1 -> 3 [label="78"];
+    2 [shape=doublecircle, label="2\nhex"];
+    2 -> 2 [label="30-39, 61-66"];
+    3 [shape=doublecircle, label="3\nx"];
+}
+`
+	if b.String() != output {
+		t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", output, b.String())
+	}
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/nihei9/maleeni/driver"
 	"github.com/nihei9/maleeni/spec"
@@ -13,8 +19,14 @@ import (
 
 var lexFlags = struct {
 	source       *string
+	text         *string
 	output       *string
 	breakOnError *bool
+	format       *string
+	lexemeFormat *string
+	stripBOM     *bool
+	filter       *[]string
+	count        *int
 }{}
 
 func init() {
@@ -26,25 +38,59 @@ As use ` + "`maleeni compile`" + `, you can generate the specification.
 
 Note that passive mode transitions are not performed. Thus, if there is a mode in
 your lexical specification that is set passively, lexemes in that mode will not be recognized.`,
-		Example: `  cat src | maleeni lex clexspec.json`,
-		Args:    cobra.ExactArgs(1),
-		RunE:    runLex,
+		Example: `  cat src | maleeni lex clexspec.json
+  Print tokens in CSV format:
+    cat src | maleeni lex clexspec.json --format csv
+  Print tokens as a JSON array:
+    cat src | maleeni lex clexspec.json --format json-array
+  Print lexemes in hexadecimal:
+    cat src | maleeni lex clexspec.json --lexeme-format hex
+  Tokenize a text passed as an argument:
+    maleeni lex clexspec.json --text 'some input'
+  Print only tokens of specific kinds:
+    cat src | maleeni lex clexspec.json --filter word,number
+  Print the first 10 words:
+    cat src | maleeni lex clexspec.json --filter word --count 10`,
+		Args: cobra.ExactArgs(1),
+		RunE: runLex,
 	}
 	lexFlags.source = cmd.Flags().StringP("source", "s", "", "source file path (default stdin)")
+	lexFlags.text = cmd.Flags().StringP("text", "t", "", "source text (cannot be used with --source)")
 	lexFlags.output = cmd.Flags().StringP("output", "o", "", "output file path (default stdout)")
 	lexFlags.breakOnError = cmd.Flags().BoolP("break-on-error", "b", false, "break lexical analysis with exit status 1 immediately when an error token appears.")
+	lexFlags.format = cmd.Flags().StringP("format", "f", "ndjson", "output format: ndjson, json-array, csv, or tsv")
+	lexFlags.lexemeFormat = cmd.Flags().String("lexeme-format", "text", "how to print lexemes: text, quoted (Go string literals), or hex")
+	lexFlags.stripBOM = cmd.Flags().Bool("strip-bom", false, "skip a UTF-8 byte order mark at the beginning of the source")
+	lexFlags.filter = cmd.Flags().StringSlice("filter", nil, "comma-separated kind names to print (the EOF token is always printed)")
+	lexFlags.count = cmd.Flags().IntP("count", "n", 0, "stop after printing the number of tokens except the EOF token (0 means no limit)")
 	rootCmd.AddCommand(cmd)
 }
 
 func runLex(cmd *cobra.Command, args []string) (retErr error) {
+	if cmd.Flags().Changed("source") && cmd.Flags().Changed("text") {
+		return fmt.Errorf("--source and --text cannot be used together")
+	}
+
 	clspec, err := readCompiledLexSpec(args[0])
 	if err != nil {
 		return fmt.Errorf("Cannot read a compiled lexical specification: %w", err)
 	}
 
+	filter, err := newKindFilter(clspec, *lexFlags.filter)
+	if err != nil {
+		return err
+	}
+	lexFmt, err := parseLexemeFormat(*lexFlags.lexemeFormat)
+	if err != nil {
+		return err
+	}
+	if *lexFlags.count < 0 {
+		return fmt.Errorf("--count must be greater than or equal to 0: %v", *lexFlags.count)
+	}
+
 	var lex *driver.Lexer
 	{
-		src := os.Stdin
+		var src io.Reader = os.Stdin
 		if *lexFlags.source != "" {
 			f, err := os.Open(*lexFlags.source)
 			if err != nil {
@@ -52,8 +98,14 @@ func runLex(cmd *cobra.Command, args []string) (retErr error) {
 			}
 			defer f.Close()
 			src = f
+		} else if cmd.Flags().Changed("text") {
+			src = strings.NewReader(*lexFlags.text)
 		}
-		lex, err = driver.NewLexer(driver.NewLexSpec(clspec), src)
+		var opts []driver.LexerOption
+		if *lexFlags.stripBOM {
+			opts = append(opts, driver.StripBOM())
+		}
+		lex, err = driver.NewLexer(driver.NewLexSpec(clspec), src, opts...)
 		if err != nil {
 			return err
 		}
@@ -68,46 +120,148 @@ func runLex(cmd *cobra.Command, args []string) (retErr error) {
 		w = f
 	}
 
-	tok2JSON := genTokenJSONMarshaler(clspec)
-	for {
+	tw, err := newTokenWriter(w, clspec, *lexFlags.format, lexFmt)
+	if err != nil {
+		return err
+	}
+	var onError func(tok *driver.Token) error
+	if *lexFlags.breakOnError {
+		tok2JSON := genTokenJSONMarshaler(clspec, lexFmt)
+		onError = func(tok *driver.Token) error {
+			data, err := tok2JSON(tok)
+			if err != nil {
+				return fmt.Errorf("failed to marshal a token; token: %v, error: %v\n", tok, err)
+			}
+			return fmt.Errorf("detected an error token: %v", string(data))
+		}
+	}
+	return writeTokens(lex, tw, filter, *lexFlags.count, onError)
+}
+
+// writeTokens writes tokens that `filter` accepts until the EOF token. When `count` is greater than 0, writeTokens stops
+// after writing `count` tokens except the EOF token. When `onError` isn't nil, writeTokens stops at an error token and
+// returns the error that `onError` returns.
+func writeTokens(lex *driver.Lexer, tw tokenWriter, filter func(tok *driver.Token) bool, count int, onError func(tok *driver.Token) error) error {
+	n := 0
+	for count <= 0 || n < count {
 		tok, err := lex.Next()
 		if err != nil {
 			return err
 		}
-		data, err := tok2JSON(tok)
-		if err != nil {
-			return fmt.Errorf("failed to marshal a token; token: %v, error: %v\n", tok, err)
+		if tok.Invalid && onError != nil {
+			// Write the tokens preceding the error token out.
+			err := tw.flush()
+			if err != nil {
+				return err
+			}
+			return onError(tok)
 		}
-		if tok.Invalid && *lexFlags.breakOnError {
-			return fmt.Errorf("detected an error token: %v", string(data))
+		if !filter(tok) {
+			continue
+		}
+		err = tw.write(tok)
+		if err != nil {
+			return err
 		}
-		fmt.Fprintf(w, "%v\n", string(data))
 		if tok.EOF {
 			break
 		}
+		n++
 	}
 
-	return nil
+	return tw.flush()
+}
+
+// newKindFilter returns a function reporting whether `lex` command prints a token. The function accepts only tokens of
+// the specified kinds and the EOF token. When `kindNames` is empty, it accepts all tokens.
+func newKindFilter(clspec *spec.CompiledLexSpec, kindNames []string) (func(tok *driver.Token) bool, error) {
+	if len(kindNames) == 0 {
+		return func(tok *driver.Token) bool {
+			return true
+		}, nil
+	}
+
+	kinds := map[driver.KindID]struct{}{}
+	for _, name := range kindNames {
+		found := false
+		for id, n := range clspec.KindNames {
+			if id == spec.LexKindIDNil.Int() {
+				continue
+			}
+			if n.String() == name {
+				kinds[driver.KindID(id)] = struct{}{}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("kind `%v` is undefined", name)
+		}
+	}
+	return func(tok *driver.Token) bool {
+		if tok.EOF {
+			return true
+		}
+		// An error token has the error kind of its mode in `KindID`, or the nil kind when the mode has no error kind.
+		// Because `kinds` never contains the nil kind, the filter accepts error tokens only of the specified error kinds.
+		_, ok := kinds[tok.KindID]
+		return ok
+	}, nil
 }
 
 func readCompiledLexSpec(path string) (*spec.CompiledLexSpec, error) {
-	f, err := os.Open(path)
+	clspec, err := decodeCompiledLexSpec(path)
 	if err != nil {
 		return nil, err
 	}
-	data, err := ioutil.ReadAll(f)
+	err = clspec.ValidateFormatVersion()
+	if err != nil {
+		return nil, err
+	}
+	return clspec, nil
+}
+
+// decodeCompiledLexSpec reads a compiled lexical specification without validating its format version.
+func decodeCompiledLexSpec(path string) (*spec.CompiledLexSpec, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	clspec := &spec.CompiledLexSpec{}
-	err = json.Unmarshal(data, clspec)
+	data, err := ioutil.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
+	// A compiled lexical specification is written in JSON or gob format. See `maleeni compile --format`.
+	var clspec *spec.CompiledLexSpec
+	if !json.Valid(data) {
+		clspec, err = spec.DecodeCompiledLexSpecGob(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("the file is neither JSON nor gob format: %w", err)
+		}
+	} else {
+		clspec = &spec.CompiledLexSpec{}
+		err = json.Unmarshal(data, clspec)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return clspec, nil
 }
 
-func genTokenJSONMarshaler(clspec *spec.CompiledLexSpec) func(tok *driver.Token) ([]byte, error) {
+// parseLexemeFormat converts a value of --lexeme-format option into a format of the driver.
+func parseLexemeFormat(s string) (driver.LexemeFormat, error) {
+	switch s {
+	case "text":
+		return driver.LexemeFormatText, nil
+	case "quoted":
+		return driver.LexemeFormatQuoted, nil
+	case "hex":
+		return driver.LexemeFormatHex, nil
+	}
+	return 0, fmt.Errorf("invalid lexeme format: %v (text, quoted, or hex is available)", s)
+}
+
+func genTokenJSONMarshaler(clspec *spec.CompiledLexSpec, lexFmt driver.LexemeFormat) func(tok *driver.Token) ([]byte, error) {
 	return func(tok *driver.Token) ([]byte, error) {
 		return json.Marshal(struct {
 			ModeID     int    `json:"mode_id"`
@@ -128,9 +282,183 @@ func genTokenJSONMarshaler(clspec *spec.CompiledLexSpec) func(tok *driver.Token)
 			KindName:   clspec.KindNames[tok.KindID].String(),
 			Row:        tok.Row,
 			Col:        tok.Col,
-			Lexeme:     string(tok.Lexeme),
+			Lexeme:     tok.FormatLexeme(lexFmt),
 			EOF:        tok.EOF,
 			Invalid:    tok.Invalid,
 		})
 	}
 }
+
+type tokenWriter interface {
+	write(tok *driver.Token) error
+	flush() error
+}
+
+func newTokenWriter(w io.Writer, clspec *spec.CompiledLexSpec, format string, lexFmt driver.LexemeFormat) (tokenWriter, error) {
+	switch format {
+	case "ndjson":
+		return &ndjsonTokenWriter{
+			w:        w,
+			tok2JSON: genTokenJSONMarshaler(clspec, lexFmt),
+		}, nil
+	case "json-array":
+		return &jsonArrayTokenWriter{
+			w:        w,
+			tok2JSON: genTokenJSONMarshaler(clspec, lexFmt),
+		}, nil
+	case "csv":
+		return newCSVTokenWriter(w, clspec, lexFmt)
+	case "tsv":
+		return newTSVTokenWriter(w, clspec, lexFmt)
+	}
+	return nil, fmt.Errorf("invalid output format: %v (ndjson, json-array, csv, or tsv is available)", format)
+}
+
+type ndjsonTokenWriter struct {
+	w        io.Writer
+	tok2JSON func(tok *driver.Token) ([]byte, error)
+}
+
+func (tw *ndjsonTokenWriter) write(tok *driver.Token) error {
+	data, err := tw.tok2JSON(tok)
+	if err != nil {
+		return fmt.Errorf("failed to marshal a token; token: %v, error: %v\n", tok, err)
+	}
+	_, err = fmt.Fprintf(tw.w, "%v\n", string(data))
+	return err
+}
+
+func (tw *ndjsonTokenWriter) flush() error {
+	return nil
+}
+
+// jsonArrayTokenWriter writes tokens as elements of a JSON array. It writes each token as soon as it receives one instead
+// of buffering all tokens, and flush closes the array.
+type jsonArrayTokenWriter struct {
+	w        io.Writer
+	tok2JSON func(tok *driver.Token) ([]byte, error)
+	n        int
+}
+
+func (tw *jsonArrayTokenWriter) write(tok *driver.Token) error {
+	data, err := tw.tok2JSON(tok)
+	if err != nil {
+		return fmt.Errorf("failed to marshal a token; token: %v, error: %v\n", tok, err)
+	}
+	sep := ",\n"
+	if tw.n == 0 {
+		sep = "[\n"
+	}
+	tw.n++
+	_, err = fmt.Fprintf(tw.w, "%v%v", sep, string(data))
+	return err
+}
+
+func (tw *jsonArrayTokenWriter) flush() error {
+	if tw.n == 0 {
+		_, err := fmt.Fprintf(tw.w, "[]\n")
+		return err
+	}
+	_, err := fmt.Fprintf(tw.w, "\n]\n")
+	return err
+}
+
+// csvTokenWriter writes tokens in CSV format. The first record is a header, and each following record represents
+// a token. encoding/csv quotes lexemes containing commas, quotes, or line breaks.
+type csvTokenWriter struct {
+	w      *csv.Writer
+	clspec *spec.CompiledLexSpec
+	lexFmt driver.LexemeFormat
+}
+
+func newCSVTokenWriter(w io.Writer, clspec *spec.CompiledLexSpec, lexFmt driver.LexemeFormat) (*csvTokenWriter, error) {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"mode_name", "kind_name", "row", "col", "lexeme", "eof", "invalid"})
+	if err != nil {
+		return nil, err
+	}
+	return &csvTokenWriter{
+		w:      cw,
+		clspec: clspec,
+		lexFmt: lexFmt,
+	}, nil
+}
+
+func (tw *csvTokenWriter) write(tok *driver.Token) error {
+	return tw.w.Write([]string{
+		tw.clspec.ModeNames[tok.ModeID].String(),
+		tw.clspec.KindNames[tok.KindID].String(),
+		strconv.Itoa(tok.Row),
+		strconv.Itoa(tok.Col),
+		tok.FormatLexeme(tw.lexFmt),
+		strconv.FormatBool(tok.EOF),
+		strconv.FormatBool(tok.Invalid),
+	})
+}
+
+func (tw *csvTokenWriter) flush() error {
+	tw.w.Flush()
+	return tw.w.Error()
+}
+
+// tsvEscaper escapes characters that cannot appear in a field of TSV format. Unlike CSV format, TSV format has no
+// quotes, so the other characters, including spaces and quotes, appear as they are.
+var tsvEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\t", `\t`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// tsvTokenWriter writes tokens in TSV format. The first record is a header, and each following record represents
+// a token. It escapes a backslash, a tab, and line breaks in lexemes as `\\`, `\t`, `\n`, and `\r`.
+type tsvTokenWriter struct {
+	w      *bufio.Writer
+	clspec *spec.CompiledLexSpec
+	lexFmt driver.LexemeFormat
+}
+
+func newTSVTokenWriter(w io.Writer, clspec *spec.CompiledLexSpec, lexFmt driver.LexemeFormat) (*tsvTokenWriter, error) {
+	tw := &tsvTokenWriter{
+		w:      bufio.NewWriter(w),
+		clspec: clspec,
+		lexFmt: lexFmt,
+	}
+	err := tw.writeRecord([]string{"mode_name", "kind_name", "row", "col", "lexeme", "eof", "invalid"})
+	if err != nil {
+		return nil, err
+	}
+	return tw, nil
+}
+
+func (tw *tsvTokenWriter) write(tok *driver.Token) error {
+	return tw.writeRecord([]string{
+		tw.clspec.ModeNames[tok.ModeID].String(),
+		tw.clspec.KindNames[tok.KindID].String(),
+		strconv.Itoa(tok.Row),
+		strconv.Itoa(tok.Col),
+		tok.FormatLexeme(tw.lexFmt),
+		strconv.FormatBool(tok.EOF),
+		strconv.FormatBool(tok.Invalid),
+	})
+}
+
+func (tw *tsvTokenWriter) writeRecord(fields []string) error {
+	for i, f := range fields {
+		if i > 0 {
+			err := tw.w.WriteByte('\t')
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tw.w.WriteString(tsvEscaper.Replace(f))
+		if err != nil {
+			return err
+		}
+	}
+	return tw.w.WriteByte('\n')
+}
+
+func (tw *tsvTokenWriter) flush() error {
+	return tw.w.Flush()
+}
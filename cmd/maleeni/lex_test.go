@@ -0,0 +1,759 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nihei9/maleeni/compiler"
+	"github.com/nihei9/maleeni/driver"
+	"github.com/nihei9/maleeni/spec"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestTokenWriter(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[^ ]+`,
+			},
+			{
+				Kind:    "white_space",
+				Pattern: ` +`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		output string
+	}{
+		{
+			format: "csv",
+			output: `mode_name,kind_name,row,col,lexeme,eof,invalid
+default,word,0,0,"a,b",false,false
+default,white_space,0,3," ",false,false
+default,word,0,4,"""c""",false,false
+default,white_space,0,7," ",false,false
+default,word,0,8,"d
+e",false,false
+default,,1,1,,true,false
+`,
+		},
+		{
+			format: "tsv",
+			output: "mode_name\tkind_name\trow\tcol\tlexeme\teof\tinvalid\n" +
+				"default\tword\t0\t0\ta,b\tfalse\tfalse\n" +
+				"default\twhite_space\t0\t3\t \tfalse\tfalse\n" +
+				"default\tword\t0\t4\t\"c\"\tfalse\tfalse\n" +
+				"default\twhite_space\t0\t7\t \tfalse\tfalse\n" +
+				"default\tword\t0\t8\td\\ne\tfalse\tfalse\n" +
+				"default\t\t1\t1\t\ttrue\tfalse\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			lex, err := driver.NewLexer(driver.NewLexSpec(clspec), strings.NewReader("a,b \"c\" d\ne"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			tw, err := newTokenWriter(&b, clspec, tt.format, driver.LexemeFormatText)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for {
+				tok, err := lex.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = tw.write(tok)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tok.EOF {
+					break
+				}
+			}
+			err = tw.flush()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.output {
+				t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", tt.output, b.String())
+			}
+		})
+	}
+}
+
+func TestTokenWriter_TSVEscape(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "line",
+				Pattern: `[^\u{000A}]+`,
+			},
+			{
+				Kind:    "new_line",
+				Pattern: `\u{000A}`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lex, err := driver.NewLexer(driver.NewLexSpec(clspec), strings.NewReader(" a\tb\\c \"d\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	tw, err := newTokenWriter(&b, clspec, "tsv", driver.LexemeFormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tw.write(tok)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.EOF {
+			break
+		}
+	}
+	err = tw.flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := "mode_name\tkind_name\trow\tcol\tlexeme\teof\tinvalid\n" +
+		"default\tline\t0\t0\t a\\tb\\\\c \"d\"\tfalse\tfalse\n" +
+		"default\tnew_line\t0\t10\t\\n\tfalse\tfalse\n" +
+		"default\t\t1\t0\t\ttrue\tfalse\n"
+	if b.String() != output {
+		t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", output, b.String())
+	}
+}
+
+func TestTokenWriter_LexemeFormat(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[^ ]+`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		lexFmt string
+		output string
+	}{
+		{
+			format: "tsv",
+			lexFmt: "hex",
+			output: "mode_name\tkind_name\trow\tcol\tlexeme\teof\tinvalid\n" +
+				"default\tword\t0\t0\tE3 81 82\tfalse\tfalse\n" +
+				"default\t\t0\t1\t\ttrue\tfalse\n",
+		},
+		{
+			format: "ndjson",
+			lexFmt: "quoted",
+			output: `{"mode_id":1,"mode_name":"default","kind_id":1,"mode_kind_id":1,"kind_name":"word","row":0,"col":0,"lexeme":"\"あ\"","eof":false,"invalid":false}
+{"mode_id":1,"mode_name":"default","kind_id":0,"mode_kind_id":0,"kind_name":"","row":0,"col":1,"lexeme":"\"\"","eof":true,"invalid":false}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lexFmt, func(t *testing.T) {
+			lexFmt, err := parseLexemeFormat(tt.lexFmt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lex, err := driver.NewLexer(driver.NewLexSpec(clspec), strings.NewReader("あ"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			tw, err := newTokenWriter(&b, clspec, tt.format, lexFmt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, err := newKindFilter(clspec, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = writeTokens(lex, tw, filter, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.output {
+				t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", tt.output, b.String())
+			}
+		})
+	}
+
+	_, err = parseLexemeFormat("binary")
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
+
+func TestTokenWriter_JSONArray(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[a-z]+`,
+			},
+			{
+				Kind:    "white_space",
+				Pattern: ` +`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filter []string
+		count  int
+		kinds  []string
+	}{
+		{
+			kinds: []string{"word", "white_space", "word", "white_space", "word", ""},
+		},
+		{
+			filter: []string{"word"},
+			count:  2,
+			kinds:  []string{"word", "word"},
+		},
+		{
+			filter: []string{"word"},
+			kinds:  []string{"word", "word", "word", ""},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("#%v", i), func(t *testing.T) {
+			filter, err := newKindFilter(clspec, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lex, err := driver.NewLexer(driver.NewLexSpec(clspec), strings.NewReader("foo bar baz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			tw, err := newTokenWriter(&b, clspec, "json-array", driver.LexemeFormatText)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = writeTokens(lex, tw, filter, tt.count, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var toks []struct {
+				KindName string `json:"kind_name"`
+			}
+			err = json.Unmarshal(b.Bytes(), &toks)
+			if err != nil {
+				t.Fatalf("the output isn't a JSON array: %v\n%v", err, b.String())
+			}
+			if len(toks) != len(tt.kinds) {
+				t.Fatalf("unexpected number of elements; want: %v, got: %v\n%v", len(tt.kinds), len(toks), b.String())
+			}
+			for i, tok := range toks {
+				if tok.KindName != tt.kinds[i] {
+					t.Fatalf("unexpected kind; want: %v, got: %v", tt.kinds[i], tok.KindName)
+				}
+			}
+		})
+	}
+
+	// The writer closes the array even when it writes no tokens.
+	var b bytes.Buffer
+	tw, err := newTokenWriter(&b, clspec, "json-array", driver.LexemeFormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tw.flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var toks []interface{}
+	err = json.Unmarshal(b.Bytes(), &toks)
+	if err != nil || toks == nil || len(toks) != 0 {
+		t.Fatalf("unexpected output: %v", b.String())
+	}
+}
+
+func TestKindFilter(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[a-z]+`,
+			},
+			{
+				Kind:    "number",
+				Pattern: `[0-9]+`,
+			},
+			{
+				Kind:    "white_space",
+				Pattern: ` +`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		caption string
+		filter  []string
+		kinds   []string
+	}{
+		{
+			caption: "when no kinds are specified, the filter accepts all tokens",
+			kinds:   []string{"word", "white_space", "number", "white_space", "", "word", ""},
+		},
+		{
+			caption: "the filter accepts only the specified kinds and EOF",
+			filter:  []string{"word", "number"},
+			kinds:   []string{"word", "number", "word", ""},
+		},
+		{
+			caption: "the filter accepts only the specified kind and EOF",
+			filter:  []string{"white_space"},
+			kinds:   []string{"white_space", "white_space", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			filter, err := newKindFilter(clspec, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lex, err := driver.NewLexer(driver.NewLexSpec(clspec), strings.NewReader("foo 123 @bar"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kinds []string
+			for {
+				tok, err := lex.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if filter(tok) {
+					kinds = append(kinds, clspec.KindNames[tok.KindID].String())
+				}
+				if tok.EOF {
+					break
+				}
+			}
+			if strings.Join(kinds, ",") != strings.Join(tt.kinds, ",") {
+				t.Fatalf("unexpected kinds; want: %v, got: %v", tt.kinds, kinds)
+			}
+		})
+	}
+
+	t.Run("the filter cannot contain undefined kinds", func(t *testing.T) {
+		_, err := newKindFilter(clspec, []string{"word", "undefined"})
+		if err == nil {
+			t.Fatalf("expected error didn't occur")
+		}
+	})
+}
+
+func TestRunLex_FilterErrorKind(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[a-z]+`,
+			},
+			{
+				Kind:    "white_space",
+				Pattern: ` +`,
+			},
+		},
+		ErrorKinds: map[spec.LexModeName]spec.LexKindName{
+			spec.LexModeNameDefault: "error",
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	clspecPath := filepath.Join(dir, "clexspec.json")
+	err = writeCompiledLexSpec(clspec, clspecPath, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		caption string
+		filter  string
+		output  string
+	}{
+		{
+			caption: "the filter accepts error tokens of the specified error kind",
+			filter:  "error",
+			output: "mode_name\tkind_name\trow\tcol\tlexeme\teof\tinvalid\n" +
+				"default\terror\t0\t4\t@\tfalse\ttrue\n" +
+				"default\t\t0\t9\t\ttrue\tfalse\n",
+		},
+		{
+			caption: "the filter rejects error tokens when the error kind isn't specified",
+			filter:  "word",
+			output: "mode_name\tkind_name\trow\tcol\tlexeme\teof\tinvalid\n" +
+				"default\tword\t0\t0\tfoo\tfalse\tfalse\n" +
+				"default\tword\t0\t6\tbar\tfalse\tfalse\n" +
+				"default\t\t0\t9\t\ttrue\tfalse\n",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			outPath := filepath.Join(dir, fmt.Sprintf("out-%v", i))
+			cmd := setLexFlags(t, map[string]string{
+				"text":   "foo @ bar",
+				"format": "tsv",
+				"filter": tt.filter,
+				"output": outPath,
+			})
+
+			err := runLex(cmd, []string{clspecPath})
+			if err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+			output, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != tt.output {
+				t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", tt.output, string(output))
+			}
+		})
+	}
+}
+
+func TestWriteTokens_Count(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[a-z]+`,
+			},
+			{
+				Kind:    "white_space",
+				Pattern: ` +`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		caption string
+		filter  []string
+		count   int
+		lines   []string
+	}{
+		{
+			caption: "the command stops after the specified number of tokens",
+			count:   3,
+			lines: []string{
+				"default,word,0,0,foo,false,false",
+				`default,white_space,0,3," ",false,false`,
+				"default,word,0,4,bar,false,false",
+			},
+		},
+		{
+			caption: "the command counts only the tokens that the filter accepts",
+			filter:  []string{"word"},
+			count:   2,
+			lines: []string{
+				"default,word,0,0,foo,false,false",
+				"default,word,0,4,bar,false,false",
+			},
+		},
+		{
+			caption: "the command prints the EOF token when it appears before the count",
+			filter:  []string{"word"},
+			count:   10,
+			lines: []string{
+				"default,word,0,0,foo,false,false",
+				"default,word,0,4,bar,false,false",
+				"default,word,0,8,baz,false,false",
+				"default,,0,11,,true,false",
+			},
+		},
+		{
+			caption: "0 means no limit",
+			filter:  []string{"word"},
+			count:   0,
+			lines: []string{
+				"default,word,0,0,foo,false,false",
+				"default,word,0,4,bar,false,false",
+				"default,word,0,8,baz,false,false",
+				"default,,0,11,,true,false",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			filter, err := newKindFilter(clspec, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lex, err := driver.NewLexer(driver.NewLexSpec(clspec), strings.NewReader("foo bar baz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			tw, err := newTokenWriter(&b, clspec, "csv", driver.LexemeFormatText)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = writeTokens(lex, tw, filter, tt.count, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+			expected := append([]string{"mode_name,kind_name,row,col,lexeme,eof,invalid"}, tt.lines...)
+			if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+				t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", strings.Join(expected, "\n"), b.String())
+			}
+		})
+	}
+}
+
+func TestReadCompiledLexSpec_FormatVersion(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[a-z]+`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clspec.FormatVersion != spec.CompiledLexSpecFormatVersion {
+		t.Fatalf("unexpected format version; want: %v, got: %v", spec.CompiledLexSpecFormatVersion, clspec.FormatVersion)
+	}
+
+	tests := []struct {
+		version int
+		err     bool
+	}{
+		{
+			version: spec.CompiledLexSpecFormatVersion,
+		},
+		{
+			version: spec.CompiledLexSpecFormatVersion - 1,
+			err:     true,
+		},
+		{
+			version: spec.CompiledLexSpecFormatVersion + 1,
+			err:     true,
+		},
+	}
+	dir := t.TempDir()
+	for _, format := range []string{"json", "gob"} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%v, version %v", format, tt.version), func(t *testing.T) {
+				clspec.FormatVersion = tt.version
+				path := filepath.Join(dir, fmt.Sprintf("clexspec-%v.%v", tt.version, format))
+				err := writeCompiledLexSpec(clspec, path, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				loaded, err := readCompiledLexSpec(path)
+				if tt.err {
+					if err == nil {
+						t.Fatalf("expected error didn't occur")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error occurred: %v", err)
+				}
+				if loaded.FormatVersion != tt.version {
+					t.Fatalf("unexpected format version; want: %v, got: %v", tt.version, loaded.FormatVersion)
+				}
+			})
+		}
+	}
+}
+
+func TestRunLex_Text(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[a-z]+`,
+			},
+			{
+				Kind:    "white_space",
+				Pattern: ` +`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	clspecPath := filepath.Join(dir, "clexspec.json")
+	err = writeCompiledLexSpec(clspec, clspecPath, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcPath := filepath.Join(dir, "src")
+	err = os.WriteFile(srcPath, []byte("foo"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		caption string
+		flags   map[string]string
+		output  string
+		err     string
+	}{
+		{
+			caption: "--text and --output",
+			flags: map[string]string{
+				"text":   "foo bar",
+				"format": "tsv",
+			},
+			output: "mode_name\tkind_name\trow\tcol\tlexeme\teof\tinvalid\n" +
+				"default\tword\t0\t0\tfoo\tfalse\tfalse\n" +
+				"default\twhite_space\t0\t3\t \tfalse\tfalse\n" +
+				"default\tword\t0\t4\tbar\tfalse\tfalse\n" +
+				"default\t\t0\t7\t\ttrue\tfalse\n",
+		},
+		{
+			caption: "--text and --break-on-error",
+			flags: map[string]string{
+				"text":           "foo @ bar",
+				"format":         "tsv",
+				"break-on-error": "true",
+			},
+			// The tokens preceding the error token are written out.
+			output: "mode_name\tkind_name\trow\tcol\tlexeme\teof\tinvalid\n" +
+				"default\tword\t0\t0\tfoo\tfalse\tfalse\n" +
+				"default\twhite_space\t0\t3\t \tfalse\tfalse\n",
+			err: "detected an error token",
+		},
+		{
+			caption: "--source and --text",
+			flags: map[string]string{
+				"source": srcPath,
+				"text":   "foo",
+			},
+			err: "--source and --text cannot be used together",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			outPath := filepath.Join(dir, fmt.Sprintf("out-%v", i))
+			flags := map[string]string{
+				"output": outPath,
+			}
+			for name, value := range tt.flags {
+				flags[name] = value
+			}
+			cmd := setLexFlags(t, flags)
+
+			err := runLex(cmd, []string{clspecPath})
+			if tt.err != "" {
+				if err == nil {
+					t.Fatal("expected error didn't occur")
+				}
+				if !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error occurred: %v", err)
+				}
+			}
+			if tt.output == "" {
+				return
+			}
+			output, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != tt.output {
+				t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", tt.output, string(output))
+			}
+		})
+	}
+}
+
+// setLexFlags sets the flags of `lex` command and returns the command. The flags are reset to their default values
+// when the test finishes.
+func setLexFlags(t *testing.T, flags map[string]string) *cobra.Command {
+	t.Helper()
+
+	cmd, _, err := rootCmd.Find([]string{"lex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			if v, ok := f.Value.(pflag.SliceValue); ok {
+				v.Replace(nil)
+			} else {
+				f.Value.Set(f.DefValue)
+			}
+			f.Changed = false
+		})
+	})
+	for name, value := range flags {
+		err := cmd.Flags().Set(name, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/nihei9/maleeni/driver"
+	"github.com/nihei9/maleeni/spec"
+	"github.com/spf13/cobra"
+)
+
+var statsFlags = struct {
+	source *string
+	format *string
+}{}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "stats clexspec",
+		Short: "Print statistics of tokens in a text stream",
+		Long: `stats tokenizes a text stream according to a compiled lexical specification and prints
+the number of tokens and bytes and the longest lexeme of each kind. You can use it to find
+which patterns dominate real data.
+
+Note that the lexer doesn't return tokens of skip kinds, so stats doesn't count them.`,
+		Example: `  cat src | maleeni stats clexspec.json
+  Print statistics in JSON format:
+    maleeni stats clexspec.json --source src --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: runStats,
+	}
+	statsFlags.source = cmd.Flags().StringP("source", "s", "", "source file path (default stdin)")
+	statsFlags.format = cmd.Flags().StringP("format", "f", "table", "output format: table or json")
+	rootCmd.AddCommand(cmd)
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if *statsFlags.format != "table" && *statsFlags.format != "json" {
+		return fmt.Errorf("invalid output format: %v (table or json is available)", *statsFlags.format)
+	}
+
+	clspec, err := readCompiledLexSpec(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot read a compiled lexical specification: %w", err)
+	}
+
+	var src io.Reader = os.Stdin
+	if *statsFlags.source != "" {
+		f, err := os.Open(*statsFlags.source)
+		if err != nil {
+			return fmt.Errorf("Cannot open the source file %s: %w", *statsFlags.source, err)
+		}
+		defer f.Close()
+		src = f
+	}
+	lex, err := driver.NewLexer(driver.NewLexSpec(clspec), src)
+	if err != nil {
+		return err
+	}
+
+	stats, err := collectTokenStats(clspec, lex)
+	if err != nil {
+		return err
+	}
+	return writeTokenStats(os.Stdout, stats, *statsFlags.format)
+}
+
+type kindStats struct {
+	Kind          string `json:"kind"`
+	Count         int    `json:"count"`
+	Bytes         int    `json:"bytes"`
+	LongestLexeme string `json:"longest_lexeme"`
+}
+
+type tokenStats struct {
+	// Kinds is sorted in descending order of the number of tokens. Kinds that never appear are omitted.
+	Kinds []*kindStats `json:"kinds"`
+
+	TotalCount   int `json:"total_count"`
+	TotalBytes   int `json:"total_bytes"`
+	InvalidCount int `json:"invalid_count"`
+	InvalidBytes int `json:"invalid_bytes"`
+}
+
+// collectTokenStats reads all tokens from a lexer and aggregates them by kind. The EOF token isn't counted.
+func collectTokenStats(clspec *spec.CompiledLexSpec, lex *driver.Lexer) (*tokenStats, error) {
+	stats := &tokenStats{}
+	kinds := map[spec.LexKindName]*kindStats{}
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.EOF {
+			break
+		}
+
+		stats.TotalCount++
+		stats.TotalBytes += len(tok.Lexeme)
+		if tok.Invalid {
+			stats.InvalidCount++
+			stats.InvalidBytes += len(tok.Lexeme)
+			continue
+		}
+
+		name := clspec.KindNames[tok.KindID]
+		ks, ok := kinds[name]
+		if !ok {
+			ks = &kindStats{
+				Kind: name.String(),
+			}
+			kinds[name] = ks
+		}
+		ks.Count++
+		ks.Bytes += len(tok.Lexeme)
+		if len(tok.Lexeme) > len(ks.LongestLexeme) {
+			ks.LongestLexeme = string(tok.Lexeme)
+		}
+	}
+
+	stats.Kinds = make([]*kindStats, 0, len(kinds))
+	for _, ks := range kinds {
+		stats.Kinds = append(stats.Kinds, ks)
+	}
+	sort.Slice(stats.Kinds, func(i, j int) bool {
+		if stats.Kinds[i].Count != stats.Kinds[j].Count {
+			return stats.Kinds[i].Count > stats.Kinds[j].Count
+		}
+		return stats.Kinds[i].Kind < stats.Kinds[j].Kind
+	})
+
+	return stats, nil
+}
+
+func writeTokenStats(w io.Writer, stats *tokenStats, format string) error {
+	if format == "json" {
+		data, err := json.Marshal(stats)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%v\n", string(data))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "kind\tcount\tbytes\tlongest lexeme\n")
+	for _, ks := range stats.Kinds {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%q\n", ks.Kind, ks.Count, ks.Bytes, ks.LongestLexeme)
+	}
+	fmt.Fprintf(tw, "(invalid)\t%v\t%v\t-\n", stats.InvalidCount, stats.InvalidBytes)
+	fmt.Fprintf(tw, "(total)\t%v\t%v\t-\n", stats.TotalCount, stats.TotalBytes)
+	return tw.Flush()
+}
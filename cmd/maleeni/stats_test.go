@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nihei9/maleeni/compiler"
+	"github.com/nihei9/maleeni/driver"
+	"github.com/nihei9/maleeni/spec"
+)
+
+func TestTokenStats(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `[a-z]+`,
+			},
+			{
+				Kind:    "number",
+				Pattern: `[0-9]+`,
+			},
+			{
+				Kind:    "white_space",
+				Pattern: ` +`,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex, err := driver.NewLexer(driver.NewLexSpec(clspec), strings.NewReader("foo bar 12 @@ hello 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := collectTokenStats(clspec, lex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		output string
+	}{
+		{
+			format: "table",
+			output: `kind         count  bytes  longest lexeme
+white_space  5      5      " "
+word         3      11     "hello"
+number       2      3      "12"
+(invalid)    1      2      -
+(total)      11     21     -
+`,
+		},
+		{
+			format: "json",
+			output: `{"kinds":[{"kind":"white_space","count":5,"bytes":5,"longest_lexeme":" "},{"kind":"word","count":3,"bytes":11,"longest_lexeme":"hello"},{"kind":"number","count":2,"bytes":3,"longest_lexeme":"12"}],"total_count":11,"total_bytes":21,"invalid_count":1,"invalid_bytes":2}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			err := writeTokenStats(&b, stats, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.output {
+				t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", tt.output, b.String())
+			}
+		})
+	}
+}
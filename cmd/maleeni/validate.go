@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nihei9/maleeni/compiler"
+	"github.com/nihei9/maleeni/spec"
+	"github.com/spf13/cobra"
+)
+
+var validateFlags = struct {
+	spelling *string
+}{}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate a lexical specification",
+		Long: `validate takes a lexical specification and reports errors in it, such as duplicate kinds,
+spelling inconsistencies, and invalid identifiers. Unlike compile, validate doesn't parse patterns
+or generate a DFA, so it finishes quickly even when the specification is large.`,
+		Example: `  maleeni validate lexspec.json`,
+		Args:    cobra.MaximumNArgs(1),
+		RunE:    runValidate,
+	}
+	validateFlags.spelling = cmd.Flags().String("spelling-inconsistencies", "error", "how to report kind names or mode names spelled the same in UpperCamelCase: error, warning, or ignore")
+	rootCmd.AddCommand(cmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	}
+	lspec, err := readLexSpec(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read a lexical specification: %w", err)
+	}
+
+	spellingSeverity, err := parseSeverity(*validateFlags.spelling)
+	if err != nil {
+		return err
+	}
+	var opts []spec.ValidateOption
+	if spellingSeverity != compiler.SeverityError {
+		opts = append(opts, spec.SkipSpellingCheck())
+	}
+	err = lspec.Validate(opts...)
+	if err != nil {
+		return fmt.Errorf("invalid lexical specification:\n%w", err)
+	}
+	if spellingSeverity == compiler.SeverityWarning {
+		for _, err := range lspec.SpellingInconsistencies() {
+			writeCompileWarning(os.Stderr, &compiler.CompileWarning{
+				Cause: err,
+			})
+		}
+	}
+	_, err = lspec.ExpandMacros()
+	if err != nil {
+		return fmt.Errorf("invalid lexical specification:\n%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunValidate(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		err     bool
+	}{
+		{
+			caption: "a valid specification",
+			src: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "word",
+            "pattern": "[a-z]+"
+        }
+    ]
+}
+`,
+		},
+		{
+			caption: "a specification containing duplicate kinds",
+			src: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "word",
+            "pattern": "[a-z]+"
+        },
+        {
+            "kind": "word",
+            "pattern": "[A-Z]+"
+        }
+    ]
+}
+`,
+			err: true,
+		},
+		{
+			caption: "a specification containing spelling inconsistencies",
+			src: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "foo_1",
+            "pattern": "foo"
+        },
+        {
+            "kind": "foo1",
+            "pattern": "bar"
+        }
+    ]
+}
+`,
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "lexspec.json")
+			err := os.WriteFile(path, []byte(tt.src), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = runValidate(nil, []string{path})
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error didn't occur")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error occurred: %v", err)
+				}
+			}
+		})
+	}
+}
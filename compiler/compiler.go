@@ -2,7 +2,12 @@ package compiler
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/nihei9/maleeni/compiler/dfa"
 	psr "github.com/nihei9/maleeni/compiler/parser"
@@ -22,8 +27,100 @@ func CompressionLevel(lv int) CompilerOption {
 	}
 }
 
+// Cache makes the compiler reuse the modes of a previously compiled specification. The compiler builds only the modes
+// whose entries, fragments, or compression level differ from the ones the cached modes were built from. The compiler
+// reports warnings about the reused modes again because the cached modes keep them. The compiler ignores a cache having
+// a format version other than spec.CompiledLexSpecFormatVersion and builds all the modes.
+func Cache(clspec *spec.CompiledLexSpec) CompilerOption {
+	return func(c *compilerConfig) error {
+		c.cache = clspec
+		return nil
+	}
+}
+
+// MaxFragmentExpansion sets the maximum number of nodes that a pattern or a fragment can consist of after expanding
+// fragments. A chain of fragments each referring to the previous one multiple times expands exponentially, and the limit
+// makes the compiler fail instead of running out of memory. A non-positive value means no limit. The default value is
+// DefaultMaxFragmentExpansion.
+func MaxFragmentExpansion(n int) CompilerOption {
+	return func(c *compilerConfig) error {
+		c.maxFragmentNodes = n
+		return nil
+	}
+}
+
+const DefaultMaxFragmentExpansion = 1000000
+
+// KeepUncompressedTransition makes the compiler keep the UncompressedTransition field of each DFA even when the
+// compression level is 1 or higher. Tools inspecting raw transitions can read the field instead of decoding the
+// compressed table. The option makes the compiled specification larger, and the driver doesn't use the field.
+func KeepUncompressedTransition() CompilerOption {
+	return func(c *compilerConfig) error {
+		c.keepUncompressed = true
+		return nil
+	}
+}
+
+// DuplicatePatternsAsErrors makes the compiler report entries whose patterns are identical to the pattern of another
+// entry in the same mode as errors. By default, the compiler reports them as warnings.
+func DuplicatePatternsAsErrors() CompilerOption {
+	return func(c *compilerConfig) error {
+		c.duplicatePatternsAsErrors = true
+		return nil
+	}
+}
+
+// FoldContinuationBytes makes the compiler share the trailing continuation bytes of UTF-8 sequences among code point
+// ranges when building DFAs. The DFAs of patterns containing many multi-byte characters, such as `.` and `\p{L}`,
+// then have fewer states. The option doesn't change what the patterns match.
+func FoldContinuationBytes() CompilerOption {
+	return func(c *compilerConfig) error {
+		c.foldContinuationBytes = true
+		return nil
+	}
+}
+
+// Minimize makes the compiler merge equivalent states of each DFA. The transition tables then become smaller, but the
+// compilation takes longer. The option doesn't change what the lexer returns.
+func Minimize() CompilerOption {
+	return func(c *compilerConfig) error {
+		c.minimize = true
+		return nil
+	}
+}
+
+// Severity represents how the compiler reports a problem in a lexical specification.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityIgnore
+)
+
+// SpellingInconsistencies sets how the compiler reports kind names or mode names that are spelled the same in
+// UpperCamelCase, such as `left_paren` and `LeftParen`. By default, the compiler reports them as errors because
+// the generated Go code identifies kinds and modes by UpperCamelCase names. With SeverityWarning or SeverityIgnore,
+// the compiler accepts such names, but maleeni-go can't generate a lexer from the compiled specification.
+func SpellingInconsistencies(sev Severity) CompilerOption {
+	return func(c *compilerConfig) error {
+		if sev < SeverityError || sev > SeverityIgnore {
+			return fmt.Errorf("invalid severity: %v", sev)
+		}
+		c.spellingSeverity = sev
+		return nil
+	}
+}
+
 type compilerConfig struct {
-	compLv int
+	compLv                    int
+	cache                     *spec.CompiledLexSpec
+	maxFragmentNodes          int
+	keepUncompressed          bool
+	duplicatePatternsAsErrors bool
+	spellingSeverity          Severity
+	foldContinuationBytes     bool
+	minimize                  bool
 }
 
 type CompileError struct {
@@ -31,55 +128,215 @@ type CompileError struct {
 	Fragment bool
 	Cause    error
 	Detail   string
+
+	// Col is a column number in code points where the parser detected a syntax error in a pattern. The column starts
+	// at 1. When the error isn't a syntax error, Col is 0.
+	Col int
 }
 
-func Compile(lexspec *spec.LexSpec, opts ...CompilerOption) (*spec.CompiledLexSpec, error, []*CompileError) {
-	err := lexspec.Validate()
-	if err != nil {
-		return nil, fmt.Errorf("invalid lexical specification:\n%w", err), nil
+// CompileWarning describes a problem in a lexical specification that doesn't prevent the compilation. When the problem
+// doesn't belong to a specific kind, Kind is empty.
+type CompileWarning struct {
+	Kind     spec.LexKindName
+	Fragment bool
+	Cause    error
+	Detail   string
+}
+
+// CompileResult bundles everything Compile reports. When Err is nil, Spec holds the compiled specification.
+// Otherwise, Spec is nil, and Errors holds errors in patterns if the compilation failed because of them. Warnings
+// are reported in both cases.
+//
+// When the Minimize option is specified, RemovedStates holds the number of states that the minimization removed from
+// the DFA of each mode. The index is a mode ID. The numbers of modes reused from a cache are 0.
+type CompileResult struct {
+	Spec          *spec.CompiledLexSpec
+	Err           error
+	Errors        []*CompileError
+	Warnings      []*CompileWarning
+	RemovedStates []int
+}
+
+// CompileWithResult compiles a lexical specification the same way as Compile does, and returns the result including
+// warnings.
+func CompileWithResult(lexspec *spec.LexSpec, opts ...CompilerOption) *CompileResult {
+	clspec, removedStates, warnings, err, cerrs := compileLexSpec(lexspec, opts...)
+	return &CompileResult{
+		Spec:          clspec,
+		Err:           err,
+		Errors:        cerrs,
+		Warnings:      warnings,
+		RemovedStates: removedStates,
 	}
+}
+
+func Compile(lexspec *spec.LexSpec, opts ...CompilerOption) (*spec.CompiledLexSpec, error, []*CompileError) {
+	r := CompileWithResult(lexspec, opts...)
+	return r.Spec, r.Err, r.Errors
+}
+
+func compileLexSpec(lexspec *spec.LexSpec, opts ...CompilerOption) (*spec.CompiledLexSpec, []int, []*CompileWarning, error, []*CompileError) {
+	var warnings []*CompileWarning
 
-	config := &compilerConfig{}
+	config := &compilerConfig{
+		maxFragmentNodes: DefaultMaxFragmentExpansion,
+	}
 	for _, opt := range opts {
 		err := opt(config)
 		if err != nil {
-			return nil, err, nil
+			return nil, nil, warnings, err, nil
+		}
+	}
+
+	var validateOpts []spec.ValidateOption
+	if config.spellingSeverity != SeverityError {
+		validateOpts = append(validateOpts, spec.SkipSpellingCheck())
+	}
+	err := lexspec.Validate(validateOpts...)
+	if err != nil {
+		return nil, nil, warnings, fmt.Errorf("invalid lexical specification:\n%w", err), nil
+	}
+	if config.spellingSeverity == SeverityWarning {
+		for _, err := range lexspec.SpellingInconsistencies() {
+			warnings = append(warnings, &CompileWarning{
+				Cause: err,
+			})
+		}
+	}
+
+	entries, err := lexspec.ExpandMacros()
+	if err != nil {
+		return nil, nil, warnings, fmt.Errorf("invalid lexical specification:\n%w", err), nil
+	}
+	// ExpandMacros returns copies of the entries, so we can fill in the default priorities without modifying the
+	// specification.
+	for i, e := range entries {
+		if (e.Fragment && !e.Emit) || e.Priority != nil {
+			continue
 		}
+		priority := i + 1
+		e.Priority = &priority
+	}
+
+	modeEntries, modeNames, modeName2ID, fragmetns := groupEntriesByLexMode(entries)
+
+	// A mode without entries can't tokenize anything, and its DFA would have no accepting states. The compiler builds
+	// only modes having entries, so the initial mode must be one of them.
+	initialModeName := lexspec.InitialMode
+	if initialModeName == "" {
+		initialModeName = spec.LexModeNameDefault
+	}
+	initialModeID, ok := modeName2ID[initialModeName]
+	if !ok {
+		return nil, nil, warnings, fmt.Errorf("%v mode has no entries", initialModeName), nil
 	}
 
-	modeEntries, modeNames, modeName2ID, fragmetns := groupEntriesByLexMode(lexspec.Entries)
+	// Fragments are shared by all modes, so we parse them only once here. The compile function doesn't mutate
+	// these trees because ApplyFragments embeds a clone of a fragment tree into a pattern.
+	fragmentCPTrees, err, cerrs := parseFragments(fragmetns, config)
+	if err != nil {
+		return nil, nil, warnings, err, cerrs
+	}
 
 	modeSpecs := []*spec.CompiledLexModeSpec{
 		nil,
 	}
+	var removedStates []int
+	if config.minimize {
+		removedStates = make([]int, len(modeEntries))
+	}
 	for i, es := range modeEntries[1:] {
 		modeName := modeNames[i+1]
-		modeSpec, err, cerrs := compile(es, modeName2ID, fragmetns, config)
+		errorKind := lexspec.ErrorKinds[modeName]
+		hash := hashModeInputs(es, errorKind, lexspec.EOFKind, modeName2ID, fragmetns, config)
+		cached, err := findCachedModeSpec(config.cache, modeName, hash)
+		if err != nil {
+			return nil, nil, warnings, err, nil
+		}
+		if cached != nil {
+			for _, w := range cached.Warnings {
+				warnings = append(warnings, &CompileWarning{
+					Kind:     w.Kind,
+					Fragment: w.Fragment,
+					Cause:    errors.New(w.Cause),
+					Detail:   w.Detail,
+				})
+			}
+			modeSpecs = append(modeSpecs, cached)
+			continue
+		}
+		modeSpec, removed, ws, err, cerrs := compile(es, errorKind, lexspec.EOFKind, modeName2ID, fragmentCPTrees, config)
+		warnings = append(warnings, ws...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to compile in %v mode: %w", modeName, err), cerrs
+			return nil, nil, warnings, fmt.Errorf("failed to compile in %v mode: %w", modeName, err), cerrs
+		}
+		modeSpec.InputHash = hash
+		for _, w := range ws {
+			modeSpec.Warnings = append(modeSpec.Warnings, &spec.CompiledLexModeWarning{
+				Kind:     w.Kind,
+				Fragment: w.Fragment,
+				Cause:    w.Cause.Error(),
+				Detail:   w.Detail,
+			})
+		}
+		if removedStates != nil {
+			removedStates[i+1] = removed
 		}
 		modeSpecs = append(modeSpecs, modeSpec)
 	}
 
+	// Kind IDs are assigned in order of first appearance, visiting modes in mode ID order and entries of each mode in
+	// the order written in the specification. Thus, compiling the same specification always yields the same IDs.
+	// However, adding a kind to a mode shifts the IDs of the kinds that first appear in later modes. Error kinds get IDs
+	// following all the kinds of entries, and the EOF kind, which all modes share, comes last.
 	var kindNames []spec.LexKindName
 	var name2ID map[spec.LexKindName]spec.LexKindID
 	{
+		kindNames = []spec.LexKindName{
+			spec.LexKindNameNil,
+		}
 		name2ID = map[spec.LexKindName]spec.LexKindID{}
-		id := spec.LexKindIDMin
+		addKind := func(name spec.LexKindName) {
+			if _, ok := name2ID[name]; ok {
+				return
+			}
+			name2ID[name] = spec.LexKindID(len(kindNames))
+			kindNames = append(kindNames, name)
+		}
 		for _, modeSpec := range modeSpecs[1:] {
-			for _, name := range modeSpec.KindNames[1:] {
-				if _, ok := name2ID[name]; ok {
+			for id, name := range modeSpec.KindNames[1:] {
+				if spec.LexModeKindID(id+1) == modeSpec.ErrorKind || spec.LexModeKindID(id+1) == modeSpec.EOFKind {
 					continue
 				}
-				name2ID[name] = id
-				id++
+				addKind(name)
+			}
+		}
+		for _, modeSpec := range modeSpecs[1:] {
+			if modeSpec.ErrorKind != spec.LexModeKindIDNil {
+				addKind(modeSpec.KindNames[modeSpec.ErrorKind])
+			}
+		}
+		for _, modeSpec := range modeSpecs[1:] {
+			if modeSpec.EOFKind != spec.LexModeKindIDNil {
+				addKind(modeSpec.KindNames[modeSpec.EOFKind])
 			}
 		}
+	}
 
-		kindNames = make([]spec.LexKindName, len(name2ID)+1)
-		for name, id := range name2ID {
-			kindNames[id] = name
+	// Kind names of non-fragment entries and emitted fragments are unique, so each kind has at most one metadata.
+	var kindMeta []map[string]string
+	for _, e := range entries {
+		if (e.Fragment && !e.Emit) || len(e.Meta) == 0 {
+			continue
+		}
+		if kindMeta == nil {
+			kindMeta = make([]map[string]string, len(kindNames))
+		}
+		meta := make(map[string]string, len(e.Meta))
+		for k, v := range e.Meta {
+			meta[k] = v
 		}
+		kindMeta[name2ID[e.Kind]] = meta
 	}
 
 	var kindIDs [][]spec.LexKindID
@@ -97,36 +354,293 @@ func Compile(lexspec *spec.LexSpec, opts ...CompilerOption) (*spec.CompiledLexSp
 		}
 	}
 
-	return &spec.CompiledLexSpec{
+	clspec := &spec.CompiledLexSpec{
+		FormatVersion:    spec.CompiledLexSpecFormatVersion,
 		Name:             lexspec.Name,
-		InitialModeID:    spec.LexModeIDDefault,
+		InitialModeID:    initialModeID,
 		ModeNames:        modeNames,
 		KindNames:        kindNames,
 		KindIDs:          kindIDs,
 		CompressionLevel: config.compLv,
 		Specs:            modeSpecs,
-	}, nil, nil
+		KindMeta:         kindMeta,
+	}
+
+	err = shareTransitionRows(clspec)
+	if err != nil {
+		return nil, nil, warnings, err, nil
+	}
+
+	return clspec, removedStates, warnings, nil, nil
+}
+
+// hashModeInputs returns a digest of everything that affects the compiled spec of a mode. A mode can refer to any
+// fragment, so the digest covers all fragments. Push targets are hashed as mode IDs because adding or removing
+// another mode can change the IDs even if the entries of this mode stay the same.
+func hashModeInputs(
+	entries []*spec.LexEntry,
+	errorKind spec.LexKindName,
+	eofKind spec.LexKindName,
+	modeName2ID map[spec.LexModeName]spec.LexModeID,
+	fragments map[spec.LexKindName]*spec.LexEntry,
+	config *compilerConfig,
+) string {
+	h := sha256.New()
+	writeField := func(v string) {
+		fmt.Fprintf(h, "%v:%v;", len(v), v)
+	}
+
+	writeField(fmt.Sprintf("compression_level=%v", config.compLv))
+	if config.keepUncompressed {
+		writeField("keep_uncompressed_transition")
+	}
+	if config.duplicatePatternsAsErrors {
+		writeField("duplicate_patterns_as_errors")
+	}
+	if config.foldContinuationBytes {
+		writeField("fold_continuation_bytes")
+	}
+	if config.minimize {
+		writeField("minimize")
+	}
+	for _, e := range entries {
+		writeField(e.Kind.String())
+		writeField(e.Pattern.String())
+		writeField(fmt.Sprintf("literal=%v,push=%v,pop=%v,skip=%v,priority=%v", e.Literal, modeName2ID[e.Push], e.Pop, e.Skip, *e.Priority))
+	}
+	if errorKind != "" {
+		writeField(fmt.Sprintf("error_kind=%v", errorKind))
+	}
+	if eofKind != "" {
+		writeField(fmt.Sprintf("eof_kind=%v", eofKind))
+	}
+
+	var fragNames []string
+	for k := range fragments {
+		fragNames = append(fragNames, k.String())
+	}
+	sort.Strings(fragNames)
+	for _, k := range fragNames {
+		writeField(k)
+		writeField(fragments[spec.LexKindName(k)].Pattern.String())
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// findCachedModeSpec returns a mode of the cache built from the inputs having the hash. When the cache stores the
+// transition rows of its modes in a shared table, the returned mode has its own copy of the rows because the modes
+// sharing the table with it may not be reused. A cache in another format version has no reusable modes because its
+// modes may lack fields that the current format has.
+func findCachedModeSpec(cache *spec.CompiledLexSpec, modeName spec.LexModeName, hash string) (*spec.CompiledLexModeSpec, error) {
+	if cache == nil || cache.FormatVersion != spec.CompiledLexSpecFormatVersion {
+		return nil, nil
+	}
+	for id, name := range cache.ModeNames {
+		if id == spec.LexModeIDNil.Int() || name != modeName {
+			continue
+		}
+		if id >= len(cache.Specs) || cache.Specs[id].InputHash != hash {
+			return nil, nil
+		}
+		modeSpec := cache.Specs[id]
+		if cache.SharedTransition == nil {
+			return modeSpec, nil
+		}
+
+		rows := decodeUniqueRows(cache.SharedTransition, cache.CompressionLevel)
+		tranTab := *modeSpec.DFA
+		tranTab.Transition = nil
+		tranTab.UncompressedTransition = make([]spec.StateID, 0, tranTab.RowCount*tranTab.ColCount)
+		for _, rowNum := range modeSpec.DFA.Transition.RowNums {
+			tranTab.UncompressedTransition = append(tranTab.UncompressedTransition, rows[rowNum]...)
+		}
+		var t *spec.TransitionTable
+		var err error
+		switch cache.CompressionLevel {
+		case 2:
+			t, err = compressTransitionTableLv2(&tranTab)
+		case 1:
+			t, err = compressTransitionTableLv1(&tranTab)
+		default:
+			return nil, fmt.Errorf("a shared transition table is unavailable at compression level %v", cache.CompressionLevel)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if modeSpec.DFA.UncompressedTransition != nil {
+			t.UncompressedTransition = tranTab.UncompressedTransition
+		}
+		ms := *modeSpec
+		ms.DFA = t
+		return &ms, nil
+	}
+	return nil, nil
+}
+
+// shareTransitionRows stores the unique transition rows of all modes in a single table, clspec.SharedTransition,
+// so that a row appearing in multiple modes is stored only once. Modes often contain identical rows, such as rows of
+// accepting states without outgoing transitions and rows of patterns enabled in multiple modes. After sharing, the
+// Transition field of each mode holds only row numbers pointing to the shared table. The compiler applies this only
+// at compression level 1 or higher and only when it makes the tables smaller.
+func shareTransitionRows(clspec *spec.CompiledLexSpec) error {
+	if clspec.CompressionLevel < 1 || len(clspec.Specs) <= 2 {
+		return nil
+	}
+
+	// All modes have the same number of columns, so the first mode tells it.
+	colCount := clspec.Specs[spec.LexModeIDNil.Int()+1].DFA.Transition.OriginalColCount
+	origSize := 0
+	var rows [][]spec.StateID
+	row2Num := map[string]int{}
+	rowNums := make([][]int, len(clspec.Specs))
+	for id, modeSpec := range clspec.Specs {
+		if id == spec.LexModeIDNil.Int() {
+			continue
+		}
+		tran := modeSpec.DFA.Transition
+		if tran.OriginalColCount != colCount {
+			return nil
+		}
+		origSize += uniqueEntriesTableSize(tran)
+
+		modeRows := decodeUniqueRows(tran, clspec.CompressionLevel)
+		nums := make([]int, len(tran.RowNums))
+		for i, rowNum := range tran.RowNums {
+			row := modeRows[rowNum]
+			key := fmt.Sprint(row)
+			num, ok := row2Num[key]
+			if !ok {
+				num = len(rows)
+				row2Num[key] = num
+				rows = append(rows, row)
+			}
+			nums[i] = num
+		}
+		rowNums[id] = nums
+	}
+
+	entries := make([]spec.StateID, 0, len(rows)*colCount)
+	for _, row := range rows {
+		entries = append(entries, row...)
+	}
+	shared := &spec.UniqueEntriesTable{
+		OriginalRowCount: len(rows),
+		OriginalColCount: colCount,
+	}
+	if clspec.CompressionLevel == 2 {
+		orig, err := compressor.NewOriginalTable(convertStateIDSliceToIntSlice(entries), colCount)
+		if err != nil {
+			return err
+		}
+		rdTab := compressor.NewRowDisplacementTable(0)
+		err = rdTab.Compress(orig)
+		if err != nil {
+			return err
+		}
+		shared.UniqueEntries = &spec.RowDisplacementTable{
+			OriginalRowCount: rdTab.OriginalRowCount,
+			OriginalColCount: rdTab.OriginalColCount,
+			EmptyValue:       spec.StateIDNil,
+			Entries:          convertIntSliceToStateIDSlice(rdTab.Entries),
+			Bounds:           rdTab.Bounds,
+			RowDisplacement:  rdTab.RowDisplacement,
+		}
+	} else {
+		shared.UncompressedUniqueEntries = entries
+	}
+	if uniqueEntriesTableSize(shared) >= origSize {
+		return nil
+	}
+
+	// The mode specs may come from a cache, so we replace them with copies instead of modifying them.
+	for id, modeSpec := range clspec.Specs {
+		if id == spec.LexModeIDNil.Int() {
+			continue
+		}
+		tranTab := *modeSpec.DFA
+		tranTab.Transition = &spec.UniqueEntriesTable{
+			RowNums:          rowNums[id],
+			OriginalRowCount: modeSpec.DFA.Transition.OriginalRowCount,
+			OriginalColCount: colCount,
+		}
+		ms := *modeSpec
+		ms.DFA = &tranTab
+		clspec.Specs[id] = &ms
+	}
+	clspec.SharedTransition = shared
+
+	return nil
+}
+
+// uniqueEntriesTableSize returns the number of entries that a table stores, excluding row numbers.
+func uniqueEntriesTableSize(tab *spec.UniqueEntriesTable) int {
+	if tab.UniqueEntries != nil {
+		return len(tab.UniqueEntries.Entries) + len(tab.UniqueEntries.Bounds) + len(tab.UniqueEntries.RowDisplacement)
+	}
+	return len(tab.UncompressedUniqueEntries)
+}
+
+// decodeUniqueRows returns the unique rows stored in a table in order of row numbers.
+func decodeUniqueRows(tab *spec.UniqueEntriesTable, compLv int) [][]spec.StateID {
+	colCount := tab.OriginalColCount
+	var rowCount int
+	if compLv == 2 {
+		rowCount = tab.UniqueEntries.OriginalRowCount
+	} else {
+		rowCount = len(tab.UncompressedUniqueEntries) / colCount
+	}
+	rows := make([][]spec.StateID, rowCount)
+	for r := 0; r < rowCount; r++ {
+		if compLv != 2 {
+			rows[r] = tab.UncompressedUniqueEntries[r*colCount : (r+1)*colCount]
+			continue
+		}
+		row := make([]spec.StateID, colCount)
+		d := tab.UniqueEntries.RowDisplacement[r]
+		for c := 0; c < colCount; c++ {
+			if tab.UniqueEntries.Bounds[d+c] == r {
+				row[c] = tab.UniqueEntries.Entries[d+c]
+			} else {
+				row[c] = tab.UniqueEntries.EmptyValue
+			}
+		}
+		rows[r] = row
+	}
+	return rows
 }
 
 func groupEntriesByLexMode(entries []*spec.LexEntry) ([][]*spec.LexEntry, []spec.LexModeName, map[spec.LexModeName]spec.LexModeID, map[spec.LexKindName]*spec.LexEntry) {
 	modeNames := []spec.LexModeName{
 		spec.LexModeNameNil,
-		spec.LexModeNameDefault,
 	}
 	modeName2ID := map[spec.LexModeName]spec.LexModeID{
-		spec.LexModeNameNil:     spec.LexModeIDNil,
-		spec.LexModeNameDefault: spec.LexModeIDDefault,
+		spec.LexModeNameNil: spec.LexModeIDNil,
 	}
-	lastModeID := spec.LexModeIDDefault
+	lastModeID := spec.LexModeIDNil
 	modeEntries := [][]*spec.LexEntry{
 		nil,
-		{},
+	}
+	// The default mode always has the ID 1 when some entry belongs to it. When all entries belong to other modes, for
+	// instance, because the specification sets another initial mode, the compiler doesn't build the default mode.
+	for _, e := range entries {
+		if (e.Fragment && !e.Emit) || !belongsToDefaultMode(e) {
+			continue
+		}
+		modeNames = append(modeNames, spec.LexModeNameDefault)
+		modeName2ID[spec.LexModeNameDefault] = spec.LexModeIDDefault
+		lastModeID = spec.LexModeIDDefault
+		modeEntries = append(modeEntries, []*spec.LexEntry{})
+		break
 	}
 	fragments := map[spec.LexKindName]*spec.LexEntry{}
 	for _, e := range entries {
 		if e.Fragment {
 			fragments[e.Kind] = e
-			continue
+			// An emitted fragment is also a kind, so it joins its modes as well.
+			if !e.Emit {
+				continue
+			}
 		}
 		ms := e.Modes
 		if len(ms) == 0 {
@@ -149,15 +663,122 @@ func groupEntriesByLexMode(entries []*spec.LexEntry) ([][]*spec.LexEntry, []spec
 	return modeEntries, modeNames, modeName2ID, fragments
 }
 
+func belongsToDefaultMode(e *spec.LexEntry) bool {
+	if len(e.Modes) == 0 {
+		return true
+	}
+	for _, m := range e.Modes {
+		if m == spec.LexModeNameDefault {
+			return true
+		}
+	}
+	return false
+}
+
+func parseFragments(fragments map[spec.LexKindName]*spec.LexEntry, config *compilerConfig) (map[spec.LexKindName]psr.CPTree, error, []*CompileError) {
+	fragmentPatterns := map[spec.LexKindName][]byte{}
+	for k, e := range fragments {
+		fragmentPatterns[k] = []byte(e.Pattern)
+	}
+
+	fragmentCPTrees := make(map[spec.LexKindName]psr.CPTree, len(fragmentPatterns))
+	var cerrs []*CompileError
+	for kind, pat := range fragmentPatterns {
+		p := psr.NewParser(kind, bytes.NewReader(pat))
+		t, err := p.Parse()
+		if err != nil {
+			if err == psr.ParseErr {
+				detail, cause := p.Error()
+				cerrs = append(cerrs, &CompileError{
+					Kind:     kind,
+					Fragment: true,
+					Cause:    cause,
+					Detail:   detail,
+					Col:      p.ErrorColumn(),
+				})
+			} else {
+				cerrs = append(cerrs, &CompileError{
+					Kind:     kind,
+					Fragment: true,
+					Cause:    err,
+				})
+			}
+			continue
+		}
+		if anchor, err := t.Anchor(); err != nil {
+			return nil, err, nil
+		} else if anchor != spec.LexAnchorNil {
+			cerrs = append(cerrs, &CompileError{
+				Kind:     kind,
+				Fragment: true,
+				Cause:    fmt.Errorf("a fragment cannot contain anchors"),
+			})
+			continue
+		}
+		fragmentCPTrees[kind] = t
+	}
+	if len(cerrs) > 0 {
+		return nil, fmt.Errorf("compile error"), cerrs
+	}
+
+	err := psr.CompleteFragments(fragmentCPTrees, config.maxFragmentNodes)
+	if err != nil {
+		if ferr, ok := err.(*psr.FragmentError); ok {
+			return nil, fmt.Errorf("compile error"), []*CompileError{
+				{
+					Kind:     ferr.Kind,
+					Fragment: true,
+					Cause:    ferr.Cause,
+					Detail:   fragmentErrorDetail(ferr, config),
+				},
+			}
+		}
+		if err == psr.ParseErr {
+			for _, frag := range fragmentCPTrees {
+				kind, frags, err := frag.Describe()
+				if err != nil {
+					return nil, err, nil
+				}
+
+				cerrs = append(cerrs, &CompileError{
+					Kind:     kind,
+					Fragment: true,
+					Cause:    fmt.Errorf("fragment contains undefined fragments or cycles"),
+					Detail:   fmt.Sprintf("%v", frags),
+				})
+			}
+
+			return nil, fmt.Errorf("compile error"), cerrs
+		}
+
+		return nil, err, nil
+	}
+
+	return fragmentCPTrees, nil, nil
+}
+
+func fragmentErrorDetail(ferr *psr.FragmentError, config *compilerConfig) string {
+	if ferr.Cause == psr.SemErrFragmentExpansionTooLarge {
+		return fmt.Sprintf("expanding \\f{%v} exceeds the limit of %v nodes", ferr.Fragment, config.maxFragmentNodes)
+	}
+	return fmt.Sprintf("\\F{%v}", ferr.Fragment)
+}
+
 func compile(
 	entries []*spec.LexEntry,
+	errorKind spec.LexKindName,
+	eofKind spec.LexKindName,
 	modeName2ID map[spec.LexModeName]spec.LexModeID,
-	fragments map[spec.LexKindName]*spec.LexEntry,
+	fragmentCPTrees map[spec.LexKindName]psr.CPTree,
 	config *compilerConfig,
-) (*spec.CompiledLexModeSpec, error, []*CompileError) {
+) (*spec.CompiledLexModeSpec, int, []*CompileWarning, error, []*CompileError) {
+	var warnings []*CompileWarning
+
 	var kindNames []spec.LexKindName
 	kindIDToName := map[spec.LexModeKindID]spec.LexKindName{}
 	var patterns map[spec.LexModeKindID][]byte
+	literals := map[spec.LexModeKindID]bool{}
+	priorities := map[spec.LexModeKindID]int{}
 	{
 		kindNames = append(kindNames, spec.LexKindNameNil)
 		patterns = map[spec.LexModeKindID][]byte{}
@@ -167,6 +788,10 @@ func compile(
 			kindNames = append(kindNames, e.Kind)
 			kindIDToName[kindID] = e.Kind
 			patterns[kindID] = []byte(e.Pattern)
+			priorities[kindID] = *e.Priority
+			if e.Literal {
+				literals[kindID] = true
+			}
 		}
 	}
 
@@ -176,7 +801,8 @@ func compile(
 	pop := []int{
 		0,
 	}
-	for _, e := range entries {
+	var skip []int
+	for i, e := range entries {
 		pushV := spec.LexModeIDNil
 		if e.Push != "" {
 			pushV = modeName2ID[e.Push]
@@ -187,68 +813,26 @@ func compile(
 			popV = 1
 		}
 		pop = append(pop, popV)
-	}
-
-	fragmentPatterns := map[spec.LexKindName][]byte{}
-	for k, e := range fragments {
-		fragmentPatterns[k] = []byte(e.Pattern)
-	}
-
-	fragmentCPTrees := make(map[spec.LexKindName]psr.CPTree, len(fragmentPatterns))
-	{
-		var cerrs []*CompileError
-		for kind, pat := range fragmentPatterns {
-			p := psr.NewParser(kind, bytes.NewReader(pat))
-			t, err := p.Parse()
-			if err != nil {
-				if err == psr.ParseErr {
-					detail, cause := p.Error()
-					cerrs = append(cerrs, &CompileError{
-						Kind:     kind,
-						Fragment: true,
-						Cause:    cause,
-						Detail:   detail,
-					})
-				} else {
-					cerrs = append(cerrs, &CompileError{
-						Kind:     kind,
-						Fragment: true,
-						Cause:    err,
-					})
-				}
-				continue
-			}
-			fragmentCPTrees[kind] = t
+		// Popping and pushing in one step is legal, but a specification author often sets both by mistake.
+		if e.Pop && e.Push != "" {
+			warnings = append(warnings, &CompileWarning{
+				Kind:     e.Kind,
+				Fragment: e.Fragment,
+				Cause:    fmt.Errorf("entry both pops and pushes a mode"),
+				Detail:   fmt.Sprintf("the lexer pops the current mode and then pushes %v mode, so %v mode replaces the current mode", e.Push, e.Push),
+			})
 		}
-		if len(cerrs) > 0 {
-			return nil, fmt.Errorf("compile error"), cerrs
-		}
-
-		err := psr.CompleteFragments(fragmentCPTrees)
-		if err != nil {
-			if err == psr.ParseErr {
-				for _, frag := range fragmentCPTrees {
-					kind, frags, err := frag.Describe()
-					if err != nil {
-						return nil, err, nil
-					}
-
-					cerrs = append(cerrs, &CompileError{
-						Kind:     kind,
-						Fragment: true,
-						Cause:    fmt.Errorf("fragment contains undefined fragments or cycles"),
-						Detail:   fmt.Sprintf("%v", frags),
-					})
-				}
-
-				return nil, fmt.Errorf("compile error"), cerrs
+		if e.Skip {
+			if skip == nil {
+				skip = make([]int, len(entries)+1)
 			}
-
-			return nil, err, nil
+			skip[i+1] = 1
 		}
 	}
 
 	cpTrees := map[spec.LexModeKindID]psr.CPTree{}
+	var anchors []spec.LexAnchor
+	var duplicates map[spec.LexModeKindID]struct{}
 	{
 		pats := make([]*psr.PatternEntry, len(patterns)+1)
 		pats[spec.LexModeKindIDNil] = &psr.PatternEntry{
@@ -267,6 +851,21 @@ func compile(
 				continue
 			}
 
+			// A literal pattern bypasses the parser because it contains no special characters.
+			if literals[pat.ID] {
+				t, err := psr.ParseLiteral(kindIDToName[pat.ID], pat.Pattern)
+				if err != nil {
+					cerrs = append(cerrs, &CompileError{
+						Kind:     kindIDToName[pat.ID],
+						Fragment: false,
+						Cause:    err,
+					})
+					continue
+				}
+				cpTrees[pat.ID] = t
+				continue
+			}
+
 			p := psr.NewParser(kindIDToName[pat.ID], bytes.NewReader(pat.Pattern))
 			t, err := p.Parse()
 			if err != nil {
@@ -277,6 +876,7 @@ func compile(
 						Fragment: false,
 						Cause:    cause,
 						Detail:   detail,
+						Col:      p.ErrorColumn(),
 					})
 				} else {
 					cerrs = append(cerrs, &CompileError{
@@ -288,14 +888,23 @@ func compile(
 				continue
 			}
 
-			complete, err := psr.ApplyFragments(t, fragmentCPTrees)
+			complete, err := psr.ApplyFragments(t, fragmentCPTrees, config.maxFragmentNodes)
 			if err != nil {
-				return nil, err, nil
+				if ferr, ok := err.(*psr.FragmentError); ok {
+					cerrs = append(cerrs, &CompileError{
+						Kind:     kindIDToName[pat.ID],
+						Fragment: false,
+						Cause:    ferr.Cause,
+						Detail:   fragmentErrorDetail(ferr, config),
+					})
+					continue
+				}
+				return nil, 0, warnings, err, nil
 			}
 			if !complete {
 				_, frags, err := t.Describe()
 				if err != nil {
-					return nil, err, nil
+					return nil, 0, warnings, err, nil
 				}
 
 				cerrs = append(cerrs, &CompileError{
@@ -307,37 +916,153 @@ func compile(
 				continue
 			}
 
+			anchor, err := t.Anchor()
+			if err != nil {
+				return nil, 0, warnings, err, nil
+			}
+			if anchor != spec.LexAnchorNil {
+				if anchors == nil {
+					anchors = make([]spec.LexAnchor, len(pats))
+				}
+				anchors[pat.ID] = anchor
+			}
+
 			cpTrees[pat.ID] = t
 		}
 		if len(cerrs) > 0 {
-			return nil, fmt.Errorf("compile error"), cerrs
+			return nil, 0, warnings, fmt.Errorf("compile error"), cerrs
+		}
+
+		// Of entries having identical patterns, only the one with the highest priority can match. Such entries are
+		// usually copy-paste mistakes.
+		fingerprint2ID := map[string]spec.LexModeKindID{}
+		duplicates = map[spec.LexModeKindID]struct{}{}
+		for _, pat := range pats {
+			t, ok := cpTrees[pat.ID]
+			if !ok {
+				continue
+			}
+			fp := psr.Fingerprint(t)
+			id, ok := fingerprint2ID[fp]
+			if !ok {
+				fingerprint2ID[fp] = pat.ID
+				continue
+			}
+			duplicates[pat.ID] = struct{}{}
+			cause := fmt.Errorf("pattern is identical to the pattern of another kind")
+			detail := fmt.Sprintf("the same as %v", kindIDToName[id])
+			if config.duplicatePatternsAsErrors {
+				cerrs = append(cerrs, &CompileError{
+					Kind:     kindIDToName[pat.ID],
+					Fragment: false,
+					Cause:    cause,
+					Detail:   detail,
+				})
+				continue
+			}
+			warnings = append(warnings, &CompileWarning{
+				Kind:     kindIDToName[pat.ID],
+				Fragment: false,
+				Cause:    cause,
+				Detail:   detail,
+			})
+		}
+		if len(cerrs) > 0 {
+			return nil, 0, warnings, fmt.Errorf("compile error"), cerrs
 		}
 	}
 
 	var tranTab *spec.TransitionTable
+	var removedStates int
 	{
-		root, symTab, err := dfa.ConvertCPTreeToByteTree(cpTrees)
+		var convOpts []dfa.ConvertOption
+		if config.foldContinuationBytes {
+			convOpts = append(convOpts, dfa.FoldContinuationBytes())
+		}
+		root, symTab, err := dfa.ConvertCPTreeToByteTree(cpTrees, convOpts...)
 		if err != nil {
-			return nil, err, nil
+			return nil, 0, warnings, err, nil
+		}
+		d := dfa.GenDFA(root, symTab, priorities)
+		shadowed := findShadowedKinds(d, anchors)
+		for _, id := range sortedModeKindIDs(shadowed) {
+			// The warning about identical patterns already covers them.
+			if _, ok := duplicates[id]; ok {
+				continue
+			}
+			var names []string
+			for _, winner := range shadowed[id] {
+				names = append(names, kindIDToName[winner].String())
+			}
+			warnings = append(warnings, &CompileWarning{
+				Kind:     kindIDToName[id],
+				Fragment: false,
+				Cause:    fmt.Errorf("pattern never matches because kinds having higher priorities match all its lexemes"),
+				Detail:   fmt.Sprintf("shadowed by %v", strings.Join(names, ", ")),
+			})
+		}
+		if config.minimize {
+			minimized := dfa.Minimize(d)
+			removedStates = len(d.States) - len(minimized.States)
+			d = minimized
 		}
-		d := dfa.GenDFA(root, symTab)
 		tranTab, err = dfa.GenTransitionTable(d)
 		if err != nil {
-			return nil, err, nil
+			return nil, 0, warnings, err, nil
+		}
+		// The driver needs the accepting candidates only to fall back from a kind whose anchors a lexeme
+		// doesn't satisfy.
+		if anchors == nil {
+			tranTab.AcceptingCandidates = nil
 		}
+		tranTab.PartialKinds = genPartialKinds(tranTab, priorities)
 	}
 
 	var err error
+	uncompressed := tranTab.UncompressedTransition
 	switch config.compLv {
 	case 2:
 		tranTab, err = compressTransitionTableLv2(tranTab)
 		if err != nil {
-			return nil, err, nil
+			return nil, 0, warnings, err, nil
 		}
 	case 1:
 		tranTab, err = compressTransitionTableLv1(tranTab)
 		if err != nil {
-			return nil, err, nil
+			return nil, 0, warnings, err, nil
+		}
+	}
+	if config.keepUncompressed {
+		tranTab.UncompressedTransition = uncompressed
+	}
+
+	// An error kind follows the kinds of the entries. It has no pattern, so the DFA never accepts it.
+	errorKindID := spec.LexModeKindIDNil
+	if errorKind != "" {
+		errorKindID = spec.LexModeKindID(len(kindNames))
+		kindNames = append(kindNames, errorKind)
+		push = append(push, spec.LexModeIDNil)
+		pop = append(pop, 0)
+		if skip != nil {
+			skip = append(skip, 0)
+		}
+		if anchors != nil {
+			anchors = append(anchors, spec.LexAnchorNil)
+		}
+	}
+
+	// Likewise, the EOF kind follows the error kind.
+	eofKindID := spec.LexModeKindIDNil
+	if eofKind != "" {
+		eofKindID = spec.LexModeKindID(len(kindNames))
+		kindNames = append(kindNames, eofKind)
+		push = append(push, spec.LexModeIDNil)
+		pop = append(pop, 0)
+		if skip != nil {
+			skip = append(skip, 0)
+		}
+		if anchors != nil {
+			anchors = append(anchors, spec.LexAnchorNil)
 		}
 	}
 
@@ -345,8 +1070,138 @@ func compile(
 		KindNames: kindNames,
 		Push:      push,
 		Pop:       pop,
+		Anchors:   anchors,
+		Skip:      skip,
+		ErrorKind: errorKindID,
+		EOFKind:   eofKindID,
 		DFA:       tranTab,
-	}, nil, nil
+	}, removedStates, warnings, nil, nil
+}
+
+// findShadowedKinds finds kinds that are accepted by some states of a DFA but never have the highest priority in any of
+// them. The lexer never returns such kinds because the kinds winning in those states match all their lexemes. The
+// result maps each shadowed kind to the winning kinds sorted by ID. A kind isn't shadowed in a state where a kind
+// having a higher priority has anchors because the lexer falls back when a lexeme doesn't satisfy the anchors. This is
+// a heuristic and doesn't detect kinds shadowed only partially.
+func findShadowedKinds(d *dfa.DFA, anchors []spec.LexAnchor) map[spec.LexModeKindID][]spec.LexModeKindID {
+	hasAnchor := func(id spec.LexModeKindID) bool {
+		return anchors != nil && anchors[id] != spec.LexAnchorNil
+	}
+	winners := map[spec.LexModeKindID]map[spec.LexModeKindID]struct{}{}
+	won := map[spec.LexModeKindID]struct{}{}
+	for _, state := range d.States {
+		winner, ok := d.AcceptingStatesTable[state]
+		if !ok {
+			continue
+		}
+		won[winner] = struct{}{}
+		// The candidates are sorted in descending order of priority, and the first one is the winner.
+		cands := d.AcceptingCandidatesTable[state]
+		anchored := false
+		for i, id := range cands {
+			if i == 0 {
+				anchored = hasAnchor(id)
+				continue
+			}
+			if anchored {
+				won[id] = struct{}{}
+			} else {
+				if winners[id] == nil {
+					winners[id] = map[spec.LexModeKindID]struct{}{}
+				}
+				winners[id][winner] = struct{}{}
+			}
+			anchored = anchored || hasAnchor(id)
+		}
+	}
+
+	shadowed := map[spec.LexModeKindID][]spec.LexModeKindID{}
+	for id, ws := range winners {
+		if _, ok := won[id]; ok {
+			continue
+		}
+		var ids []spec.LexModeKindID
+		for w := range ws {
+			ids = append(ids, w)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i] < ids[j]
+		})
+		shadowed[id] = ids
+	}
+	return shadowed
+}
+
+func sortedModeKindIDs(m map[spec.LexModeKindID][]spec.LexModeKindID) []spec.LexModeKindID {
+	var ids []spec.LexModeKindID
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// genPartialKinds finds, for each state, the kind with the highest priority among the kinds accepted by the states
+// reachable from the state. It visits kinds in descending order of priority and marks the states from which the
+// accepting states of each kind are reachable by walking the transitions backward.
+func genPartialKinds(tab *spec.TransitionTable, priorities map[spec.LexModeKindID]int) []spec.LexModeKindID {
+	preds := make([][]spec.StateID, tab.RowCount)
+	for from := spec.StateIDMin.Int(); from < tab.RowCount; from++ {
+		row := tab.UncompressedTransition[from*tab.ColCount : (from+1)*tab.ColCount]
+		seen := map[spec.StateID]struct{}{}
+		for _, to := range row {
+			if to == spec.StateIDNil {
+				continue
+			}
+			if _, ok := seen[to]; ok {
+				continue
+			}
+			seen[to] = struct{}{}
+			preds[to] = append(preds[to], spec.StateID(from))
+		}
+	}
+
+	kind2States := map[spec.LexModeKindID][]spec.StateID{}
+	for state := spec.StateIDMin.Int(); state < tab.RowCount; state++ {
+		if id := tab.AcceptingStates[state]; id != spec.LexModeKindIDNil {
+			kind2States[id] = append(kind2States[id], spec.StateID(state))
+		}
+		// A state may fall back to a candidate with lower priority when a lexeme doesn't satisfy anchors.
+		if tab.AcceptingCandidates != nil {
+			for _, id := range tab.AcceptingCandidates[state] {
+				kind2States[id] = append(kind2States[id], spec.StateID(state))
+			}
+		}
+	}
+	var kinds []spec.LexModeKindID
+	for id := range kind2States {
+		kinds = append(kinds, id)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if priorities[kinds[i]] != priorities[kinds[j]] {
+			return priorities[kinds[i]] < priorities[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+
+	partial := make([]spec.LexModeKindID, tab.RowCount)
+	for _, kind := range kinds {
+		queue := kind2States[kind]
+		for len(queue) > 0 {
+			state := queue[0]
+			queue = queue[1:]
+			if partial[state] != spec.LexModeKindIDNil {
+				continue
+			}
+			partial[state] = kind
+			queue = append(queue, preds[state]...)
+		}
+	}
+	partial[tab.InitialStateID] = spec.LexModeKindIDNil
+
+	return partial
 }
 
 const (
@@ -1,11 +1,17 @@
 package compiler
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
+	psr "github.com/nihei9/maleeni/compiler/parser"
 	"github.com/nihei9/maleeni/spec"
+	"gopkg.in/yaml.v3"
 )
 
 func TestCompile(t *testing.T) {
@@ -189,6 +195,291 @@ func TestCompile(t *testing.T) {
 }
 `,
 		},
+		{
+			Caption: "allow fragments to be referenced from multiple modes",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "a2z",
+            "pattern": "\\f{a2z}+",
+            "push": "other_mode"
+        },
+        {
+            "modes": ["other_mode"],
+            "kind": "a2z_other",
+            "pattern": "\\f{a2z}\\f{a2z}",
+            "pop": true
+        },
+        {
+            "fragment": true,
+            "kind": "a2z",
+            "pattern": "[a-z]"
+        }
+    ]
+}
+`,
+		},
+		{
+			Caption: "allow patterns to contain macros",
+			Spec: `
+{
+    "name": "test",
+    "macros": {
+        "digit": "[0-9]",
+        "digits": "${digit}+"
+    },
+    "entries": [
+        {
+            "kind": "decimal",
+            "pattern": "${digits}\\.${digits}"
+        }
+    ]
+}
+`,
+		},
+		{
+			Caption: "don't allow patterns to contain undefined macros",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "decimal",
+            "pattern": "${digits}"
+        }
+    ]
+}
+`,
+			Err: true,
+		},
+		{
+			Caption: "allow entries to be literals",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "literal",
+            "pattern": "(a.b*c|${x}",
+            "literal": true
+        }
+    ]
+}
+`,
+		},
+		{
+			Caption: "don't allow fragments to be literals",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "abc",
+            "pattern": "\\f{abc}"
+        },
+        {
+            "fragment": true,
+            "kind": "abc",
+            "pattern": "abc",
+            "literal": true
+        }
+    ]
+}
+`,
+			Err: true,
+		},
+		{
+			Caption: "don't allow fragments to be skipped",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "white_space",
+            "pattern": "\\f{ws}"
+        },
+        {
+            "fragment": true,
+            "kind": "ws",
+            "pattern": " +",
+            "skip": true
+        }
+    ]
+}
+`,
+			Err: true,
+		},
+		{
+			Caption: "don't allow fragments to have metadata",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "white_space",
+            "pattern": "\\f{ws}"
+        },
+        {
+            "fragment": true,
+            "kind": "ws",
+            "pattern": " +",
+            "meta": {
+                "category": "trivia"
+            }
+        }
+    ]
+}
+`,
+			Err: true,
+		},
+		{
+			Caption: "don't allow metadata keys to be invalid identifiers",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "white_space",
+            "pattern": " +",
+            "meta": {
+                "Category": "trivia"
+            }
+        }
+    ]
+}
+`,
+			Err: true,
+		},
+		{
+			Caption: "allow patterns to contain anchors",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "heading",
+            "pattern": "^#+ \\f{text}$"
+        },
+        {
+            "fragment": true,
+            "kind": "text",
+            "pattern": "[^\\u{000A}]*"
+        }
+    ]
+}
+`,
+		},
+		{
+			Caption: "allow patterns to contain negated fragments referring to character sets",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "non_vowels",
+            "pattern": "\\F{vowel}+"
+        },
+        {
+            "fragment": true,
+            "kind": "consonant_or_vowel",
+            "pattern": "\\F{vowel}|\\f{vowel}"
+        },
+        {
+            "fragment": true,
+            "kind": "vowel",
+            "pattern": "[aeiou]"
+        }
+    ]
+}
+`,
+		},
+		{
+			Caption: "don't allow negated fragments to refer to character sequences",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "not_foo",
+            "pattern": "\\F{foo}"
+        },
+        {
+            "fragment": true,
+            "kind": "foo",
+            "pattern": "foo"
+        }
+    ]
+}
+`,
+			Err: true,
+		},
+		{
+			Caption: "don't allow fragments to contain negated fragments referring to character sequences",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "a",
+            "pattern": "a"
+        },
+        {
+            "fragment": true,
+            "kind": "not_foo",
+            "pattern": "\\F{foo}"
+        },
+        {
+            "fragment": true,
+            "kind": "foo",
+            "pattern": "fo*"
+        }
+    ]
+}
+`,
+			Err: true,
+		},
+		{
+			Caption: "don't allow negated fragments to match no characters",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "nothing",
+            "pattern": "\\F{any}"
+        },
+        {
+            "fragment": true,
+            "kind": "any",
+            "pattern": "."
+        }
+    ]
+}
+`,
+			Err: true,
+		},
+		{
+			Caption: "don't allow fragments to contain anchors",
+			Spec: `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "heading",
+            "pattern": "#+ \\f{text}"
+        },
+        {
+            "fragment": true,
+            "kind": "text",
+            "pattern": "[^\\u{000A}]*$"
+        }
+    ]
+}
+`,
+			Err: true,
+		},
 	}
 	for i, tt := range tests {
 		t.Run(fmt.Sprintf("#%v %s", i, tt.Caption), func(t *testing.T) {
@@ -216,3 +507,1586 @@ func TestCompile(t *testing.T) {
 		})
 	}
 }
+
+func TestCompile_JSONAndYAMLAreEquivalent(t *testing.T) {
+	jsonSrc := `
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "string_open",
+            "pattern": "\"",
+            "push": "string"
+        },
+        {
+            "modes": ["string"],
+            "kind": "char_seq",
+            "pattern": "[^\"\\\\]+"
+        },
+        {
+            "modes": ["string"],
+            "kind": "string_close",
+            "pattern": "\"",
+            "pop": true
+        },
+        {
+            "kind": "id",
+            "pattern": "\\f{letter}+"
+        },
+        {
+            "fragment": true,
+            "kind": "letter",
+            "pattern": "[A-Za-z_]"
+        }
+    ]
+}
+`
+	yamlSrc := `
+name: test
+entries:
+  - kind: string_open
+    pattern: '"'
+    push: string
+  - modes: [string]
+    kind: char_seq
+    pattern: '[^"\\]+'
+  - modes: [string]
+    kind: string_close
+    pattern: '"'
+    pop: true
+  - kind: id
+    pattern: '\f{letter}+'
+  - fragment: true
+    kind: letter
+    pattern: '[A-Za-z_]'
+`
+
+	jsonSpec := &spec.LexSpec{}
+	err := json.Unmarshal([]byte(jsonSrc), jsonSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlSpec := &spec.LexSpec{}
+	err = yaml.Unmarshal([]byte(yamlSrc), yamlSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonCLSpec, err, _ := Compile(jsonSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlCLSpec, err, _ := Compile(yamlSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(jsonCLSpec, yamlCLSpec) {
+		t.Fatalf("compiled specifications must be identical")
+	}
+}
+
+func TestCompile_UnmatchablePattern(t *testing.T) {
+	tests := []struct {
+		caption  string
+		entry    *spec.LexEntry
+		fragment bool
+	}{
+		{
+			caption: "an inverse of all code points",
+			entry: &spec.LexEntry{
+				Kind:    "nothing",
+				Pattern: `[^\u{0000}-\u{10FFFF}]`,
+			},
+		},
+		{
+			caption: "an inverse of all code points split into multiple ranges",
+			entry: &spec.LexEntry{
+				Kind:    "nothing",
+				Pattern: `a|[^\u{0000}-\u{FFFF}\u{010000}-\u{10FFFF}]`,
+			},
+		},
+		{
+			caption: "a subtraction of all code points",
+			entry: &spec.LexEntry{
+				Kind:    "nothing",
+				Pattern: `[a-z-[\u{0000}-\u{10FFFF}]]`,
+			},
+		},
+		{
+			caption: "an inverse of all code points except surrogate ones",
+			entry: &spec.LexEntry{
+				Kind:    "nothing",
+				Pattern: `[^\u{0000}-\u{D7FF}\u{E000}-\u{10FFFF}]`,
+			},
+		},
+		{
+			caption: "an inverse of all code points in a fragment",
+			entry: &spec.LexEntry{
+				Kind:     "nothing",
+				Pattern:  `[^\u{0000}-\u{10FFFF}]`,
+				Fragment: true,
+			},
+			fragment: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			lspec := &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					{
+						Kind:    "word",
+						Pattern: `[a-z]+`,
+					},
+					tt.entry,
+				},
+			}
+			_, err, cerrs := Compile(lspec)
+			if err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if len(cerrs) != 1 {
+				t.Fatalf("unexpected compile errors: %v", cerrs)
+			}
+			cerr := cerrs[0]
+			if cerr.Kind != "nothing" || cerr.Fragment != tt.fragment || cerr.Cause == nil || cerr.Cause.Error() != "a pattern cannot match any characters" {
+				t.Fatalf("unexpected compile error: %+v", cerr)
+			}
+		})
+	}
+}
+
+func TestCompile_InverseAroundSurrogates(t *testing.T) {
+	// UTF-8 can't encode the surrogate code points <U+D800..U+DFFF>. These patterns leave ranges adjacent to them.
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:     "bmp_before_surrogates",
+				Pattern:  `[\u{0000}-\u{D7FF}]`,
+				Fragment: true,
+			},
+			{
+				Kind:    "inverse_bracket",
+				Pattern: `[^\u{0000}-\u{D7FF}]`,
+			},
+			{
+				Kind:    "negated_fragment",
+				Pattern: `#\F{bmp_before_surrogates}`,
+			},
+			{
+				Kind:    "subtraction",
+				Pattern: `%[\u{0000}-\u{10FFFF}--[\u{E000}-\u{10FFFF}]]`,
+			},
+			{
+				Kind:    "inverse_property",
+				Pattern: `!\P{General_Category=Co}`,
+			},
+		},
+	}
+	_, err, cerrs := Compile(lspec)
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v: %v", err, cerrs)
+	}
+}
+
+func TestCompile_RangeWithProperty(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		fragment bool
+	}{
+		{
+			pattern: `[a-\p{Lu}]`,
+		},
+		{
+			pattern: `[\p{Lu}-z]`,
+		},
+		{
+			pattern: `[^a-\p{Lu}]`,
+		},
+		{
+			pattern: `[^\p{Lu}-z]`,
+		},
+		{
+			pattern:  `[a-\p{Lu}]`,
+			fragment: true,
+		},
+		{
+			pattern:  `[\p{Lu}-z]`,
+			fragment: true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("#%v %v", i, tt.pattern), func(t *testing.T) {
+			lspec := &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					{
+						Kind:    "word",
+						Pattern: `[a-z]+`,
+					},
+					{
+						Kind:     "range",
+						Pattern:  spec.LexPattern(tt.pattern),
+						Fragment: tt.fragment,
+					},
+				},
+			}
+			_, err, cerrs := Compile(lspec)
+			if err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if len(cerrs) != 1 {
+				t.Fatalf("unexpected compile errors: %v", cerrs)
+			}
+			cerr := cerrs[0]
+			if cerr.Kind != "range" || cerr.Fragment != tt.fragment || cerr.Cause == nil || cerr.Cause.Error() != "a property expression is unavailable in a range expression" {
+				t.Fatalf("unexpected compile error: %+v", cerr)
+			}
+		})
+	}
+}
+
+func TestCompile_ErrorColumn(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "foo",
+				Pattern: "foo)",
+			},
+			{
+				Kind:     "bar",
+				Pattern:  "b(ar",
+				Fragment: true,
+			},
+		},
+	}
+	_, err, cerrs := Compile(lspec)
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if len(cerrs) != 1 {
+		t.Fatalf("unexpected compile errors: %v", cerrs)
+	}
+	if !cerrs[0].Fragment || cerrs[0].Col != 5 {
+		t.Fatalf("unexpected compile error: %+v", cerrs[0])
+	}
+
+	lspec.Entries = lspec.Entries[:1]
+	_, err, cerrs = Compile(lspec)
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if len(cerrs) != 1 {
+		t.Fatalf("unexpected compile errors: %v", cerrs)
+	}
+	if cerrs[0].Fragment || cerrs[0].Col != 4 {
+		t.Fatalf("unexpected compile error: %+v", cerrs[0])
+	}
+}
+
+func TestCompile_KindIDsAreStable(t *testing.T) {
+	newSpec := func() *spec.LexSpec {
+		return &spec.LexSpec{
+			Name: "test",
+			Entries: []*spec.LexEntry{
+				{
+					Kind:    "white_space",
+					Pattern: "( |\t)+",
+					Modes:   []spec.LexModeName{"default", "string", "comment"},
+				},
+				{
+					Kind:    "string_open",
+					Pattern: "\"",
+					Push:    "string",
+				},
+				{
+					Kind:    "comment_open",
+					Pattern: "/\\*",
+					Push:    "comment",
+				},
+				{
+					Kind:    "char_seq",
+					Pattern: "[^\"]+",
+					Modes:   []spec.LexModeName{"string"},
+				},
+				{
+					Kind:    "string_close",
+					Pattern: "\"",
+					Modes:   []spec.LexModeName{"string"},
+					Pop:     true,
+				},
+				{
+					Kind:    "comment_close",
+					Pattern: "\\*/",
+					Modes:   []spec.LexModeName{"comment"},
+					Pop:     true,
+				},
+				{
+					Kind:    "id",
+					Pattern: "[a-z]+",
+				},
+			},
+		}
+	}
+
+	first, err, _ := Compile(newSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Kinds are numbered by mode ID and then by entry order, so white_space, which appears in all modes, gets the
+	// smallest ID and the kinds of the default mode precede the kinds of the other modes.
+	expectedKindNames := []spec.LexKindName{
+		spec.LexKindNameNil,
+		"white_space",
+		"string_open",
+		"comment_open",
+		"id",
+		"char_seq",
+		"string_close",
+		"comment_close",
+	}
+	if !reflect.DeepEqual(first.KindNames, expectedKindNames) {
+		t.Fatalf("unexpected kind names: want: %v, got: %v", expectedKindNames, first.KindNames)
+	}
+
+	for i := 0; i < 10; i++ {
+		clspec, err, _ := Compile(newSpec())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(clspec.KindNames, first.KindNames) {
+			t.Fatalf("kind names must be identical: want: %v, got: %v", first.KindNames, clspec.KindNames)
+		}
+		if !reflect.DeepEqual(clspec.KindIDs, first.KindIDs) {
+			t.Fatalf("kind IDs must be identical: want: %v, got: %v", first.KindIDs, clspec.KindIDs)
+		}
+	}
+
+	// Adding a kind to the default mode shifts the IDs of the kinds that first appear in later modes even if the entry
+	// is appended to the end of the specification.
+	lspec := newSpec()
+	lspec.Entries = append(lspec.Entries, &spec.LexEntry{
+		Kind:    "int",
+		Pattern: "[0-9]+",
+	})
+	clspec, err, _ := Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedKindNames = []spec.LexKindName{
+		spec.LexKindNameNil,
+		"white_space",
+		"string_open",
+		"comment_open",
+		"id",
+		"int",
+		"char_seq",
+		"string_close",
+		"comment_close",
+	}
+	if !reflect.DeepEqual(clspec.KindNames, expectedKindNames) {
+		t.Fatalf("unexpected kind names: want: %v, got: %v", expectedKindNames, clspec.KindNames)
+	}
+}
+
+func TestCompile_Cache(t *testing.T) {
+	newSpec := func(charSeqPattern string) *spec.LexSpec {
+		return &spec.LexSpec{
+			Name: "test",
+			Entries: []*spec.LexEntry{
+				{
+					Kind:    "string_open",
+					Pattern: "\"",
+					Push:    "string",
+				},
+				{
+					Kind:    "id",
+					Pattern: "\\f{letter}+",
+				},
+				{
+					Kind:    "char_seq",
+					Pattern: spec.LexPattern(charSeqPattern),
+					Modes:   []spec.LexModeName{"string"},
+				},
+				{
+					Kind:    "string_close",
+					Pattern: "\"",
+					Modes:   []spec.LexModeName{"string"},
+					Pop:     true,
+				},
+				{
+					Kind:     "letter",
+					Pattern:  "[A-Za-z_]",
+					Fragment: true,
+				},
+			},
+		}
+	}
+
+	orig, err, _ := Compile(newSpec("[^\"]+"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The compiler must be able to use a cache read from a file.
+	var cache *spec.CompiledLexSpec
+	{
+		data, err := json.Marshal(orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache = &spec.CompiledLexSpec{}
+		err = json.Unmarshal(data, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clspec, err, _ := Compile(newSpec("[^\"\\\\]+"), Cache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaultMode := clspec.Specs[spec.LexModeIDDefault]
+	if defaultMode != cache.Specs[spec.LexModeIDDefault] {
+		t.Fatalf("the default mode must be reused")
+	}
+	cachedDFA, err := json.Marshal(orig.Specs[spec.LexModeIDDefault].DFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dfa, err := json.Marshal(defaultMode.DFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dfa, cachedDFA) {
+		t.Fatalf("the DFA of the default mode must be identical to the cached one")
+	}
+
+	stringMode := clspec.Specs[2]
+	if stringMode == cache.Specs[2] {
+		t.Fatalf("the string mode must be rebuilt")
+	}
+	if stringMode.InputHash == cache.Specs[2].InputHash {
+		t.Fatalf("the input hash of the string mode must change")
+	}
+
+	// The result must be the same as compiling without the cache.
+	noCache, err, _ := Compile(newSpec("[^\"\\\\]+"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(clspec, noCache) {
+		t.Fatalf("compiling with a cache must yield the same specification as compiling without it")
+	}
+
+	// Changing a fragment or the compression level invalidates all modes.
+	for _, opt := range []CompilerOption{CompressionLevel(1), CompressionLevel(2)} {
+		clspec, err, _ := Compile(newSpec("[^\"]+"), Cache(cache), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, s := range clspec.Specs[1:] {
+			if s == cache.Specs[id+1] {
+				t.Fatalf("mode %v must be rebuilt", id+1)
+			}
+		}
+	}
+	lspec := newSpec("[^\"]+")
+	lspec.Entries[4].Pattern = "[A-Za-z]"
+	clspec, err, _ = Compile(lspec, Cache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, s := range clspec.Specs[1:] {
+		if s == cache.Specs[id+1] {
+			t.Fatalf("mode %v must be rebuilt", id+1)
+		}
+	}
+
+	// A cache in another format version invalidates all modes.
+	for _, version := range []int{0, spec.CompiledLexSpecFormatVersion - 1, spec.CompiledLexSpecFormatVersion + 1} {
+		oldCache := *cache
+		oldCache.FormatVersion = version
+		clspec, err, _ := Compile(newSpec("[^\"]+"), Cache(&oldCache))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, s := range clspec.Specs[1:] {
+			if s == cache.Specs[id+1] {
+				t.Fatalf("mode %v must be rebuilt when the cache has format version %v", id+1, version)
+			}
+		}
+		if clspec.FormatVersion != spec.CompiledLexSpecFormatVersion {
+			t.Fatalf("unexpected format version; want: %v, got: %v", spec.CompiledLexSpecFormatVersion, clspec.FormatVersion)
+		}
+	}
+}
+
+func TestCompile_CacheWarnings(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{Kind: "foo", Pattern: "foo"},
+			{Kind: "foo_again", Pattern: "foo"},
+			{Kind: "word", Pattern: "[a-z]+"},
+			{Kind: "if", Pattern: "if"},
+			{Kind: "go_b", Pattern: "#", Pop: true, Push: "b"},
+			{Kind: "c", Pattern: "c", Modes: []spec.LexModeName{"b"}},
+		},
+	}
+	warningsToStrings := func(ws []*CompileWarning) []string {
+		var s []string
+		for _, w := range ws {
+			s = append(s, fmt.Sprintf("%v: %v: %v", w.Kind, w.Cause, w.Detail))
+		}
+		return s
+	}
+
+	orig := CompileWithResult(lspec)
+	if orig.Err != nil {
+		t.Fatal(orig.Err)
+	}
+	expected := warningsToStrings(orig.Warnings)
+	if len(expected) != 3 {
+		t.Fatalf("unexpected warnings: %v", expected)
+	}
+
+	// The warnings must survive a cache read from a file.
+	var cache *spec.CompiledLexSpec
+	{
+		data, err := json.Marshal(orig.Spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache = &spec.CompiledLexSpec{}
+		err = json.Unmarshal(data, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := CompileWithResult(lspec, Cache(cache))
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	for id, s := range r.Spec.Specs[1:] {
+		if s != cache.Specs[id+1] {
+			t.Fatalf("mode %v must be reused", id+1)
+		}
+	}
+	actual := warningsToStrings(r.Warnings)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("the compiler must report the warnings about the reused modes again;\nwant: %v\ngot: %v", expected, actual)
+	}
+}
+
+func TestCompile_LiteralPattern(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "literal",
+				Pattern: "a.b*c",
+				Literal: true,
+			},
+			{
+				Kind:    "regexp",
+				Pattern: "a.b*c",
+			},
+		},
+	}
+	clspec, err, _ := Compile(lspec, CompressionLevel(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input  string
+		kindID spec.LexModeKindID
+	}{
+		{
+			input:  "a.b*c",
+			kindID: 1,
+		},
+		{
+			input:  "a.bbc",
+			kindID: 2,
+		},
+		{
+			input:  "axc",
+			kindID: 2,
+		},
+		{
+			input:  "a.b*",
+			kindID: spec.LexModeKindIDNil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			kindID := runDFA(clspec.Specs[spec.LexModeIDDefault].DFA, []byte(tt.input))
+			if kindID != tt.kindID {
+				t.Fatalf("unexpected kind: want: %v, got: %v", tt.kindID, kindID)
+			}
+		})
+	}
+}
+
+// runDFA returns a kind that an uncompressed DFA accepts after reading all of the input.
+func runDFA(tab *spec.TransitionTable, input []byte) spec.LexModeKindID {
+	state := tab.InitialStateID
+	for _, b := range input {
+		state = tab.UncompressedTransition[state.Int()*tab.ColCount+int(b)]
+		if state == spec.StateIDNil {
+			return spec.LexModeKindIDNil
+		}
+	}
+	return tab.AcceptingStates[state]
+}
+
+func BenchmarkCompile_CharProp(b *testing.B) {
+	for _, pattern := range []string{
+		`\p{Letter}`,
+		`[^\p{Letter}]`,
+		`\p{Lu}`,
+		`[\p{L}\p{Nd}]`,
+		`[\p{Lu}\p{Ll}\p{Lt}\p{Lm}\p{Lo}]`,
+	} {
+		b.Run(pattern, func(b *testing.B) {
+			lspec := &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					{
+						Kind:    "a",
+						Pattern: spec.LexPattern(pattern),
+					},
+				},
+			}
+			var rowCount int
+			for i := 0; i < b.N; i++ {
+				clspec, err, _ := Compile(lspec, CompressionLevel(0))
+				if err != nil {
+					b.Fatal(err)
+				}
+				rowCount = clspec.Specs[spec.LexModeIDDefault].DFA.RowCount
+			}
+			b.ReportMetric(float64(rowCount), "rows")
+		})
+	}
+}
+
+// BenchmarkCompile_LongLiteral compiles long literal patterns, which become deep chains of concatenations. The time per
+// byte of a pattern should stay constant as the pattern grows.
+func BenchmarkCompile_LongLiteral(b *testing.B) {
+	for _, n := range []int{1000, 4000, 16000} {
+		b.Run(fmt.Sprintf("%v bytes", n), func(b *testing.B) {
+			lspec := &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					{
+						Kind:    "a",
+						Pattern: spec.LexPattern(strings.Repeat("abcdefgh", n/8)),
+						Literal: true,
+					},
+				},
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err, _ := Compile(lspec, CompressionLevel(0))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileWithResult(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "foo",
+				Pattern: "foo",
+			},
+		},
+	}
+	r := CompileWithResult(lspec)
+	if r.Err != nil {
+		t.Fatalf("unexpected error occurred: %v", r.Err)
+	}
+	if r.Spec == nil || r.Spec.Name != "test" {
+		t.Fatalf("unexpected compiled spec: %+v", r.Spec)
+	}
+	if len(r.Errors) != 0 || len(r.Warnings) != 0 {
+		t.Fatalf("unexpected errors or warnings: %v, %v", r.Errors, r.Warnings)
+	}
+
+	lspec.Entries[0].Pattern = "foo)"
+	r = CompileWithResult(lspec)
+	if r.Err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if r.Spec != nil {
+		t.Fatalf("compiled spec must be nil: %+v", r.Spec)
+	}
+	if len(r.Errors) != 1 || r.Errors[0].Kind != "foo" {
+		t.Fatalf("unexpected compile errors: %v", r.Errors)
+	}
+
+	clspec, err, cerrs := Compile(lspec)
+	if clspec != nil || err == nil || !reflect.DeepEqual(cerrs, r.Errors) {
+		t.Fatalf("Compile must return the same result as CompileWithResult: %v, %v, %v", clspec, err, cerrs)
+	}
+}
+
+func TestCompile_SharedTransitionRows(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "white_space",
+				Pattern: "[\\u{0009}\\u{0020}]+",
+				Modes:   []spec.LexModeName{"default", "string", "comment"},
+			},
+			{
+				Kind:    "string_open",
+				Pattern: "\"",
+				Push:    "string",
+			},
+			{
+				Kind:    "comment_open",
+				Pattern: "/\\*",
+				Push:    "comment",
+			},
+			{
+				Kind:    "word",
+				Pattern: "[a-z]+",
+			},
+			{
+				Kind:    "string_close",
+				Pattern: "\"",
+				Modes:   []spec.LexModeName{"string"},
+				Pop:     true,
+			},
+			{
+				Kind:    "char_seq",
+				Pattern: "[^\"\\u{0009}\\u{0020}]+",
+				Modes:   []spec.LexModeName{"string"},
+			},
+			{
+				Kind:    "comment_close",
+				Pattern: "\\*/",
+				Modes:   []spec.LexModeName{"comment"},
+				Pop:     true,
+			},
+		},
+	}
+	for _, compLv := range []int{1, 2} {
+		t.Run(fmt.Sprintf("compression level %v", compLv), func(t *testing.T) {
+			clspec, err, _ := Compile(lspec, CompressionLevel(compLv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clspec.SharedTransition == nil {
+				t.Fatalf("the modes must share transition rows")
+			}
+
+			// findCachedModeSpec gives each mode its own copy of the rows, so we use it to build the spec that
+			// the compiler would generate without sharing.
+			unshared := *clspec
+			unshared.SharedTransition = nil
+			unshared.Specs = []*spec.CompiledLexModeSpec{nil}
+			for id, modeSpec := range clspec.Specs[1:] {
+				ms, err := findCachedModeSpec(clspec, clspec.ModeNames[id+1], modeSpec.InputHash)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ms.DFA.Transition.UniqueEntries == nil && ms.DFA.Transition.UncompressedUniqueEntries == nil {
+					t.Fatalf("a mode taken from a cache must have its own transition rows")
+				}
+				unshared.Specs = append(unshared.Specs, ms)
+			}
+
+			unsharedSize := 0
+			for _, modeSpec := range unshared.Specs[1:] {
+				unsharedSize += uniqueEntriesTableSize(modeSpec.DFA.Transition)
+			}
+			sharedSize := uniqueEntriesTableSize(clspec.SharedTransition)
+			if sharedSize >= unsharedSize {
+				t.Fatalf("shared rows must be smaller than rows of each mode; shared: %v, unshared: %v", sharedSize, unsharedSize)
+			}
+			sharedJSON, err := json.Marshal(clspec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unsharedJSON, err := json.Marshal(&unshared)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sharedJSON) >= len(unsharedJSON) {
+				t.Fatalf("shared rows must make the output smaller; shared: %v bytes, unshared: %v bytes", len(sharedJSON), len(unsharedJSON))
+			}
+
+			// Both specs must describe the same transitions.
+			for id := range clspec.Specs[1:] {
+				sharedRows := decodeUniqueRows(clspec.SharedTransition, compLv)
+				sharedTran := clspec.Specs[id+1].DFA.Transition
+				ownTran := unshared.Specs[id+1].DFA.Transition
+				ownRows := decodeUniqueRows(ownTran, compLv)
+				for state := range sharedTran.RowNums {
+					if !reflect.DeepEqual(sharedRows[sharedTran.RowNums[state]], ownRows[ownTran.RowNums[state]]) {
+						t.Fatalf("transitions of state %v in mode %v differ", state, clspec.ModeNames[id+1])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompile_InitialMode(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "foo",
+				Pattern: "foo",
+			},
+			{
+				Kind:    "bar",
+				Pattern: "bar",
+				Modes:   []spec.LexModeName{"bar_mode"},
+			},
+		},
+	}
+	clspec, err, _ := Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clspec.InitialModeID != spec.LexModeIDDefault {
+		t.Fatalf("the initial mode must be the default mode by default: %v", clspec.InitialModeID)
+	}
+
+	lspec.InitialMode = "bar_mode"
+	clspec, err, _ = Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clspec.ModeNames[clspec.InitialModeID] != "bar_mode" {
+		t.Fatalf("unexpected initial mode: %v", clspec.ModeNames[clspec.InitialModeID])
+	}
+
+	lspec.InitialMode = "baz_mode"
+	_, err, _ = Compile(lspec)
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+}
+
+func TestCompile_MaxFragmentExpansion(t *testing.T) {
+	// Each fragment refers to the previous one twice, so the pattern doubles in size at every link of the chain.
+	genSpec := func(chainLen int) *spec.LexSpec {
+		entries := []*spec.LexEntry{
+			{
+				Kind:     "f0",
+				Pattern:  "a",
+				Fragment: true,
+			},
+		}
+		for i := 1; i <= chainLen; i++ {
+			entries = append(entries, &spec.LexEntry{
+				Kind:     spec.LexKindName(fmt.Sprintf("f%v", i)),
+				Pattern:  spec.LexPattern(fmt.Sprintf("\\f{f%v}\\f{f%v}", i-1, i-1)),
+				Fragment: true,
+			})
+		}
+		entries = append(entries, &spec.LexEntry{
+			Kind:    "pattern",
+			Pattern: spec.LexPattern(fmt.Sprintf("\\f{f%v}", chainLen)),
+		})
+		return &spec.LexSpec{
+			Name:    "test",
+			Entries: entries,
+		}
+	}
+
+	_, err, cerrs := Compile(genSpec(64))
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if len(cerrs) != 1 || !cerrs[0].Fragment || cerrs[0].Cause != psr.SemErrFragmentExpansionTooLarge {
+		t.Fatalf("unexpected compile errors: %v", cerrs)
+	}
+
+	// A chain of 5 links expands into 126 nodes, which consist of 32 symbols, 31 concatenations, and 63 fragment nodes.
+	_, err, _ = Compile(genSpec(5), MaxFragmentExpansion(126))
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+	_, err, cerrs = Compile(genSpec(5), MaxFragmentExpansion(125))
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if len(cerrs) != 1 || cerrs[0].Cause != psr.SemErrFragmentExpansionTooLarge {
+		t.Fatalf("unexpected compile errors: %v", cerrs)
+	}
+
+	// The limit also applies to a pattern referring to fragments.
+	lspec := genSpec(3)
+	lspec.Entries[len(lspec.Entries)-1].Pattern = "\\f{f3}\\f{f3}\\f{f3}"
+	_, err, cerrs = Compile(lspec, MaxFragmentExpansion(60))
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if len(cerrs) != 1 || cerrs[0].Fragment || cerrs[0].Kind != "pattern" || cerrs[0].Cause != psr.SemErrFragmentExpansionTooLarge {
+		t.Fatalf("unexpected compile errors: %v", cerrs)
+	}
+}
+
+func TestCompile_KeepUncompressedTransition(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "white_space",
+				Pattern: "[\\u{0009}\\u{0020}]+",
+				Modes:   []spec.LexModeName{"default", "string"},
+			},
+			{
+				Kind:    "string_open",
+				Pattern: "\"",
+				Push:    "string",
+			},
+			{
+				Kind:    "word",
+				Pattern: "[a-z]+",
+			},
+			{
+				Kind:    "string_close",
+				Pattern: "\"",
+				Modes:   []spec.LexModeName{"string"},
+				Pop:     true,
+			},
+			{
+				Kind:    "char",
+				Pattern: "[^\"\\u{0009}\\u{0020}]",
+				Modes:   []spec.LexModeName{"string"},
+			},
+		},
+	}
+
+	for compLv := CompressionLevelMin; compLv <= CompressionLevelMax; compLv++ {
+		t.Run(fmt.Sprintf("compression level %v", compLv), func(t *testing.T) {
+			clspec, err, _ := Compile(lspec, CompressionLevel(compLv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, modeSpec := range clspec.Specs {
+				if id == spec.LexModeIDNil.Int() {
+					continue
+				}
+				if compLv > CompressionLevelMin && modeSpec.DFA.UncompressedTransition != nil {
+					t.Fatalf("the uncompressed transition must be discarded by default")
+				}
+			}
+
+			clspec, err, _ = Compile(lspec, CompressionLevel(compLv), KeepUncompressedTransition())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, modeSpec := range clspec.Specs {
+				if id == spec.LexModeIDNil.Int() {
+					continue
+				}
+				tab := modeSpec.DFA
+				if len(tab.UncompressedTransition) != tab.RowCount*tab.ColCount {
+					t.Fatalf("unexpected size of the uncompressed transition; want: %v, got: %v", tab.RowCount*tab.ColCount, len(tab.UncompressedTransition))
+				}
+				if compLv == CompressionLevelMin {
+					continue
+				}
+				if tab.Transition == nil {
+					t.Fatalf("the compressed transition must be populated")
+				}
+				uniqueEntries := tab.Transition
+				if clspec.SharedTransition != nil {
+					uniqueEntries = clspec.SharedTransition
+				}
+				rows := decodeUniqueRows(uniqueEntries, compLv)
+				for state, rowNum := range tab.Transition.RowNums {
+					expected := tab.UncompressedTransition[state*tab.ColCount : (state+1)*tab.ColCount]
+					if !reflect.DeepEqual(rows[rowNum], expected) {
+						t.Fatalf("the compressed transition doesn't match the uncompressed one; state: %v\nwant: %v\ngot: %v", state, expected, rows[rowNum])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompile_DuplicatePatterns(t *testing.T) {
+	tests := []struct {
+		caption    string
+		entries    []*spec.LexEntry
+		duplicates []spec.LexKindName
+	}{
+		{
+			caption: "the compiler detects exactly duplicate patterns",
+			entries: []*spec.LexEntry{
+				{Kind: "foo", Pattern: "[a-z]+"},
+				{Kind: "bar", Pattern: "[a-z]+"},
+				{Kind: "baz", Pattern: "[0-9]+"},
+			},
+			duplicates: []spec.LexKindName{"bar"},
+		},
+		{
+			caption: "the compiler compares patterns after expanding fragments",
+			entries: []*spec.LexEntry{
+				{Kind: "digit", Pattern: "[0-9]", Fragment: true},
+				{Kind: "foo", Pattern: "\\f{digit}+"},
+				{Kind: "bar", Pattern: "[0-9]+"},
+			},
+			duplicates: []spec.LexKindName{"bar"},
+		},
+		{
+			caption: "the compiler compares a literal pattern with a normal one",
+			entries: []*spec.LexEntry{
+				{Kind: "foo", Pattern: "a.b", Literal: true},
+				{Kind: "bar", Pattern: "a\\.b"},
+			},
+			duplicates: []spec.LexKindName{"bar"},
+		},
+		{
+			caption: "patterns in different modes are not duplicates",
+			entries: []*spec.LexEntry{
+				{Kind: "open", Pattern: "\"", Push: "string"},
+				{Kind: "close", Pattern: "\"", Modes: []spec.LexModeName{"string"}, Pop: true},
+			},
+		},
+		{
+			caption: "distinct patterns are not duplicates",
+			entries: []*spec.LexEntry{
+				{Kind: "foo", Pattern: "[a-z]+"},
+				{Kind: "bar", Pattern: "[a-z]*"},
+				{Kind: "baz", Pattern: "^[a-z]+"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			lspec := &spec.LexSpec{
+				Name:    "test",
+				Entries: tt.entries,
+			}
+
+			r := CompileWithResult(lspec)
+			if r.Err != nil {
+				t.Fatalf("unexpected error occurred: %v", r.Err)
+			}
+			var warned []spec.LexKindName
+			for _, w := range r.Warnings {
+				// Kinds shadowed by other kinds are reported as different warnings.
+				if !strings.HasPrefix(w.Detail, "the same as ") {
+					continue
+				}
+				warned = append(warned, w.Kind)
+			}
+			if !reflect.DeepEqual(warned, tt.duplicates) {
+				t.Fatalf("unexpected warnings; want: %v, got: %v", tt.duplicates, warned)
+			}
+
+			r = CompileWithResult(lspec, DuplicatePatternsAsErrors())
+			if len(tt.duplicates) == 0 {
+				if r.Err != nil {
+					t.Fatalf("unexpected error occurred: %v", r.Err)
+				}
+				return
+			}
+			if r.Err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			var failed []spec.LexKindName
+			for _, cerr := range r.Errors {
+				failed = append(failed, cerr.Kind)
+			}
+			if !reflect.DeepEqual(failed, tt.duplicates) {
+				t.Fatalf("unexpected errors; want: %v, got: %v", tt.duplicates, failed)
+			}
+		})
+	}
+}
+
+func TestCompile_SpellingInconsistencies(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{Kind: "utf_8", Pattern: "utf-8"},
+			{Kind: "utf8", Pattern: "utf8"},
+			{Kind: "ascii", Pattern: "ascii"},
+		},
+	}
+
+	// By default, the compiler reports spelling inconsistencies as errors.
+	for _, opts := range [][]CompilerOption{
+		nil,
+		{SpellingInconsistencies(SeverityError)},
+	} {
+		r := CompileWithResult(lspec, opts...)
+		if r.Err == nil {
+			t.Fatalf("expected error didn't occur")
+		}
+		if !strings.Contains(r.Err.Error(), "utf8, utf_8") {
+			t.Fatalf("unexpected error: %v", r.Err)
+		}
+	}
+
+	r := CompileWithResult(lspec, SpellingInconsistencies(SeverityWarning))
+	if r.Err != nil {
+		t.Fatalf("unexpected error occurred: %v", r.Err)
+	}
+	if len(r.Warnings) != 1 {
+		t.Fatalf("unexpected warnings: %v", r.Warnings)
+	}
+	if w := r.Warnings[0]; w.Kind != "" || !strings.Contains(w.Cause.Error(), "utf8, utf_8") {
+		t.Fatalf("unexpected warning: %v", w.Cause)
+	}
+
+	r = CompileWithResult(lspec, SpellingInconsistencies(SeverityIgnore))
+	if r.Err != nil {
+		t.Fatalf("unexpected error occurred: %v", r.Err)
+	}
+	if len(r.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", r.Warnings)
+	}
+	if !reflect.DeepEqual(r.Spec.KindNameSet(), map[string]struct{}{
+		"utf_8": {},
+		"utf8":  {},
+		"ascii": {},
+	}) {
+		t.Fatalf("unexpected kinds: %v", r.Spec.KindNames)
+	}
+
+	// The option doesn't relax the other checks.
+	invalid := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{Kind: "utf_8", Pattern: "utf-8"},
+			{Kind: "utf_8", Pattern: "utf8"},
+		},
+	}
+	r = CompileWithResult(invalid, SpellingInconsistencies(SeverityIgnore))
+	if r.Err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+
+	_, err, _ := Compile(lspec, SpellingInconsistencies(Severity(3)))
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+}
+
+func TestCompile_ShadowedKinds(t *testing.T) {
+	highest := 0
+	tests := []struct {
+		caption  string
+		entries  []*spec.LexEntry
+		shadowed map[spec.LexKindName]string
+	}{
+		{
+			caption: "an identifier rule shadows a keyword having a lower priority",
+			entries: []*spec.LexEntry{
+				{Kind: "identifier", Pattern: "[a-z]+"},
+				{Kind: "kw_if", Pattern: "if"},
+			},
+			shadowed: map[spec.LexKindName]string{
+				"kw_if": "shadowed by identifier",
+			},
+		},
+		{
+			caption: "a keyword having a higher priority isn't shadowed",
+			entries: []*spec.LexEntry{
+				{Kind: "kw_if", Pattern: "if"},
+				{Kind: "identifier", Pattern: "[a-z]+"},
+			},
+		},
+		{
+			caption: "a priority overrides the order of entries",
+			entries: []*spec.LexEntry{
+				{Kind: "identifier", Pattern: "[a-z]+"},
+				{Kind: "kw_if", Pattern: "if", Priority: &highest},
+			},
+		},
+		{
+			caption: "multiple kinds can shadow a kind together",
+			entries: []*spec.LexEntry{
+				{Kind: "lower", Pattern: "[a-z]+"},
+				{Kind: "upper", Pattern: "[A-Z]+"},
+				{Kind: "kw", Pattern: "if|IF"},
+			},
+			shadowed: map[spec.LexKindName]string{
+				"kw": "shadowed by lower, upper",
+			},
+		},
+		{
+			caption: "a kind matching some lexemes that the others don't match isn't shadowed",
+			entries: []*spec.LexEntry{
+				{Kind: "identifier", Pattern: "[a-z]+"},
+				{Kind: "snake_case", Pattern: "[a-z_]+"},
+			},
+		},
+		{
+			caption: "a kind isn't shadowed by an anchored kind because the lexer can fall back to it",
+			entries: []*spec.LexEntry{
+				{Kind: "label", Pattern: "^[a-z]+"},
+				{Kind: "identifier", Pattern: "[a-z]+"},
+			},
+		},
+		{
+			caption: "kinds in different modes don't shadow each other",
+			entries: []*spec.LexEntry{
+				{Kind: "identifier", Pattern: "[a-z]+", Push: "m"},
+				{Kind: "kw_if", Pattern: "if", Modes: []spec.LexModeName{"m"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			lspec := &spec.LexSpec{
+				Name:    "test",
+				Entries: tt.entries,
+			}
+			r := CompileWithResult(lspec)
+			if r.Err != nil {
+				t.Fatalf("unexpected error occurred: %v", r.Err)
+			}
+			shadowed := map[spec.LexKindName]string{}
+			for _, w := range r.Warnings {
+				shadowed[w.Kind] = w.Detail
+			}
+			if len(tt.shadowed) == 0 && len(shadowed) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(shadowed, tt.shadowed) {
+				t.Fatalf("unexpected warnings; want: %v, got: %v", tt.shadowed, shadowed)
+			}
+		})
+	}
+}
+
+func TestCompile_PopAndPush(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{Kind: "tag_open", Pattern: "<", Push: "tag"},
+			{Kind: "name", Pattern: "[a-z]+", Modes: []spec.LexModeName{"tag"}},
+			{Kind: "attrs_open", Pattern: " ", Modes: []spec.LexModeName{"tag"}, Pop: true, Push: "attrs"},
+			{Kind: "tag_close", Pattern: ">", Modes: []spec.LexModeName{"tag", "attrs"}, Pop: true},
+			{Kind: "attr", Pattern: "[a-z]+=[a-z]+", Modes: []spec.LexModeName{"attrs"}},
+		},
+	}
+	r := CompileWithResult(lspec)
+	if r.Err != nil {
+		t.Fatalf("unexpected error occurred: %v", r.Err)
+	}
+	var warnings []*CompileWarning
+	for _, w := range r.Warnings {
+		if strings.Contains(w.Cause.Error(), "pops and pushes") {
+			warnings = append(warnings, w)
+		}
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("unexpected warnings: %v", r.Warnings)
+	}
+	if warnings[0].Kind != "attrs_open" {
+		t.Fatalf("unexpected kind; want: %v, got: %v", "attrs_open", warnings[0].Kind)
+	}
+	if !strings.Contains(warnings[0].Detail, "attrs mode replaces the current mode") {
+		t.Fatalf("unexpected detail: %v", warnings[0].Detail)
+	}
+}
+
+func TestCompile_EmptyMode(t *testing.T) {
+	tests := []struct {
+		caption string
+		spec    *spec.LexSpec
+		err     string
+	}{
+		{
+			caption: "the initial mode is the default mode explicitly, but all entries belong to another mode",
+			spec: &spec.LexSpec{
+				Name:        "test",
+				InitialMode: "default",
+				Entries: []*spec.LexEntry{
+					{
+						Kind:    "foo",
+						Pattern: "foo",
+						Modes:   []spec.LexModeName{"foo_mode"},
+					},
+				},
+			},
+			err: "default mode has no entries",
+		},
+		{
+			// The validation of the specification rejects it before the compiler finds the empty mode.
+			caption: "a specification has only fragments",
+			spec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					{
+						Kind:     "foo",
+						Pattern:  "foo",
+						Fragment: true,
+					},
+				},
+			},
+			err: "the lexical specification must have at least one entry that isn't a fragment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			clspec, err, cerrs := Compile(tt.spec)
+			if err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if clspec != nil {
+				t.Fatalf("the compiled specification must be nil: %#v", clspec)
+			}
+			if len(cerrs) > 0 {
+				t.Fatalf("unexpected compile errors: %v", cerrs)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCompile_NoDefaultMode(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name:        "test",
+		InitialMode: "foo_mode",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "foo",
+				Pattern: "foo",
+				Modes:   []spec.LexModeName{"foo_mode"},
+				Push:    "bar_mode",
+			},
+			{
+				Kind:    "bar",
+				Pattern: "bar",
+				Modes:   []spec.LexModeName{"bar_mode"},
+				Pop:     true,
+			},
+		},
+	}
+	for _, compLv := range []int{CompressionLevelMin, CompressionLevelMax} {
+		t.Run(fmt.Sprintf("compression level %v", compLv), func(t *testing.T) {
+			clspec, err, _ := Compile(lspec, CompressionLevel(compLv))
+			if err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+			// The compiler doesn't build the default mode because no entry belongs to it.
+			expectedModeNames := []spec.LexModeName{spec.LexModeNameNil, "foo_mode", "bar_mode"}
+			if !reflect.DeepEqual(clspec.ModeNames, expectedModeNames) {
+				t.Fatalf("unexpected mode names; want: %v, got: %v", expectedModeNames, clspec.ModeNames)
+			}
+			if clspec.InitialModeID != 1 {
+				t.Fatalf("unexpected initial mode ID; want: %v, got: %v", 1, clspec.InitialModeID)
+			}
+			if len(clspec.Specs) != 3 {
+				t.Fatalf("unexpected mode specs: %v", clspec.Specs)
+			}
+			for _, s := range clspec.Specs[1:] {
+				if s == nil || s.DFA == nil {
+					t.Fatalf("each mode must have a DFA")
+				}
+			}
+		})
+	}
+}
+
+// TestCompile_DFASize guards against the growth of DFAs. When a change of the compiler alters the expected values, make
+// sure the change is intended and update them.
+func TestCompile_DFASize(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "white_space",
+				Pattern: `[\u{0009}\u{0020}]+`,
+			},
+			{
+				Kind:    "if",
+				Pattern: "if",
+			},
+			{
+				Kind:    "identifier",
+				Pattern: "[A-Za-z_][0-9A-Za-z_]*",
+			},
+			{
+				Kind:    "integer",
+				Pattern: "0|[1-9][0-9]*",
+			},
+			{
+				Kind:    "string_open",
+				Pattern: `"`,
+				Push:    "string",
+			},
+			{
+				Modes:   []spec.LexModeName{"string"},
+				Kind:    "char_seq",
+				Pattern: `[^"\\]+`,
+			},
+			{
+				Modes:   []spec.LexModeName{"string"},
+				Kind:    "escaped_char",
+				Pattern: `\\["\\]`,
+			},
+			{
+				Modes:   []spec.LexModeName{"string"},
+				Kind:    "string_close",
+				Pattern: `"`,
+				Pop:     true,
+			},
+		},
+	}
+	// The sizes are in ints so that the expected values don't depend on the platform.
+	tests := []struct {
+		compLv      int
+		stateCounts []int
+		sizes       []int
+		sharedSize  int
+	}{
+		{
+			compLv:      0,
+			stateCounts: []int{8, 41},
+			sizes:       []int{2304, 10752},
+		},
+		{
+			compLv:      1,
+			stateCounts: []int{8, 41},
+			sizes:       []int{9, 42},
+			sharedSize:  7680,
+		},
+		{
+			compLv:      2,
+			stateCounts: []int{8, 41},
+			sizes:       []int{9, 42},
+			sharedSize:  4288,
+		},
+	}
+	intSize := strconv.IntSize / 8
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("compression level: %v", tt.compLv), func(t *testing.T) {
+			clspec, err, _ := Compile(lspec, CompressionLevel(tt.compLv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, modeSpec := range clspec.Specs[spec.LexModeIDDefault:] {
+				modeName := clspec.ModeNames[spec.LexModeIDDefault.Int()+i]
+				if modeSpec.StateCount() != tt.stateCounts[i] {
+					t.Errorf("unexpected state count of %v mode; want: %v, got: %v", modeName, tt.stateCounts[i], modeSpec.StateCount())
+				}
+				if modeSpec.TransitionByteSize() != tt.sizes[i]*intSize {
+					t.Errorf("unexpected transition size of %v mode; want: %v, got: %v", modeName, tt.sizes[i]*intSize, modeSpec.TransitionByteSize())
+				}
+			}
+			sharedSize := 0
+			if clspec.SharedTransition != nil {
+				sharedSize = clspec.SharedTransition.ByteSize()
+			}
+			if sharedSize != tt.sharedSize*intSize {
+				t.Errorf("unexpected size of the shared transition; want: %v, got: %v", tt.sharedSize*intSize, sharedSize)
+			}
+		})
+	}
+}
+
+func TestCompile_FoldContinuationBytes(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `\p{Letter}+`,
+			},
+			{
+				Kind:    "other",
+				Pattern: `[^\p{Letter}]+`,
+			},
+		},
+	}
+	stateCount := func(opts ...CompilerOption) int {
+		t.Helper()
+		clspec, err, _ := Compile(lspec, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return clspec.Specs[spec.LexModeIDDefault].DFA.RowCount - 1
+	}
+	unfolded := stateCount()
+	folded := stateCount(FoldContinuationBytes())
+	if folded >= unfolded {
+		t.Fatalf("the folded DFA must have fewer states; unfolded: %v, folded: %v", unfolded, folded)
+	}
+}
+
+func TestCompile_Minimize(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{
+				Kind:    "word",
+				Pattern: `\p{Letter}+`,
+			},
+			{
+				Kind:    "quote_open",
+				Pattern: `"`,
+				Push:    "string",
+			},
+			{
+				Modes:   []spec.LexModeName{"string"},
+				Kind:    "char_seq",
+				Pattern: `[^"]+`,
+			},
+			{
+				Modes:   []spec.LexModeName{"string"},
+				Kind:    "quote_close",
+				Pattern: `"`,
+				Pop:     true,
+			},
+		},
+	}
+	r := CompileWithResult(lspec)
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if r.RemovedStates != nil {
+		t.Fatalf("RemovedStates must be nil without the minimization: %v", r.RemovedStates)
+	}
+	for _, compLv := range []int{0, 1, 2} {
+		t.Run(fmt.Sprintf("compression level %v", compLv), func(t *testing.T) {
+			mr := CompileWithResult(lspec, Minimize(), CompressionLevel(compLv))
+			if mr.Err != nil {
+				t.Fatal(mr.Err)
+			}
+			if len(mr.RemovedStates) != len(mr.Spec.Specs) {
+				t.Fatalf("unexpected removed states: %v", mr.RemovedStates)
+			}
+			for id, modeSpec := range mr.Spec.Specs[1:] {
+				modeID := id + 1
+				if mr.RemovedStates[modeID] <= 0 {
+					t.Fatalf("the minimization must remove states of %v mode", mr.Spec.ModeNames[modeID])
+				}
+				expected := r.Spec.Specs[modeID].StateCount() - mr.RemovedStates[modeID]
+				if modeSpec.StateCount() != expected {
+					t.Fatalf("unexpected state count of %v mode; want: %v, got: %v", mr.Spec.ModeNames[modeID], expected, modeSpec.StateCount())
+				}
+			}
+		})
+	}
+}
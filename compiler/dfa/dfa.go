@@ -1,7 +1,9 @@
 package dfa
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/nihei9/maleeni/spec"
 )
@@ -41,13 +43,29 @@ func genSymTab(symTab *symbolTable, node byteTree) *symbolTable {
 }
 
 type DFA struct {
-	States               []string
-	InitialState         string
-	AcceptingStatesTable map[string]spec.LexModeKindID
-	TransitionTable      map[string][256]string
+	States                   []string
+	InitialState             string
+	AcceptingStatesTable     map[string]spec.LexModeKindID
+	AcceptingCandidatesTable map[string][]spec.LexModeKindID
+	TransitionTable          map[string][256]string
 }
 
-func GenDFA(root byteTree, symTab *symbolTable) *DFA {
+// GenDFA generates a DFA from a byte tree. When multiple kinds accept the same state, the kind with the smallest value
+// in priorities wins, and kinds having the same priority are ordered by their IDs. A nil priorities means all kinds
+// have the same priority.
+func GenDFA(root byteTree, symTab *symbolTable, priorities map[spec.LexModeKindID]int) *DFA {
+	// A nil root accepts nothing, so the DFA consists only of the initial state, which has no transitions.
+	if root == nil {
+		initialStateHash := newSymbolPositionSet().hash()
+		return &DFA{
+			States:                   []string{initialStateHash},
+			InitialState:             initialStateHash,
+			AcceptingStatesTable:     map[string]spec.LexModeKindID{},
+			AcceptingCandidatesTable: map[string][]spec.LexModeKindID{},
+			TransitionTable:          map[string][256]string{},
+		}
+	}
+
 	initialState := root.first()
 	initialStateHash := initialState.hash()
 	stateMap := map[string]*symbolPositionSet{
@@ -68,7 +86,8 @@ func GenDFA(root byteTree, symTab *symbolTable) *DFA {
 						continue
 					}
 					valRange := symTab.symPos2Byte[pos]
-					for symVal := valRange.from; symVal <= valRange.to; symVal++ {
+					// We use an int counter because a byte counter would wrap around and never exceed 0xFF.
+					for symVal := int(valRange.from); symVal <= int(valRange.to); symVal++ {
 						if tranTabOfState[symVal] == nil {
 							tranTabOfState[symVal] = newSymbolPositionSet()
 						}
@@ -100,21 +119,29 @@ func GenDFA(root byteTree, symTab *symbolTable) *DFA {
 	}
 
 	accTab := map[string]spec.LexModeKindID{}
+	candTab := map[string][]spec.LexModeKindID{}
 	{
 		for h, s := range stateMap {
+			var ids []spec.LexModeKindID
 			for _, pos := range s.set() {
 				if !pos.isEndMark() {
 					continue
 				}
-				priorID, ok := accTab[h]
-				if !ok {
-					accTab[h] = symTab.endPos2ID[pos]
-				} else {
-					id := symTab.endPos2ID[pos]
-					if id < priorID {
-						accTab[h] = id
-					}
+				ids = append(ids, symTab.endPos2ID[pos])
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			// The smaller the priority value, the higher the priority.
+			sort.Slice(ids, func(i, j int) bool {
+				if pi, pj := priorities[ids[i]], priorities[ids[j]]; pi != pj {
+					return pi < pj
 				}
+				return ids[i] < ids[j]
+			})
+			accTab[h] = ids[0]
+			if len(ids) > 1 {
+				candTab[h] = ids
 			}
 		}
 	}
@@ -130,10 +157,11 @@ func GenDFA(root byteTree, symTab *symbolTable) *DFA {
 	}
 
 	return &DFA{
-		States:               states,
-		InitialState:         initialStateHash,
-		AcceptingStatesTable: accTab,
-		TransitionTable:      tranTab,
+		States:                   states,
+		InitialState:             initialStateHash,
+		AcceptingStatesTable:     accTab,
+		AcceptingCandidatesTable: candTab,
+		TransitionTable:          tranTab,
 	}
 }
 
@@ -154,6 +182,14 @@ func GenTransitionTable(dfa *DFA) (*spec.TransitionTable, error) {
 		acc[stateHash2ID[s]] = id
 	}
 
+	var cand [][]spec.LexModeKindID
+	if len(dfa.AcceptingCandidatesTable) > 0 {
+		cand = make([][]spec.LexModeKindID, len(dfa.States)+1)
+		for s, ids := range dfa.AcceptingCandidatesTable {
+			cand[stateHash2ID[s]] = ids
+		}
+	}
+
 	rowCount := len(dfa.States) + 1
 	colCount := 256
 	tran := make([]spec.StateID, rowCount*colCount)
@@ -166,8 +202,108 @@ func GenTransitionTable(dfa *DFA) (*spec.TransitionTable, error) {
 	return &spec.TransitionTable{
 		InitialStateID:         stateHash2ID[dfa.InitialState],
 		AcceptingStates:        acc,
+		AcceptingCandidates:    cand,
 		UncompressedTransition: tran,
 		RowCount:               rowCount,
 		ColCount:               colCount,
 	}, nil
 }
+
+// Minimize merges equivalent states of a DFA and returns a new DFA. Two states are equivalent when they accept the
+// same kinds with the same candidates and their transitions on every byte lead to equivalent states. Each merged state
+// takes the smallest name among the states it consists of, so minimizing the same DFA always yields the same result.
+func Minimize(dfa *DFA) *DFA {
+	// blocks maps each state to the ID of the block it belongs to. States are equivalent when they belong to the same
+	// block. First, we split states by the kinds they accept, and then we keep splitting blocks by the blocks the
+	// transitions lead to until no block splits.
+	blocks := map[string]int{}
+	blockCount := 0
+	{
+		key2Block := map[string]int{}
+		for _, s := range dfa.States {
+			key := fmt.Sprintf("%v:%v", dfa.AcceptingStatesTable[s], dfa.AcceptingCandidatesTable[s])
+			b, ok := key2Block[key]
+			if !ok {
+				b = len(key2Block)
+				key2Block[key] = b
+			}
+			blocks[s] = b
+		}
+		blockCount = len(key2Block)
+	}
+	for {
+		key2Block := map[string]int{}
+		nextBlocks := map[string]int{}
+		for _, s := range dfa.States {
+			var b strings.Builder
+			fmt.Fprintf(&b, "%v", blocks[s])
+			tab := dfa.TransitionTable[s]
+			for _, to := range tab {
+				if to == "" {
+					fmt.Fprintf(&b, ",-")
+					continue
+				}
+				fmt.Fprintf(&b, ",%v", blocks[to])
+			}
+			key := b.String()
+			nb, ok := key2Block[key]
+			if !ok {
+				nb = len(key2Block)
+				key2Block[key] = nb
+			}
+			nextBlocks[s] = nb
+		}
+		blocks = nextBlocks
+		// Blocks only split, so the partition is stable when the number of blocks doesn't change.
+		if len(key2Block) == blockCount {
+			break
+		}
+		blockCount = len(key2Block)
+	}
+
+	// States are sorted, so the first state of each block has the smallest name.
+	block2Rep := map[int]string{}
+	var states []string
+	for _, s := range dfa.States {
+		if _, ok := block2Rep[blocks[s]]; ok {
+			continue
+		}
+		block2Rep[blocks[s]] = s
+		states = append(states, s)
+	}
+	rep := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return block2Rep[blocks[s]]
+	}
+
+	accTab := map[string]spec.LexModeKindID{}
+	candTab := map[string][]spec.LexModeKindID{}
+	tranTab := map[string][256]string{}
+	for _, s := range states {
+		if id, ok := dfa.AcceptingStatesTable[s]; ok {
+			accTab[s] = id
+		}
+		if ids, ok := dfa.AcceptingCandidatesTable[s]; ok {
+			candTab[s] = ids
+		}
+		tab, ok := dfa.TransitionTable[s]
+		if !ok {
+			continue
+		}
+		var newTab [256]string
+		for v, to := range tab {
+			newTab[v] = rep(to)
+		}
+		tranTab[s] = newTab
+	}
+
+	return &DFA{
+		States:                   states,
+		InitialState:             rep(dfa.InitialState),
+		AcceptingStatesTable:     accTab,
+		AcceptingCandidatesTable: candTab,
+		TransitionTable:          tranTab,
+	}
+}
@@ -20,7 +20,7 @@ func TestGenDFA(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	dfa := GenDFA(bt, symTab)
+	dfa := GenDFA(bt, symTab, nil)
 	if dfa == nil {
 		t.Fatalf("DFA is nil")
 	}
@@ -119,3 +119,178 @@ func TestGenDFA(t *testing.T) {
 		}
 	}
 }
+
+func TestGenDFA_EmptyRuleSet(t *testing.T) {
+	_, _, err := ConvertCPTreeToByteTree(map[spec.LexModeKindID]parser.CPTree{})
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+
+	dfa := GenDFA(nil, nil, nil)
+	if len(dfa.States) != 1 || dfa.States[0] != dfa.InitialState {
+		t.Fatalf("DFA must consist only of the initial state: %v", dfa.States)
+	}
+	if len(dfa.AcceptingStatesTable) != 0 {
+		t.Fatalf("DFA must have no accepting states: %v", dfa.AcceptingStatesTable)
+	}
+	tranTab, err := GenTransitionTable(dfa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tranTab.InitialStateID != spec.StateIDMin {
+		t.Fatalf("unexpected initial state: %v", tranTab.InitialStateID)
+	}
+	for _, next := range tranTab.UncompressedTransition {
+		if next != spec.StateIDNil {
+			t.Fatalf("DFA must have no transitions: %v", tranTab.UncompressedTransition)
+		}
+	}
+}
+
+func TestGenDFA_FoldContinuationBytes(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		match    []string
+		mismatch []string
+	}{
+		{
+			pattern:  `.+`,
+			match:    []string{"a", "©", "\u0800", "あい", "\uFFFF", "\U00010000", "\U0010FFFF"},
+			mismatch: []string{"\xC0\x80", "\xE0\x80\x80", "\xED\xA0\x80", "\xF4\x90\x80\x80", "\xE3\x81"},
+		},
+		{
+			pattern:  `\p{Letter}+`,
+			match:    []string{"abc", "é", "あいう", "\U00020000"},
+			mismatch: []string{"1", "、", "\U0001F600"},
+		},
+		{
+			pattern:  `[\u{0080}-\u{07FF}\u{E000}-\u{FFFF}]`,
+			match:    []string{"\u0080", "\u07FF", "\uE000", "\uFFFF"},
+			mismatch: []string{"\u007F", "\u0800", "\xED\xBF\xBF", "\U00010000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			genDFA := func(opts ...ConvertOption) *DFA {
+				t.Helper()
+				p := parser.NewParser(spec.LexKindName("test"), strings.NewReader(tt.pattern))
+				cpt, err := p.Parse()
+				if err != nil {
+					t.Fatal(err)
+				}
+				bt, symTab, err := ConvertCPTreeToByteTree(map[spec.LexModeKindID]parser.CPTree{
+					spec.LexModeKindIDMin: cpt,
+				}, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return GenDFA(bt, symTab, nil)
+			}
+			unfolded := genDFA()
+			folded := genDFA(FoldContinuationBytes())
+			if len(folded.States) >= len(unfolded.States) {
+				t.Fatalf("the folded DFA must have fewer states; unfolded: %v, folded: %v", len(unfolded.States), len(folded.States))
+			}
+			for _, input := range tt.match {
+				if !testAccept(unfolded, input) || !testAccept(folded, input) {
+					t.Errorf("%q must match", input)
+				}
+			}
+			for _, input := range tt.mismatch {
+				if testAccept(unfolded, input) || testAccept(folded, input) {
+					t.Errorf("%q must not match", input)
+				}
+			}
+		})
+	}
+}
+
+func testAccept(dfa *DFA, input string) bool {
+	return testRun(dfa, input) != spec.LexModeKindIDNil
+}
+
+// testRun returns the kind that a DFA accepts after reading the whole input.
+func testRun(dfa *DFA, input string) spec.LexModeKindID {
+	state := dfa.InitialState
+	for _, b := range []byte(input) {
+		state = dfa.TransitionTable[state][b]
+		if state == "" {
+			return spec.LexModeKindIDNil
+		}
+	}
+	return dfa.AcceptingStatesTable[state]
+}
+
+func TestMinimize(t *testing.T) {
+	tests := []struct {
+		patterns        []string
+		stateCount      int
+		minimStateCount int
+	}{
+		{
+			// The states after reading `a` and `c` are equivalent.
+			patterns:        []string{"ab|cb"},
+			stateCount:      4,
+			minimStateCount: 3,
+		},
+		{
+			// The DFA is already minimal.
+			patterns:        []string{"(a|b)*abb"},
+			stateCount:      4,
+			minimStateCount: 4,
+		},
+		{
+			// The states accepting different kinds must not be merged.
+			patterns:        []string{"ab", "cb"},
+			stateCount:      5,
+			minimStateCount: 5,
+		},
+		{
+			// The state after reading `c` accepts the second kind, so it isn't equivalent to the others.
+			patterns:        []string{"ab|bb|cb", "c"},
+			stateCount:      5,
+			minimStateCount: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.patterns, ","), func(t *testing.T) {
+			cpTrees := map[spec.LexModeKindID]parser.CPTree{}
+			for i, pattern := range tt.patterns {
+				p := parser.NewParser(spec.LexKindName("test"), strings.NewReader(pattern))
+				cpt, err := p.Parse()
+				if err != nil {
+					t.Fatal(err)
+				}
+				cpTrees[spec.LexModeKindID(i+1)] = cpt
+			}
+			bt, symTab, err := ConvertCPTreeToByteTree(cpTrees)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dfa := GenDFA(bt, symTab, nil)
+			minim := Minimize(dfa)
+			if len(dfa.States) != tt.stateCount || len(minim.States) != tt.minimStateCount {
+				t.Fatalf("unexpected state counts; want: %v -> %v, got: %v -> %v", tt.stateCount, tt.minimStateCount, len(dfa.States), len(minim.States))
+			}
+
+			// Both DFAs must accept the same kinds for all inputs consisting of the letters up to a length.
+			var inputs []string
+			inputs = append(inputs, "")
+			for n, prev := 0, []string{""}; n < 6; n++ {
+				var next []string
+				for _, s := range prev {
+					for _, c := range "abc" {
+						next = append(next, s+string(c))
+					}
+				}
+				inputs = append(inputs, next...)
+				prev = next
+			}
+			for _, input := range inputs {
+				if id, minimID := testRun(dfa, input), testRun(minim, input); id != minimID {
+					t.Fatalf("the minimized DFA accepts a different kind; input: %q, want: %v, got: %v", input, id, minimID)
+				}
+			}
+		})
+	}
+}
@@ -38,7 +38,7 @@ func (p symbolPosition) String() string {
 }
 
 func (p symbolPosition) isEndMark() bool {
-	return uint16(p)&symbolPositionMaskEndMark > 1
+	return uint16(p)&symbolPositionMaskEndMark != 0
 }
 
 func (p symbolPosition) describe() (uint16, bool) {
@@ -77,3 +77,43 @@ func TestNewSymbolPosition(t *testing.T) {
 		})
 	}
 }
+
+func TestSymbolPosition_IsEndMark(t *testing.T) {
+	tests := []struct {
+		pos     symbolPosition
+		endMark bool
+	}{
+		{
+			pos:     symbolPosition(symbolPositionMin),
+			endMark: false,
+		},
+		{
+			pos:     symbolPosition(symbolPositionMax),
+			endMark: false,
+		},
+		{
+			pos:     symbolPosition(symbolPositionMaskEndMark | symbolPositionMin),
+			endMark: true,
+		},
+		{
+			pos:     symbolPosition(symbolPositionMaskEndMark | symbolPositionMax),
+			endMark: true,
+		},
+		// The end mark flag alone must be recognized even though the value part is out of range.
+		{
+			pos:     symbolPosition(symbolPositionMaskEndMark),
+			endMark: true,
+		},
+		{
+			pos:     symbolPositionNil,
+			endMark: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%#04x", uint16(tt.pos)), func(t *testing.T) {
+			if tt.pos.isEndMark() != tt.endMark {
+				t.Fatalf("unexpected result: want: %v, got: %v", tt.endMark, tt.pos.isEndMark())
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/nihei9/maleeni/compiler/parser"
 	"github.com/nihei9/maleeni/spec"
+	"github.com/nihei9/maleeni/ucd"
 	"github.com/nihei9/maleeni/utf8"
 )
 
@@ -143,6 +144,11 @@ type concatNode struct {
 	right     byteTree
 	firstMemo *symbolPositionSet
 	lastMemo  *symbolPositionSet
+
+	// nullableMemo holds the result of nullable when nullableMemoized is true. A long pattern, such as a literal,
+	// becomes a deep chain of concatenations, so computing nullable each time takes quadratic time.
+	nullableMemo     bool
+	nullableMemoized bool
 }
 
 func newConcatNode(left, right byteTree) *concatNode {
@@ -161,7 +167,11 @@ func (n *concatNode) children() (byteTree, byteTree) {
 }
 
 func (n *concatNode) nullable() bool {
-	return n.left.nullable() && n.right.nullable()
+	if !n.nullableMemoized {
+		n.nullableMemo = n.left.nullable() && n.right.nullable()
+		n.nullableMemoized = true
+	}
+	return n.nullableMemo
 }
 
 func (n *concatNode) first() *symbolPositionSet {
@@ -197,6 +207,11 @@ type altNode struct {
 	right     byteTree
 	firstMemo *symbolPositionSet
 	lastMemo  *symbolPositionSet
+
+	// nullableMemo holds the result of nullable when nullableMemoized is true. A long pattern, such as a literal,
+	// becomes a deep chain of concatenations, so computing nullable each time takes quadratic time.
+	nullableMemo     bool
+	nullableMemoized bool
 }
 
 func newAltNode(left, right byteTree) *altNode {
@@ -215,7 +230,11 @@ func (n *altNode) children() (byteTree, byteTree) {
 }
 
 func (n *altNode) nullable() bool {
-	return n.left.nullable() || n.right.nullable()
+	if !n.nullableMemoized {
+		n.nullableMemo = n.left.nullable() || n.right.nullable()
+		n.nullableMemoized = true
+	}
+	return n.nullableMemo
 }
 
 func (n *altNode) first() *symbolPositionSet {
@@ -480,7 +499,34 @@ func printByteTree(w io.Writer, t byteTree, ruledLine string, childRuledLinePref
 	}
 }
 
-func ConvertCPTreeToByteTree(cpTrees map[spec.LexModeKindID]parser.CPTree) (byteTree, *symbolTable, error) {
+type ConvertOption func(c *convertConfig)
+
+// FoldContinuationBytes makes the conversion share the trailing bytes of UTF-8 sequences among the alternatives of
+// code point ranges. For instance, all 3-byte characters of `.` end with the same continuation bytes 0x80-0xBF, and
+// sharing them lets a DFA reach the same state after reading any of their leading bytes. The option makes DFAs of
+// Unicode-heavy patterns have fewer states without changing the accepted language.
+func FoldContinuationBytes() ConvertOption {
+	return func(c *convertConfig) {
+		c.foldContinuationBytes = true
+	}
+}
+
+type convertConfig struct {
+	foldContinuationBytes bool
+}
+
+// ConvertCPTreeToByteTree combines the trees of all kinds into one byte tree. A DFA needs at least one kind to
+// accept, so an empty cpTrees is an error.
+func ConvertCPTreeToByteTree(cpTrees map[spec.LexModeKindID]parser.CPTree, opts ...ConvertOption) (byteTree, *symbolTable, error) {
+	if len(cpTrees) == 0 {
+		return nil, nil, fmt.Errorf("no patterns to convert")
+	}
+
+	config := &convertConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
 	var ids []spec.LexModeKindID
 	for id := range cpTrees {
 		ids = append(ids, id)
@@ -492,7 +538,7 @@ func ConvertCPTreeToByteTree(cpTrees map[spec.LexModeKindID]parser.CPTree) (byte
 	var bt byteTree
 	for _, id := range ids {
 		cpTree := cpTrees[id]
-		t, err := convCPTreeToByteTree(cpTree)
+		t, err := convCPTreeToByteTree(cpTree, config)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -506,7 +552,17 @@ func ConvertCPTreeToByteTree(cpTrees map[spec.LexModeKindID]parser.CPTree) (byte
 	return bt, genSymbolTable(bt), nil
 }
 
-func convCPTreeToByteTree(cpTree parser.CPTree) (byteTree, error) {
+func convCPTreeToByteTree(cpTree parser.CPTree, config *convertConfig) (byteTree, error) {
+	if from, to, ok := cpTree.ByteRange(); ok {
+		return newRangeSymbolNode(from, to), nil
+	}
+
+	if config.foldContinuationBytes {
+		if cpRanges, ok := parser.CollectCodePointRanges(cpTree, nil); ok {
+			return convCodePointRangesToFoldedByteTree(cpRanges)
+		}
+	}
+
 	if from, to, ok := cpTree.Range(); ok {
 		bs, err := utf8.GenCharBlocks(from, to)
 		if err != nil {
@@ -524,7 +580,7 @@ func convCPTreeToByteTree(cpTree parser.CPTree) (byteTree, error) {
 	}
 
 	if tree, ok := cpTree.Repeatable(); ok {
-		t, err := convCPTreeToByteTree(tree)
+		t, err := convCPTreeToByteTree(tree, config)
 		if err != nil {
 			return nil, err
 		}
@@ -532,7 +588,7 @@ func convCPTreeToByteTree(cpTree parser.CPTree) (byteTree, error) {
 	}
 
 	if tree, ok := cpTree.Optional(); ok {
-		t, err := convCPTreeToByteTree(tree)
+		t, err := convCPTreeToByteTree(tree, config)
 		if err != nil {
 			return nil, err
 		}
@@ -540,11 +596,11 @@ func convCPTreeToByteTree(cpTree parser.CPTree) (byteTree, error) {
 	}
 
 	if left, right, ok := cpTree.Concatenation(); ok {
-		l, err := convCPTreeToByteTree(left)
+		l, err := convCPTreeToByteTree(left, config)
 		if err != nil {
 			return nil, err
 		}
-		r, err := convCPTreeToByteTree(right)
+		r, err := convCPTreeToByteTree(right, config)
 		if err != nil {
 			return nil, err
 		}
@@ -552,11 +608,11 @@ func convCPTreeToByteTree(cpTree parser.CPTree) (byteTree, error) {
 	}
 
 	if left, right, ok := cpTree.Alternatives(); ok {
-		l, err := convCPTreeToByteTree(left)
+		l, err := convCPTreeToByteTree(left, config)
 		if err != nil {
 			return nil, err
 		}
-		r, err := convCPTreeToByteTree(right)
+		r, err := convCPTreeToByteTree(right, config)
 		if err != nil {
 			return nil, err
 		}
@@ -565,3 +621,63 @@ func convCPTreeToByteTree(cpTree parser.CPTree) (byteTree, error) {
 
 	return nil, fmt.Errorf("invalid tree type: %T", cpTree)
 }
+
+// convCodePointRangesToFoldedByteTree converts code point ranges into a byte tree in which UTF-8 sequences ending
+// with the same byte ranges share them.
+func convCodePointRangesToFoldedByteTree(cpRanges []*ucd.CodePointRange) (byteTree, error) {
+	var seqs [][]byteRange
+	for _, r := range cpRanges {
+		bs, err := utf8.GenCharBlocks(r.From, r.To)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bs {
+			seq := make([]byteRange, len(b.From))
+			for i := 0; i < len(b.From); i++ {
+				seq[i] = byteRange{
+					from: b.From[i],
+					to:   b.To[i],
+				}
+			}
+			seqs = append(seqs, seq)
+		}
+	}
+	return foldByteSequences(seqs), nil
+}
+
+// foldByteSequences converts byte sequences into alternatives of them. The sequences ending with the same byte range
+// are grouped, and each group becomes a concatenation of alternatives of the preceding parts and the shared byte
+// range. foldByteSequences applies the same process to the preceding parts recursively.
+func foldByteSequences(seqs [][]byteRange) byteTree {
+	var lasts []byteRange
+	groups := map[byteRange][][]byteRange{}
+	for _, seq := range seqs {
+		last := seq[len(seq)-1]
+		if _, ok := groups[last]; !ok {
+			lasts = append(lasts, last)
+		}
+		groups[last] = append(groups[last], seq[:len(seq)-1])
+	}
+
+	var alt byteTree
+	for _, last := range lasts {
+		var prefixes [][]byteRange
+		nullable := false
+		for _, prefix := range groups[last] {
+			if len(prefix) == 0 {
+				nullable = true
+				continue
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		var prefix byteTree
+		if len(prefixes) > 0 {
+			prefix = foldByteSequences(prefixes)
+			if nullable {
+				prefix = newOptionNode(prefix)
+			}
+		}
+		alt = oneOf(alt, concat(prefix, newRangeSymbolNode(last.from, last.to)))
+	}
+	return alt
+}
@@ -255,3 +255,46 @@ func testSymbolTable(t *testing.T, expected, actual *symbolTable) {
 		}
 	}
 }
+
+// BenchmarkConvertCPTreeToByteTree_Keywords reports the size of a DFA for many keywords. The subset construction shares
+// the common prefixes of the keywords, so the DFA needs no special handling of literals to stay as small as a trie.
+func BenchmarkConvertCPTreeToByteTree_Keywords(b *testing.B) {
+	// 200 keywords and an identifier pattern having the lowest priority.
+	var patterns []string
+	prefixes := []string{"get", "set", "is", "to"}
+	for i := 0; i < 200; i++ {
+		n := i / len(prefixes)
+		patterns = append(patterns, fmt.Sprintf("%v%c%c", prefixes[i%len(prefixes)], 'a'+n/26, 'a'+n%26))
+	}
+	patterns = append(patterns, "[a-z]+")
+
+	var d *DFA
+	for i := 0; i < b.N; i++ {
+		d = genTestDFA(b, patterns)
+	}
+	tab, err := GenTransitionTable(d)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(len(d.States)), "states")
+	b.ReportMetric(float64(len(tab.UncompressedTransition)), "entries")
+}
+
+// genTestDFA generates a DFA from patterns. The patterns have the priorities in the order.
+func genTestDFA(tb testing.TB, patterns []string) *DFA {
+	tb.Helper()
+	cpTrees := map[spec.LexModeKindID]parser.CPTree{}
+	for i, pattern := range patterns {
+		p := parser.NewParser(spec.LexKindName("test"), strings.NewReader(pattern))
+		cpt, err := p.Parse()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		cpTrees[spec.LexModeKindID(i+1)] = cpt
+	}
+	bt, symTab, err := ConvertCPTreeToByteTree(cpTrees)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return GenDFA(bt, symTab, nil)
+}
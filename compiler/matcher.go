@@ -0,0 +1,79 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/nihei9/maleeni/compiler/dfa"
+	psr "github.com/nihei9/maleeni/compiler/parser"
+	"github.com/nihei9/maleeni/spec"
+)
+
+// Matcher is a DFA compiled from a single pattern. It is useful for validating strings with the same regular
+// expressions as lexical specifications without building a lexer.
+type Matcher struct {
+	tab *spec.TransitionTable
+}
+
+// CompilePattern compiles a pattern into a Matcher. The pattern is written in the same regular expression as patterns
+// of lexical specifications. However, the pattern cannot refer to fragments, and it cannot contain word boundaries
+// (`\b` and `\B`) because a Matcher has no surrounding text to check them against. `^` and `$` always hold because
+// a Matcher matches whole input.
+func CompilePattern(pattern string) (*Matcher, error) {
+	p := psr.NewParser("pattern", bytes.NewReader([]byte(pattern)))
+	t, err := p.Parse()
+	if err != nil {
+		if err == psr.ParseErr {
+			detail, cause := p.Error()
+			if detail != "" {
+				return nil, fmt.Errorf("%w: %v", cause, detail)
+			}
+			return nil, cause
+		}
+		return nil, err
+	}
+	_, frags, err := t.Describe()
+	if err != nil {
+		return nil, err
+	}
+	if len(frags) > 0 {
+		return nil, fmt.Errorf("a pattern cannot refer to fragments: %v", frags)
+	}
+	anchor, err := t.Anchor()
+	if err != nil {
+		return nil, err
+	}
+	if anchor&^(spec.LexAnchorLineStart|spec.LexAnchorLineEnd) != spec.LexAnchorNil {
+		return nil, fmt.Errorf("a pattern cannot contain word boundaries")
+	}
+
+	const id = spec.LexModeKindID(1)
+	root, symTab, err := dfa.ConvertCPTreeToByteTree(map[spec.LexModeKindID]psr.CPTree{
+		id: t,
+	})
+	if err != nil {
+		return nil, err
+	}
+	d := dfa.GenDFA(root, symTab, map[spec.LexModeKindID]int{
+		id: 1,
+	})
+	tab, err := dfa.GenTransitionTable(d)
+	if err != nil {
+		return nil, err
+	}
+	return &Matcher{
+		tab: tab,
+	}, nil
+}
+
+// Match reports whether the whole input matches the pattern.
+func (m *Matcher) Match(input []byte) bool {
+	state := m.tab.InitialStateID
+	for _, b := range input {
+		state = m.tab.UncompressedTransition[state.Int()*m.tab.ColCount+int(b)]
+		if state == spec.StateIDNil {
+			return false
+		}
+	}
+	return m.tab.AcceptingStates[state] != spec.LexModeKindIDNil
+}
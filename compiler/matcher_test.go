@@ -0,0 +1,78 @@
+package compiler
+
+import (
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		match    []string
+		mismatch []string
+	}{
+		{
+			pattern:  "[a-z]+",
+			match:    []string{"a", "foo"},
+			mismatch: []string{"", "Foo", "foo1", "foo bar"},
+		},
+		{
+			pattern:  "0|[1-9][0-9]*",
+			match:    []string{"0", "1", "123"},
+			mismatch: []string{"", "01", "1a"},
+		},
+		{
+			pattern:  `\u{3042}\u{3044}?`,
+			match:    []string{"あ", "あい"},
+			mismatch: []string{"い", "ああ"},
+		},
+		{
+			pattern:  `\p{Letter}+`,
+			match:    []string{"abc", "あいう", "Ωmega"},
+			mismatch: []string{"", "123", "a-b"},
+		},
+		{
+			pattern:  `[^\p{White_Space=yes}]+`,
+			match:    []string{"foo", "あ"},
+			mismatch: []string{"", "foo bar", "　"},
+		},
+		{
+			pattern:  `^foo$`,
+			match:    []string{"foo"},
+			mismatch: []string{"foofoo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			m, err := CompilePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, input := range tt.match {
+				if !m.Match([]byte(input)) {
+					t.Errorf("%q must match", input)
+				}
+			}
+			for _, input := range tt.mismatch {
+				if m.Match([]byte(input)) {
+					t.Errorf("%q must not match", input)
+				}
+			}
+		})
+	}
+}
+
+func TestCompilePattern_Error(t *testing.T) {
+	for _, pattern := range []string{
+		"",
+		"[a-",
+		`\f{digit}+`,
+		`\bfoo\b`,
+	} {
+		t.Run(pattern, func(t *testing.T) {
+			_, err := CompilePattern(pattern)
+			if err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+		})
+	}
+}
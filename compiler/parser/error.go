@@ -1,6 +1,10 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/nihei9/maleeni/spec"
+)
 
 var (
 	ParseErr = fmt.Errorf("parse error")
@@ -11,26 +15,52 @@ var (
 	synErrInvalidCodePoint      = fmt.Errorf("code points must consist of just 4 or 6 hex digits")
 	synErrCharPropInvalidSymbol = fmt.Errorf("invalid character property symbol")
 	SynErrFragmentInvalidSymbol = fmt.Errorf("invalid fragment symbol")
+	synErrPOSIXClassUnclosed    = fmt.Errorf("a POSIX character class must be closed with :]")
 
 	// syntax errors
-	synErrUnexpectedToken        = fmt.Errorf("unexpected token")
-	synErrNullPattern            = fmt.Errorf("a pattern must be a non-empty byte sequence")
-	synErrUnmatchablePattern     = fmt.Errorf("a pattern cannot match any characters")
-	synErrAltLackOfOperand       = fmt.Errorf("an alternation expression must have operands")
-	synErrRepNoTarget            = fmt.Errorf("a repeat expression must have an operand")
-	synErrGroupNoElem            = fmt.Errorf("a grouping expression must include at least one character")
-	synErrGroupUnclosed          = fmt.Errorf("unclosed grouping expression")
-	synErrGroupNoInitiator       = fmt.Errorf(") needs preceding (")
-	synErrGroupInvalidForm       = fmt.Errorf("invalid grouping expression")
-	synErrBExpNoElem             = fmt.Errorf("a bracket expression must include at least one character")
-	synErrBExpUnclosed           = fmt.Errorf("unclosed bracket expression")
-	synErrBExpInvalidForm        = fmt.Errorf("invalid bracket expression")
-	synErrRangeInvalidOrder      = fmt.Errorf("a range expression with invalid order")
-	synErrRangePropIsUnavailable = fmt.Errorf("a property expression is unavailable in a range expression")
-	synErrRangeInvalidForm       = fmt.Errorf("invalid range expression")
-	synErrCPExpInvalidForm       = fmt.Errorf("invalid code point expression")
-	synErrCPExpOutOfRange        = fmt.Errorf("a code point must be between U+0000 to U+10FFFF")
-	synErrCharPropExpInvalidForm = fmt.Errorf("invalid character property expression")
-	synErrCharPropUnsupported    = fmt.Errorf("unsupported character property")
-	synErrFragmentExpInvalidForm = fmt.Errorf("invalid fragment expression")
+	synErrUnexpectedToken              = fmt.Errorf("unexpected token")
+	synErrNullPattern                  = fmt.Errorf("a pattern must be a non-empty byte sequence")
+	synErrUnmatchablePattern           = fmt.Errorf("a pattern cannot match any characters")
+	synErrAltLackOfOperand             = fmt.Errorf("an alternation expression must have operands")
+	synErrRepNoTarget                  = fmt.Errorf("a repeat expression must have an operand")
+	synErrGroupNoElem                  = fmt.Errorf("a grouping expression must include at least one character")
+	synErrGroupUnclosed                = fmt.Errorf("unclosed grouping expression")
+	synErrGroupNoInitiator             = fmt.Errorf(") needs preceding (")
+	synErrGroupInvalidForm             = fmt.Errorf("invalid grouping expression")
+	synErrBExpNoElem                   = fmt.Errorf("a bracket expression must include at least one character")
+	synErrBExpUnclosed                 = fmt.Errorf("unclosed bracket expression")
+	synErrBExpInvalidForm              = fmt.Errorf("invalid bracket expression")
+	synErrBExpSubtractMisplaced        = fmt.Errorf("a subtraction must appear at the end of a bracket expression")
+	synErrRangeInvalidOrder            = fmt.Errorf("a range expression with invalid order")
+	synErrRangePropIsUnavailable       = fmt.Errorf("a property expression is unavailable in a range expression")
+	synErrRangePOSIXClassIsUnavailable = fmt.Errorf("a POSIX character class is unavailable in a range expression")
+	synErrRangeInvalidForm             = fmt.Errorf("invalid range expression")
+	synErrCPExpInvalidForm             = fmt.Errorf("invalid code point expression")
+	synErrCPExpOutOfRange              = fmt.Errorf("a code point must be between U+0000 to U+10FFFF")
+	synErrCharPropExpInvalidForm       = fmt.Errorf("invalid character property expression")
+	synErrCharPropUnsupported          = fmt.Errorf("unsupported character property")
+	synErrFragmentExpInvalidForm       = fmt.Errorf("invalid fragment expression")
+	synErrPOSIXClassUnsupported        = fmt.Errorf("unsupported POSIX character class")
+	synErrAnchorMisplaced              = fmt.Errorf("an anchor must appear at the beginning or end of a pattern")
+	synErrAnchorAlt                    = fmt.Errorf("an anchor cannot apply to an alternation without a group")
+
+	// semantic errors
+	SemErrNegatedFragmentNotCharSet  = fmt.Errorf("a negated fragment must be a character set")
+	SemErrNegatedFragmentUnmatchable = fmt.Errorf("a negated fragment cannot match any characters")
+	SemErrFragmentExpansionTooLarge  = fmt.Errorf("expanding fragments makes a pattern too large")
 )
+
+// FragmentError represents an error that occurs when a pattern refers to a fragment in an invalid way.
+type FragmentError struct {
+	// Kind is a kind name of the pattern referring to the fragment.
+	Kind spec.LexKindName
+
+	// Fragment is a kind name of the referred fragment.
+	Fragment spec.LexKindName
+
+	Cause error
+}
+
+func (e *FragmentError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Kind, e.Cause, e.Fragment)
+}
@@ -11,7 +11,10 @@ type incompleteFragment struct {
 	root *rootNode
 }
 
-func CompleteFragments(fragments map[spec.LexKindName]CPTree) error {
+// CompleteFragments expands the fragments that fragments refer to. When a fragment would consist of more than maxNodes
+// nodes after the expansion, this function returns a FragmentError instead of expanding it. A non-positive maxNodes
+// means no limit.
+func CompleteFragments(fragments map[spec.LexKindName]CPTree, maxNodes int) error {
 	if len(fragments) == 0 {
 		return nil
 	}
@@ -36,7 +39,7 @@ func CompleteFragments(fragments map[spec.LexKindName]CPTree) error {
 		lastIncompCount := len(incompleteFragments)
 		remainingFragments := []*incompleteFragment{}
 		for _, e := range incompleteFragments {
-			complete, err := ApplyFragments(e.root, completeFragments)
+			complete, err := ApplyFragments(e.root, completeFragments, maxNodes)
 			if err != nil {
 				return err
 			}
@@ -55,14 +58,16 @@ func CompleteFragments(fragments map[spec.LexKindName]CPTree) error {
 	return nil
 }
 
-func ApplyFragments(t CPTree, fragments map[spec.LexKindName]CPTree) (bool, error) {
+// ApplyFragments expands the fragments that a tree refers to and reports whether the tree no longer refers to
+// undefined fragments. maxNodes limits the number of nodes of the expanded tree as CompleteFragments does.
+func ApplyFragments(t CPTree, fragments map[spec.LexKindName]CPTree, maxNodes int) (bool, error) {
 	root, ok := t.(*rootNode)
 	if !ok {
 		return false, fmt.Errorf("ApplyFragments can take only *rootNode type: %T", t)
 	}
 
 	for name, frag := range fragments {
-		err := root.applyFragment(name, frag)
+		err := root.applyFragment(name, frag, maxNodes)
 		if err != nil {
 			return false, err
 		}
@@ -12,25 +12,34 @@ type tokenKind string
 const (
 	tokenKindChar            tokenKind = "char"
 	tokenKindAnyChar         tokenKind = "."
+	tokenKindAnyByte         tokenKind = "\\C"
 	tokenKindRepeat          tokenKind = "*"
 	tokenKindRepeatOneOrMore tokenKind = "+"
 	tokenKindOption          tokenKind = "?"
 	tokenKindAlt             tokenKind = "|"
 	tokenKindGroupOpen       tokenKind = "("
 	tokenKindGroupClose      tokenKind = ")"
+	tokenKindLineStart       tokenKind = "^"
+	tokenKindLineEnd         tokenKind = "$"
+	tokenKindWordBoundary    tokenKind = "\\b"
+	tokenKindNonWordBoundary tokenKind = "\\B"
 	tokenKindBExpOpen        tokenKind = "["
 	tokenKindInverseBExpOpen tokenKind = "[^"
 	tokenKindBExpClose       tokenKind = "]"
+	tokenKindBExpSubtract    tokenKind = "-["
 	tokenKindCharRange       tokenKind = "-"
 	tokenKindCodePointLeader tokenKind = "\\u"
 	tokenKindCharPropLeader  tokenKind = "\\p"
+	tokenKindNegPropLeader   tokenKind = "\\P"
 	tokenKindFragmentLeader  tokenKind = "\\f"
+	tokenKindNegFragLeader   tokenKind = "\\F"
 	tokenKindLBrace          tokenKind = "{"
 	tokenKindRBrace          tokenKind = "}"
 	tokenKindEqual           tokenKind = "="
 	tokenKindCodePoint       tokenKind = "code point"
 	tokenKindCharPropSymbol  tokenKind = "character property symbol"
 	tokenKindFragmentSymbol  tokenKind = "fragment symbol"
+	tokenKindPOSIXClass      tokenKind = "POSIX character class"
 	tokenKindEOF             tokenKind = "eof"
 )
 
@@ -40,6 +49,10 @@ type token struct {
 	propSymbol     string
 	codePoint      string
 	fragmentSymbol string
+	posixClassName string
+
+	// col is a column number in code points where the token begins. The column starts at 1.
+	col int
 }
 
 const nullChar = '\u0000'
@@ -72,6 +85,13 @@ func newFragmentSymbolToken(fragmentSymbol string) *token {
 	}
 }
 
+func newPOSIXClassToken(name string) *token {
+	return &token{
+		kind:           tokenKindPOSIXClass,
+		posixClassName: name,
+	}
+}
+
 type lexerMode string
 
 const (
@@ -135,8 +155,12 @@ type lexer struct {
 	modeStack  *lexerModeStack
 	rangeState rangeState
 
+	// pos is the number of characters the lexer has read.
+	pos int
+
 	errCause  error
 	errDetail string
+	errCol    int
 }
 
 func newLexer(src io.Reader) *lexer {
@@ -161,7 +185,21 @@ func (l *lexer) error() (string, error) {
 	return l.errDetail, l.errCause
 }
 
+// next returns the next token. When the lexer fails to read the token, it records the column where the token begins.
 func (l *lexer) next() (*token, error) {
+	col := l.pos + 1
+	tok, err := l.nextToken()
+	if err != nil {
+		if err == ParseErr {
+			l.errCol = col
+		}
+		return nil, err
+	}
+	tok.col = col
+	return tok, nil
+}
+
+func (l *lexer) nextToken() (*token, error) {
 	c, eof, err := l.read()
 	if err != nil {
 		return nil, err
@@ -176,7 +214,7 @@ func (l *lexer) next() (*token, error) {
 		if err != nil {
 			return nil, err
 		}
-		if tok.kind == tokenKindChar || tok.kind == tokenKindCodePointLeader || tok.kind == tokenKindCharPropLeader {
+		if tok.kind == tokenKindChar || tok.kind == tokenKindCodePointLeader || tok.kind == tokenKindCharPropLeader || tok.kind == tokenKindNegPropLeader || tok.kind == tokenKindPOSIXClass {
 			switch l.rangeState {
 			case rangeStateReady:
 				l.rangeState = rangeStateReadRangeInitiator
@@ -187,11 +225,14 @@ func (l *lexer) next() (*token, error) {
 		switch tok.kind {
 		case tokenKindBExpClose:
 			l.modeStack.pop()
+		case tokenKindBExpSubtract:
+			l.modeStack.push(lexerModeBExp)
+			l.rangeState = rangeStateReady
 		case tokenKindCharRange:
 			l.rangeState = rangeStateExpectRangeTerminator
 		case tokenKindCodePointLeader:
 			l.modeStack.push(lexerModeCPExp)
-		case tokenKindCharPropLeader:
+		case tokenKindCharPropLeader, tokenKindNegPropLeader:
 			l.modeStack.push(lexerModeCharPropExp)
 		}
 		return tok, nil
@@ -239,9 +280,9 @@ func (l *lexer) next() (*token, error) {
 			l.rangeState = rangeStateReady
 		case tokenKindCodePointLeader:
 			l.modeStack.push(lexerModeCPExp)
-		case tokenKindCharPropLeader:
+		case tokenKindCharPropLeader, tokenKindNegPropLeader:
 			l.modeStack.push(lexerModeCharPropExp)
-		case tokenKindFragmentLeader:
+		case tokenKindFragmentLeader, tokenKindNegFragLeader:
 			l.modeStack.push(lexerModeFragmentExp)
 		}
 		return tok, nil
@@ -264,6 +305,10 @@ func (l *lexer) nextInDefault(c rune) (*token, error) {
 		return newToken(tokenKindGroupOpen, nullChar), nil
 	case ')':
 		return newToken(tokenKindGroupClose, nullChar), nil
+	case '^':
+		return newToken(tokenKindLineStart, nullChar), nil
+	case '$':
+		return newToken(tokenKindLineEnd, nullChar), nil
 	case '[':
 		c1, eof, err := l.read()
 		if err != nil {
@@ -325,10 +370,25 @@ func (l *lexer) nextInDefault(c rune) (*token, error) {
 		if c == 'p' {
 			return newToken(tokenKindCharPropLeader, nullChar), nil
 		}
+		if c == 'P' {
+			return newToken(tokenKindNegPropLeader, nullChar), nil
+		}
 		if c == 'f' {
 			return newToken(tokenKindFragmentLeader, nullChar), nil
 		}
-		if c == '\\' || c == '.' || c == '*' || c == '+' || c == '?' || c == '|' || c == '(' || c == ')' || c == '[' || c == ']' {
+		if c == 'F' {
+			return newToken(tokenKindNegFragLeader, nullChar), nil
+		}
+		if c == 'C' {
+			return newToken(tokenKindAnyByte, nullChar), nil
+		}
+		if c == 'b' {
+			return newToken(tokenKindWordBoundary, nullChar), nil
+		}
+		if c == 'B' {
+			return newToken(tokenKindNonWordBoundary, nullChar), nil
+		}
+		if c == '\\' || c == '.' || c == '*' || c == '+' || c == '?' || c == '|' || c == '(' || c == ')' || c == '[' || c == ']' || c == '^' || c == '$' {
 			return newToken(tokenKindChar, c), nil
 		}
 		l.errCause = synErrInvalidEscSeq
@@ -342,10 +402,37 @@ func (l *lexer) nextInDefault(c rune) (*token, error) {
 func (l *lexer) nextInBExp(c rune) (*token, error) {
 	switch c {
 	case '-':
+		// `-[` is a subtraction of a nested bracket expression regardless of the range state. However, `-[:` is
+		// a hyphen followed by a POSIX character class.
+		c1, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		if !eof && c1 == '[' {
+			c2, eof, err := l.read()
+			if err != nil {
+				return nil, err
+			}
+			if eof || c2 != ':' {
+				err := l.restore()
+				if err != nil {
+					return nil, err
+				}
+				return newToken(tokenKindBExpSubtract, nullChar), nil
+			}
+			err = l.restore()
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = l.restore()
+		if err != nil {
+			return nil, err
+		}
 		if l.rangeState != rangeStateReadRangeInitiator {
 			return newToken(tokenKindChar, c), nil
 		}
-		c1, eof, err := l.read()
+		c1, eof, err = l.read()
 		if err != nil {
 			return nil, err
 		}
@@ -370,6 +457,19 @@ func (l *lexer) nextInBExp(c rune) (*token, error) {
 		return newToken(tokenKindChar, c), nil
 	case ']':
 		return newToken(tokenKindBExpClose, nullChar), nil
+	case '[':
+		c1, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		if eof || c1 != ':' {
+			err := l.restore()
+			if err != nil {
+				return nil, err
+			}
+			return newToken(tokenKindChar, c), nil
+		}
+		return l.nextInPOSIXClass()
 	case '\\':
 		c, eof, err := l.read()
 		if err != nil {
@@ -385,6 +485,9 @@ func (l *lexer) nextInBExp(c rune) (*token, error) {
 		if c == 'p' {
 			return newToken(tokenKindCharPropLeader, nullChar), nil
 		}
+		if c == 'P' {
+			return newToken(tokenKindNegPropLeader, nullChar), nil
+		}
 		if c == '\\' || c == '^' || c == '-' || c == ']' {
 			return newToken(tokenKindChar, c), nil
 		}
@@ -396,6 +499,55 @@ func (l *lexer) nextInBExp(c rune) (*token, error) {
 	}
 }
 
+// nextInPOSIXClass reads a POSIX character class, such as [:alpha:], following the leading `[:`. A `[:` must be closed
+// with `:]`.
+func (l *lexer) nextInPOSIXClass() (*token, error) {
+	var b strings.Builder
+	for {
+		c, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		if eof || c == ']' {
+			l.errCause = synErrPOSIXClassUnclosed
+			return nil, ParseErr
+		}
+		if c == ':' {
+			break
+		}
+		fmt.Fprint(&b, string(c))
+	}
+	c, eof, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	if eof || c != ']' {
+		l.errCause = synErrPOSIXClassUnclosed
+		return nil, ParseErr
+	}
+	return newPOSIXClassToken(b.String()), nil
+}
+
+// codePointAliases maps names of well-known code points to their hexadecimal forms. A code point expression accepts
+// these names instead of hexadecimal numbers, e.g., `\u{LF}` is the same as `\u{000A}`. No name consists of 4 or 6
+// hexadecimal digits, so the names never conflict with the numeric forms.
+var codePointAliases = map[string]string{
+	"NUL":  "0000",
+	"TAB":  "0009",
+	"LF":   "000A",
+	"VT":   "000B",
+	"FF":   "000C",
+	"CR":   "000D",
+	"ESC":  "001B",
+	"SP":   "0020",
+	"DEL":  "007F",
+	"NEL":  "0085",
+	"NBSP": "00A0",
+	"LS":   "2028",
+	"PS":   "2029",
+	"BOM":  "FEFF",
+}
+
 func (l *lexer) nextInCodePoint(c rune) (*token, error) {
 	switch c {
 	case '{':
@@ -403,7 +555,7 @@ func (l *lexer) nextInCodePoint(c rune) (*token, error) {
 	case '}':
 		return newToken(tokenKindRBrace, nullChar), nil
 	default:
-		if !isHexDigit(c) {
+		if !isAlphanumeric(c) {
 			l.errCause = synErrInvalidCodePoint
 			return nil, ParseErr
 		}
@@ -429,7 +581,7 @@ func (l *lexer) nextInCodePoint(c rune) (*token, error) {
 				}
 				break
 			}
-			if !isHexDigit(c) || n >= 6 {
+			if !isAlphanumeric(c) || n >= 6 {
 				l.errCause = synErrInvalidCodePoint
 				return nil, ParseErr
 			}
@@ -437,20 +589,30 @@ func (l *lexer) nextInCodePoint(c rune) (*token, error) {
 			n++
 		}
 		cp := b.String()
+		if hex, ok := codePointAliases[cp]; ok {
+			return newCodePointToken(hex), nil
+		}
 		cpLen := len(cp)
 		if !(cpLen == 4 || cpLen == 6) {
 			l.errCause = synErrInvalidCodePoint
 			return nil, ParseErr
 		}
-		return newCodePointToken(b.String()), nil
+		for _, c := range cp {
+			if !isHexDigit(c) {
+				l.errCause = synErrInvalidCodePoint
+				return nil, ParseErr
+			}
+		}
+		return newCodePointToken(cp), nil
 	}
 }
 
+func isAlphanumeric(c rune) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
 func isHexDigit(c rune) bool {
-	if c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
-		return true
-	}
-	return false
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'F' || c >= 'a' && c <= 'f'
 }
 
 func (l *lexer) nextInCharProp(c rune) (*token, error) {
@@ -552,6 +714,9 @@ func (l *lexer) read() (rune, bool, error) {
 		l.peekEOF1 = l.peekEOF2
 		l.peekChar2 = nullChar
 		l.peekEOF2 = false
+		if !l.reachedEOF {
+			l.pos++
+		}
 		return l.lastChar, l.reachedEOF, nil
 	}
 	c, _, err := l.src.ReadRune()
@@ -573,6 +738,7 @@ func (l *lexer) read() (rune, bool, error) {
 	l.prevEOF1 = l.reachedEOF
 	l.lastChar = c
 	l.reachedEOF = false
+	l.pos++
 	return l.lastChar, l.reachedEOF, nil
 }
 
@@ -580,6 +746,9 @@ func (l *lexer) restore() error {
 	if l.lastChar == nullChar && !l.reachedEOF {
 		return fmt.Errorf("failed to call restore() because the last character is null")
 	}
+	if !l.reachedEOF {
+		l.pos--
+	}
 	l.peekChar2 = l.peekChar1
 	l.peekEOF2 = l.peekEOF1
 	l.peekChar1 = l.lastChar
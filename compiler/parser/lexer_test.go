@@ -30,7 +30,7 @@ func TestLexer(t *testing.T) {
 		},
 		{
 			caption: "lexer can recognize the special characters in default mode",
-			src:     ".*+?|()[\\u",
+			src:     ".*+?|()^$[\\u",
 			tokens: []*token{
 				newToken(tokenKindAnyChar, nullChar),
 				newToken(tokenKindRepeat, nullChar),
@@ -39,6 +39,8 @@ func TestLexer(t *testing.T) {
 				newToken(tokenKindAlt, nullChar),
 				newToken(tokenKindGroupOpen, nullChar),
 				newToken(tokenKindGroupClose, nullChar),
+				newToken(tokenKindLineStart, nullChar),
+				newToken(tokenKindLineEnd, nullChar),
 				newToken(tokenKindBExpOpen, nullChar),
 				newToken(tokenKindCodePointLeader, nullChar),
 				newToken(tokenKindEOF, nullChar),
@@ -46,7 +48,7 @@ func TestLexer(t *testing.T) {
 		},
 		{
 			caption: "lexer can recognize the escape sequences in default mode",
-			src:     "\\\\\\.\\*\\+\\?\\|\\(\\)\\[",
+			src:     "\\\\\\.\\*\\+\\?\\|\\(\\)\\[\\^\\$",
 			tokens: []*token{
 				newToken(tokenKindChar, '\\'),
 				newToken(tokenKindChar, '.'),
@@ -57,6 +59,8 @@ func TestLexer(t *testing.T) {
 				newToken(tokenKindChar, '('),
 				newToken(tokenKindChar, ')'),
 				newToken(tokenKindChar, '['),
+				newToken(tokenKindChar, '^'),
+				newToken(tokenKindChar, '$'),
 				newToken(tokenKindEOF, nullChar),
 			},
 		},
@@ -125,6 +129,63 @@ func TestLexer(t *testing.T) {
 				newToken(tokenKindEOF, nullChar),
 			},
 		},
+		{
+			caption: "lexer can recognize POSIX character classes in bracket expression mode",
+			src:     "[[:alpha:][:digit:]][[a][[",
+			tokens: []*token{
+				newToken(tokenKindBExpOpen, nullChar),
+				newPOSIXClassToken("alpha"),
+				newPOSIXClassToken("digit"),
+				newToken(tokenKindBExpClose, nullChar),
+				newToken(tokenKindBExpOpen, nullChar),
+				newToken(tokenKindChar, '['),
+				newToken(tokenKindChar, 'a'),
+				newToken(tokenKindBExpClose, nullChar),
+				newToken(tokenKindBExpOpen, nullChar),
+				newToken(tokenKindChar, '['),
+				newToken(tokenKindEOF, nullChar),
+			},
+		},
+		{
+			caption: "lexer can recognize subtractions in bracket expression mode",
+			src:     "[a-z-[aeiou]][a-[:alpha:]]",
+			tokens: []*token{
+				newToken(tokenKindBExpOpen, nullChar),
+				newToken(tokenKindChar, 'a'),
+				newToken(tokenKindCharRange, nullChar),
+				newToken(tokenKindChar, 'z'),
+				newToken(tokenKindBExpSubtract, nullChar),
+				newToken(tokenKindChar, 'a'),
+				newToken(tokenKindChar, 'e'),
+				newToken(tokenKindChar, 'i'),
+				newToken(tokenKindChar, 'o'),
+				newToken(tokenKindChar, 'u'),
+				newToken(tokenKindBExpClose, nullChar),
+				newToken(tokenKindBExpClose, nullChar),
+				newToken(tokenKindBExpOpen, nullChar),
+				newToken(tokenKindChar, 'a'),
+				newToken(tokenKindCharRange, nullChar),
+				newPOSIXClassToken("alpha"),
+				newToken(tokenKindBExpClose, nullChar),
+				newToken(tokenKindEOF, nullChar),
+			},
+		},
+		{
+			caption: "lexer raises an error when a POSIX character class isn't closed with :]",
+			src:     "[[:alpha]",
+			tokens: []*token{
+				newToken(tokenKindBExpOpen, nullChar),
+			},
+			err: synErrPOSIXClassUnclosed,
+		},
+		{
+			caption: "lexer raises an error when a POSIX character class reaches EOF",
+			src:     "[[:alpha:",
+			tokens: []*token{
+				newToken(tokenKindBExpOpen, nullChar),
+			},
+			err: synErrPOSIXClassUnclosed,
+		},
 		{
 			caption: "hyphen symbols that appear in bracket expressions are handled as the character range symbol or ordinary characters",
 			// [...-...][...-][-...][-]
@@ -218,6 +279,24 @@ func TestLexer(t *testing.T) {
 				newToken(tokenKindEOF, nullChar),
 			},
 		},
+		{
+			caption: "lexer can recognize the any-byte expression in default mode",
+			src:     "\\C.\\C",
+			tokens: []*token{
+				newToken(tokenKindAnyByte, nullChar),
+				newToken(tokenKindAnyChar, nullChar),
+				newToken(tokenKindAnyByte, nullChar),
+				newToken(tokenKindEOF, nullChar),
+			},
+		},
+		{
+			caption: "lexer raises an error when the any-byte expression appears in a bracket expression",
+			src:     "[\\C",
+			tokens: []*token{
+				newToken(tokenKindBExpOpen, nullChar),
+			},
+			err: synErrInvalidEscSeq,
+		},
 		{
 			caption: "lexer raises an error when an invalid escape sequence appears",
 			src:     "\\@",
@@ -401,6 +480,43 @@ func TestLexer(t *testing.T) {
 			},
 			err: synErrInvalidCodePoint,
 		},
+		{
+			caption: "a code point must be hex digits even when it consists of 4 characters",
+			src:     "\\u{ZZZZ}",
+			tokens: []*token{
+				newToken(tokenKindCodePointLeader, nullChar),
+				newToken(tokenKindLBrace, nullChar),
+			},
+			err: synErrInvalidCodePoint,
+		},
+		{
+			caption: "lexer converts names of code points into hex strings",
+			src:     "\\u{LF}\\u{NBSP}\\u{BOM}",
+			tokens: []*token{
+				newToken(tokenKindCodePointLeader, nullChar),
+				newToken(tokenKindLBrace, nullChar),
+				newCodePointToken("000A"),
+				newToken(tokenKindRBrace, nullChar),
+				newToken(tokenKindCodePointLeader, nullChar),
+				newToken(tokenKindLBrace, nullChar),
+				newCodePointToken("00A0"),
+				newToken(tokenKindRBrace, nullChar),
+				newToken(tokenKindCodePointLeader, nullChar),
+				newToken(tokenKindLBrace, nullChar),
+				newCodePointToken("FEFF"),
+				newToken(tokenKindRBrace, nullChar),
+				newToken(tokenKindEOF, nullChar),
+			},
+		},
+		{
+			caption: "an unknown name isn't a valid code point",
+			src:     "\\u{FOO}",
+			tokens: []*token{
+				newToken(tokenKindCodePointLeader, nullChar),
+				newToken(tokenKindLBrace, nullChar),
+			},
+			err: synErrInvalidCodePoint,
+		},
 		{
 			caption: "lexer can recognize the special characters and symbols in character property expression mode",
 			src:     "\\p{Letter}\\p{General_Category=Letter}[\\p{Letter}\\p{General_Category=Letter}][^\\p{Letter}\\p{General_Category=Letter}]",
@@ -445,6 +561,25 @@ func TestLexer(t *testing.T) {
 				newToken(tokenKindEOF, nullChar),
 			},
 		},
+		{
+			caption: "lexer can recognize the negated character property expressions",
+			src:     "\\P{Letter}[\\P{Letter}]",
+			tokens: []*token{
+				newToken(tokenKindNegPropLeader, nullChar),
+				newToken(tokenKindLBrace, nullChar),
+				newCharPropSymbolToken("Letter"),
+				newToken(tokenKindRBrace, nullChar),
+
+				newToken(tokenKindBExpOpen, nullChar),
+				newToken(tokenKindNegPropLeader, nullChar),
+				newToken(tokenKindLBrace, nullChar),
+				newCharPropSymbolToken("Letter"),
+				newToken(tokenKindRBrace, nullChar),
+				newToken(tokenKindBExpClose, nullChar),
+
+				newToken(tokenKindEOF, nullChar),
+			},
+		},
 		{
 			caption: "lexer can recognize the special characters and symbols in fragment expression mode",
 			src:     "\\f{integer}",
@@ -457,6 +592,26 @@ func TestLexer(t *testing.T) {
 				newToken(tokenKindEOF, nullChar),
 			},
 		},
+		{
+			caption: "lexer can recognize the special characters and symbols in negated fragment expression mode",
+			src:     "\\F{vowel}",
+			tokens: []*token{
+				newToken(tokenKindNegFragLeader, nullChar),
+				newToken(tokenKindLBrace, nullChar),
+				newFragmentSymbolToken("vowel"),
+				newToken(tokenKindRBrace, nullChar),
+
+				newToken(tokenKindEOF, nullChar),
+			},
+		},
+		{
+			caption: "a negated fragment expression is not supported in a bracket expression",
+			src:     "[\\F",
+			tokens: []*token{
+				newToken(tokenKindBExpOpen, nullChar),
+			},
+			err: synErrInvalidEscSeq,
+		},
 		{
 			caption: "a fragment expression is not supported in a bracket expression",
 			src:     "[\\f",
@@ -518,7 +673,7 @@ func TestLexer(t *testing.T) {
 
 func testToken(t *testing.T, a, e *token) {
 	t.Helper()
-	if e.kind != a.kind || e.char != a.char || e.codePoint != a.codePoint {
+	if e.kind != a.kind || e.char != a.char || e.codePoint != a.codePoint || e.posixClassName != a.posixClassName {
 		t.Fatalf("unexpected token: want: %+v, got: %+v", e, a)
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/nihei9/maleeni/spec"
 	"github.com/nihei9/maleeni/ucd"
@@ -42,6 +43,7 @@ type parser struct {
 
 	errCause  error
 	errDetail string
+	errCol    int
 }
 
 func NewParser(kind spec.LexKindName, src io.Reader) *parser {
@@ -52,6 +54,24 @@ func NewParser(kind spec.LexKindName, src io.Reader) *parser {
 	}
 }
 
+// ParseLiteral returns a tree matching a pattern literally. Unlike the parser, this function treats all characters in
+// the pattern as ordinary characters, so the pattern doesn't need any escape sequences.
+func ParseLiteral(kind spec.LexKindName, pattern []byte) (CPTree, error) {
+	var cs []CPTree
+	for len(pattern) > 0 {
+		c, size := utf8.DecodeRune(pattern)
+		if c == utf8.RuneError && size <= 1 {
+			return nil, fmt.Errorf("a literal pattern must be a valid UTF-8 byte sequence")
+		}
+		cs = append(cs, newSymbolNode(c))
+		pattern = pattern[size:]
+	}
+	if len(cs) == 0 {
+		return nil, synErrNullPattern
+	}
+	return newRootNode(kind, genConcatNode(cs...)), nil
+}
+
 func (p *parser) exposeContributoryProperty() {
 	p.isContributoryPropertyExposed = true
 }
@@ -60,6 +80,12 @@ func (p *parser) Error() (string, error) {
 	return p.errDetail, p.errCause
 }
 
+// ErrorColumn returns a column number in code points where the parser detected a syntax error. The column starts at 1.
+// Note that the column points to the token the parser was reading, which may follow the actual mistake.
+func (p *parser) ErrorColumn() int {
+	return p.errCol
+}
+
 func (p *parser) Parse() (root CPTree, retErr error) {
 	defer func() {
 		err := recover()
@@ -73,43 +99,121 @@ func (p *parser) Parse() (root CPTree, retErr error) {
 		}
 	}()
 
-	return newRootNode(p.kind, p.parseRegexp()), nil
+	tree, anchor := p.parseRegexp()
+	r := newRootNode(p.kind, tree)
+	r.anchor = anchor
+	return r, nil
 }
 
-func (p *parser) parseRegexp() CPTree {
-	alt := p.parseAlt()
+// parseRegexp parses a whole pattern. Anchors are allowed only at the beginning or end of the pattern, so this
+// function handles them here and returns them separately from the tree.
+func (p *parser) parseRegexp() (CPTree, spec.LexAnchor) {
+	anchor := spec.LexAnchorNil
+	if p.consume(tokenKindLineStart) {
+		anchor |= spec.LexAnchorLineStart
+	}
+	if p.consume(tokenKindWordBoundary) {
+		anchor |= spec.LexAnchorWordBoundaryStart
+	} else if p.consume(tokenKindNonWordBoundary) {
+		anchor |= spec.LexAnchorNonWordBoundaryStart
+	}
+	alt, alternated := p.parseAlt()
 	if alt == nil {
 		if p.consume(tokenKindGroupClose) {
 			p.raiseParseError(synErrGroupNoInitiator, "")
 		}
+		if p.consume(tokenKindLineEnd) && !p.consume(tokenKindEOF) {
+			p.raiseParseError(synErrAnchorMisplaced, "$ must appear at the end of a pattern")
+		}
+		if (p.consume(tokenKindWordBoundary) || p.consume(tokenKindNonWordBoundary)) && !p.consume(tokenKindEOF) {
+			p.raiseParseError(synErrAnchorMisplaced, wordBoundaryMisplacedHint)
+		}
 		p.raiseParseError(synErrNullPattern, "")
 	}
+	wordBoundaryEnd := false
+	if p.consume(tokenKindWordBoundary) {
+		anchor |= spec.LexAnchorWordBoundaryEnd
+		wordBoundaryEnd = true
+	} else if p.consume(tokenKindNonWordBoundary) {
+		anchor |= spec.LexAnchorNonWordBoundaryEnd
+		wordBoundaryEnd = true
+	}
+	if p.consume(tokenKindLineEnd) {
+		anchor |= spec.LexAnchorLineEnd
+		if p.consume(tokenKindGroupClose) {
+			p.raiseParseError(synErrGroupNoInitiator, "")
+		}
+		if !p.consume(tokenKindEOF) {
+			p.raiseParseError(synErrAnchorMisplaced, "$ must appear at the end of a pattern")
+		}
+		if alternated {
+			p.raiseParseError(synErrAnchorAlt, anchorAltHint)
+		}
+		return alt, anchor
+	}
 	if p.consume(tokenKindGroupClose) {
 		p.raiseParseError(synErrGroupNoInitiator, "")
 	}
+	if wordBoundaryEnd && !p.consume(tokenKindEOF) {
+		p.raiseParseError(synErrAnchorMisplaced, wordBoundaryMisplacedHint)
+	}
 	p.expect(tokenKindEOF)
-	return alt
+	if alternated && anchor != spec.LexAnchorNil {
+		p.raiseParseError(synErrAnchorAlt, anchorAltHint)
+	}
+	return alt, anchor
 }
 
-func (p *parser) parseAlt() CPTree {
-	left := p.parseConcat()
-	if left == nil {
-		if p.consume(tokenKindAlt) {
-			p.raiseParseError(synErrAltLackOfOperand, "")
+// anchorAltHint tells how to anchor an alternation. Anchors apply to a whole pattern, so `^a|b` would mean `^(a|b)`
+// unlike other regular expression engines, where it means `(^a)|b`. The parser requires the group to avoid confusion.
+const anchorAltHint = "enclose the alternation in a group, such as ^(a|b)$"
+
+// wordBoundaryMisplacedHint tells where `\b` and `\B` can appear. They can be followed by `$` at the end of a pattern.
+const wordBoundaryMisplacedHint = "\\b and \\B must appear at the beginning or end of a pattern"
+
+// parseAlt parses an alternation that cannot have empty branches. The second return value reports whether the
+// alternation has two or more branches.
+func (p *parser) parseAlt() (CPTree, bool) {
+	alt, emptyBranch, alternated := p.parseAltWithEmptyBranch()
+	if emptyBranch {
+		if p.consume(tokenKindWordBoundary) || p.consume(tokenKindNonWordBoundary) {
+			p.raiseParseError(synErrAnchorMisplaced, wordBoundaryMisplacedHint)
 		}
-		return nil
+		p.raiseParseError(synErrAltLackOfOperand, altEmptyBranchHint)
+	}
+	return alt, alternated
+}
+
+// altEmptyBranchHint tells how to write an alternation with an empty branch.
+const altEmptyBranchHint = "use (...)? to make an expression optional; (a|)? is also allowed"
+
+// parseAltWithEmptyBranch parses an alternation that may have empty branches, such as `a|` and `a||b`. It returns an
+// alternation of the non-empty branches and whether the alternation has an empty branch. When all branches are empty,
+// the returned tree is nil. The caller is responsible for rejecting empty branches where they are not allowed. The third
+// return value reports whether the alternation has two or more branches including empty ones.
+func (p *parser) parseAltWithEmptyBranch() (CPTree, bool, bool) {
+	left := p.parseConcat()
+	if left == nil && !p.consume(tokenKindAlt) {
+		return nil, false, false
+	}
+	emptyBranch := left == nil
+	if left != nil && !p.consume(tokenKindAlt) {
+		return left, false, false
 	}
 	for {
-		if !p.consume(tokenKindAlt) {
-			break
-		}
 		right := p.parseConcat()
 		if right == nil {
-			p.raiseParseError(synErrAltLackOfOperand, "")
+			emptyBranch = true
+		} else if left == nil {
+			left = right
+		} else {
+			left = newAltNode(left, right)
+		}
+		if !p.consume(tokenKindAlt) {
+			break
 		}
-		left = newAltNode(left, right)
 	}
-	return left
+	return left, emptyBranch, true
 }
 
 func (p *parser) parseConcat() CPTree {
@@ -125,7 +229,18 @@ func (p *parser) parseConcat() CPTree {
 }
 
 func (p *parser) parseRepeat() CPTree {
-	group := p.parseGroup()
+	group, emptyBranch := p.parseGroup()
+	// A grouping expression containing an empty branch, such as `(a|)`, is allowed only when it is explicitly
+	// optional. In that case, the empty branch is redundant, so the expression is equivalent to `(a)?`.
+	if emptyBranch {
+		if !p.consume(tokenKindOption) {
+			p.raiseParseError(synErrAltLackOfOperand, altEmptyBranchHint)
+		}
+		if group == nil {
+			p.raiseParseError(synErrGroupNoElem, "")
+		}
+		return newOptionNode(group)
+	}
 	if group == nil {
 		if p.consume(tokenKindRepeat) {
 			p.raiseParseError(synErrRepNoTarget, "* needs an operand")
@@ -150,10 +265,15 @@ func (p *parser) parseRepeat() CPTree {
 	return group
 }
 
-func (p *parser) parseGroup() CPTree {
+// parseGroup parses a grouping expression or a single character. The second return value reports whether the grouping
+// expression contains an empty branch.
+func (p *parser) parseGroup() (CPTree, bool) {
 	if p.consume(tokenKindGroupOpen) {
-		alt := p.parseAlt()
-		if alt == nil {
+		alt, emptyBranch, _ := p.parseAltWithEmptyBranch()
+		if p.consume(tokenKindWordBoundary) || p.consume(tokenKindNonWordBoundary) {
+			p.raiseParseError(synErrAnchorMisplaced, wordBoundaryMisplacedHint)
+		}
+		if alt == nil && !emptyBranch {
 			if p.consume(tokenKindEOF) {
 				p.raiseParseError(synErrGroupUnclosed, "")
 			}
@@ -162,18 +282,27 @@ func (p *parser) parseGroup() CPTree {
 		if p.consume(tokenKindEOF) {
 			p.raiseParseError(synErrGroupUnclosed, "")
 		}
+		if p.consume(tokenKindLineEnd) {
+			p.raiseParseError(synErrAnchorMisplaced, "$ must appear at the end of a pattern")
+		}
 		if !p.consume(tokenKindGroupClose) {
 			p.raiseParseError(synErrGroupInvalidForm, "")
 		}
-		return alt
+		return alt, emptyBranch
 	}
-	return p.parseSingleChar()
+	return p.parseSingleChar(), false
 }
 
 func (p *parser) parseSingleChar() CPTree {
+	if p.consume(tokenKindLineStart) {
+		p.raiseParseError(synErrAnchorMisplaced, "^ must appear at the beginning of a pattern")
+	}
 	if p.consume(tokenKindAnyChar) {
 		return genAnyCharAST()
 	}
+	if p.consume(tokenKindAnyByte) {
+		return newByteRangeNode(0x00, 0xFF)
+	}
 	if p.consume(tokenKindBExpOpen) {
 		left := p.parseBExpElem()
 		if left == nil {
@@ -189,10 +318,12 @@ func (p *parser) parseSingleChar() CPTree {
 			}
 			left = newAltNode(left, right)
 		}
-		if p.consume(tokenKindEOF) {
-			p.raiseParseError(synErrBExpUnclosed, "")
+		left = coalesceBExpElems(left)
+		subtracted := p.consume(tokenKindBExpSubtract)
+		if subtracted {
+			left = p.parseBExpSubtraction(left)
 		}
-		p.expect(tokenKindBExpClose)
+		p.expectBExpClose(subtracted)
 		return left
 	}
 	if p.consume(tokenKindInverseBExpOpen) {
@@ -217,20 +348,27 @@ func (p *parser) parseSingleChar() CPTree {
 				p.raiseParseError(synErrUnmatchablePattern, "")
 			}
 		}
-		if p.consume(tokenKindEOF) {
-			p.raiseParseError(synErrBExpUnclosed, "")
+		subtracted := p.consume(tokenKindBExpSubtract)
+		if subtracted {
+			inverse = p.parseBExpSubtraction(inverse)
 		}
-		p.expect(tokenKindBExpClose)
+		p.expectBExpClose(subtracted)
 		return inverse
 	}
 	if p.consume(tokenKindCodePointLeader) {
 		return p.parseCodePoint()
 	}
 	if p.consume(tokenKindCharPropLeader) {
-		return p.parseCharProp()
+		return p.parseCharProp(false)
+	}
+	if p.consume(tokenKindNegPropLeader) {
+		return p.parseCharProp(true)
 	}
 	if p.consume(tokenKindFragmentLeader) {
-		return p.parseFragment()
+		return p.parseFragment(false)
+	}
+	if p.consume(tokenKindNegFragLeader) {
+		return p.parseFragment(true)
 	}
 	c := p.parseNormalChar()
 	if c == nil {
@@ -242,16 +380,71 @@ func (p *parser) parseSingleChar() CPTree {
 	return c
 }
 
+// parseBExpSubtraction parses a nested bracket expression following `-[` and excludes characters it matches from
+// `base`. The nested bracket expression can also have a subtraction, such as `[a-z-[aeiou-[e]]]`.
+func (p *parser) parseBExpSubtraction(base CPTree) CPTree {
+	subtrahend := p.parseBExpElem()
+	if subtrahend == nil {
+		if p.consume(tokenKindEOF) {
+			p.raiseParseError(synErrBExpUnclosed, "")
+		}
+		p.raiseParseError(synErrBExpNoElem, "")
+	}
+	for {
+		elem := p.parseBExpElem()
+		if elem == nil {
+			break
+		}
+		subtrahend = newAltNode(subtrahend, elem)
+	}
+	subtrahend = coalesceBExpElems(subtrahend)
+	subtracted := p.consume(tokenKindBExpSubtract)
+	if subtracted {
+		subtrahend = p.parseBExpSubtraction(subtrahend)
+	}
+	p.expectBExpClose(subtracted)
+	diff := exclude(subtrahend, base)
+	if diff == nil {
+		p.raiseParseError(synErrUnmatchablePattern, "")
+	}
+	return coalesceBExpElems(diff)
+}
+
+// expectBExpClose expects `]` closing a bracket expression. `subtracted` must be true when the bracket expression
+// has a subtraction because nothing can follow the subtraction.
+func (p *parser) expectBExpClose(subtracted bool) {
+	if p.consume(tokenKindBExpClose) {
+		return
+	}
+	if p.consume(tokenKindEOF) {
+		p.raiseParseError(synErrBExpUnclosed, "")
+	}
+	if subtracted {
+		p.raiseParseError(synErrBExpSubtractMisplaced, "")
+	}
+	p.expect(tokenKindBExpClose)
+}
+
 func (p *parser) parseBExpElem() CPTree {
 	var left CPTree
 	switch {
 	case p.consume(tokenKindCodePointLeader):
 		left = p.parseCodePoint()
 	case p.consume(tokenKindCharPropLeader):
-		left = p.parseCharProp()
+		left = p.parseCharProp(false)
 		if p.consume(tokenKindCharRange) {
 			p.raiseParseError(synErrRangePropIsUnavailable, "")
 		}
+	case p.consume(tokenKindNegPropLeader):
+		left = p.parseCharProp(true)
+		if p.consume(tokenKindCharRange) {
+			p.raiseParseError(synErrRangePropIsUnavailable, "")
+		}
+	case p.consume(tokenKindPOSIXClass):
+		left = p.parsePOSIXClass()
+		if p.consume(tokenKindCharRange) {
+			p.raiseParseError(synErrRangePOSIXClassIsUnavailable, "")
+		}
 	default:
 		left = p.parseNormalChar()
 	}
@@ -265,8 +458,10 @@ func (p *parser) parseBExpElem() CPTree {
 	switch {
 	case p.consume(tokenKindCodePointLeader):
 		right = p.parseCodePoint()
-	case p.consume(tokenKindCharPropLeader):
+	case p.consume(tokenKindCharPropLeader), p.consume(tokenKindNegPropLeader):
 		p.raiseParseError(synErrRangePropIsUnavailable, "")
+	case p.consume(tokenKindPOSIXClass):
+		p.raiseParseError(synErrRangePOSIXClassIsUnavailable, "")
 	default:
 		right = p.parseNormalChar()
 	}
@@ -306,7 +501,9 @@ func (p *parser) parseCodePoint() CPTree {
 	return sym
 }
 
-func (p *parser) parseCharProp() CPTree {
+// parseCharProp parses a character property expression following `\p` or `\P`. When `negated` is true, the expression
+// matches characters that don't have the property.
+func (p *parser) parseCharProp(negated bool) CPTree {
 	if !p.consume(tokenKindLBrace) {
 		p.raiseParseError(synErrCharPropExpInvalidForm, "")
 	}
@@ -339,38 +536,38 @@ func (p *parser) parseCharProp() CPTree {
 		p.raiseParseError(synErrCharPropUnsupported, err.Error())
 	}
 	if pat != "" {
-		p := NewParser(p.kind, bytes.NewReader([]byte(pat)))
-		p.exposeContributoryProperty()
-		ast, err := p.Parse()
+		q := NewParser(p.kind, bytes.NewReader([]byte(pat)))
+		q.exposeContributoryProperty()
+		ast, err := q.Parse()
 		if err != nil {
+			// The error of the nested parser belongs to the pattern this parser is reading, so the parser reports it
+			// as its own error at the current position.
+			if err == ParseErr {
+				detail, cause := q.Error()
+				p.raiseParseError(cause, detail)
+			}
 			panic(err)
 		}
 		alt = ast
+		if negated {
+			alt = exclude(alt, genAnyCharAST())
+			if alt == nil {
+				p.raiseParseError(synErrUnmatchablePattern, "")
+			}
+		}
 	} else {
 		cpRanges, inverse, err := ucd.FindCodePointRanges(propName, propVal)
 		if err != nil {
 			p.raiseParseError(synErrCharPropUnsupported, err.Error())
 		}
-		if inverse {
-			r := cpRanges[0]
-			alt = exclude(newRangeSymbolNode(r.From, r.To), genAnyCharAST())
-			if alt == nil {
+		// `\P` negates a property that is already inverse, such as `\p{Other_Alphabetic=no}`, into the original one.
+		if inverse != negated {
+			cpRanges = complementCodePointRanges(cpRanges)
+			if len(cpRanges) == 0 {
 				p.raiseParseError(synErrUnmatchablePattern, "")
 			}
-			for _, r := range cpRanges[1:] {
-				alt = exclude(newRangeSymbolNode(r.From, r.To), alt)
-				if alt == nil {
-					p.raiseParseError(synErrUnmatchablePattern, "")
-				}
-			}
-		} else {
-			for _, r := range cpRanges {
-				alt = genAltNode(
-					alt,
-					newRangeSymbolNode(r.From, r.To),
-				)
-			}
 		}
+		alt = genBalancedAltNode(cpRanges)
 	}
 
 	if !p.consume(tokenKindRBrace) {
@@ -380,7 +577,64 @@ func (p *parser) parseCharProp() CPTree {
 	return alt
 }
 
-func (p *parser) parseFragment() CPTree {
+// posixClasses maps the names of POSIX character classes to their code point ranges. The parser supports only
+// the ASCII range to keep DFAs small.
+var posixClasses = map[string][]*ucd.CodePointRange{
+	"alpha": {
+		{From: 'A', To: 'Z'},
+		{From: 'a', To: 'z'},
+	},
+	"digit": {
+		{From: '0', To: '9'},
+	},
+	"alnum": {
+		{From: '0', To: '9'},
+		{From: 'A', To: 'Z'},
+		{From: 'a', To: 'z'},
+	},
+	"space": {
+		// HT, LF, VT, FF, and CR
+		{From: 0x09, To: 0x0D},
+		{From: ' ', To: ' '},
+	},
+	"upper": {
+		{From: 'A', To: 'Z'},
+	},
+	"lower": {
+		{From: 'a', To: 'z'},
+	},
+	"xdigit": {
+		{From: '0', To: '9'},
+		{From: 'A', To: 'F'},
+		{From: 'a', To: 'f'},
+	},
+	"punct": {
+		{From: '!', To: '/'},
+		{From: ':', To: '@'},
+		{From: '[', To: '`'},
+		{From: '{', To: '~'},
+	},
+}
+
+func (p *parser) parsePOSIXClass() CPTree {
+	name := p.lastTok.posixClassName
+	cpRanges, ok := posixClasses[name]
+	if !ok {
+		p.raiseParseError(synErrPOSIXClassUnsupported, fmt.Sprintf("[:%v:]", name))
+	}
+	var alt CPTree
+	for _, r := range cpRanges {
+		alt = genAltNode(
+			alt,
+			newRangeSymbolNode(r.From, r.To),
+		)
+	}
+	return alt
+}
+
+// parseFragment parses a fragment expression. When `negated` is true, the expression is `\F{...}` matching any one
+// character that the fragment doesn't match.
+func (p *parser) parseFragment(negated bool) CPTree {
 	if !p.consume(tokenKindLBrace) {
 		p.raiseParseError(synErrFragmentExpInvalidForm, "")
 	}
@@ -393,7 +647,9 @@ func (p *parser) parseFragment() CPTree {
 		p.raiseParseError(synErrFragmentExpInvalidForm, "")
 	}
 
-	return newFragmentNode(spec.LexKindName(sym), nil)
+	f := newFragmentNode(spec.LexKindName(sym), nil)
+	f.negated = negated
+	return f
 }
 
 func (p *parser) parseNormalChar() CPTree {
@@ -404,6 +660,11 @@ func (p *parser) parseNormalChar() CPTree {
 }
 
 func exclude(symbol, base CPTree) CPTree {
+	// When the previous exclusion has removed all characters from the base, nothing remains.
+	if base == nil {
+		return nil
+	}
+
 	if left, right, ok := symbol.Alternatives(); ok {
 		return exclude(right, exclude(left, base))
 	}
@@ -424,13 +685,13 @@ func exclude(symbol, base CPTree) CPTree {
 		switch {
 		case sFrom > bFrom && sTo < bTo:
 			return genAltNode(
-				newRangeSymbolNode(bFrom, sFrom-1),
-				newRangeSymbolNode(sTo+1, bTo),
+				genNonSurrogateRangeNode(bFrom, sFrom-1),
+				genNonSurrogateRangeNode(sTo+1, bTo),
 			)
 		case sFrom <= bFrom && sTo >= bFrom && sTo < bTo:
-			return newRangeSymbolNode(sTo+1, bTo)
+			return genNonSurrogateRangeNode(sTo+1, bTo)
 		case sFrom > bFrom && sFrom <= bTo && sTo >= bTo:
-			return newRangeSymbolNode(bFrom, sFrom-1)
+			return genNonSurrogateRangeNode(bFrom, sFrom-1)
 		case sFrom <= bFrom && sTo >= bTo:
 			return nil
 		default:
@@ -445,6 +706,35 @@ func genAnyCharAST() CPTree {
 	return newRangeSymbolNode(0x0, 0x10FFFF)
 }
 
+const (
+	surrogateMin rune = 0xD800
+	surrogateMax rune = 0xDFFF
+)
+
+// trimSurrogates moves the ends of a range <from..to> out of the surrogate code points <U+D800..U+DFFF>. UTF-8 can't
+// encode surrogate code points, and the conversion into UTF-8 rejects a range whose end is one of them. Surrogate
+// code points inside a range are harmless because the conversion skips them. When the range consists only of
+// surrogate code points, the third return value is false.
+func trimSurrogates(from, to rune) (rune, rune, bool) {
+	if from >= surrogateMin && from <= surrogateMax {
+		from = surrogateMax + 1
+	}
+	if to >= surrogateMin && to <= surrogateMax {
+		to = surrogateMin - 1
+	}
+	return from, to, from <= to
+}
+
+// genNonSurrogateRangeNode generates a range node of <from..to> whose ends aren't surrogate code points. When the
+// range consists only of surrogate code points, this function returns nil.
+func genNonSurrogateRangeNode(from, to rune) CPTree {
+	from, to, ok := trimSurrogates(from, to)
+	if !ok {
+		return nil
+	}
+	return newRangeSymbolNode(from, to)
+}
+
 func isValidOrder(from, to rune) bool {
 	return from <= to
 }
@@ -470,6 +760,78 @@ func genConcatNode(cs ...CPTree) CPTree {
 	return concat
 }
 
+// coalesceBExpElems merges the code point ranges of all elements in a bracket expression, such as `[\p{Lu}\p{Ll}]`,
+// into a single sorted set of ranges. Without this, each element remains its own tree of alternatives, and
+// properties overlapping each other bloat the AST and the DFA. When the tree contains a node other than alternatives
+// and code point ranges, this function returns the tree as it is.
+func coalesceBExpElems(elems CPTree) CPTree {
+	cpRanges, ok := CollectCodePointRanges(elems, nil)
+	if !ok {
+		return elems
+	}
+	return genBalancedAltNode(ucd.NormalizeCodePointRanges(cpRanges))
+}
+
+// CollectCodePointRanges appends the code point ranges that a tree consisting of alternatives and code point ranges
+// matches to cpRanges. When the tree contains other nodes, the second return value is false.
+func CollectCodePointRanges(t CPTree, cpRanges []*ucd.CodePointRange) ([]*ucd.CodePointRange, bool) {
+	if left, right, ok := t.Alternatives(); ok {
+		cpRanges, ok = CollectCodePointRanges(left, cpRanges)
+		if !ok {
+			return nil, false
+		}
+		return CollectCodePointRanges(right, cpRanges)
+	}
+	if from, to, ok := t.Range(); ok {
+		return append(cpRanges, &ucd.CodePointRange{
+			From: from,
+			To:   to,
+		}), true
+	}
+	return nil, false
+}
+
+// complementCodePointRanges returns the code point ranges not covered by cpRanges. cpRanges must be sorted and
+// coalesced by ucd.NormalizeCodePointRanges.
+func complementCodePointRanges(cpRanges []*ucd.CodePointRange) []*ucd.CodePointRange {
+	var comp []*ucd.CodePointRange
+	appendRange := func(from, to rune) {
+		from, to, ok := trimSurrogates(from, to)
+		if !ok {
+			return
+		}
+		comp = append(comp, &ucd.CodePointRange{
+			From: from,
+			To:   to,
+		})
+	}
+	var from rune = 0x0
+	for _, r := range cpRanges {
+		if r.From > from {
+			appendRange(from, r.From-1)
+		}
+		from = r.To + 1
+	}
+	if from <= 0x10FFFF {
+		appendRange(from, 0x10FFFF)
+	}
+	return comp
+}
+
+// genBalancedAltNode generates a tree of alternatives from code point ranges. Unlike genAltNode, which generates
+// a left-leaning tree, this function splits the ranges in half recursively so that the depth of the tree is
+// logarithmic in the number of the ranges. Properties like \p{Letter} consist of hundreds of ranges.
+func genBalancedAltNode(cpRanges []*ucd.CodePointRange) CPTree {
+	switch len(cpRanges) {
+	case 0:
+		return nil
+	case 1:
+		return newRangeSymbolNode(cpRanges[0].From, cpRanges[0].To)
+	}
+	mid := len(cpRanges) / 2
+	return newAltNode(genBalancedAltNode(cpRanges[:mid]), genBalancedAltNode(cpRanges[mid:]))
+}
+
 func genAltNode(cs ...CPTree) CPTree {
 	nonNilNodes := []CPTree{}
 	for _, c := range cs {
@@ -509,7 +871,7 @@ func (p *parser) consume(expected tokenKind) bool {
 		if err != nil {
 			if err == ParseErr {
 				detail, cause := p.lex.error()
-				p.raiseParseError(cause, detail)
+				p.raiseParseErrorAt(cause, detail, p.lex.errCol)
 			}
 			panic(err)
 		}
@@ -524,8 +886,22 @@ func (p *parser) consume(expected tokenKind) bool {
 	return false
 }
 
+// raiseParseError raises a syntax error at the token the parser consumed or peeked last.
 func (p *parser) raiseParseError(err error, detail string) {
+	tok := p.lastTok
+	if tok == nil {
+		tok = p.peekedTok
+	}
+	col := 0
+	if tok != nil {
+		col = tok.col
+	}
+	p.raiseParseErrorAt(err, detail, col)
+}
+
+func (p *parser) raiseParseErrorAt(err error, detail string, col int) {
 	p.errCause = err
 	p.errDetail = detail
+	p.errCol = col
 	panic(ParseErr)
 }
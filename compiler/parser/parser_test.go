@@ -15,6 +15,7 @@ func TestParse(t *testing.T) {
 		pattern     string
 		fragments   map[spec.LexKindName]string
 		ast         CPTree
+		anchor      spec.LexAnchor
 		syntaxError error
 
 		// When an AST is large, as patterns containing a character property expression, this test only checks
@@ -43,11 +44,7 @@ func TestParse(t *testing.T) {
 		{
 			pattern: "[abc]?",
 			ast: newOptionNode(
-				genAltNode(
-					newSymbolNode('a'),
-					newSymbolNode('b'),
-					newSymbolNode('c'),
-				),
+				newRangeSymbolNode('a', 'c'),
 			),
 		},
 		{
@@ -139,11 +136,7 @@ func TestParse(t *testing.T) {
 		{
 			pattern: "[abc]*",
 			ast: newRepeatNode(
-				genAltNode(
-					newSymbolNode('a'),
-					newSymbolNode('b'),
-					newSymbolNode('c'),
-				),
+				newRangeSymbolNode('a', 'c'),
 			),
 		},
 		{
@@ -232,17 +225,9 @@ func TestParse(t *testing.T) {
 		{
 			pattern: "[abc]+",
 			ast: genConcatNode(
-				genAltNode(
-					newSymbolNode('a'),
-					newSymbolNode('b'),
-					newSymbolNode('c'),
-				),
+				newRangeSymbolNode('a', 'c'),
 				newRepeatNode(
-					genAltNode(
-						newSymbolNode('a'),
-						newSymbolNode('b'),
-						newSymbolNode('c'),
-					),
+					newRangeSymbolNode('a', 'c'),
 				),
 			),
 		},
@@ -380,17 +365,26 @@ func TestParse(t *testing.T) {
 			pattern: ".",
 			ast:     newRangeSymbolNode(0x00, 0x10FFFF),
 		},
+		{
+			pattern: "\\C",
+			ast:     newByteRangeNode(0x00, 0xFF),
+		},
+		{
+			pattern: "\\C+",
+			ast: genConcatNode(
+				newByteRangeNode(0x00, 0xFF),
+				newRepeatNode(
+					newByteRangeNode(0x00, 0xFF),
+				),
+			),
+		},
 		{
 			pattern: "[a]",
 			ast:     newSymbolNode('a'),
 		},
 		{
 			pattern: "[abc]",
-			ast: genAltNode(
-				newSymbolNode('a'),
-				newSymbolNode('b'),
-				newSymbolNode('c'),
-			),
+			ast:     newRangeSymbolNode('a', 'c'),
 		},
 		{
 			pattern: "[a-z]",
@@ -411,6 +405,26 @@ func TestParse(t *testing.T) {
 			pattern: "[\\u{0061}-\\u{007A}]",
 			ast:     newRangeSymbolNode('a', 'z'),
 		},
+		{
+			pattern: "[\\u{3041}-\\u{3096}\\u{30FC}]",
+			ast: newAltNode(
+				newRangeSymbolNode('\u3041', '\u3096'),
+				newSymbolNode('\u30FC'),
+			),
+		},
+		{
+			// Outside bracket expressions, `-` is an ordinary character, so the pattern isn't a range.
+			pattern: "\\u{0061}-\\u{007A}",
+			ast: genConcatNode(
+				newSymbolNode('a'),
+				newSymbolNode('-'),
+				newSymbolNode('z'),
+			),
+		},
+		{
+			pattern:     "[\\u{007A}-\\u{0061}]",
+			syntaxError: synErrRangeInvalidOrder,
+		},
 		{
 			pattern:     "[\\p{Lu}]",
 			skipTestAST: true,
@@ -427,6 +441,142 @@ func TestParse(t *testing.T) {
 			pattern:     "[\\p{Lu}-\\p{Ll}]",
 			syntaxError: synErrRangePropIsUnavailable,
 		},
+		{
+			pattern: "[[:digit:]]",
+			ast:     newRangeSymbolNode('0', '9'),
+		},
+		{
+			pattern: "[[:alpha:]_]",
+			ast: newAltNode(
+				newRangeSymbolNode('A', 'Z'),
+				newAltNode(
+					newSymbolNode('_'),
+					newRangeSymbolNode('a', 'z'),
+				),
+			),
+		},
+		{
+			pattern: "[[:xdigit:][:space:]]",
+			ast: newAltNode(
+				newAltNode(
+					newRangeSymbolNode(0x09, 0x0D),
+					newSymbolNode(' '),
+				),
+				newAltNode(
+					newRangeSymbolNode('0', '9'),
+					newAltNode(
+						newRangeSymbolNode('A', 'F'),
+						newRangeSymbolNode('a', 'f'),
+					),
+				),
+			),
+		},
+		{
+			pattern: "[^[:space:]]",
+			ast: genAltNode(
+				newRangeSymbolNode(0x00, 0x08),
+				genAltNode(
+					newRangeSymbolNode(0x0E, 0x1F),
+					newRangeSymbolNode(0x21, 0x10FFFF),
+				),
+			),
+		},
+		{
+			pattern: "[[a]",
+			ast: genAltNode(
+				newSymbolNode('['),
+				newSymbolNode('a'),
+			),
+		},
+		{
+			pattern:     "[[:foo:]]",
+			syntaxError: synErrPOSIXClassUnsupported,
+		},
+		{
+			pattern:     "[[:alpha]",
+			syntaxError: synErrPOSIXClassUnclosed,
+		},
+		{
+			pattern:     "[[:alpha:",
+			syntaxError: synErrPOSIXClassUnclosed,
+		},
+		{
+			pattern:     "[[:alpha:]-z]",
+			syntaxError: synErrRangePOSIXClassIsUnavailable,
+		},
+		{
+			pattern:     "[a-[:alpha:]]",
+			syntaxError: synErrRangePOSIXClassIsUnavailable,
+		},
+		{
+			pattern: "[a-z-[aeiou]]",
+			ast: newAltNode(
+				newAltNode(
+					newRangeSymbolNode('b', 'd'),
+					newRangeSymbolNode('f', 'h'),
+				),
+				newAltNode(
+					newRangeSymbolNode('j', 'n'),
+					newAltNode(
+						newRangeSymbolNode('p', 't'),
+						newRangeSymbolNode('v', 'z'),
+					),
+				),
+			),
+		},
+		{
+			pattern: "[a-f-[x-z]]",
+			ast:     newRangeSymbolNode('a', 'f'),
+		},
+		{
+			pattern: "[a-f-[d-z]]",
+			ast:     newRangeSymbolNode('a', 'c'),
+		},
+		{
+			pattern: "[a-z-[b-y-[m]]]",
+			ast: genAltNode(
+				newSymbolNode('a'),
+				genAltNode(
+					newSymbolNode('m'),
+					newSymbolNode('z'),
+				),
+			),
+		},
+		{
+			pattern: "[^a-z-[\\u{0000}-\\u{005F}]]",
+			ast: genAltNode(
+				newSymbolNode('`'),
+				newRangeSymbolNode('{', 0x10FFFF),
+			),
+		},
+		{
+			pattern:     "[+-[]",
+			syntaxError: synErrBExpNoElem,
+		},
+		{
+			pattern:     "[a-z-[aeiou]x]",
+			syntaxError: synErrBExpSubtractMisplaced,
+		},
+		{
+			pattern:     "[a-z-[aeiou]-[x]]",
+			syntaxError: synErrBExpSubtractMisplaced,
+		},
+		{
+			pattern:     "[a-z-[]]",
+			syntaxError: synErrBExpNoElem,
+		},
+		{
+			pattern:     "[a-z-[aeiou]",
+			syntaxError: synErrBExpUnclosed,
+		},
+		{
+			pattern:     "[a-z-[a-z]]",
+			syntaxError: synErrUnmatchablePattern,
+		},
+		{
+			pattern:     "[-[a]]",
+			syntaxError: synErrBExpNoElem,
+		},
 		{
 			pattern:     "[z-a]",
 			syntaxError: synErrRangeInvalidOrder,
@@ -563,8 +713,8 @@ func TestParse(t *testing.T) {
 		{
 			pattern: "[a-]",
 			ast: genAltNode(
-				newSymbolNode('a'),
 				newSymbolNode('-'),
+				newSymbolNode('a'),
 			),
 		},
 		{
@@ -624,6 +774,34 @@ func TestParse(t *testing.T) {
 				newRangeSymbolNode('z'+1, 0x10FFFF),
 			),
 		},
+		// UTF-8 can't encode the surrogate code points <U+D800..U+DFFF>, so the ends of a complement must not be them.
+		{
+			pattern: "[^\\u{0000}-\\u{D7FF}]",
+			ast:     newRangeSymbolNode(0xE000, 0x10FFFF),
+		},
+		{
+			pattern: "[^\\u{E000}-\\u{10FFFF}]",
+			ast:     newRangeSymbolNode(0x0000, 0xD7FF),
+		},
+		{
+			pattern:     "[^\\u{0000}-\\u{D8FF}\\u{DE00}-\\u{10FFFF}]",
+			syntaxError: synErrUnmatchablePattern,
+		},
+		{
+			pattern:     "[^\\u{0000}-\\u{D7FF}\\u{E000}-\\u{10FFFF}]",
+			syntaxError: synErrUnmatchablePattern,
+		},
+		{
+			pattern: "[^\\u{D7FF}-\\u{E000}]",
+			ast: genAltNode(
+				newRangeSymbolNode(0x0000, 0xD7FE),
+				newRangeSymbolNode(0xE001, 0x10FFFF),
+			),
+		},
+		{
+			pattern: "[\\u{0000}-\\u{10FFFF}--[\\u{0000}-\\u{D7FF}]]",
+			ast:     newRangeSymbolNode(0xE000, 0x10FFFF),
+		},
 		{
 			pattern: "[^az]",
 			ast: genAltNode(
@@ -658,6 +836,47 @@ func TestParse(t *testing.T) {
 			pattern: "\\u{10FFFF}",
 			ast:     newSymbolNode('\U0010FFFF'),
 		},
+		{
+			pattern: "\\u{LF}",
+			ast:     newSymbolNode('\u000A'),
+		},
+		{
+			pattern: "\\u{TAB}\\u{SP}",
+			ast: newConcatNode(
+				newSymbolNode('\u0009'),
+				newSymbolNode('\u0020'),
+			),
+		},
+		{
+			pattern: "[\\u{NUL}-\\u{ESC}\\u{DEL}]",
+			ast: newAltNode(
+				newRangeSymbolNode('\u0000', '\u001B'),
+				newSymbolNode('\u007F'),
+			),
+		},
+		{
+			pattern: "[^\\u{CR}\\u{LF}]",
+			ast: newAltNode(
+				newAltNode(
+					newRangeSymbolNode(0x0000, 0x0009),
+					newRangeSymbolNode(0x000B, 0x000C),
+				),
+				newRangeSymbolNode(0x000E, 0x10FFFF),
+			),
+		},
+		{
+			// Aliases are case-sensitive.
+			pattern:     "\\u{lf}",
+			syntaxError: synErrInvalidCodePoint,
+		},
+		{
+			pattern:     "\\u{FOO}",
+			syntaxError: synErrInvalidCodePoint,
+		},
+		{
+			pattern:     "\\u{ZZZZ}",
+			syntaxError: synErrInvalidCodePoint,
+		},
 		{
 			pattern:     "\\u{110000}",
 			syntaxError: synErrCPExpOutOfRange,
@@ -694,6 +913,38 @@ func TestParse(t *testing.T) {
 			pattern:     "\\p{ General_Category = Letter }",
 			skipTestAST: true,
 		},
+		{
+			pattern:     "\\P{Letter}",
+			skipTestAST: true,
+		},
+		{
+			pattern:     "\\P{General_Category=Letter}",
+			skipTestAST: true,
+		},
+		{
+			pattern:     "[\\P{Lu}]",
+			skipTestAST: true,
+		},
+		{
+			pattern:     "\\P{Alphabetic=yes}",
+			skipTestAST: true,
+		},
+		{
+			pattern:     "[^\\P{Lu}]",
+			skipTestAST: true,
+		},
+		{
+			pattern:     "[a-\\P{Lu}]",
+			syntaxError: synErrRangePropIsUnavailable,
+		},
+		{
+			pattern:     "[\\P{Lu}-z]",
+			syntaxError: synErrRangePropIsUnavailable,
+		},
+		{
+			pattern:     "\\P{}",
+			syntaxError: synErrCharPropExpInvalidForm,
+		},
 		{
 			pattern:     "\\p",
 			syntaxError: synErrCharPropExpInvalidForm,
@@ -743,6 +994,18 @@ func TestParse(t *testing.T) {
 				),
 			),
 		},
+		{
+			pattern: "\\F{a_or_b}",
+			fragments: map[spec.LexKindName]string{
+				"a_or_b": "[ab]",
+			},
+			ast: newFragmentNode("a_or_b",
+				genAltNode(
+					newRangeSymbolNode(0x00, 'a'-1),
+					newRangeSymbolNode('b'+1, 0x10FFFF),
+				),
+			),
+		},
 		{
 			pattern: "\\f{ a2c }",
 			fragments: map[spec.LexKindName]string{
@@ -831,6 +1094,169 @@ func TestParse(t *testing.T) {
 			pattern:     "(a))",
 			syntaxError: synErrGroupNoInitiator,
 		},
+		{
+			pattern: "^a",
+			ast:     newSymbolNode('a'),
+			anchor:  spec.LexAnchorLineStart,
+		},
+		{
+			pattern: "a$",
+			ast:     newSymbolNode('a'),
+			anchor:  spec.LexAnchorLineEnd,
+		},
+		{
+			pattern: "^(a|b)$",
+			ast: genAltNode(
+				newSymbolNode('a'),
+				newSymbolNode('b'),
+			),
+			anchor: spec.LexAnchorLineStart | spec.LexAnchorLineEnd,
+		},
+		{
+			pattern:     "^(a)|(b)",
+			syntaxError: synErrAnchorAlt,
+		},
+		{
+			pattern:     "^a|b$",
+			syntaxError: synErrAnchorAlt,
+		},
+		{
+			pattern:     "^a|b",
+			syntaxError: synErrAnchorAlt,
+		},
+		{
+			pattern:     "a|b$",
+			syntaxError: synErrAnchorAlt,
+		},
+		{
+			pattern:     "\\ba|b",
+			syntaxError: synErrAnchorAlt,
+		},
+		{
+			pattern: "a|b",
+			ast: genAltNode(
+				newSymbolNode('a'),
+				newSymbolNode('b'),
+			),
+		},
+		{
+			pattern: "\\^a\\$",
+			ast: genConcatNode(
+				newSymbolNode('^'),
+				newSymbolNode('a'),
+				newSymbolNode('$'),
+			),
+		},
+		{
+			pattern: "[^$]",
+			ast: genAltNode(
+				newRangeSymbolNode(0x00, '#'),
+				newRangeSymbolNode('%', 0x10FFFF),
+			),
+		},
+		{
+			pattern:     "^",
+			syntaxError: synErrNullPattern,
+		},
+		{
+			pattern:     "$",
+			syntaxError: synErrNullPattern,
+		},
+		{
+			pattern:     "^$",
+			syntaxError: synErrNullPattern,
+		},
+		{
+			pattern:     "a^",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "^^a",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "$a",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "a$$",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "a$|b",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "a$*",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "(^a)",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "(a$)",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern: "\\ba\\b",
+			ast:     newSymbolNode('a'),
+			anchor:  spec.LexAnchorWordBoundaryStart | spec.LexAnchorWordBoundaryEnd,
+		},
+		{
+			pattern: "\\Ba\\B",
+			ast:     newSymbolNode('a'),
+			anchor:  spec.LexAnchorNonWordBoundaryStart | spec.LexAnchorNonWordBoundaryEnd,
+		},
+		{
+			pattern: "^\\ba\\B$",
+			ast:     newSymbolNode('a'),
+			anchor:  spec.LexAnchorLineStart | spec.LexAnchorWordBoundaryStart | spec.LexAnchorNonWordBoundaryEnd | spec.LexAnchorLineEnd,
+		},
+		{
+			pattern:     "\\b",
+			syntaxError: synErrNullPattern,
+		},
+		{
+			pattern:     "\\b\\b",
+			syntaxError: synErrNullPattern,
+		},
+		{
+			pattern:     "\\b\\ba",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "a\\bb",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "a\\b\\b",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "a$\\b",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "\\b^a",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "a|\\bb",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "(\\ba)",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "(a\\B)",
+			syntaxError: synErrAnchorMisplaced,
+		},
+		{
+			pattern:     "[\\b]",
+			syntaxError: synErrInvalidEscSeq,
+		},
 		{
 			pattern: "Mulder|Scully",
 			ast: genAltNode(
@@ -925,6 +1351,62 @@ func TestParse(t *testing.T) {
 			pattern:     "Fox(|Mulder)",
 			syntaxError: synErrAltLackOfOperand,
 		},
+		{
+			pattern: "Fox(Mulder|)?",
+			ast: genConcatNode(
+				newSymbolNode('F'),
+				newSymbolNode('o'),
+				newSymbolNode('x'),
+				newOptionNode(
+					genConcatNode(
+						newSymbolNode('M'),
+						newSymbolNode('u'),
+						newSymbolNode('l'),
+						newSymbolNode('d'),
+						newSymbolNode('e'),
+						newSymbolNode('r'),
+					),
+				),
+			),
+		},
+		{
+			pattern: "(|a||b|)?",
+			ast: newOptionNode(
+				genAltNode(
+					newSymbolNode('a'),
+					newSymbolNode('b'),
+				),
+			),
+		},
+		{
+			pattern: "((a|)?|b)",
+			ast: genAltNode(
+				newOptionNode(
+					newSymbolNode('a'),
+				),
+				newSymbolNode('b'),
+			),
+		},
+		{
+			pattern:     "(a|)*",
+			syntaxError: synErrAltLackOfOperand,
+		},
+		{
+			pattern:     "(a|)+",
+			syntaxError: synErrAltLackOfOperand,
+		},
+		{
+			pattern:     "a|?",
+			syntaxError: synErrRepNoTarget,
+		},
+		{
+			pattern:     "(|)?",
+			syntaxError: synErrGroupNoElem,
+		},
+		{
+			pattern:     "(||)?",
+			syntaxError: synErrGroupNoElem,
+		},
 	}
 	for i, tt := range tests {
 		t.Run(fmt.Sprintf("#%v %v", i, tt.pattern), func(t *testing.T) {
@@ -938,7 +1420,7 @@ func TestParse(t *testing.T) {
 
 				fragmentTrees[kind] = root
 			}
-			err := CompleteFragments(fragmentTrees)
+			err := CompleteFragments(fragmentTrees, 0)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -966,7 +1448,7 @@ func TestParse(t *testing.T) {
 					t.Fatal("tree must be non-nil")
 				}
 
-				complete, err := ApplyFragments(root, fragmentTrees)
+				complete, err := ApplyFragments(root, fragmentTrees, 0)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -979,6 +1461,14 @@ func TestParse(t *testing.T) {
 					r := root.(*rootNode)
 					testAST(t, tt.ast, r.tree)
 				}
+
+				anchor, err := root.Anchor()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if anchor != tt.anchor {
+					t.Fatalf("unexpected anchor: want: %v, got: %v", tt.anchor, anchor)
+				}
 			}
 		})
 	}
@@ -1003,6 +1493,196 @@ func TestParse_ContributoryPropertyIsNotExposed(t *testing.T) {
 	}
 }
 
+func TestParse_CoalesceBExpElems(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{
+			pattern:  `[\p{Lu}\p{Ll}\p{Lt}\p{Lm}\p{Lo}]`,
+			expected: `\p{L}`,
+		},
+		{
+			pattern:  `[a-z\p{Ll}]`,
+			expected: `\p{Ll}`,
+		},
+		{
+			pattern:  `[\p{L}\p{Nd}\p{L}0-9]`,
+			expected: `[\p{L}\p{Nd}]`,
+		},
+		{
+			pattern:  `[\p{L}\p{Nd}-[\p{Lu}\p{Ll}\p{Lt}\p{Lm}\p{Lo}]]`,
+			expected: `\p{Nd}`,
+		},
+	}
+	parse := func(t *testing.T, pattern string) CPTree {
+		t.Helper()
+		p := NewParser(spec.LexKindName("test"), strings.NewReader(pattern))
+		root, err := p.Parse()
+		if err != nil {
+			detail, cause := p.Error()
+			t.Fatalf("%v: %v: %v", err, cause, detail)
+		}
+		return root.(*rootNode).tree
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			actual := parse(t, tt.pattern)
+			testAST(t, parse(t, tt.expected), actual)
+
+			// The ranges must be sorted and must neither overlap nor adjoin each other.
+			cpRanges, ok := CollectCodePointRanges(actual, nil)
+			if !ok {
+				t.Fatalf("the tree must consist of alternatives and code point ranges")
+			}
+			for i := 1; i < len(cpRanges); i++ {
+				if cpRanges[i].From <= cpRanges[i-1].To+1 {
+					t.Fatalf("the ranges are not coalesced: %v, %v", cpRanges[i-1], cpRanges[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParse_ErrorColumn(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		syntaxError error
+		col         int
+	}{
+		{
+			pattern:     "abc)",
+			syntaxError: synErrGroupNoInitiator,
+			col:         4,
+		},
+		{
+			pattern:     "あい)",
+			syntaxError: synErrGroupNoInitiator,
+			col:         3,
+		},
+		{
+			pattern:     "a**",
+			syntaxError: synErrRepNoTarget,
+			col:         3,
+		},
+		{
+			pattern:     "(abc",
+			syntaxError: synErrGroupUnclosed,
+			col:         5,
+		},
+		{
+			pattern:     "ab\\q",
+			syntaxError: synErrInvalidEscSeq,
+			col:         3,
+		},
+		{
+			pattern:     "a\\u{123}",
+			syntaxError: synErrInvalidCodePoint,
+			col:         5,
+		},
+		{
+			pattern:     "[a-\\p{Letter}]",
+			syntaxError: synErrRangePropIsUnavailable,
+			col:         4,
+		},
+		{
+			pattern:     "a[[:foo:]]",
+			syntaxError: synErrPOSIXClassUnsupported,
+			col:         3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			p := NewParser(spec.LexKindName("test"), strings.NewReader(tt.pattern))
+			_, err := p.Parse()
+			if err != ParseErr {
+				t.Fatalf("unexpected error: want: %v, got: %v", ParseErr, err)
+			}
+			_, synErr := p.Error()
+			if synErr != tt.syntaxError {
+				t.Fatalf("unexpected syntax error: want: %v, got: %v", tt.syntaxError, synErr)
+			}
+			if p.ErrorColumn() != tt.col {
+				t.Fatalf("unexpected column: want: %v, got: %v", tt.col, p.ErrorColumn())
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	parse := func(t *testing.T, pattern string) CPTree {
+		t.Helper()
+		p := NewParser(spec.LexKindName("test"), strings.NewReader(pattern))
+		root, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+	countKinds := func(root CPTree, fn func(n Node) bool) map[NodeKind]int {
+		counts := map[NodeKind]int{}
+		Walk(root, func(n Node) bool {
+			counts[n.NodeKind()]++
+			if fn != nil {
+				return fn(n)
+			}
+			return true
+		})
+		return counts
+	}
+
+	root := parse(t, "(a|b)*c?[d-f]")
+	counts := countKinds(root, nil)
+	expected := map[NodeKind]int{
+		NodeKindRoot:   1,
+		NodeKindConcat: 2,
+		NodeKindRepeat: 1,
+		NodeKindAlt:    1,
+		NodeKindOption: 1,
+		NodeKindSymbol: 4,
+	}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("unexpected node counts; want: %v, got: %v", expected, counts)
+	}
+
+	var ranges []CPRange
+	Walk(root, func(n Node) bool {
+		if from, to, ok := n.Range(); ok && n.NodeKind() == NodeKindSymbol {
+			ranges = append(ranges, CPRange{From: from, To: to})
+		}
+		return true
+	})
+	expectedRanges := []CPRange{{'a', 'a'}, {'b', 'b'}, {'c', 'c'}, {'d', 'f'}}
+	if !reflect.DeepEqual(ranges, expectedRanges) {
+		t.Fatalf("unexpected ranges; want: %v, got: %v", expectedRanges, ranges)
+	}
+
+	// Returning false skips the children of the node.
+	counts = countKinds(root, func(n Node) bool {
+		return n.NodeKind() != NodeKindRepeat
+	})
+	if counts[NodeKindSymbol] != 2 || counts[NodeKindAlt] != 0 {
+		t.Fatalf("the children of the repeat node must be skipped: %v", counts)
+	}
+
+	// A fragment has the tree of the fragment as its child only after the fragment is applied.
+	root = parse(t, "\\f{digit}+")
+	counts = countKinds(root, nil)
+	if counts[NodeKindFragment] != 2 || counts[NodeKindSymbol] != 0 {
+		t.Fatalf("unexpected node counts: %v", counts)
+	}
+	_, err := ApplyFragments(root, map[spec.LexKindName]CPTree{
+		"digit": parse(t, "[0-9]"),
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts = countKinds(root, nil)
+	if counts[NodeKindFragment] != 2 || counts[NodeKindSymbol] != 2 {
+		t.Fatalf("unexpected node counts: %v", counts)
+	}
+}
+
 func TestExclude(t *testing.T) {
 	for _, test := range []struct {
 		caption string
@@ -1381,9 +2061,55 @@ func testAST(t *testing.T, expected, actual CPTree) {
 		if a.From != e.From || a.To != e.To {
 			t.Fatalf("unexpected node: want: %+v, got: %+v", e, a)
 		}
+	case *byteNode:
+		a := actual.(*byteNode)
+		if a.from != e.from || a.to != e.to {
+			t.Fatalf("unexpected node: want: %+v, got: %+v", e, a)
+		}
 	}
 	eLeft, eRight := expected.children()
 	aLeft, aRight := actual.children()
 	testAST(t, eLeft, aLeft)
 	testAST(t, eRight, aRight)
 }
+
+func TestComplementCodePointRanges(t *testing.T) {
+	src := []*ucd.CodePointRange{
+		{From: 0x10, To: 0x25},
+		{From: 0x30, To: 0x40},
+		{From: 0x50, To: 0x50},
+	}
+	comp := complementCodePointRanges(src)
+	expectedComp := []*ucd.CodePointRange{
+		{From: 0x0, To: 0x0F},
+		{From: 0x26, To: 0x2F},
+		{From: 0x41, To: 0x4F},
+		{From: 0x51, To: 0x10FFFF},
+	}
+	if !reflect.DeepEqual(comp, expectedComp) {
+		t.Fatalf("unexpected complement; want: %v, got: %v", expectedComp, comp)
+	}
+	if len(complementCodePointRanges([]*ucd.CodePointRange{{From: 0x0, To: 0x10FFFF}})) != 0 {
+		t.Fatalf("the complement of all code points must be empty")
+	}
+
+	// The ends of a complement must not be surrogate code points <U+D800..U+DFFF>.
+	comp = complementCodePointRanges([]*ucd.CodePointRange{
+		{From: 0x0, To: 0xD8FF},
+		{From: 0xF000, To: 0xFFFF},
+	})
+	expectedComp = []*ucd.CodePointRange{
+		{From: 0xE000, To: 0xEFFF},
+		{From: 0x10000, To: 0x10FFFF},
+	}
+	if !reflect.DeepEqual(comp, expectedComp) {
+		t.Fatalf("unexpected complement; want: %v, got: %v", expectedComp, comp)
+	}
+	comp = complementCodePointRanges([]*ucd.CodePointRange{
+		{From: 0x0, To: 0xD7FF},
+		{From: 0xE000, To: 0x10FFFF},
+	})
+	if len(comp) != 0 {
+		t.Fatalf("the complement of all code points except surrogate ones must be empty: %v", comp)
+	}
+}
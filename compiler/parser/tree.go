@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 
 	"github.com/nihei9/maleeni/spec"
 )
@@ -16,19 +17,108 @@ type CPRange struct {
 type CPTree interface {
 	fmt.Stringer
 	Range() (rune, rune, bool)
+
+	// ByteRange returns a range of raw bytes that a node matches regardless of UTF-8 encoding.
+	ByteRange() (byte, byte, bool)
+
 	Optional() (CPTree, bool)
 	Repeatable() (CPTree, bool)
 	Concatenation() (CPTree, CPTree, bool)
 	Alternatives() (CPTree, CPTree, bool)
 	Describe() (spec.LexKindName, []spec.LexKindName, error)
+	Anchor() (spec.LexAnchor, error)
+
+	NodeKind() NodeKind
+	Children() []Node
 
 	children() (CPTree, CPTree)
 	clone() CPTree
 }
 
+// NodeKind represents the kind of a node of a tree.
+type NodeKind int
+
+const (
+	// NodeKindRoot is the root of a pattern. It has the tree of the pattern as its only child.
+	NodeKindRoot NodeKind = iota + 1
+	// NodeKindSymbol matches a character in a range of code points.
+	NodeKindSymbol
+	// NodeKindByte matches a byte in a range of raw bytes.
+	NodeKindByte
+	// NodeKindConcat matches its two children in order.
+	NodeKindConcat
+	// NodeKindAlt matches either of its two children.
+	NodeKindAlt
+	// NodeKindRepeat matches its child zero or more times. A parser represents `x+` as a concatenation of `x` and
+	// `x*`.
+	NodeKindRepeat
+	// NodeKindOption matches its child zero or one time.
+	NodeKindOption
+	// NodeKindFragment refers to a fragment. Its only child is the tree of the fragment, or it has no children when the
+	// fragment hasn't been applied yet.
+	NodeKindFragment
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case NodeKindRoot:
+		return "root"
+	case NodeKindSymbol:
+		return "symbol"
+	case NodeKindByte:
+		return "byte"
+	case NodeKindConcat:
+		return "concat"
+	case NodeKindAlt:
+		return "alt"
+	case NodeKindRepeat:
+		return "repeat"
+	case NodeKindOption:
+		return "option"
+	case NodeKindFragment:
+		return "fragment"
+	}
+	return fmt.Sprintf("invalid node kind (%d)", int(k))
+}
+
+// Node is a read-only view of a node of a tree. It lets tools analyze patterns without depending on the concrete
+// types of nodes. Range reports the range of code points when the node is a symbol.
+type Node interface {
+	fmt.Stringer
+	NodeKind() NodeKind
+	Children() []Node
+	Range() (rune, rune, bool)
+}
+
+// Walk traverses a tree in depth-first order, calling fn for each node. When fn returns false, Walk skips the
+// children of the node.
+func Walk(n Node, fn func(n Node) bool) {
+	if n == nil {
+		return
+	}
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.Children() {
+		Walk(c, fn)
+	}
+}
+
+func childNodes(ts ...CPTree) []Node {
+	var nodes []Node
+	for _, t := range ts {
+		if t == nil {
+			continue
+		}
+		nodes = append(nodes, t)
+	}
+	return nodes
+}
+
 var (
 	_ CPTree = &rootNode{}
 	_ CPTree = &symbolNode{}
+	_ CPTree = &byteNode{}
 	_ CPTree = &concatNode{}
 	_ CPTree = &altNode{}
 	_ CPTree = &quantifierNode{}
@@ -39,6 +129,10 @@ type rootNode struct {
 	kind      spec.LexKindName
 	tree      CPTree
 	fragments map[spec.LexKindName][]*fragmentNode
+	anchor    spec.LexAnchor
+
+	// nodeCount is the number of nodes in the tree including the ones of the applied fragments.
+	nodeCount int
 }
 
 func newRootNode(kind spec.LexKindName, t CPTree) *rootNode {
@@ -49,7 +143,56 @@ func newRootNode(kind spec.LexKindName, t CPTree) *rootNode {
 		kind:      kind,
 		tree:      t,
 		fragments: fragments,
+		nodeCount: countNodes(t),
+	}
+}
+
+// countNodes returns the number of nodes in a tree. Fragments that haven't been applied yet count as one node.
+func countNodes(t CPTree) int {
+	if t == nil {
+		return 0
+	}
+	switch n := t.(type) {
+	case *rootNode:
+		return n.nodeCount
+	case *fragmentNode:
+		return 1 + countNodes(n.tree)
+	}
+	left, right := t.children()
+	return 1 + countNodes(left) + countNodes(right)
+}
+
+// Fingerprint returns a string representing the structure of a tree. Trees having the same fingerprint match the same
+// strings. The fingerprint covers the trees of applied fragments instead of their names, so `\f{digit}` and `[0-9]`
+// have the same fingerprint when the fragment `digit` is `[0-9]`. Note that the converse doesn't hold; trees matching
+// the same strings, such as `a|b` and `b|a`, may have different fingerprints.
+func Fingerprint(t CPTree) string {
+	var b strings.Builder
+	writeFingerprint(&b, t)
+	return b.String()
+}
+
+func writeFingerprint(b *strings.Builder, t CPTree) {
+	if t == nil {
+		fmt.Fprintf(b, "nil;")
+		return
+	}
+	switch n := t.(type) {
+	case *rootNode:
+		if n.anchor != spec.LexAnchorNil {
+			fmt.Fprintf(b, "anchor: %v;", n.anchor)
+		}
+		writeFingerprint(b, n.tree)
+		return
+	case *fragmentNode:
+		writeFingerprint(b, n.tree)
+		return
 	}
+	fmt.Fprintf(b, "%v(", t)
+	left, right := t.children()
+	writeFingerprint(b, left)
+	writeFingerprint(b, right)
+	fmt.Fprintf(b, ");")
 }
 
 func collectFragments(n CPTree, fragments map[spec.LexKindName][]*fragmentNode) {
@@ -75,6 +218,10 @@ func (n *rootNode) Range() (rune, rune, bool) {
 	return n.tree.Range()
 }
 
+func (n *rootNode) ByteRange() (byte, byte, bool) {
+	return n.tree.ByteRange()
+}
+
 func (n *rootNode) Optional() (CPTree, bool) {
 	return n.tree.Optional()
 }
@@ -103,6 +250,18 @@ func (n *rootNode) Describe() (spec.LexKindName, []spec.LexKindName, error) {
 	return n.kind, frags, nil
 }
 
+func (n *rootNode) Anchor() (spec.LexAnchor, error) {
+	return n.anchor, nil
+}
+
+func (n *rootNode) NodeKind() NodeKind {
+	return NodeKindRoot
+}
+
+func (n *rootNode) Children() []Node {
+	return childNodes(n.tree)
+}
+
 func (n *rootNode) children() (CPTree, CPTree) {
 	return n.tree.children()
 }
@@ -115,7 +274,7 @@ func (n *rootNode) incomplete() bool {
 	return len(n.fragments) > 0
 }
 
-func (n *rootNode) applyFragment(kind spec.LexKindName, fragment CPTree) error {
+func (n *rootNode) applyFragment(kind spec.LexKindName, fragment CPTree, maxNodes int) error {
 	root, ok := fragment.(*rootNode)
 	if !ok {
 		return fmt.Errorf("applyFragment can take only *rootNode: %T", fragment)
@@ -129,13 +288,59 @@ func (n *rootNode) applyFragment(kind spec.LexKindName, fragment CPTree) error {
 		return nil
 	}
 	for _, f := range fs {
-		f.tree = root.clone()
+		var c CPTree
+		if !f.negated {
+			c = root
+		} else {
+			if !isCharSet(root) {
+				return &FragmentError{
+					Kind:     n.kind,
+					Fragment: kind,
+					Cause:    SemErrNegatedFragmentNotCharSet,
+				}
+			}
+			c = exclude(root.clone(), genAnyCharAST())
+			if c == nil {
+				return &FragmentError{
+					Kind:     n.kind,
+					Fragment: kind,
+					Cause:    SemErrNegatedFragmentUnmatchable,
+				}
+			}
+		}
+
+		// A chain of fragments each referring to the previous one multiple times expands exponentially, so we check
+		// the size before cloning the fragment.
+		if maxNodes > 0 && n.nodeCount+countNodes(c) > maxNodes {
+			return &FragmentError{
+				Kind:     n.kind,
+				Fragment: kind,
+				Cause:    SemErrFragmentExpansionTooLarge,
+			}
+		}
+		n.nodeCount += countNodes(c)
+
+		if !f.negated {
+			f.tree = root.clone()
+		} else {
+			f.tree = c
+		}
 	}
 	delete(n.fragments, kind)
 
 	return nil
 }
 
+// isCharSet reports whether a tree matches only one character, that is, the tree consists of only alternatives of
+// symbols.
+func isCharSet(t CPTree) bool {
+	if left, right, ok := t.Alternatives(); ok {
+		return isCharSet(left) && isCharSet(right)
+	}
+	_, _, ok := t.Range()
+	return ok
+}
+
 type symbolNode struct {
 	CPRange
 }
@@ -166,6 +371,10 @@ func (n *symbolNode) Range() (rune, rune, bool) {
 	return n.From, n.To, true
 }
 
+func (n *symbolNode) ByteRange() (byte, byte, bool) {
+	return 0, 0, false
+}
+
 func (n *symbolNode) Optional() (CPTree, bool) {
 	return nil, false
 }
@@ -186,6 +395,18 @@ func (n *symbolNode) Describe() (spec.LexKindName, []spec.LexKindName, error) {
 	return spec.LexKindNameNil, nil, fmt.Errorf("%T cannot describe", n)
 }
 
+func (n *symbolNode) Anchor() (spec.LexAnchor, error) {
+	return spec.LexAnchorNil, fmt.Errorf("%T cannot have anchors", n)
+}
+
+func (n *symbolNode) NodeKind() NodeKind {
+	return NodeKindSymbol
+}
+
+func (n *symbolNode) Children() []Node {
+	return nil
+}
+
 func (n *symbolNode) children() (CPTree, CPTree) {
 	return nil, nil
 }
@@ -194,6 +415,72 @@ func (n *symbolNode) clone() CPTree {
 	return newRangeSymbolNode(n.From, n.To)
 }
 
+// byteNode matches a single raw byte in a range. Unlike symbolNode, the compiler doesn't encode the range in UTF-8,
+// so the node can match a part of a multi-byte character or a byte that isn't valid UTF-8.
+type byteNode struct {
+	from byte
+	to   byte
+}
+
+func newByteRangeNode(from, to byte) *byteNode {
+	return &byteNode{
+		from: from,
+		to:   to,
+	}
+}
+
+func (n *byteNode) String() string {
+	return fmt.Sprintf("byte: %X..%X", n.from, n.to)
+}
+
+func (n *byteNode) Range() (rune, rune, bool) {
+	return 0, 0, false
+}
+
+func (n *byteNode) ByteRange() (byte, byte, bool) {
+	return n.from, n.to, true
+}
+
+func (n *byteNode) Optional() (CPTree, bool) {
+	return nil, false
+}
+
+func (n *byteNode) Repeatable() (CPTree, bool) {
+	return nil, false
+}
+
+func (n *byteNode) Concatenation() (CPTree, CPTree, bool) {
+	return nil, nil, false
+}
+
+func (n *byteNode) Alternatives() (CPTree, CPTree, bool) {
+	return nil, nil, false
+}
+
+func (n *byteNode) Describe() (spec.LexKindName, []spec.LexKindName, error) {
+	return spec.LexKindNameNil, nil, fmt.Errorf("%T cannot describe", n)
+}
+
+func (n *byteNode) Anchor() (spec.LexAnchor, error) {
+	return spec.LexAnchorNil, fmt.Errorf("%T cannot have anchors", n)
+}
+
+func (n *byteNode) NodeKind() NodeKind {
+	return NodeKindByte
+}
+
+func (n *byteNode) Children() []Node {
+	return nil
+}
+
+func (n *byteNode) children() (CPTree, CPTree) {
+	return nil, nil
+}
+
+func (n *byteNode) clone() CPTree {
+	return newByteRangeNode(n.from, n.to)
+}
+
 type concatNode struct {
 	left  CPTree
 	right CPTree
@@ -214,6 +501,10 @@ func (n *concatNode) Range() (rune, rune, bool) {
 	return 0, 0, false
 }
 
+func (n *concatNode) ByteRange() (byte, byte, bool) {
+	return 0, 0, false
+}
+
 func (n *concatNode) Optional() (CPTree, bool) {
 	return nil, false
 }
@@ -234,6 +525,18 @@ func (n *concatNode) Describe() (spec.LexKindName, []spec.LexKindName, error) {
 	return spec.LexKindNameNil, nil, fmt.Errorf("%T cannot describe", n)
 }
 
+func (n *concatNode) Anchor() (spec.LexAnchor, error) {
+	return spec.LexAnchorNil, fmt.Errorf("%T cannot have anchors", n)
+}
+
+func (n *concatNode) NodeKind() NodeKind {
+	return NodeKindConcat
+}
+
+func (n *concatNode) Children() []Node {
+	return childNodes(n.left, n.right)
+}
+
 func (n *concatNode) children() (CPTree, CPTree) {
 	return n.left, n.right
 }
@@ -265,6 +568,10 @@ func (n *altNode) Range() (rune, rune, bool) {
 	return 0, 0, false
 }
 
+func (n *altNode) ByteRange() (byte, byte, bool) {
+	return 0, 0, false
+}
+
 func (n *altNode) Optional() (CPTree, bool) {
 	return nil, false
 }
@@ -285,6 +592,18 @@ func (n *altNode) Describe() (spec.LexKindName, []spec.LexKindName, error) {
 	return spec.LexKindNameNil, nil, fmt.Errorf("%T cannot describe", n)
 }
 
+func (n *altNode) Anchor() (spec.LexAnchor, error) {
+	return spec.LexAnchorNil, fmt.Errorf("%T cannot have anchors", n)
+}
+
+func (n *altNode) NodeKind() NodeKind {
+	return NodeKindAlt
+}
+
+func (n *altNode) Children() []Node {
+	return childNodes(n.left, n.right)
+}
+
 func (n *altNode) children() (CPTree, CPTree) {
 	return n.left, n.right
 }
@@ -337,6 +656,10 @@ func (n *quantifierNode) Range() (rune, rune, bool) {
 	return 0, 0, false
 }
 
+func (n *quantifierNode) ByteRange() (byte, byte, bool) {
+	return 0, 0, false
+}
+
 func (n *quantifierNode) Optional() (CPTree, bool) {
 	return n.tree, n.optional
 }
@@ -357,6 +680,21 @@ func (n *quantifierNode) Describe() (spec.LexKindName, []spec.LexKindName, error
 	return spec.LexKindNameNil, nil, fmt.Errorf("%T cannot describe", n)
 }
 
+func (n *quantifierNode) Anchor() (spec.LexAnchor, error) {
+	return spec.LexAnchorNil, fmt.Errorf("%T cannot have anchors", n)
+}
+
+func (n *quantifierNode) NodeKind() NodeKind {
+	if n.repeatable {
+		return NodeKindRepeat
+	}
+	return NodeKindOption
+}
+
+func (n *quantifierNode) Children() []Node {
+	return childNodes(n.tree)
+}
+
 func (n *quantifierNode) children() (CPTree, CPTree) {
 	return n.tree, nil
 }
@@ -371,6 +709,9 @@ func (n *quantifierNode) clone() CPTree {
 type fragmentNode struct {
 	kind spec.LexKindName
 	tree CPTree
+
+	// When negated is true, the node is the complement of the fragment, and the fragment must be a character set.
+	negated bool
 }
 
 func newFragmentNode(kind spec.LexKindName, t CPTree) *fragmentNode {
@@ -381,13 +722,24 @@ func newFragmentNode(kind spec.LexKindName, t CPTree) *fragmentNode {
 }
 
 func (n *fragmentNode) String() string {
+	if n.negated {
+		return fmt.Sprintf("negated fragment: %v", n.kind)
+	}
 	return fmt.Sprintf("fragment: %v", n.kind)
 }
 
 func (n *fragmentNode) Range() (rune, rune, bool) {
+	// A fragment that hasn't been applied yet has no tree.
+	if n.tree == nil {
+		return 0, 0, false
+	}
 	return n.tree.Range()
 }
 
+func (n *fragmentNode) ByteRange() (byte, byte, bool) {
+	return n.tree.ByteRange()
+}
+
 func (n *fragmentNode) Optional() (CPTree, bool) {
 	return n.tree.Optional()
 }
@@ -408,15 +760,31 @@ func (n *fragmentNode) Describe() (spec.LexKindName, []spec.LexKindName, error)
 	return spec.LexKindNameNil, nil, fmt.Errorf("%T cannot describe", n)
 }
 
+func (n *fragmentNode) Anchor() (spec.LexAnchor, error) {
+	return spec.LexAnchorNil, fmt.Errorf("%T cannot have anchors", n)
+}
+
+func (n *fragmentNode) NodeKind() NodeKind {
+	return NodeKindFragment
+}
+
+func (n *fragmentNode) Children() []Node {
+	return childNodes(n.tree)
+}
+
 func (n *fragmentNode) children() (CPTree, CPTree) {
 	return n.tree.children()
 }
 
 func (n *fragmentNode) clone() CPTree {
+	var c *fragmentNode
 	if n.tree == nil {
-		return newFragmentNode(n.kind, nil)
+		c = newFragmentNode(n.kind, nil)
+	} else {
+		c = newFragmentNode(n.kind, n.tree.clone())
 	}
-	return newFragmentNode(n.kind, n.tree.clone())
+	c.negated = n.negated
+	return c
 }
 
 //nolint:unused
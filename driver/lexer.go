@@ -1,9 +1,15 @@
 package driver
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type ModeID int
@@ -30,15 +36,36 @@ func (id ModeKindID) Int() int {
 	return int(id)
 }
 
+// Anchor represents zero-width assertions that a lexeme must satisfy. A value of this type is a set of flags.
+type Anchor int
+
+const (
+	AnchorNil                  Anchor = 0
+	AnchorLineStart            Anchor = 1 << 0
+	AnchorLineEnd              Anchor = 1 << 1
+	AnchorWordBoundaryStart    Anchor = 1 << 2
+	AnchorWordBoundaryEnd      Anchor = 1 << 3
+	AnchorNonWordBoundaryStart Anchor = 1 << 4
+	AnchorNonWordBoundaryEnd   Anchor = 1 << 5
+)
+
 type LexSpec interface {
 	InitialMode() ModeID
 	Pop(mode ModeID, modeKind ModeKindID) bool
 	Push(mode ModeID, modeKind ModeKindID) (ModeID, bool)
 	ModeName(mode ModeID) string
+	ModeIDByName(name string) (ModeID, bool)
 	InitialState(mode ModeID) StateID
 	NextState(mode ModeID, state StateID, v int) (StateID, bool)
 	Accept(mode ModeID, state StateID) (ModeKindID, bool)
+	AcceptCandidates(mode ModeID, state StateID) []ModeKindID
+	PartialKind(mode ModeID, state StateID) (ModeKindID, bool)
+	Anchor(mode ModeID, modeKind ModeKindID) Anchor
+	Skip(mode ModeID, modeKind ModeKindID) bool
+	ErrorKind(mode ModeID) (ModeKindID, bool)
+	EOFKind(mode ModeID) (ModeKindID, bool)
 	KindIDAndName(mode ModeID, modeKind ModeKindID) (KindID, string)
+	KindMeta(kind KindID) map[string]string
 }
 
 // Token representes a token.
@@ -53,25 +80,219 @@ type Token struct {
 	// Note that you need to use KindID field if you want to identify a kind across all modes.
 	ModeKindID ModeKindID
 
-	// Row is a row number where a lexeme appears.
+	// Row is a row number where a lexeme appears. The EOF token is located at the end of the source.
 	Row int
 
 	// Col is a column number where a lexeme appears.
-	// Note that Col is counted in code points, not bytes.
+	// Note that Col is counted in code points, not bytes, by default. See CountColumnsIn and ExpandTabs.
 	Col int
 
 	// Lexeme is a byte sequence matched a pattern of a lexical specification.
 	Lexeme []byte
 
-	// When this field is true, it means the token is the EOF token.
+	// When this field is true, it means the token is the EOF token. The EOF token has the EOF kind in KindID and
+	// ModeKindID when the lexical specification defines one. Otherwise, these fields are 0.
 	EOF bool
 
-	// When this field is true, it means the token is an error token.
+	// When this field is true, it means the token is an error token. An error token has the error kind of its mode in
+	// KindID and ModeKindID when the lexical specification defines one. Otherwise, these fields are 0.
 	Invalid bool
+
+	// PartialKindID is a hint for an error token. It is the kind with the highest priority among the kinds that the
+	// lexer could still have accepted when it failed in the middle of a lexeme, such as a string literal lacking the
+	// closing quote. When the lexer failed at the first byte, this field is 0. When the lexer merges consecutive error
+	// tokens, this field holds the first hint of them.
+	PartialKindID KindID
+
+	// InvalidSpans holds the parts of an error token. The lexer merges consecutive error tokens into one token unless you
+	// enable DontMergeInvalid option, and each span corresponds to one of the merged tokens. The lexer records this
+	// field only when you enable RecordInvalidSpans option.
+	InvalidSpans []*InvalidSpan
+
+	// spec is the lexical specification of the lexer that returned the token. IsKind and InMode use it to look up the
+	// names of a kind and a mode.
+	spec LexSpec
+}
+
+// IsKind reports whether a token has a kind named `name`. An error token has the error kind of its mode, if any, and
+// the EOF token has the EOF kind, if any. Tokens that the lexer didn't make, such as synthetic tokens of OnToken option, never have
+// a kind according to this method; compare KindID field instead.
+func (t *Token) IsKind(name string) bool {
+	if t.spec == nil {
+		return false
+	}
+	kindID, kindName := t.spec.KindIDAndName(t.ModeID, t.ModeKindID)
+	if kindID == 0 {
+		return false
+	}
+	return kindName == name
+}
+
+// InMode reports whether a token appeared in a mode named `name`. Like IsKind, this method always returns false for
+// tokens that the lexer didn't make.
+func (t *Token) InMode(name string) bool {
+	if t.spec == nil {
+		return false
+	}
+	return t.spec.ModeName(t.ModeID) == name
+}
+
+// String returns a human-readable representation of a token for debugging.
+func (t *Token) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "{mode: %v, kind: %v, mode_kind: %v, pos: %v:%v, lexeme: %q", t.ModeID, t.KindID, t.ModeKindID, t.Row, t.Col, t.Lexeme)
+	if t.EOF {
+		fmt.Fprintf(&b, ", eof")
+	}
+	if t.Invalid {
+		fmt.Fprintf(&b, ", invalid")
+	}
+	fmt.Fprintf(&b, "}")
+	return b.String()
+}
+
+// LexemeFormat is a way to render a lexeme as a string.
+type LexemeFormat int
+
+const (
+	// LexemeFormatText renders a lexeme as it is.
+	LexemeFormatText LexemeFormat = iota
+
+	// LexemeFormatQuoted renders a lexeme as a double-quoted Go string literal, so control characters and invalid
+	// UTF-8 bytes are visible as escape sequences.
+	LexemeFormatQuoted
+
+	// LexemeFormatHex renders each byte of a lexeme as two upper-case hexadecimal digits separated by spaces, such as
+	// `E3 81 82`.
+	LexemeFormatHex
+)
+
+// FormatLexeme renders the lexeme of a token in a specified format.
+func (t *Token) FormatLexeme(format LexemeFormat) string {
+	switch format {
+	case LexemeFormatQuoted:
+		return strconv.Quote(string(t.Lexeme))
+	case LexemeFormatHex:
+		var b strings.Builder
+		for i, c := range t.Lexeme {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(&b, "%02X", c)
+		}
+		return b.String()
+	}
+	return string(t.Lexeme)
+}
+
+// MarshalJSON encodes a token as a JSON object. The object has the same fields as tokens that `maleeni lex` command
+// prints except for the names of a mode and a kind, which only a lexical specification knows.
+func (t *Token) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ModeID     int    `json:"mode_id"`
+		KindID     int    `json:"kind_id"`
+		ModeKindID int    `json:"mode_kind_id"`
+		Row        int    `json:"row"`
+		Col        int    `json:"col"`
+		Lexeme     string `json:"lexeme"`
+		EOF        bool   `json:"eof"`
+		Invalid    bool   `json:"invalid"`
+	}{
+		ModeID:     t.ModeID.Int(),
+		KindID:     t.KindID.Int(),
+		ModeKindID: t.ModeKindID.Int(),
+		Row:        t.Row,
+		Col:        t.Col,
+		Lexeme:     string(t.Lexeme),
+		EOF:        t.EOF,
+		Invalid:    t.Invalid,
+	})
+}
+
+// InvalidSpan represents a byte sequence that the lexer couldn't accept.
+type InvalidSpan struct {
+	// Offset is a byte offset from the beginning of the source.
+	Offset int
+
+	// Length is the number of bytes.
+	Length int
+
+	// Row and Col are the position where the span begins.
+	Row int
+	Col int
 }
 
 type LexerOption func(l *Lexer) error
 
+// ColumnUnit is a unit in which the lexer counts columns.
+type ColumnUnit int
+
+const (
+	// ColumnUnitCodePoint counts columns in code points. This is the default.
+	ColumnUnitCodePoint ColumnUnit = iota
+
+	// ColumnUnitByte counts columns in bytes.
+	ColumnUnitByte
+
+	// ColumnUnitUTF16 counts columns in UTF-16 code units, that is, a code point outside the BMP occupies two columns.
+	// Language servers use this unit by default.
+	ColumnUnitUTF16
+)
+
+// CountColumnsIn makes the lexer count columns in a specified unit.
+func CountColumnsIn(unit ColumnUnit) LexerOption {
+	return func(l *Lexer) error {
+		switch unit {
+		case ColumnUnitCodePoint, ColumnUnitByte, ColumnUnitUTF16:
+		default:
+			return fmt.Errorf("invalid column unit: %v", unit)
+		}
+		l.colUnit = unit
+		return nil
+	}
+}
+
+// InputEncoding is an encoding of the source.
+type InputEncoding int
+
+const (
+	// InputEncodingUTF8 means the source is encoded in UTF-8. This is the default.
+	InputEncodingUTF8 InputEncoding = iota
+
+	InputEncodingUTF16LE
+	InputEncodingUTF16BE
+	InputEncodingUTF32LE
+	InputEncodingUTF32BE
+)
+
+// DecodeFrom makes the lexer transcode the source from a specified encoding into UTF-8 before tokenizing it, so
+// patterns match code points regardless of the encoding. The lexer replaces ill-formed sequences, such as unpaired
+// surrogates, with U+FFFD. Note that lexemes are UTF-8 byte sequences and byte offsets are offsets in the transcoded
+// source. To count columns in the units of the original source, use CountColumnsIn option together, for instance,
+// ColumnUnitUTF16 for UTF-16. A byte order mark becomes EF BB BF, which StripBOM option skips.
+func DecodeFrom(enc InputEncoding) LexerOption {
+	return func(l *Lexer) error {
+		switch enc {
+		case InputEncodingUTF8, InputEncodingUTF16LE, InputEncodingUTF16BE, InputEncodingUTF32LE, InputEncodingUTF32BE:
+		default:
+			return fmt.Errorf("invalid input encoding: %v", enc)
+		}
+		l.inputEnc = enc
+		return nil
+	}
+}
+
+// ExpandTabs makes a tab (U+0009) advance a column number to the next multiple of `width` instead of by one.
+func ExpandTabs(width int) LexerOption {
+	return func(l *Lexer) error {
+		if width < 1 {
+			return fmt.Errorf("tab width must be greater than or equal to 1: %v", width)
+		}
+		l.tabWidth = width
+		return nil
+	}
+}
+
 // DisableModeTransition disables the active mode transition. Thus, even if the lexical specification has the push and pop
 // operations, the lexer doesn't perform these operations. When the lexical specification has multiple modes, and this option is
 // enabled, you need to call the Lexer.Push and Lexer.Pop methods to perform the mode transition. You can use the Lexer.Mode method
@@ -83,35 +304,292 @@ func DisableModeTransition() LexerOption {
 	}
 }
 
+// DisableSkip disables skipping tokens. By default, the lexer doesn't return tokens of kinds whose `skip` is true in the
+// lexical specification. When this option is enabled, the lexer returns all tokens, and you can use the LexSpec.Skip method
+// to know whether a token is one that the lexer would skip.
+func DisableSkip() LexerOption {
+	return func(l *Lexer) error {
+		l.skipDisabled = true
+		return nil
+	}
+}
+
+// StripBOM makes the lexer skip a UTF-8 byte order mark (EF BB BF) at the beginning of the source. The lexer doesn't
+// count the BOM in columns, so the first token starts at column 0. Editors on Windows often save files with the BOM.
+func StripBOM() LexerOption {
+	return func(l *Lexer) error {
+		l.stripBOM = true
+		return nil
+	}
+}
+
+// RecordInvalidSpans makes the lexer record the parts of each error token in the Token.InvalidSpans field. Tools can use
+// them to point exactly where the lexer failed.
+func RecordInvalidSpans() LexerOption {
+	return func(l *Lexer) error {
+		l.recordInvalidSpans = true
+		return nil
+	}
+}
+
+// DontMergeInvalid makes the lexer return each invalid token separately. By default, the lexer merges consecutive
+// invalid tokens into one token. This option is useful when you resynchronize the lexer at each invalid token.
+func DontMergeInvalid() LexerOption {
+	return func(l *Lexer) error {
+		l.dontMergeInvalid = true
+		return nil
+	}
+}
+
+// ReadIncrementally makes the lexer read the source in chunks as it needs instead of reading the whole source at once.
+// The lexer keeps only the bytes from the beginning of the current token in memory, so it can tokenize a large stream
+// with a small amount of memory.
+func ReadIncrementally() LexerOption {
+	return func(l *Lexer) error {
+		l.readIncrementally = true
+		return nil
+	}
+}
+
+// StopOnInvalid makes Next return a *LexError instead of an error token. This is useful when a caller needs the whole
+// source to consist of valid tokens. Next consumes the error token before it returns the error, so a subsequent call of
+// Next returns the token following the error token. Peek and PeekN still return error tokens as they are.
+func StopOnInvalid() LexerOption {
+	return func(l *Lexer) error {
+		l.stopOnInvalid = true
+		return nil
+	}
+}
+
+// RequireValidUTF8 makes the lexer return an *InvalidUTF8Error instead of error tokens when the source contains a byte
+// sequence that isn't well-formed UTF-8, such as a truncated or an overlong sequence. The lexer returns the tokens
+// preceding the sequence first and returns the error when a token would begin with it. The lexer doesn't advance past
+// the sequence, so Next keeps returning the error.
+func RequireValidUTF8() LexerOption {
+	return func(l *Lexer) error {
+		l.requireValidUTF8 = true
+		return nil
+	}
+}
+
+// OnToken makes the lexer call a hook for each token before Next returns it. The hook receives the token and the token
+// preceding it, and returns synthetic tokens that the lexer inserts before the token. prev is nil for the first token.
+// The hook also receives the EOF token, so it can close anything still open at the end of the source, but the lexer
+// doesn't call the hook after the EOF token. Skipped tokens never reach the hook. This is useful for languages that need
+// tokens a DFA can't produce, such as indent and dedent tokens of indentation-sensitive languages.
+//
+// The lexer calls the hook when it reads a token into the token buffer, so Peek and PeekN also call it. When SetMode or
+// Restore makes the lexer read tokens again, the lexer calls the hook for them again, so the hook should decide the
+// synthetic tokens only from prev and cur. The lexer doesn't duplicate synthetic tokens that Next has already returned.
+func OnToken(hook func(prev, cur *Token) []*Token) LexerOption {
+	return func(l *Lexer) error {
+		l.onToken = hook
+		return nil
+	}
+}
+
+// LexError is an error that Next returns for an error token when you enable StopOnInvalid option.
+type LexError struct {
+	// Lexeme is the byte sequence that the lexer couldn't accept.
+	Lexeme []byte
+
+	// Offset is a byte offset from the beginning of the source where Lexeme begins.
+	Offset int
+
+	// Row and Col are the position where Lexeme begins. They are counted in the same way as Token.Row and Token.Col.
+	Row int
+	Col int
+
+	// Token is the error token.
+	Token *Token
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("invalid token at %v:%v: %q", e.Row, e.Col, e.Lexeme)
+}
+
+// InvalidUTF8Error is an error that Next returns for a byte sequence that isn't well-formed UTF-8 when you enable
+// RequireValidUTF8 option.
+type InvalidUTF8Error struct {
+	// Bytes is the ill-formed byte sequence. It consists of a byte that cannot begin a code point or the bytes that
+	// begin a code point but cannot complete it.
+	Bytes []byte
+
+	// Offset is a byte offset from the beginning of the source where Bytes begins.
+	Offset int
+
+	// Row and Col are the position where Bytes begins. They are counted in the same way as Token.Row and Token.Col.
+	Row int
+	Col int
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("invalid UTF-8 sequence at %v:%v: % X", e.Row, e.Col, e.Bytes)
+}
+
+// readIncrementallyByDefault is the default of the ReadIncrementally option. maleeni-go sets this constant to true when
+// it generates a streaming lexer.
+const readIncrementallyByDefault = false
+
+// srcChunkSize is the number of bytes the lexer reads at a time when it reads the source incrementally.
+const srcChunkSize = 4096
+
 type Lexer struct {
 	spec            LexSpec
 	src             []byte
 	srcPtr          int
 	row             int
 	col             int
-	prevRow         int
-	prevCol         int
 	tokBuf          []*Token
 	modeStack       []ModeID
 	passiveModeTran bool
+	skipDisabled    bool
+	colUnit         ColumnUnit
+	tabWidth        int
+	stripBOM        bool
+	inputEnc        InputEncoding
+
+	recordInvalidSpans bool
+	stopOnInvalid      bool
+	dontMergeInvalid   bool
+
+	// When the lexer requires valid UTF-8, validUTF8End is the offset up to which the lexer has validated the source.
+	// invalidUTF8 holds an error that `read` found, and `next` returns it.
+	requireValidUTF8 bool
+	validUTF8End     int
+	invalidUTF8      *InvalidUTF8Error
+
+	// onToken is the hook that OnToken sets. hookPrev is the last token passed to the hook. When the lexer restores a
+	// state right after synthetic tokens, skipSynthetic is the number of the synthetic tokens that Next has already
+	// returned, and the lexer drops them when the hook returns them again.
+	onToken       func(prev, cur *Token) []*Token
+	hookPrev      *Token
+	skipSynthetic int
+
+	// When the lexer reads the source incrementally, srcReader is the rest of the source, and `src` holds only a window
+	// of the source. srcOffset is the offset of the window from the beginning of the source, and tokStart is the
+	// position of the current token in the window. The lexer never discards bytes following tokStart because it may
+	// give back them.
+	readIncrementally bool
+	srcReader         io.Reader
+	srcOffset         int
+	srcErr            error
+	tokStart          int
+
+	// When tokBufTailFixed is true, the lexer must not merge an invalid token into the last token in the token buffer
+	// because a skipped token separates them.
+	tokBufTailFixed bool
+
+	// tokBufEnds holds the offsets where the tokens in the token buffer end, and consumed is the offset where the
+	// last token that Next returned ends. The offsets are relative to the beginning of the source.
+	tokBufEnds []int
+	consumed   int
+
+	// tokBufStates holds the states of the lexer right after the tokens in the token buffer. The lexer records a state
+	// only when it reads beyond the token before Next returns it, so the last element is usually nil. consumedState is
+	// the state right after the last token that Next returned, or nil when the lexer hasn't read beyond the token. SetMode
+	// uses these states to discard the tokens that the lexer has read ahead.
+	tokBufStates  []*lexerState
+	consumedState *lexerState
+}
+
+// lexerState is a snapshot of the position and the mode stack of a lexer. offset is relative to the beginning of the
+// source.
+type lexerState struct {
+	offset        int
+	row           int
+	col           int
+	modeStack     []ModeID
+	hookPrev      *Token
+	skipSynthetic int
+}
+
+func (s *lexerState) clone() *lexerState {
+	if s == nil {
+		return nil
+	}
+	modeStack := make([]ModeID, len(s.modeStack))
+	copy(modeStack, s.modeStack)
+	return &lexerState{
+		offset:        s.offset,
+		row:           s.row,
+		col:           s.col,
+		modeStack:     modeStack,
+		hookPrev:      s.hookPrev,
+		skipSynthetic: s.skipSynthetic,
+	}
 }
 
 // NewLexer returns a new lexer.
 func NewLexer(spec LexSpec, src io.Reader, opts ...LexerOption) (*Lexer, error) {
-	b, err := ioutil.ReadAll(src)
+	l, err := newLexer(spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	if l.inputEnc != InputEncodingUTF8 {
+		src = &transcoder{
+			r:   src,
+			enc: l.inputEnc,
+			buf: make([]byte, srcChunkSize),
+		}
+	}
+	if l.readIncrementally {
+		l.src = make([]byte, 0, srcChunkSize)
+		l.srcReader = src
+	} else {
+		b, err := readAll(src)
+		if err != nil {
+			return nil, err
+		}
+		l.src = b
+	}
+	err = l.skipBOM()
 	if err != nil {
 		return nil, err
 	}
+	return l, nil
+}
+
+// NewLexerFromBytes returns a lexer tokenizing `src`. Unlike NewLexer, the lexer reads the slice directly instead of
+// copying it into its own buffer, so the caller must not modify `src` while using the lexer. ReadIncrementally option
+// has no effect because the whole source is already in memory. When the source is encoded in UTF-16 or UTF-32 (see
+// DecodeFrom), the lexer still transcodes it into a new buffer.
+func NewLexerFromBytes(spec LexSpec, src []byte, opts ...LexerOption) (*Lexer, error) {
+	l, err := newLexer(spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	l.readIncrementally = false
+	if l.inputEnc != InputEncodingUTF8 {
+		src, err = readAll(&transcoder{
+			r:   bytes.NewReader(src),
+			enc: l.inputEnc,
+			buf: make([]byte, srcChunkSize),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.src = src
+	err = l.skipBOM()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func newLexer(spec LexSpec, opts []LexerOption) (*Lexer, error) {
 	l := &Lexer{
 		spec:   spec,
-		src:    b,
 		srcPtr: 0,
 		row:    0,
 		col:    0,
 		modeStack: []ModeID{
 			spec.InitialMode(),
 		},
-		passiveModeTran: false,
+		passiveModeTran:   false,
+		colUnit:           ColumnUnitCodePoint,
+		readIncrementally: readIncrementallyByDefault,
 	}
 	for _, opt := range opts {
 		err := opt(l)
@@ -119,39 +597,425 @@ func NewLexer(spec LexSpec, src io.Reader, opts ...LexerOption) (*Lexer, error)
 			return nil, err
 		}
 	}
-
 	return l, nil
 }
 
+// skipBOM skips a UTF-8 byte order mark at the beginning of the source when StripBOM option is enabled.
+func (l *Lexer) skipBOM() error {
+	if l.stripBOM {
+		for len(l.src) < 3 && l.readSrc() {
+		}
+		if l.srcErr != nil {
+			return l.srcErr
+		}
+		if len(l.src) >= 3 && l.src[0] == 0xEF && l.src[1] == 0xBB && l.src[2] == 0xBF {
+			l.srcPtr = 3
+		}
+	}
+	l.consumed = l.srcPtr
+	return nil
+}
+
+// readAll reads the whole source. When the source knows its size, as in-memory readers and regular files do, readAll
+// allocates a buffer of the size at once instead of growing a buffer repeatedly. Otherwise, it falls back to
+// ioutil.ReadAll.
+func readAll(r io.Reader) ([]byte, error) {
+	size := -1
+	switch s := r.(type) {
+	case *bytes.Reader:
+		size = s.Len()
+	case *bytes.Buffer:
+		size = s.Len()
+	case *strings.Reader:
+		size = s.Len()
+	case *os.File:
+		// The size is only a hint because the file offset may not be at the beginning.
+		if fi, err := s.Stat(); err == nil && fi.Mode().IsRegular() {
+			size = int(fi.Size())
+		}
+	}
+	if size < 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	// One extra byte lets the last Read report the EOF without growing the buffer.
+	b := make([]byte, 0, size+1)
+	for {
+		if len(b) == cap(b) {
+			b = append(b, 0)[:len(b)]
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err != nil {
+			if err == io.EOF {
+				return b, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// transcoder is a reader that transcodes UTF-16 or UTF-32 into UTF-8.
+type transcoder struct {
+	r   io.Reader
+	enc InputEncoding
+	buf []byte
+
+	// in holds the bytes read from `r` that the transcoder hasn't decoded yet because they are an incomplete code
+	// unit or surrogate pair, and out holds the UTF-8 bytes that Read hasn't returned yet.
+	in  []byte
+	out []byte
+
+	// When `r` returns an error, the transcoder holds the error in err, and Read returns it after all the bytes.
+	err error
+}
+
+func (t *transcoder) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		n, err := t.r.Read(t.buf)
+		t.in = append(t.in, t.buf[:n]...)
+		t.err = err
+		t.decode()
+	}
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+// decode decodes as many code points from `in` as possible into `out`. When the source has ended, decode replaces an
+// incomplete code unit or surrogate pair at the end with U+FFFD.
+func (t *transcoder) decode() {
+	var b [utf8.UTFMax]byte
+	emit := func(r rune) {
+		n := utf8.EncodeRune(b[:], r)
+		t.out = append(t.out, b[:n]...)
+	}
+	ended := t.err != nil
+	in := t.in
+	switch t.enc {
+	case InputEncodingUTF16LE, InputEncodingUTF16BE:
+		unit := func(b []byte) rune {
+			if t.enc == InputEncodingUTF16LE {
+				return rune(b[0]) | rune(b[1])<<8
+			}
+			return rune(b[0])<<8 | rune(b[1])
+		}
+		for len(in) >= 2 {
+			u := unit(in)
+			switch {
+			case u >= 0xD800 && u <= 0xDBFF:
+				if len(in) < 4 {
+					if !ended {
+						t.in = append(t.in[:0], in...)
+						return
+					}
+					emit(utf8.RuneError)
+					in = in[2:]
+					continue
+				}
+				if v := unit(in[2:]); v >= 0xDC00 && v <= 0xDFFF {
+					emit((u-0xD800)<<10 | (v - 0xDC00) + 0x10000)
+					in = in[4:]
+					continue
+				}
+				emit(utf8.RuneError)
+			case u >= 0xDC00 && u <= 0xDFFF:
+				emit(utf8.RuneError)
+			default:
+				emit(u)
+			}
+			in = in[2:]
+		}
+	case InputEncodingUTF32LE, InputEncodingUTF32BE:
+		for len(in) >= 4 {
+			var r rune
+			if t.enc == InputEncodingUTF32LE {
+				r = rune(uint32(in[0]) | uint32(in[1])<<8 | uint32(in[2])<<16 | uint32(in[3])<<24)
+			} else {
+				r = rune(uint32(in[0])<<24 | uint32(in[1])<<16 | uint32(in[2])<<8 | uint32(in[3]))
+			}
+			if !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			emit(r)
+			in = in[4:]
+		}
+	}
+	if ended && len(in) > 0 {
+		emit(utf8.RuneError)
+		in = nil
+	}
+	t.in = append(t.in[:0], in...)
+}
+
 // Next returns a next token.
 func (l *Lexer) Next() (*Token, error) {
-	if len(l.tokBuf) > 0 {
-		tok := l.tokBuf[0]
-		l.tokBuf = l.tokBuf[1:]
-		return tok, nil
+	err := l.fill(1)
+	if err != nil {
+		return nil, err
+	}
+	tok := l.tokBuf[0]
+	l.tokBuf = l.tokBuf[1:]
+	l.consumed = l.tokBufEnds[0]
+	l.tokBufEnds = l.tokBufEnds[1:]
+	l.consumedState = l.tokBufStates[0]
+	l.tokBufStates = l.tokBufStates[1:]
+	if tok.Invalid && l.stopOnInvalid {
+		return nil, &LexError{
+			Lexeme: tok.Lexeme,
+			Offset: l.consumed - len(tok.Lexeme),
+			Row:    tok.Row,
+			Col:    tok.Col,
+			Token:  tok,
+		}
+	}
+	return tok, nil
+}
+
+// Rest returns a copy of the source following the last token that Next returned. The result includes the tokens that
+// Peek or PeekN has read in advance because Next hasn't returned them yet. When the lexer reads the source
+// incrementally, the result contains only the bytes the lexer has already read, and the remaining bytes stay in the
+// reader passed to NewLexer.
+func (l *Lexer) Rest() []byte {
+	rest := l.src[l.consumed-l.srcOffset:]
+	b := make([]byte, len(rest))
+	copy(b, rest)
+	return b
+}
+
+// Clone returns a copy of the lexer. The copy has the same position, mode stack, and tokens read in advance as the
+// original, and you can advance it independently of the original. This is useful for speculative lexing: advance a
+// copy, and discard it when the speculation fails. The copy and the original share the source and the lexical
+// specification read-only.
+//
+// When the lexer reads the source incrementally, Clone returns an error because the copy and the original cannot share
+// the rest of the source in the reader.
+func (l *Lexer) Clone() (*Lexer, error) {
+	if l.readIncrementally {
+		return nil, fmt.Errorf("the lexer cannot be cloned when it reads the source incrementally")
+	}
+	c := *l
+	c.modeStack = make([]ModeID, len(l.modeStack))
+	copy(c.modeStack, l.modeStack)
+	c.tokBuf = make([]*Token, len(l.tokBuf))
+	for i, tok := range l.tokBuf {
+		t := *tok
+		// The lexer appends lexemes of error tokens to the last token in the token buffer, so the copy must not share
+		// spare capacity of the slices with the original.
+		t.Lexeme = t.Lexeme[:len(t.Lexeme):len(t.Lexeme)]
+		t.InvalidSpans = t.InvalidSpans[:len(t.InvalidSpans):len(t.InvalidSpans)]
+		c.tokBuf[i] = &t
 	}
+	c.tokBufEnds = make([]int, len(l.tokBufEnds))
+	copy(c.tokBufEnds, l.tokBufEnds)
+	c.tokBufStates = make([]*lexerState, len(l.tokBufStates))
+	for i, st := range l.tokBufStates {
+		c.tokBufStates[i] = st.clone()
+	}
+	c.consumedState = l.consumedState.clone()
+	return &c, nil
+}
 
-	tok, err := l.nextAndTransition()
+// Peek returns a next token without consuming it. A subsequent call of Next returns the same token.
+//
+// Note that Peek performs the active mode transition of the peeked tokens in advance. Thus, when you enable
+// DisableModeTransition option, Peek returns an error because the lexer cannot know the mode transition you will
+// perform after the peeked tokens.
+func (l *Lexer) Peek() (*Token, error) {
+	toks, err := l.PeekN(1)
 	if err != nil {
 		return nil, err
 	}
-	if !tok.Invalid {
-		return tok, nil
+	return toks[0], nil
+}
+
+// PeekN returns the next n tokens without consuming them. When the EOF token appears within n tokens, the returned
+// slice ends with it, so its length may be less than n. See also Peek.
+func (l *Lexer) PeekN(n int) ([]*Token, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("the number of tokens to peek must be greater than or equal to 1: %v", n)
 	}
-	errTok := tok
+	if l.passiveModeTran {
+		return nil, fmt.Errorf("the lexer cannot peek tokens when the passive mode transition is enabled")
+	}
+	// When the token buffer is empty, the lexer is right after the last token that Next returned. Save the state
+	// before reading ahead.
+	if len(l.tokBuf) == 0 && l.consumedState == nil {
+		l.consumedState = l.saveState()
+	}
+	err := l.fill(n)
+	if err != nil {
+		return nil, err
+	}
+	if n > len(l.tokBuf) {
+		n = len(l.tokBuf)
+	}
+	toks := make([]*Token, n)
+	copy(toks, l.tokBuf[:n])
+	return toks, nil
+}
+
+// fill reads tokens into the token buffer until it has n tokens or the EOF token. The lexer merges consecutive
+// invalid tokens into one token, so when the last token is invalid, fill reads tokens until a valid one appears.
+// Tokens to be skipped never enter the token buffer, but the lexer performs their mode transitions.
+func (l *Lexer) fill(n int) error {
 	for {
-		tok, err = l.nextAndTransition()
+		if len(l.tokBuf) > 0 {
+			last := l.tokBuf[len(l.tokBuf)-1]
+			if last.EOF {
+				return nil
+			}
+			if len(l.tokBuf) >= n && (!last.Invalid || l.tokBufTailFixed || l.dontMergeInvalid) {
+				return nil
+			}
+		}
+
+		if len(l.tokBuf) > 0 && l.tokBufStates[len(l.tokBufStates)-1] == nil {
+			l.tokBufStates[len(l.tokBufStates)-1] = l.saveState()
+		}
+
+		// Synthetic tokens end where the token following them begins, so the lexer needs the state before the token.
+		var before *lexerState
+		if l.onToken != nil {
+			before = l.saveState()
+		}
+
+		tok, err := l.nextAndTransition()
 		if err != nil {
-			return nil, err
+			return err
+		}
+		tok.spec = l.spec
+		if tok.Invalid && len(l.tokBuf) > 0 && !l.tokBufTailFixed && !l.dontMergeInvalid {
+			if last := l.tokBuf[len(l.tokBuf)-1]; last.Invalid {
+				last.Lexeme = append(last.Lexeme, tok.Lexeme...)
+				last.InvalidSpans = append(last.InvalidSpans, tok.InvalidSpans...)
+				if last.PartialKindID == 0 {
+					last.PartialKindID = tok.PartialKindID
+				}
+				l.tokBufEnds[len(l.tokBufEnds)-1] = l.srcOffset + l.srcPtr
+				l.tokBufStates[len(l.tokBufStates)-1] = nil
+				continue
+			}
 		}
-		if !tok.Invalid {
-			break
+		if !l.skipDisabled && !tok.EOF && !tok.Invalid && l.spec.Skip(tok.ModeID, tok.ModeKindID) {
+			l.tokBufTailFixed = true
+			continue
 		}
-		errTok.Lexeme = append(errTok.Lexeme, tok.Lexeme...)
+		if l.onToken != nil && (l.hookPrev == nil || !l.hookPrev.EOF) {
+			l.insertSyntheticTokens(tok, before)
+		}
+		l.tokBuf = append(l.tokBuf, tok)
+		l.tokBufEnds = append(l.tokBufEnds, l.srcOffset+l.srcPtr)
+		l.tokBufStates = append(l.tokBufStates, nil)
+		l.tokBufTailFixed = false
 	}
-	l.tokBuf = append(l.tokBuf, tok)
+}
 
-	return errTok, nil
+// insertSyntheticTokens appends the synthetic tokens that the hook returns for a token to the token buffer. `before` is
+// the state right before the token. The state after each synthetic token is the same as `before` except that it
+// remembers how many synthetic tokens precede it, so the lexer doesn't return them again after restoring the state.
+func (l *Lexer) insertSyntheticTokens(tok *Token, before *lexerState) {
+	synth := l.onToken(l.hookPrev, tok)
+	l.hookPrev = tok
+	skipped := l.skipSynthetic
+	l.skipSynthetic = 0
+	if skipped >= len(synth) {
+		return
+	}
+	start := l.srcOffset + l.srcPtr - len(tok.Lexeme)
+	for i, t := range synth[skipped:] {
+		st := before.clone()
+		st.skipSynthetic = skipped + i + 1
+		l.tokBuf = append(l.tokBuf, t)
+		l.tokBufEnds = append(l.tokBufEnds, start)
+		l.tokBufStates = append(l.tokBufStates, st)
+	}
+}
+
+func (l *Lexer) saveState() *lexerState {
+	modeStack := make([]ModeID, len(l.modeStack))
+	copy(modeStack, l.modeStack)
+	return &lexerState{
+		offset:        l.srcOffset + l.srcPtr,
+		row:           l.row,
+		col:           l.col,
+		modeStack:     modeStack,
+		hookPrev:      l.hookPrev,
+		skipSynthetic: l.skipSynthetic,
+	}
+}
+
+// discardReadAhead discards the tokens that the lexer has read ahead and restores the state right after the last token
+// that Next returned. The lexer keeps the bytes following the token in the window, so it can read them again.
+func (l *Lexer) discardReadAhead() {
+	if l.consumedState == nil {
+		return
+	}
+	l.restoreState(l.consumedState)
+}
+
+// restoreState restores a state and discards the tokens that the lexer has read ahead. The bytes following the state
+// must be in the window.
+func (l *Lexer) restoreState(st *lexerState) {
+	l.srcPtr = st.offset - l.srcOffset
+	l.row = st.row
+	l.col = st.col
+	l.modeStack = st.modeStack
+	l.hookPrev = st.hookPrev
+	l.skipSynthetic = st.skipSynthetic
+	l.tokBuf = nil
+	l.tokBufEnds = nil
+	l.tokBufStates = nil
+	l.tokBufTailFixed = false
+	l.consumedState = nil
+}
+
+// Position is a position of a lexer that Mark returns. It holds the offset, the row and column numbers, and the mode
+// stack.
+type Position struct {
+	lexer *Lexer
+	state *lexerState
+}
+
+// Mark returns the position right after the last token that Next returned. You can pass the position to Restore to
+// lex the source again from there. Unlike Clone, Mark doesn't copy the token buffer, so it is cheap enough for
+// backtracking parsers to call at each choice point.
+func (l *Lexer) Mark() Position {
+	st := l.consumedState.clone()
+	if st == nil {
+		st = l.saveState()
+	}
+	return Position{
+		lexer: l,
+		state: st,
+	}
+}
+
+// Restore moves the lexer back to a position that Mark returned. The lexer discards the tokens that it has read ahead,
+// and Next returns the token following the position. You can restore the same position more than once.
+//
+// Restore returns an error when the position is ahead of the current position because the lexer may have moved to
+// another mode since then, or when the position is one that another lexer returned. When the lexer reads the source
+// incrementally, the lexer may have already discarded the bytes following the position. In that case, Restore also
+// returns an error.
+func (l *Lexer) Restore(pos Position) error {
+	if pos.lexer != l || pos.state == nil {
+		return fmt.Errorf("the position was not marked on this lexer")
+	}
+	if pos.state.offset > l.consumed {
+		return fmt.Errorf("cannot restore a position ahead of the current position: %v > %v", pos.state.offset, l.consumed)
+	}
+	// A word boundary at the beginning of a token needs the byte preceding the token.
+	if pos.state.offset-1 < l.srcOffset && l.srcOffset > 0 {
+		return fmt.Errorf("cannot restore a position whose source the lexer has already discarded: %v", pos.state.offset)
+	}
+	l.restoreState(pos.state.clone())
+	l.tokStart = l.srcPtr
+	l.consumed = pos.state.offset
+	return nil
 }
 
 func (l *Lexer) nextAndTransition() (*Token, error) {
@@ -189,54 +1053,65 @@ func (l *Lexer) next() (*Token, error) {
 	state := l.spec.InitialState(mode)
 	buf := []byte{}
 	unfixedBufLen := 0
+	l.tokStart = l.srcPtr
+	offset := l.srcOffset + l.srcPtr
 	row := l.row
 	col := l.col
+	lineStart := l.col == 0
+	// tokEndRow and tokEndCol hold the position right after the last accepted lexeme. When the lexer gives back the
+	// bytes read beyond the lexeme, it restores the position to them.
+	tokEndRow := l.row
+	tokEndCol := l.col
 	var tok *Token
 	for {
 		v, eof := l.read()
 		if eof {
+			if l.srcErr != nil {
+				return nil, l.srcErr
+			}
+			// The lexer returns an ill-formed UTF-8 sequence as an error only when a token begins with it. Otherwise, it
+			// treats the sequence as the end of the source, so it returns the preceding token first.
+			if l.invalidUTF8 != nil {
+				err := l.invalidUTF8
+				l.invalidUTF8 = nil
+				if tok == nil && len(buf) == 0 {
+					return nil, err
+				}
+			}
 			if tok != nil {
-				l.unread(unfixedBufLen)
+				l.unread(unfixedBufLen, tokEndRow, tokEndCol)
 				return tok, nil
 			}
 			// When `buf` has unaccepted data and reads the EOF, the lexer treats the buffered data as an invalid token.
 			if len(buf) > 0 {
-				return &Token{
-					ModeID:     mode,
-					ModeKindID: 0,
-					Lexeme:     buf,
-					Row:        row,
-					Col:        col,
-					Invalid:    true,
-				}, nil
+				return l.newInvalidToken(mode, state, buf, offset, row, col), nil
 			}
-			return &Token{
+			// The EOF token is located at the end of the source.
+			tok := &Token{
 				ModeID:     mode,
 				ModeKindID: 0,
-				Row:        0,
-				Col:        0,
+				Row:        l.row,
+				Col:        l.col,
 				EOF:        true,
-			}, nil
+			}
+			if modeKindID, ok := l.spec.EOFKind(mode); ok {
+				tok.ModeKindID = modeKindID
+				tok.KindID, _ = l.spec.KindIDAndName(mode, modeKindID)
+			}
+			return tok, nil
 		}
 		buf = append(buf, v)
 		unfixedBufLen++
 		nextState, ok := l.spec.NextState(mode, state, int(v))
 		if !ok {
 			if tok != nil {
-				l.unread(unfixedBufLen)
+				l.unread(unfixedBufLen, tokEndRow, tokEndCol)
 				return tok, nil
 			}
-			return &Token{
-				ModeID:     mode,
-				ModeKindID: 0,
-				Lexeme:     buf,
-				Row:        row,
-				Col:        col,
-				Invalid:    true,
-			}, nil
+			return l.newInvalidToken(mode, state, buf, offset, row, col), nil
 		}
 		state = nextState
-		if modeKindID, ok := l.spec.Accept(mode, state); ok {
+		if modeKindID, ok := l.accept(mode, state, lineStart); ok {
 			kindID, _ := l.spec.KindIDAndName(mode, modeKindID)
 			tok = &Token{
 				ModeID:     mode,
@@ -247,8 +1122,107 @@ func (l *Lexer) next() (*Token, error) {
 				Col:        col,
 			}
 			unfixedBufLen = 0
+			tokEndRow = l.row
+			tokEndCol = l.col
+		}
+	}
+}
+
+// newInvalidToken returns an error token. `state` must be the last state the lexer reached before it failed.
+func (l *Lexer) newInvalidToken(mode ModeID, state StateID, lexeme []byte, offset, row, col int) *Token {
+	tok := &Token{
+		ModeID:     mode,
+		ModeKindID: 0,
+		Lexeme:     lexeme,
+		Row:        row,
+		Col:        col,
+		Invalid:    true,
+	}
+	if modeKindID, ok := l.spec.ErrorKind(mode); ok {
+		tok.ModeKindID = modeKindID
+		tok.KindID, _ = l.spec.KindIDAndName(mode, modeKindID)
+	}
+	if modeKindID, ok := l.spec.PartialKind(mode, state); ok {
+		tok.PartialKindID, _ = l.spec.KindIDAndName(mode, modeKindID)
+	}
+	if l.recordInvalidSpans {
+		tok.InvalidSpans = []*InvalidSpan{
+			{
+				Offset: offset,
+				Length: len(lexeme),
+				Row:    row,
+				Col:    col,
+			},
+		}
+	}
+	return tok
+}
+
+// accept returns a kind that a state accepts. When a lexeme doesn't satisfy the anchors of the kind with the highest priority,
+// this method falls back to the next candidate.
+func (l *Lexer) accept(mode ModeID, state StateID, lineStart bool) (ModeKindID, bool) {
+	modeKindID, ok := l.spec.Accept(mode, state)
+	if !ok {
+		return 0, false
+	}
+	if l.satisfyAnchor(l.spec.Anchor(mode, modeKindID), lineStart) {
+		return modeKindID, true
+	}
+	for _, id := range l.spec.AcceptCandidates(mode, state) {
+		if l.satisfyAnchor(l.spec.Anchor(mode, id), lineStart) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// satisfyAnchor reports whether a lexeme ending at the current position satisfies anchors. `lineStart` must be true when
+// the lexeme begins at the beginning of a line.
+func (l *Lexer) satisfyAnchor(anchor Anchor, lineStart bool) bool {
+	if anchor&AnchorLineStart != 0 && !lineStart {
+		return false
+	}
+	if anchor&AnchorLineEnd != 0 {
+		if l.srcPtr >= len(l.src) {
+			l.readSrc()
+		}
+		// 0x0A is LF.
+		if l.srcPtr < len(l.src) && l.src[l.srcPtr] != 0x0A {
+			return false
+		}
+	}
+	if anchor&(AnchorWordBoundaryStart|AnchorNonWordBoundaryStart) != 0 {
+		// readSrc keeps the byte preceding the current token, so the byte is in the window unless the token begins at
+		// the beginning of the source.
+		prevWord := l.tokStart > 0 && isWordChar(l.src[l.tokStart-1])
+		boundary := prevWord != isWordChar(l.src[l.tokStart])
+		if anchor&AnchorWordBoundaryStart != 0 && !boundary || anchor&AnchorNonWordBoundaryStart != 0 && boundary {
+			return false
+		}
+	}
+	if anchor&(AnchorWordBoundaryEnd|AnchorNonWordBoundaryEnd) != 0 {
+		if l.srcPtr >= len(l.src) {
+			l.readSrc()
+		}
+		nextWord := l.srcPtr < len(l.src) && isWordChar(l.src[l.srcPtr])
+		boundary := isWordChar(l.src[l.srcPtr-1]) != nextWord
+		if anchor&AnchorWordBoundaryEnd != 0 && !boundary || anchor&AnchorNonWordBoundaryEnd != 0 && boundary {
+			return false
 		}
 	}
+	return true
+}
+
+// isWordChar reports whether a byte is a word character for word boundaries. The word characters are ASCII letters,
+// digits, and `_`.
+func isWordChar(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_'
+}
+
+// KindMeta returns the metadata of a kind defined in the `meta` field of the lexical specification. When the kind has
+// no metadata, this method returns nil. The caller must not modify the returned map.
+func (l *Lexer) KindMeta(kind KindID) map[string]string {
+	return l.spec.KindMeta(kind)
 }
 
 // Mode returns the current lex mode.
@@ -256,11 +1230,27 @@ func (l *Lexer) Mode() ModeID {
 	return l.modeStack[len(l.modeStack)-1]
 }
 
+// CurrentModeName returns the name of the current lex mode.
+func (l *Lexer) CurrentModeName() string {
+	return l.spec.ModeName(l.Mode())
+}
+
 // PushMode adds a lex mode onto the mode stack.
 func (l *Lexer) PushMode(mode ModeID) {
 	l.modeStack = append(l.modeStack, mode)
 }
 
+// PushModeByName adds a lex mode specified by its name onto the mode stack. Unlike PushMode, this method doesn't depend on
+// mode IDs, which change when you reorder modes in a lexical specification.
+func (l *Lexer) PushModeByName(name string) error {
+	mode, ok := l.spec.ModeIDByName(name)
+	if !ok {
+		return fmt.Errorf("mode `%v` is undefined", name)
+	}
+	l.PushMode(mode)
+	return nil
+}
+
 // PopMode removes a lex mode from the top of the mode stack.
 func (l *Lexer) PopMode() error {
 	sLen := len(l.modeStack)
@@ -271,42 +1261,169 @@ func (l *Lexer) PopMode() error {
 	return nil
 }
 
+// SetMode replaces the lex mode on the top of the mode stack with a specified lex mode. Unlike PushMode and PopMode, this
+// method doesn't change the depth of the mode stack, so the lexer returns to the same lex mode as before when it pops the
+// mode later. This is useful for resynchronizing the lexer with a known lex mode after an error.
+//
+// The lexer reads a token ahead after an invalid token to merge consecutive invalid tokens, and Peek also reads tokens
+// ahead. SetMode discards such tokens, and the lexer reads them again in the new lex mode.
+func (l *Lexer) SetMode(mode ModeID) error {
+	l.discardReadAhead()
+	sLen := len(l.modeStack)
+	if sLen == 0 {
+		return fmt.Errorf("cannot set a lex mode because a lex mode stack is empty")
+	}
+	l.modeStack[sLen-1] = mode
+	return nil
+}
+
+// SetModeByName replaces the lex mode on the top of the mode stack with a lex mode specified by its name.
+func (l *Lexer) SetModeByName(name string) error {
+	mode, ok := l.spec.ModeIDByName(name)
+	if !ok {
+		return fmt.Errorf("mode `%v` is undefined", name)
+	}
+	return l.SetMode(mode)
+}
+
 func (l *Lexer) read() (byte, bool) {
-	if l.srcPtr >= len(l.src) {
+	if l.srcPtr >= len(l.src) && !l.readSrc() {
+		return 0, true
+	}
+
+	if l.requireValidUTF8 && l.srcOffset+l.srcPtr >= l.validUTF8End && !l.validateUTF8() {
 		return 0, true
 	}
 
 	b := l.src[l.srcPtr]
 	l.srcPtr++
 
-	l.prevRow = l.row
-	l.prevCol = l.col
-
 	// Count the token positions.
-	// The driver treats LF as the end of lines and counts columns in code points, not bytes.
-	// To count in code points, we refer to the First Byte column in the Table 3-6.
+	// The driver treats LF as the end of lines. To count columns in code points or UTF-16 code units, we refer to
+	// the First Byte column in the Table 3-6. A code point encoded in four bytes is outside the BMP, so it occupies
+	// two UTF-16 code units.
 	//
 	// Reference:
 	// - [Table 3-6] https://www.unicode.org/versions/Unicode13.0.0/ch03.pdf > Table 3-6.  UTF-8 Bit Distribution
 	if b < 128 {
-		// 0x0A is LF.
+		// 0x0A is LF and 0x09 is TAB.
 		if b == 0x0A {
 			l.row++
 			l.col = 0
+		} else if b == 0x09 && l.tabWidth > 0 {
+			l.col = (l.col/l.tabWidth + 1) * l.tabWidth
 		} else {
 			l.col++
 		}
-	} else if b>>5 == 6 || b>>4 == 14 || b>>3 == 30 {
+	} else if l.colUnit == ColumnUnitByte {
+		l.col++
+	} else if b>>5 == 6 || b>>4 == 14 {
 		l.col++
+	} else if b>>3 == 30 {
+		if l.colUnit == ColumnUnitUTF16 {
+			l.col += 2
+		} else {
+			l.col++
+		}
 	}
 
 	return b, false
 }
 
-// We must not call this function consecutively to record the token position correctly.
-func (l *Lexer) unread(n int) {
+// validateUTF8 checks that a well-formed UTF-8 sequence begins at the current position. When the window doesn't hold
+// the whole sequence, it reads more of the source. When the sequence is ill-formed, it records an error in invalidUTF8
+// and returns false.
+func (l *Lexer) validateUTF8() bool {
+	for !utf8.FullRune(l.src[l.srcPtr:]) && l.readSrc() {
+	}
+	if l.srcErr != nil {
+		return false
+	}
+	r, size := utf8.DecodeRune(l.src[l.srcPtr:])
+	if r == utf8.RuneError && size <= 1 {
+		expected := 1
+		switch b := l.src[l.srcPtr]; {
+		case b>>5 == 6:
+			expected = 2
+		case b>>4 == 14:
+			expected = 3
+		case b>>3 == 30:
+			expected = 4
+		}
+		// utf8.FullRune reports an ill-formed sequence as a full rune as soon as it finds the error, so read the rest of
+		// the sequence to report the same bytes regardless of the chunks.
+		for len(l.src)-l.srcPtr < expected && l.readSrc() {
+		}
+		if l.srcErr != nil {
+			return false
+		}
+		seq := l.src[l.srcPtr:]
+		n := 1
+		for n < expected && n < len(seq) && seq[n]>>6 == 2 {
+			n++
+		}
+		bs := make([]byte, n)
+		copy(bs, seq[:n])
+		l.invalidUTF8 = &InvalidUTF8Error{
+			Bytes:  bs,
+			Offset: l.srcOffset + l.srcPtr,
+			Row:    l.row,
+			Col:    l.col,
+		}
+		return false
+	}
+	l.validUTF8End = l.srcOffset + l.srcPtr + size
+	return true
+}
+
+// unread gives back the last n bytes and restores the position to `row` and `col`, which must be the position before
+// reading the bytes.
+func (l *Lexer) unread(n int, row, col int) {
 	l.srcPtr -= n
 
-	l.row = l.prevRow
-	l.col = l.prevCol
+	l.row = row
+	l.col = col
+}
+
+// readSrc reads the next chunk of the source into the window when the lexer reads the source incrementally. It reports
+// whether the window got new bytes. When reading the source fails, the lexer holds the error in srcErr.
+func (l *Lexer) readSrc() bool {
+	if l.srcReader == nil {
+		return false
+	}
+
+	// Discard the bytes preceding the current token because the lexer never reads them again. However, we keep the
+	// bytes following the last token that Next returned for Rest, and one more byte preceding them for word boundaries.
+	discard := l.tokStart
+	if c := l.consumed - l.srcOffset; c < discard {
+		discard = c
+	}
+	discard--
+	if discard > 0 {
+		n := copy(l.src, l.src[discard:])
+		l.src = l.src[:n]
+		l.srcPtr -= discard
+		l.srcOffset += discard
+		l.tokStart -= discard
+	}
+	if len(l.src) == cap(l.src) {
+		src := make([]byte, len(l.src), 2*cap(l.src))
+		copy(src, l.src)
+		l.src = src
+	}
+
+	for {
+		n, err := l.srcReader.Read(l.src[len(l.src):cap(l.src)])
+		l.src = l.src[:len(l.src)+n]
+		if err != nil {
+			if err != io.EOF {
+				l.srcErr = err
+			}
+			l.srcReader = nil
+			return n > 0
+		}
+		if n > 0 {
+			return true
+		}
+	}
 }
@@ -2,9 +2,17 @@ package driver
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"unicode/utf16"
 
 	"github.com/nihei9/maleeni/compiler"
 	"github.com/nihei9/maleeni/spec"
@@ -34,6 +42,11 @@ func newLexEntryDefaultNOP(kind string, pattern string) *spec.LexEntry {
 	}
 }
 
+func withPriority(e *spec.LexEntry, priority int) *spec.LexEntry {
+	e.Priority = &priority
+	return e
+}
+
 func newLexEntryFragment(kind string, pattern string) *spec.LexEntry {
 	return &spec.LexEntry{
 		Kind:     spec.LexKindName(kind),
@@ -504,6 +517,65 @@ func TestLexer_Next(t *testing.T) {
 				newEOFTokenDefault(),
 			},
 		},
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("vowels", "\\f{vowel}+"),
+					newLexEntryDefaultNOP("non_vowels", "\\F{vowel}+"),
+					newLexEntryFragment("vowel", "[aeiou]"),
+				},
+			},
+			src: "strength aeiou",
+			tokens: []*Token{
+				newTokenDefault(2, 2, []byte("str")),
+				newTokenDefault(1, 1, []byte("e")),
+				newTokenDefault(2, 2, []byte("ngth ")),
+				newTokenDefault(1, 1, []byte("aeiou")),
+				newEOFTokenDefault(),
+			},
+		},
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("consonants", "[a-z-[aeiou]]+"),
+					newLexEntryDefaultNOP("vowels", "[a-z-[b-df-hj-np-tv-z]]+"),
+				},
+			},
+			src: "strength aeiou",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("str")),
+				newTokenDefault(2, 2, []byte("e")),
+				newTokenDefault(1, 1, []byte("ngth")),
+				newInvalidTokenDefault([]byte(" ")),
+				newTokenDefault(2, 2, []byte("aeiou")),
+				newEOFTokenDefault(),
+			},
+		},
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("identifier", "[[:alpha:]_][[:alnum:]_]*"),
+					newLexEntryDefaultNOP("hex", "0x[[:xdigit:]]+"),
+					newLexEntryDefaultNOP("punct", "[[:punct:]]"),
+					newLexEntryDefaultNOP("ws", "[[:space:]]+"),
+				},
+			},
+			src: "foo_1 0xBeeF\t;\nαbar",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("foo_1")),
+				newTokenDefault(4, 4, []byte(" ")),
+				newTokenDefault(2, 2, []byte("0xBeeF")),
+				newTokenDefault(4, 4, []byte("\t")),
+				newTokenDefault(3, 3, []byte(";")),
+				newTokenDefault(4, 4, []byte("\n")),
+				newInvalidTokenDefault([]byte("α")),
+				newTokenDefault(1, 1, []byte("bar")),
+				newEOFTokenDefault(),
+			},
+		},
 		{
 			lspec: &spec.LexSpec{
 				Name: "test",
@@ -586,6 +658,29 @@ func TestLexer_Next(t *testing.T) {
 				newEOFTokenDefault(),
 			},
 		},
+		{
+			// An emitted fragment is referenced by another pattern and also becomes tokens by itself.
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("float", `\f{digits}\.\f{digits}`),
+					{
+						Kind:     "digits",
+						Pattern:  "[0-9]+",
+						Fragment: true,
+						Emit:     true,
+					},
+					newLexEntryDefaultNOP("white_space", ` +`),
+				},
+			},
+			src: "12.3 45",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("12.3")),
+				newTokenDefault(3, 3, []byte(" ")),
+				newTokenDefault(2, 2, []byte("45")),
+				newEOFTokenDefault(),
+			},
+		},
 		{
 			lspec: &spec.LexSpec{
 				Name: "test",
@@ -713,13 +808,14 @@ func TestLexer_Next(t *testing.T) {
 				newEOFTokenDefault(),
 			},
 			// Active mode transition and an external transition function can be used together.
+			// The external transition function can refer to modes by their names.
 			passiveModeTran: false,
 			tran: func(l *Lexer, tok *Token) error {
-				switch l.spec.ModeName(l.Mode()) {
+				switch l.CurrentModeName() {
 				case "mode_1":
 					switch tok.KindID {
 					case 4: // push_2
-						l.PushMode(3)
+						return l.PushModeByName("mode_2")
 					case 5: // pop_1
 						return l.PopMode()
 					}
@@ -727,6 +823,41 @@ func TestLexer_Next(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+					newLexEntry([]string{"default"}, "space", ` `, "", false),
+					newLexEntry([]string{"default"}, "open", `"`, "string", false),
+					newLexEntry([]string{"string"}, "chars", `[a-z ]+`, "", false),
+					newLexEntry([]string{"string"}, "close", `"`, "", true),
+				},
+			},
+			src: "\"ab\ncd \"ef\"",
+			tokens: []*Token{
+				newToken(1, 3, 3, []byte(`"`)),
+				newToken(2, 4, 1, []byte(`ab`)),
+				{
+					ModeID:  2,
+					Lexeme:  []byte("\n"),
+					Invalid: true,
+				},
+				newToken(1, 1, 1, []byte(`cd`)),
+				newToken(1, 2, 2, []byte(` `)),
+				newToken(1, 3, 3, []byte(`"`)),
+				newToken(2, 4, 1, []byte(`ef`)),
+				newToken(2, 5, 2, []byte(`"`)),
+				newEOFTokenDefault(),
+			},
+			// An external transition function can resynchronize the lexer with a known mode after an invalid token.
+			tran: func(l *Lexer, tok *Token) error {
+				if tok.Invalid {
+					return l.SetModeByName("default")
+				}
+				return nil
+			},
+		},
 		{
 			lspec: &spec.LexSpec{
 				Name: "test",
@@ -740,9 +871,11 @@ func TestLexer_Next(t *testing.T) {
 					newLexEntryDefaultNOP("rparen", spec.EscapePattern(`)`)),
 					newLexEntryDefaultNOP("lbrace", spec.EscapePattern(`[`)),
 					newLexEntryDefaultNOP("backslash", spec.EscapePattern(`\`)),
+					newLexEntryDefaultNOP("caret", spec.EscapePattern(`^`)),
+					newLexEntryDefaultNOP("dollar", spec.EscapePattern(`$`)),
 				},
 			},
-			src: `.*+?|()[\`,
+			src: `.*+?|()[\^$`,
 			tokens: []*Token{
 				newTokenDefault(1, 1, []byte(`.`)),
 				newTokenDefault(2, 2, []byte(`*`)),
@@ -753,6 +886,8 @@ func TestLexer_Next(t *testing.T) {
 				newTokenDefault(7, 7, []byte(`)`)),
 				newTokenDefault(8, 8, []byte(`[`)),
 				newTokenDefault(9, 9, []byte(`\`)),
+				newTokenDefault(10, 10, []byte(`^`)),
+				newTokenDefault(11, 11, []byte(`$`)),
 				newEOFTokenDefault(),
 			},
 		},
@@ -772,6 +907,145 @@ func TestLexer_Next(t *testing.T) {
 				newEOFTokenDefault(),
 			},
 		},
+		// `\P` matches characters that don't have a character property.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("letter", `\p{L}+`),
+					newLexEntryDefaultNOP("non_letter", `\P{L}+`),
+				},
+			},
+			src: "foo\u3042123 .",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("foo\u3042")),
+				newTokenDefault(2, 2, []byte(`123 .`)),
+				newEOFTokenDefault(),
+			},
+		},
+		// `^` matches only at the beginning of a line. When a lexeme doesn't satisfy the anchor, the driver falls back to
+		// another kind the lexeme matches.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("heading", `^#+`),
+					newLexEntryDefaultNOP("hash", `#+`),
+					newLexEntryDefaultNOP("text", `[^#\u{000A}]+`),
+					newLexEntryDefaultNOP("newline", `\u{000A}`),
+				},
+			},
+			src: "# foo #\n## bar\n \n#",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("#")),
+				newTokenDefault(3, 3, []byte(" foo ")),
+				newTokenDefault(2, 2, []byte("#")),
+				newTokenDefault(4, 4, []byte("\n")),
+				newTokenDefault(1, 1, []byte("##")),
+				newTokenDefault(3, 3, []byte(" bar")),
+				newTokenDefault(4, 4, []byte("\n")),
+				newTokenDefault(3, 3, []byte(" ")),
+				newTokenDefault(4, 4, []byte("\n")),
+				newTokenDefault(1, 1, []byte("#")),
+				newEOFTokenDefault(),
+			},
+		},
+		// `$` matches only when a lexeme is followed by LF or EOF. When a lexeme doesn't satisfy the anchor, the driver
+		// continues to look for a longer lexeme.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("trailing_space", ` +$`),
+					newLexEntryDefaultNOP("space", ` +`),
+					newLexEntryDefaultNOP("kw_end", `end$`),
+					newLexEntryDefaultNOP("id", `[a-z]+`),
+					newLexEntryDefaultNOP("newline", `\u{000A}`),
+				},
+			},
+			src: "foo end  \nendx end\nend  ",
+			tokens: []*Token{
+				newTokenDefault(4, 4, []byte("foo")),
+				newTokenDefault(2, 2, []byte(" ")),
+				newTokenDefault(4, 4, []byte("end")),
+				newTokenDefault(1, 1, []byte("  ")),
+				newTokenDefault(5, 5, []byte("\n")),
+				newTokenDefault(4, 4, []byte("endx")),
+				newTokenDefault(2, 2, []byte(" ")),
+				newTokenDefault(3, 3, []byte("end")),
+				newTokenDefault(5, 5, []byte("\n")),
+				newTokenDefault(4, 4, []byte("end")),
+				newTokenDefault(1, 1, []byte("  ")),
+				newEOFTokenDefault(),
+			},
+		},
+		// A pattern can have both anchors.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("separator", `^-+$`),
+					newLexEntryDefaultNOP("text", `[^\u{000A}]+`),
+					newLexEntryDefaultNOP("newline", `\u{000A}`),
+				},
+			},
+			src: "---\nfoo ---\n--- foo\n---",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("---")),
+				newTokenDefault(3, 3, []byte("\n")),
+				newTokenDefault(2, 2, []byte("foo ---")),
+				newTokenDefault(3, 3, []byte("\n")),
+				newTokenDefault(2, 2, []byte("--- foo")),
+				newTokenDefault(3, 3, []byte("\n")),
+				newTokenDefault(1, 1, []byte("---")),
+				newEOFTokenDefault(),
+			},
+		},
+		// `\b` matches at a word boundary, including the beginning and end of the source. `_` is a word character.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("cat", `\bcat\b`),
+					newLexEntryDefaultNOP("letters", `[a-z]+`),
+					newLexEntryDefaultNOP("other", `[^a-z]`),
+				},
+			},
+			src: "cat cats _cat cat_ cat",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("cat")),
+				newTokenDefault(3, 3, []byte(" ")),
+				newTokenDefault(2, 2, []byte("cats")),
+				newTokenDefault(3, 3, []byte(" ")),
+				newTokenDefault(3, 3, []byte("_")),
+				newTokenDefault(2, 2, []byte("cat")),
+				newTokenDefault(3, 3, []byte(" ")),
+				newTokenDefault(2, 2, []byte("cat")),
+				newTokenDefault(3, 3, []byte("_")),
+				newTokenDefault(3, 3, []byte(" ")),
+				newTokenDefault(1, 1, []byte("cat")),
+				newEOFTokenDefault(),
+			},
+		},
+		// `\B` matches where `\b` doesn't.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("inner", `\B[a-z]\B`),
+					newLexEntryDefaultNOP("char", `[a-z ]`),
+				},
+			},
+			src: "abc d",
+			tokens: []*Token{
+				newTokenDefault(2, 2, []byte("a")),
+				newTokenDefault(1, 1, []byte("b")),
+				newTokenDefault(2, 2, []byte("c")),
+				newTokenDefault(2, 2, []byte(" ")),
+				newTokenDefault(2, 2, []byte("d")),
+				newEOFTokenDefault(),
+			},
+		},
 		// The driver can continue lexical analysis even after it detects an invalid token.
 		{
 			lspec: &spec.LexSpec{
@@ -788,6 +1062,113 @@ func TestLexer_Next(t *testing.T) {
 				newEOFTokenDefault(),
 			},
 		},
+		// An entry with a higher priority takes precedence regardless of its position.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("identifier", `[a-z]+`),
+					newLexEntryDefaultNOP("white_space", ` +`),
+					withPriority(newLexEntryDefaultNOP("kw_if", `if`), 0),
+					newLexEntryDefaultNOP("kw_else", `else`),
+				},
+			},
+			src: "if else iff",
+			tokens: []*Token{
+				newTokenDefault(3, 3, []byte("if")),
+				newTokenDefault(2, 2, []byte(" ")),
+				newTokenDefault(1, 1, []byte("else")),
+				newTokenDefault(2, 2, []byte(" ")),
+				newTokenDefault(1, 1, []byte("iff")),
+				newEOFTokenDefault(),
+			},
+		},
+		// Entries having the same priority are ordered by their positions.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					withPriority(newLexEntryDefaultNOP("identifier", `[a-z]+`), 5),
+					withPriority(newLexEntryDefaultNOP("kw_if", `if`), 5),
+					withPriority(newLexEntryDefaultNOP("kw_else", `else`), 1),
+				},
+			},
+			src: "ifelse",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("ifelse")),
+				newEOFTokenDefault(),
+			},
+		},
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					withPriority(newLexEntryDefaultNOP("identifier", `[a-z]+`), 5),
+					withPriority(newLexEntryDefaultNOP("kw_if", `if`), 5),
+					withPriority(newLexEntryDefaultNOP("kw_else", `else`), 1),
+					newLexEntryDefaultNOP("white_space", ` +`),
+				},
+			},
+			src: "if else",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("if")),
+				newTokenDefault(4, 4, []byte(" ")),
+				newTokenDefault(3, 3, []byte("else")),
+				newEOFTokenDefault(),
+			},
+		},
+		// `.` matches one character encoded in UTF-8, and a byte that isn't valid UTF-8 is an invalid token.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("char", `.`),
+				},
+			},
+			src: "a\u3042\xFF",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("a")),
+				newTokenDefault(1, 1, []byte("\u3042")),
+				newInvalidTokenDefault([]byte("\xFF")),
+				newEOFTokenDefault(),
+			},
+		},
+		// `\C` matches one byte regardless of UTF-8 encoding.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("byte", `\C`),
+				},
+			},
+			src: "a\u3042\xFF",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("a")),
+				newTokenDefault(1, 1, []byte("\xE3")),
+				newTokenDefault(1, 1, []byte("\x81")),
+				newTokenDefault(1, 1, []byte("\x82")),
+				newTokenDefault(1, 1, []byte("\xFF")),
+				newEOFTokenDefault(),
+			},
+		},
+		// `\C` can be a part of a pattern with characters.
+		{
+			lspec: &spec.LexSpec{
+				Name: "test",
+				Entries: []*spec.LexEntry{
+					newLexEntryDefaultNOP("tagged", `#\C\C`),
+					newLexEntryDefaultNOP("char", `.`),
+				},
+			},
+			src: "#\xFF\x00a#\u3042",
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("#\xFF\x00")),
+				newTokenDefault(2, 2, []byte("a")),
+				newTokenDefault(1, 1, []byte("#\xE3\x81")),
+				newInvalidTokenDefault([]byte("\x82")),
+				newEOFTokenDefault(),
+			},
+		},
 	}
 	for i, tt := range test {
 		for compLv := compiler.CompressionLevelMin; compLv <= compiler.CompressionLevelMax; compLv++ {
@@ -905,7 +1286,8 @@ func TestLexer_Next_WithPosition(t *testing.T) {
 		// the line number where a lexeme first appears.
 		withPos(newTokenDefault(1, 1, []byte{0x0A, 0x0A, 0x0A}), 3, 6),
 
-		withPos(newEOFTokenDefault(), 0, 0),
+		// The EOF token is located at the end of the source.
+		withPos(newEOFTokenDefault(), 6, 0),
 	}
 
 	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src))
@@ -927,21 +1309,2550 @@ func TestLexer_Next_WithPosition(t *testing.T) {
 	}
 }
 
-func testToken(t *testing.T, expected, actual *Token, checkPosition bool) {
-	t.Helper()
+func TestLexer_Next_ColumnUnit(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("white_space", `( |\u{0009})+`),
+			newLexEntryDefaultNOP("abcde", `abcde`),
+			newLexEntryDefaultNOP("abc", `abc`),
+			newLexEntryDefaultNOP("newline", `\u{000A}`),
+			newLexEntryDefaultNOP("any", `.`),
+		},
+	}
 
-	if actual.ModeID != expected.ModeID ||
-		actual.KindID != expected.KindID ||
-		actual.ModeKindID != expected.ModeKindID ||
-		!bytes.Equal(actual.Lexeme, expected.Lexeme) ||
-		actual.EOF != expected.EOF ||
-		actual.Invalid != expected.Invalid {
-		t.Fatalf(`unexpected token; want: %v ("%#v"), got: %v ("%#v")`, expected, string(expected.Lexeme), actual, string(actual.Lexeme))
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if checkPosition {
-		if actual.Row != expected.Row || actual.Col != expected.Col {
-			t.Fatalf(`unexpected token; want: %v ("%#v"), got: %v ("%#v")`, expected, string(expected.Lexeme), actual, string(actual.Lexeme))
+	// The lexer reads `d` and the following tab to try `abcde`, and then it gives back them. The position of `d`
+	// must not be affected by them.
+	src := "\tabcd\tβ😀 x\n😀\ty"
+
+	tokens := []*Token{
+		newTokenDefault(1, 1, []byte("\t")),
+		newTokenDefault(3, 3, []byte("abc")),
+		newTokenDefault(5, 5, []byte("d")),
+		newTokenDefault(1, 1, []byte("\t")),
+		newTokenDefault(5, 5, []byte("β")),
+		newTokenDefault(5, 5, []byte("😀")),
+		newTokenDefault(1, 1, []byte(" ")),
+		newTokenDefault(5, 5, []byte("x")),
+		newTokenDefault(4, 4, []byte("\n")),
+		newTokenDefault(5, 5, []byte("😀")),
+		newTokenDefault(1, 1, []byte("\t")),
+		newTokenDefault(5, 5, []byte("y")),
+	}
+
+	tests := []struct {
+		caption string
+		opts    []LexerOption
+		cols    []int
+	}{
+		{
+			caption: "the lexer counts columns in code points by default",
+			cols:    []int{0, 1, 4, 5, 6, 7, 8, 9, 10, 0, 1, 2},
+		},
+		{
+			caption: "the lexer can count columns in bytes",
+			opts: []LexerOption{
+				CountColumnsIn(ColumnUnitByte),
+			},
+			cols: []int{0, 1, 4, 5, 6, 8, 12, 13, 14, 0, 4, 5},
+		},
+		{
+			caption: "the lexer can count columns in UTF-16 code units",
+			opts: []LexerOption{
+				CountColumnsIn(ColumnUnitUTF16),
+			},
+			cols: []int{0, 1, 4, 5, 6, 7, 9, 10, 11, 0, 2, 3},
+		},
+		{
+			caption: "the lexer can expand tabs",
+			opts: []LexerOption{
+				ExpandTabs(4),
+			},
+			cols: []int{0, 4, 7, 8, 12, 13, 14, 15, 16, 0, 1, 4},
+		},
+		{
+			caption: "the lexer can expand tabs while counting columns in bytes",
+			opts: []LexerOption{
+				CountColumnsIn(ColumnUnitByte),
+				ExpandTabs(8),
+			},
+			cols: []int{0, 8, 11, 12, 16, 18, 22, 23, 24, 0, 4, 8},
+		},
+		{
+			caption: "the lexer can expand tabs while counting columns in UTF-16 code units",
+			opts: []LexerOption{
+				CountColumnsIn(ColumnUnitUTF16),
+				ExpandTabs(4),
+			},
+			cols: []int{0, 4, 7, 8, 12, 13, 15, 16, 17, 0, 2, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i, eTok := range tokens {
+				row := 0
+				if i >= 9 {
+					row = 1
+				}
+				tok, err := lexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				testToken(t, withPos(eTok, row, tt.cols[i]), tok, true)
+			}
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tok.EOF {
+				t.Fatalf("expected EOF token: %+v", tok)
+			}
+		})
+	}
+
+	t.Run("invalid options", func(t *testing.T) {
+		for _, opt := range []LexerOption{CountColumnsIn(ColumnUnit(-1)), ExpandTabs(0)} {
+			_, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src), opt)
+			if err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
 		}
+	})
+}
+
+func TestToken_IsKindAndInMode(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntry([]string{"default"}, "quote_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[^"]+`, "", false),
+			newLexEntry([]string{"string"}, "quote_close", `"`, "", true),
+		},
 	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(`foo"bar"1`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		kind string
+		mode string
+	}{
+		{kind: "word", mode: "default"},
+		{kind: "quote_open", mode: "default"},
+		{kind: "char_seq", mode: "string"},
+		{kind: "quote_close", mode: "string"},
+		// Neither the error token nor the EOF token has a kind.
+		{kind: "", mode: "default"},
+		{kind: "", mode: "default"},
+	}
+	for i, tt := range tests {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tt.kind != "" && !tok.IsKind(tt.kind) {
+			t.Errorf("#%v: the token must be %v: %v", i, tt.kind, tok)
+		}
+		for _, k := range []string{"word", "quote_open", "char_seq", "quote_close", ""} {
+			if k != tt.kind && tok.IsKind(k) {
+				t.Errorf("#%v: the token must not be %q: %v", i, k, tok)
+			}
+		}
+		if !tok.InMode(tt.mode) {
+			t.Errorf("#%v: the token must be in %v mode: %v", i, tt.mode, tok)
+		}
+		if tok.InMode("other") {
+			t.Errorf("#%v: the token must not be in other mode: %v", i, tok)
+		}
+	}
+
+	// A token that the lexer didn't make doesn't know the names.
+	tok := &Token{
+		ModeID:     ModeID(spec.LexModeIDDefault.Int()),
+		KindID:     1,
+		ModeKindID: 1,
+	}
+	if tok.IsKind("word") || tok.InMode("default") {
+		t.Errorf("a token that the lexer didn't make must not have names: %v", tok)
+	}
+}
+
+func TestToken_FormatLexeme(t *testing.T) {
+	tests := []struct {
+		lexeme []byte
+		format LexemeFormat
+		output string
+	}{
+		{
+			lexeme: []byte("あ\t\"a\""),
+			format: LexemeFormatText,
+			output: "あ\t\"a\"",
+		},
+		{
+			lexeme: []byte("あ\t\"a\""),
+			format: LexemeFormatQuoted,
+			output: `"あ\t\"a\""`,
+		},
+		{
+			lexeme: []byte("あ\t\"a\""),
+			format: LexemeFormatHex,
+			output: "E3 81 82 09 22 61 22",
+		},
+		{
+			// An invalid UTF-8 byte is escaped.
+			lexeme: []byte{0xe3, 0x81},
+			format: LexemeFormatQuoted,
+			output: `"\xe3\x81"`,
+		},
+		{
+			lexeme: []byte{0xe3, 0x81},
+			format: LexemeFormatHex,
+			output: "E3 81",
+		},
+		{
+			lexeme: nil,
+			format: LexemeFormatHex,
+			output: "",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("#%v", i), func(t *testing.T) {
+			tok := &Token{
+				Lexeme: tt.lexeme,
+			}
+			if out := tok.FormatLexeme(tt.format); out != tt.output {
+				t.Fatalf("unexpected output; want: %v, got: %v", tt.output, out)
+			}
+		})
+	}
+}
+
+func TestLexer_OnToken(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", `[\u{0020}\u{000A}]+`),
+		},
+	}
+	lspec.Entries[1].Skip = true
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A simple indent tracker injects an indent or a dedent token when the first token of a line is indented more or
+	// less than the preceding token. It assumes that each line has only one token.
+	const (
+		indent = "<indent>"
+		dedent = "<dedent>"
+	)
+	hook := func(prev, cur *Token) []*Token {
+		if prev == nil {
+			return nil
+		}
+		if cur.EOF {
+			if prev.Col > 0 {
+				return []*Token{{Lexeme: []byte(dedent), Row: cur.Row, Col: cur.Col}}
+			}
+			return nil
+		}
+		if cur.Row == prev.Row {
+			return nil
+		}
+		switch {
+		case cur.Col > prev.Col:
+			return []*Token{{Lexeme: []byte(indent), Row: cur.Row, Col: cur.Col}}
+		case cur.Col < prev.Col:
+			// Returning multiple tokens is also possible.
+			return []*Token{
+				{Lexeme: []byte(dedent), Row: cur.Row, Col: cur.Col},
+				{Lexeme: []byte(dedent), Row: cur.Row, Col: cur.Col},
+			}
+		}
+		return nil
+	}
+	lexemes := func(t *testing.T, lexer *Lexer) []string {
+		t.Helper()
+		var lexemes []string
+		for {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tok.EOF {
+				return lexemes
+			}
+			lexemes = append(lexemes, string(tok.Lexeme))
+		}
+	}
+
+	src := "foo\n  bar\n  baz\nqux\n  quux"
+	expected := []string{"foo", indent, "bar", "baz", dedent, dedent, "qux", indent, "quux", dedent}
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src), OnToken(hook))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := lexemes(t, lexer); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("unexpected tokens; want: %q, got: %q", expected, actual)
+	}
+
+	// The lexer doesn't return synthetic tokens that Next has already returned even when it reads the token following
+	// them again.
+	lexer, err = NewLexer(NewLexSpec(clspec), strings.NewReader(src), OnToken(hook))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var actual []string
+	for i := 0; i < 5; i++ {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		actual = append(actual, string(tok.Lexeme))
+	}
+	// The lexer has returned the first dedent token, and it has read `qux` ahead.
+	pos := lexer.Mark()
+	if _, err := lexer.PeekN(3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = lexer.Restore(pos)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = lexer.SetMode(ModeID(spec.LexModeIDDefault.Int()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual = append(actual, lexemes(t, lexer)...)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("unexpected tokens; want: %q, got: %q", expected, actual)
+	}
+	if rest := lexer.Rest(); len(rest) != 0 {
+		t.Fatalf("unexpected rest: %q", rest)
+	}
+}
+
+func TestLexer_Next_EmptyInput(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("line_head", `^#`),
+			newLexEntryDefaultNOP("word_head", `\b_`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		caption string
+		src     string
+		opts    []LexerOption
+	}{
+		{
+			caption: "empty input",
+		},
+		{
+			caption: "empty input read incrementally",
+			opts:    []LexerOption{ReadIncrementally()},
+		},
+		{
+			caption: "a byte order mark only",
+			src:     "\uFEFF",
+			opts:    []LexerOption{StripBOM()},
+		},
+		{
+			caption: "a byte order mark only read incrementally",
+			src:     "\uFEFF",
+			opts:    []LexerOption{StripBOM(), ReadIncrementally()},
+		},
+	}
+	for _, tt := range tests {
+		newLexers := map[string]func() (*Lexer, error){
+			"NewLexer": func() (*Lexer, error) {
+				return NewLexer(NewLexSpec(clspec), strings.NewReader(tt.src), tt.opts...)
+			},
+			"NewLexerFromBytes": func() (*Lexer, error) {
+				return NewLexerFromBytes(NewLexSpec(clspec), []byte(tt.src), tt.opts...)
+			},
+		}
+		for name, newLexer := range newLexers {
+			t.Run(fmt.Sprintf("%v, %v", tt.caption, name), func(t *testing.T) {
+				lexer, err := newLexer()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				pos := lexer.Mark()
+				tok, err := lexer.Peek()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				testToken(t, withPos(newEOFTokenDefault(), 0, 0), tok, true)
+				// The lexer keeps returning the EOF token at the same position.
+				for i := 0; i < 3; i++ {
+					tok, err := lexer.Next()
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					testToken(t, withPos(newEOFTokenDefault(), 0, 0), tok, true)
+					if len(tok.Lexeme) != 0 {
+						t.Fatalf("the EOF token must have no lexeme: %v", tok.Lexeme)
+					}
+					rest := lexer.Rest()
+					if rest == nil || len(rest) != 0 {
+						t.Fatalf("the rest of the source must be empty: %#v", rest)
+					}
+				}
+				err = lexer.Restore(pos)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				tok, err = lexer.Next()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				testToken(t, withPos(newEOFTokenDefault(), 0, 0), tok, true)
+			})
+		}
+	}
+}
+
+func TestNewLexerFromBytes(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z\u{3042}]+`),
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lexAll := func(t *testing.T, lexer *Lexer) []*Token {
+		t.Helper()
+		var toks []*Token
+		for {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			toks = append(toks, tok)
+			if tok.EOF {
+				return toks
+			}
+		}
+	}
+	text := "\uFEFFfoo \u3042\nbar!"
+	for _, opts := range [][]LexerOption{
+		nil,
+		{StripBOM()},
+		// ReadIncrementally has no effect on NewLexerFromBytes.
+		{ReadIncrementally(), StripBOM()},
+		{CountColumnsIn(ColumnUnitByte)},
+	} {
+		lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(text), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := lexAll(t, lexer)
+		src := []byte(text)
+		lexer, err = NewLexerFromBytes(NewLexSpec(clspec), src, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := lexAll(t, lexer)
+		if len(actual) != len(expected) {
+			t.Fatalf("unexpected tokens; want: %v, got: %v", expected, actual)
+		}
+		for i, tok := range actual {
+			testToken(t, expected[i], tok, true)
+		}
+		if string(src) != text {
+			t.Fatalf("the lexer must not modify the source")
+		}
+	}
+
+	// The lexer transcodes a source encoded in UTF-16.
+	var src []byte
+	for _, r := range "foo \u3042" {
+		src = append(src, byte(r), byte(r>>8))
+	}
+	lexer, err := NewLexerFromBytes(NewLexSpec(clspec), src, DecodeFrom(InputEncodingUTF16LE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	toks := lexAll(t, lexer)
+	if len(toks) != 4 || string(toks[0].Lexeme) != "foo" || string(toks[2].Lexeme) != "\u3042" {
+		t.Fatalf("unexpected tokens: %v", toks)
+	}
+}
+
+func TestLexer_Next_DecodeFrom(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z\u{3042}\u{01F600}]+`),
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+			newLexEntryDefaultNOP("replacement", `\u{FFFD}`),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	encodeUTF16 := func(s string, le bool) []byte {
+		var b []byte
+		for _, u := range utf16.Encode([]rune(s)) {
+			if le {
+				b = append(b, byte(u), byte(u>>8))
+			} else {
+				b = append(b, byte(u>>8), byte(u))
+			}
+		}
+		return b
+	}
+	encodeUTF32 := func(s string, le bool) []byte {
+		var b []byte
+		for _, r := range s {
+			if le {
+				b = append(b, byte(r), byte(r>>8), byte(r>>16), byte(r>>24))
+			} else {
+				b = append(b, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+			}
+		}
+		return b
+	}
+
+	// U+1F600 is outside the BMP, so it occupies two UTF-16 code units.
+	text := "\uFEFFfoo \u3042\U0001F600\nbar"
+	lex := func(src []byte, opts ...LexerOption) []*Token {
+		t.Helper()
+		opts = append(opts, StripBOM(), CountColumnsIn(ColumnUnitUTF16))
+		// The reader returns one byte at a time, so surrogate pairs and code units are split across reads.
+		lexer, err := NewLexer(NewLexSpec(clspec), iotest.OneByteReader(bytes.NewReader(src)), opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var toks []*Token
+		for {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			toks = append(toks, tok)
+			if tok.EOF {
+				return toks
+			}
+		}
+	}
+	expected := lex([]byte(text))
+	if len(expected) != 6 || expected[4].Col != 0 || expected[5].Col != 3 {
+		t.Fatalf("unexpected tokens: %v", expected)
+	}
+	for _, tt := range []struct {
+		enc InputEncoding
+		src []byte
+	}{
+		{enc: InputEncodingUTF8, src: []byte(text)},
+		{enc: InputEncodingUTF16LE, src: encodeUTF16(text, true)},
+		{enc: InputEncodingUTF16BE, src: encodeUTF16(text, false)},
+		{enc: InputEncodingUTF32LE, src: encodeUTF32(text, true)},
+		{enc: InputEncodingUTF32BE, src: encodeUTF32(text, false)},
+	} {
+		for _, incremental := range []bool{false, true} {
+			t.Run(fmt.Sprintf("encoding: %v, incremental: %v", tt.enc, incremental), func(t *testing.T) {
+				opts := []LexerOption{DecodeFrom(tt.enc)}
+				if incremental {
+					opts = append(opts, ReadIncrementally())
+				}
+				actual := lex(tt.src, opts...)
+				if len(actual) != len(expected) {
+					t.Fatalf("unexpected tokens; want: %v, got: %v", expected, actual)
+				}
+				for i, tok := range actual {
+					testToken(t, expected[i], tok, true)
+				}
+			})
+		}
+	}
+
+	// Ill-formed sequences become U+FFFD.
+	for _, tt := range []struct {
+		caption string
+		enc     InputEncoding
+		src     []byte
+	}{
+		{
+			caption: "an unpaired high surrogate",
+			enc:     InputEncodingUTF16LE,
+			src:     []byte{0x3D, 0xD8, 'a', 0x00},
+		},
+		{
+			caption: "an unpaired low surrogate",
+			enc:     InputEncodingUTF16BE,
+			src:     []byte{0xDE, 0x00, 0x00, 'a'},
+		},
+		{
+			caption: "an incomplete code unit",
+			enc:     InputEncodingUTF16LE,
+			src:     []byte{'a', 0x00, 'a'},
+		},
+		{
+			caption: "a code point out of range",
+			enc:     InputEncodingUTF32BE,
+			src:     []byte{0x00, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 'a'},
+		},
+	} {
+		t.Run(tt.caption, func(t *testing.T) {
+			toks := lex(tt.src, DecodeFrom(tt.enc))
+			var kinds []string
+			for _, tok := range toks[:len(toks)-1] {
+				kinds = append(kinds, clspec.KindNames[tok.KindID].String())
+			}
+			if len(kinds) != 2 || !(kinds[0] == "replacement" && kinds[1] == "word" || kinds[0] == "word" && kinds[1] == "replacement") {
+				t.Fatalf("unexpected tokens: %v", toks)
+			}
+		})
+	}
+
+	_, err = NewLexer(NewLexSpec(clspec), strings.NewReader(""), DecodeFrom(InputEncoding(100)))
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
+
+func TestLexer_Next_StripBOM(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bom := "\xEF\xBB\xBF"
+	expected := []*Token{
+		withPos(newTokenDefault(1, 1, []byte("foo")), 0, 0),
+		withPos(newTokenDefault(2, 2, []byte(" ")), 0, 3),
+		withPos(newTokenDefault(1, 1, []byte("bar")), 0, 4),
+		withPos(newTokenDefault(2, 2, []byte("\n")), 0, 7),
+		withPos(newTokenDefault(1, 1, []byte("baz")), 1, 0),
+		withPos(newEOFTokenDefault(), 1, 3),
+	}
+
+	tests := []struct {
+		caption  string
+		src      string
+		opts     []LexerOption
+		expected []*Token
+	}{
+		{
+			caption:  "the lexer skips a BOM",
+			src:      bom + "foo bar\nbaz",
+			opts:     []LexerOption{StripBOM()},
+			expected: expected,
+		},
+		{
+			caption:  "the lexer works without a BOM",
+			src:      "foo bar\nbaz",
+			opts:     []LexerOption{StripBOM()},
+			expected: expected,
+		},
+		{
+			caption: "the lexer skips only a BOM at the beginning of the source",
+			src:     bom + bom + "foo",
+			opts:    []LexerOption{StripBOM()},
+			expected: []*Token{
+				withPos(newInvalidTokenDefault([]byte(bom)), 0, 0),
+				withPos(newTokenDefault(1, 1, []byte("foo")), 0, 1),
+				withPos(newEOFTokenDefault(), 0, 4),
+			},
+		},
+		{
+			caption: "the lexer treats a BOM as an invalid token by default",
+			src:     bom + "foo",
+			expected: []*Token{
+				withPos(newInvalidTokenDefault([]byte(bom)), 0, 0),
+				withPos(newTokenDefault(1, 1, []byte("foo")), 0, 1),
+				withPos(newEOFTokenDefault(), 0, 4),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(tt.src), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, eTok := range tt.expected {
+				tok, err := lexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				testToken(t, eTok, tok, true)
+			}
+		})
+	}
+}
+
+func TestLexer_Next_RecordInvalidSpans(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+			newLexEntryDefaultNOP("hiragana_a", `\u{3042}`),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 0xE3 0x81 is a prefix of U+3042, so the lexer reads both bytes and the following `c` before it fails.
+	src := "ab\xFF\xE3\x81c\n\xFEd"
+
+	tests := []struct {
+		tok   *Token
+		spans []*InvalidSpan
+	}{
+		{
+			tok: withPos(newTokenDefault(1, 1, []byte("ab")), 0, 0),
+		},
+		{
+			tok: withPos(newInvalidTokenDefault([]byte("\xFF\xE3\x81c")), 0, 2),
+			spans: []*InvalidSpan{
+				{Offset: 2, Length: 1, Row: 0, Col: 2},
+				{Offset: 3, Length: 3, Row: 0, Col: 2},
+			},
+		},
+		{
+			tok: withPos(newTokenDefault(2, 2, []byte("\n")), 0, 4),
+		},
+		{
+			tok: withPos(newInvalidTokenDefault([]byte("\xFE")), 1, 0),
+			spans: []*InvalidSpan{
+				{Offset: 7, Length: 1, Row: 1, Col: 0},
+			},
+		},
+		{
+			tok: withPos(newTokenDefault(1, 1, []byte("d")), 1, 0),
+		},
+		{
+			tok: withPos(newEOFTokenDefault(), 1, 1),
+		},
+	}
+
+	t.Run("the lexer records the parts of error tokens", func(t *testing.T) {
+		lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src), RecordInvalidSpans())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, tt := range tests {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testToken(t, tt.tok, tok, true)
+			if len(tok.InvalidSpans) != len(tt.spans) {
+				t.Fatalf("unexpected spans; want: %v spans, got: %v spans", len(tt.spans), len(tok.InvalidSpans))
+			}
+			for i, span := range tok.InvalidSpans {
+				if *span != *tt.spans[i] {
+					t.Fatalf("unexpected span; want: %+v, got: %+v", tt.spans[i], span)
+				}
+			}
+		}
+	})
+
+	t.Run("the lexer doesn't record the parts of error tokens by default", func(t *testing.T) {
+		lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, tt := range tests {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testToken(t, tt.tok, tok, true)
+			if tok.InvalidSpans != nil {
+				t.Fatalf("spans must be nil: %v", tok.InvalidSpans)
+			}
+		}
+	})
+}
+
+func TestLexer_Next_StopOnInvalid(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader("ab\nc#$d"), StopOnInvalid())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []*Token{
+		withPos(newTokenDefault(1, 1, []byte("ab")), 0, 0),
+		withPos(newTokenDefault(2, 2, []byte("\n")), 0, 2),
+		withPos(newTokenDefault(1, 1, []byte("c")), 1, 0),
+	} {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testToken(t, expected, tok, true)
+	}
+
+	tok, err := lexer.Next()
+	if tok != nil {
+		t.Fatalf("the lexer must not return a token: %v", tok)
+	}
+	lexErr, ok := err.(*LexError)
+	if !ok {
+		t.Fatalf("unexpected error; want: *LexError, got: %T (%v)", err, err)
+	}
+	if string(lexErr.Lexeme) != "#$" || lexErr.Offset != 4 || lexErr.Row != 1 || lexErr.Col != 1 {
+		t.Fatalf("unexpected error: %+v", lexErr)
+	}
+	testToken(t, withPos(newInvalidTokenDefault([]byte("#$")), 1, 1), lexErr.Token, true)
+
+	// The lexer can continue after the error.
+	for _, expected := range []*Token{
+		withPos(newTokenDefault(1, 1, []byte("d")), 1, 3),
+		withPos(newEOFTokenDefault(), 1, 4),
+	} {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testToken(t, expected, tok, true)
+	}
+}
+
+func TestLexer_Next_RequireValidUTF8(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("hiragana", `[\u{3041}-\u{3096}]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		caption string
+		src     []byte
+		tokens  []*Token
+		invalid []byte
+		offset  int
+		col     int
+	}{
+		{
+			caption: "a sequence truncated at the end of the source",
+			src:     []byte("abあ\xe3\x81"),
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("ab")),
+				newTokenDefault(2, 2, []byte("あ")),
+			},
+			invalid: []byte{0xe3, 0x81},
+			offset:  5,
+			col:     3,
+		},
+		{
+			caption: "a sequence truncated by the following code point",
+			src:     []byte("ab\xe3\x81cd"),
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("ab")),
+			},
+			invalid: []byte{0xe3, 0x81},
+			offset:  2,
+			col:     2,
+		},
+		{
+			caption: "a sequence truncated in the middle of a token",
+			src:     []byte("あ\xe3\x81い"),
+			tokens: []*Token{
+				newTokenDefault(2, 2, []byte("あ")),
+			},
+			invalid: []byte{0xe3, 0x81},
+			offset:  3,
+			col:     1,
+		},
+		{
+			caption: "an overlong encoding of `/`",
+			src:     []byte("ab\xc0\xaf"),
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("ab")),
+			},
+			invalid: []byte{0xc0, 0xaf},
+			offset:  2,
+			col:     2,
+		},
+		{
+			caption: "an overlong encoding of NUL in three bytes",
+			src:     []byte("\xe0\x80\x80ab"),
+			invalid: []byte{0xe0, 0x80, 0x80},
+			offset:  0,
+			col:     0,
+		},
+		{
+			caption: "a surrogate code point",
+			src:     []byte("ab\xed\xa0\x80"),
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("ab")),
+			},
+			invalid: []byte{0xed, 0xa0, 0x80},
+			offset:  2,
+			col:     2,
+		},
+		{
+			caption: "a continuation byte without a leading byte",
+			src:     []byte("ab\x80"),
+			tokens: []*Token{
+				newTokenDefault(1, 1, []byte("ab")),
+			},
+			invalid: []byte{0x80},
+			offset:  2,
+			col:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			for _, readIncrementally := range []bool{false, true} {
+				var src io.Reader = bytes.NewReader(tt.src)
+				opts := []LexerOption{RequireValidUTF8()}
+				if readIncrementally {
+					src = iotest.OneByteReader(src)
+					opts = append(opts, ReadIncrementally())
+				}
+				lexer, err := NewLexer(NewLexSpec(clspec), src, opts...)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				for _, expected := range tt.tokens {
+					tok, err := lexer.Next()
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					testToken(t, expected, tok, false)
+				}
+				// The lexer keeps returning the error.
+				for i := 0; i < 2; i++ {
+					tok, err := lexer.Next()
+					if tok != nil {
+						t.Fatalf("the lexer must not return a token: %v", tok)
+					}
+					utf8Err, ok := err.(*InvalidUTF8Error)
+					if !ok {
+						t.Fatalf("unexpected error; want: *InvalidUTF8Error, got: %T (%v)", err, err)
+					}
+					if !bytes.Equal(utf8Err.Bytes, tt.invalid) || utf8Err.Offset != tt.offset || utf8Err.Row != 0 || utf8Err.Col != tt.col {
+						t.Fatalf("unexpected error: %+v", utf8Err)
+					}
+				}
+			}
+		})
+	}
+
+	// A well-formed code point that no pattern accepts is still an error token.
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader("ab漢cd"), RequireValidUTF8())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []*Token{
+		newTokenDefault(1, 1, []byte("ab")),
+		newInvalidTokenDefault([]byte("漢")),
+		newTokenDefault(1, 1, []byte("cd")),
+		newEOFTokenDefault(),
+	} {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		testToken(t, expected, tok, false)
+	}
+}
+
+func TestLexer_Next_PopAndPushInitialMode(t *testing.T) {
+	// An entry popping the initial mode and pushing another mode at once replaces the initial mode, so the mode stack
+	// is empty only temporarily.
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			{Kind: "a", Pattern: "a"},
+			{Kind: "go_b", Pattern: "b", Pop: true, Push: "b"},
+			{Kind: "c", Pattern: "c", Modes: []spec.LexModeName{"b"}},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader("abcc"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var kinds []string
+	for {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tok.EOF {
+			break
+		}
+		if tok.Invalid {
+			t.Fatalf("unexpected invalid token: %v", tok)
+		}
+		kinds = append(kinds, clspec.KindNames[tok.KindID].String())
+	}
+	if strings.Join(kinds, " ") != "a go_b c c" {
+		t.Fatalf("unexpected kinds: %v", kinds)
+	}
+	if len(lexer.modeStack) != 1 || lexer.CurrentModeName() != "b" {
+		t.Fatalf("the b mode must replace the default mode: %v", lexer.modeStack)
+	}
+}
+
+func TestLexer_Next_ErrorKind(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+			newLexEntry([]string{"default"}, "quote_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[a-z]+`, "", false),
+			newLexEntry([]string{"string"}, "quote_close", `"`, "", true),
+			newLexEntry([]string{"comment"}, "comment", `#`, "", false),
+		},
+		ErrorKinds: map[spec.LexModeName]spec.LexKindName{
+			"default": "error",
+			"string":  "string_error",
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Error kinds get kind IDs following the kinds of the entries.
+	errorKindID := KindID(6)
+	stringErrorKindID := KindID(7)
+	if clspec.KindNames[errorKindID] != "error" || clspec.KindNames[stringErrorKindID] != "string_error" {
+		t.Fatalf("unexpected kind names: %v", clspec.KindNames)
+	}
+
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(`ab#$"cd%"`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	errTok := withPos(newInvalidTokenDefault([]byte("#$")), 0, 2)
+	errTok.KindID = errorKindID
+	errTok.ModeKindID = 3
+	stringErrTok := withPos(newToken(2, stringErrorKindID, 3, []byte("%")), 0, 7)
+	stringErrTok.Invalid = true
+	for _, expected := range []*Token{
+		withPos(newTokenDefault(1, 1, []byte("ab")), 0, 0),
+		errTok,
+		withPos(newTokenDefault(2, 2, []byte(`"`)), 0, 4),
+		withPos(newToken(2, 3, 1, []byte("cd")), 0, 5),
+		stringErrTok,
+		withPos(newToken(2, 4, 2, []byte(`"`)), 0, 8),
+		withPos(newEOFTokenDefault(), 0, 9),
+	} {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testToken(t, expected, tok, true)
+	}
+
+	// In a mode without an error kind, error tokens have no kind.
+	lexer, err = NewLexer(NewLexSpec(clspec), strings.NewReader(`#a`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = lexer.SetModeByName("comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lexer.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := lexer.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tok.Invalid || tok.KindID != 0 || tok.ModeKindID != 0 {
+		t.Fatalf("unexpected token: %v", tok)
+	}
+}
+
+func TestLexer_Next_EOFKind(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+			newLexEntry([]string{"default"}, "quote_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[a-z]+`, "", false),
+			newLexEntry([]string{"string"}, "quote_close", `"`, "", true),
+		},
+		ErrorKinds: map[spec.LexModeName]spec.LexKindName{
+			"default": "error",
+		},
+		EOFKind: "eof",
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The EOF kind gets the kind ID following the error kinds.
+	eofKindID := KindID(6)
+	if clspec.KindNames[eofKindID] != "eof" || len(clspec.KindNames) != 7 {
+		t.Fatalf("unexpected kind names: %v", clspec.KindNames)
+	}
+
+	tests := []struct {
+		src        string
+		mode       ModeID
+		modeKindID ModeKindID
+		col        int
+	}{
+		// In the default mode, the EOF kind follows the error kind.
+		{
+			src:        `ab`,
+			mode:       1,
+			modeKindID: 4,
+			col:        2,
+		},
+		// The EOF kind belongs to all modes.
+		{
+			src:        `"ab`,
+			mode:       2,
+			modeKindID: 3,
+			col:        3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var tok *Token
+			for {
+				tok, err = lexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tok.EOF {
+					break
+				}
+				if tok.IsKind("eof") {
+					t.Fatalf("only the EOF token can have the EOF kind: %v", tok)
+				}
+			}
+			expected := &Token{
+				ModeID:     tt.mode,
+				KindID:     eofKindID,
+				ModeKindID: tt.modeKindID,
+				Row:        0,
+				Col:        tt.col,
+				EOF:        true,
+			}
+			testToken(t, expected, tok, true)
+			if !tok.IsKind("eof") {
+				t.Fatalf("the EOF token must have the EOF kind: %v", tok)
+			}
+		})
+	}
+}
+
+func TestLexer_Clone(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+			newLexEntry([]string{"default"}, "quote_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[^"]+`, "", false),
+			newLexEntry([]string{"string"}, "quote_close", `"`, "", true),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(`ab"cd"#ef`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tok, err := lexer.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testToken(t, withPos(newTokenDefault(1, 1, []byte("ab")), 0, 0), tok, true)
+	// The original has read the opening quote in advance and moved to the string mode.
+	_, err = lexer.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := lexer.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTokens := []*Token{
+		withPos(newTokenDefault(2, 2, []byte(`"`)), 0, 2),
+		withPos(newToken(2, 3, 1, []byte("cd")), 0, 3),
+		withPos(newToken(2, 4, 2, []byte(`"`)), 0, 5),
+		withPos(newInvalidTokenDefault([]byte("#")), 0, 6),
+		withPos(newTokenDefault(1, 1, []byte("ef")), 0, 7),
+		withPos(newEOFTokenDefault(), 0, 9),
+	}
+	for _, expected := range expectedTokens {
+		tok, err := clone.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testToken(t, expected, tok, true)
+	}
+	if clone.Mode() != ModeID(spec.LexModeIDDefault) {
+		t.Fatalf("unexpected mode of the clone: %v", clone.Mode())
+	}
+
+	// Advancing the clone doesn't affect the original.
+	if lexer.Mode() != 2 {
+		t.Fatalf("unexpected mode of the original: %v", lexer.Mode())
+	}
+	for _, expected := range expectedTokens {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testToken(t, expected, tok, true)
+	}
+
+	// A lexer reading the source incrementally cannot be cloned.
+	lexer, err = NewLexer(NewLexSpec(clspec), strings.NewReader(`ab`), ReadIncrementally())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = lexer.Clone()
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
+
+func TestLexer_MarkAndRestore(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+			newLexEntry([]string{"default"}, "quote_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[^"]+`, "", false),
+			newLexEntry([]string{"string"}, "quote_close", `"`, "", true),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(`ab"cd"ef`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tok, err := lexer.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testToken(t, withPos(newTokenDefault(1, 1, []byte("ab")), 0, 0), tok, true)
+	// The lexer has read the opening quote in advance and moved to the string mode, but the position is right after
+	// `ab` in the default mode.
+	_, err = lexer.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := lexer.Mark()
+
+	expectedTokens := []*Token{
+		withPos(newTokenDefault(2, 2, []byte(`"`)), 0, 2),
+		withPos(newToken(2, 3, 1, []byte("cd")), 0, 3),
+		withPos(newToken(2, 4, 2, []byte(`"`)), 0, 5),
+		withPos(newTokenDefault(1, 1, []byte("ef")), 0, 6),
+		withPos(newEOFTokenDefault(), 0, 8),
+	}
+	var ahead Position
+	for i := 0; i < 2; i++ {
+		for j, expected := range expectedTokens {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testToken(t, expected, tok, true)
+			// Mark a position in the string mode.
+			if j == 1 {
+				ahead = lexer.Mark()
+			}
+		}
+		err := lexer.Restore(pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The lexer cannot restore a position ahead of the current one.
+	err = lexer.Restore(ahead)
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+
+	// The lexer returns to the mode it was in at the position.
+	for _, expected := range expectedTokens[:2] {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testToken(t, expected, tok, true)
+	}
+	_, err = lexer.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lexer.Restore(ahead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lexer.Mode() != 2 {
+		t.Fatalf("unexpected mode: %v", lexer.Mode())
+	}
+	for _, expected := range expectedTokens[2:] {
+		tok, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testToken(t, expected, tok, true)
+	}
+
+	// A position belongs to the lexer that returned it.
+	other, err := NewLexer(NewLexSpec(clspec), strings.NewReader(`ab"cd"ef`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = other.Restore(pos)
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+	err = other.Restore(Position{})
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
+
+func TestLexer_MarkAndRestore_ReadIncrementally(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", ` +`),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := strings.Repeat("foo ", 2*srcChunkSize)
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src), ReadIncrementally())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pos := lexer.Mark()
+	for i := 0; i < 4; i++ {
+		_, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The lexer still holds the bytes following the position.
+	err = lexer.Restore(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := lexer.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testToken(t, withPos(newTokenDefault(1, 1, []byte("foo")), 0, 0), tok, true)
+
+	// The lexer discards the bytes following the position after it reads some chunks.
+	for i := 0; i < 2*srcChunkSize; i++ {
+		_, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = lexer.Restore(pos)
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
+
+func TestLexer_Next_DontMergeInvalid(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", ` +`),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := "a#$ b%c&*!"
+	tests := []struct {
+		caption  string
+		opts     []LexerOption
+		expected []*Token
+	}{
+		{
+			caption: "the lexer merges consecutive invalid tokens by default",
+			expected: []*Token{
+				withPos(newTokenDefault(1, 1, []byte("a")), 0, 0),
+				withPos(newInvalidTokenDefault([]byte("#$")), 0, 1),
+				withPos(newTokenDefault(2, 2, []byte(" ")), 0, 3),
+				withPos(newTokenDefault(1, 1, []byte("b")), 0, 4),
+				withPos(newInvalidTokenDefault([]byte("%")), 0, 5),
+				withPos(newTokenDefault(1, 1, []byte("c")), 0, 6),
+				withPos(newInvalidTokenDefault([]byte("&*!")), 0, 7),
+				withPos(newEOFTokenDefault(), 0, 10),
+			},
+		},
+		{
+			caption: "the lexer returns each invalid token separately",
+			opts:    []LexerOption{DontMergeInvalid()},
+			expected: []*Token{
+				withPos(newTokenDefault(1, 1, []byte("a")), 0, 0),
+				withPos(newInvalidTokenDefault([]byte("#")), 0, 1),
+				withPos(newInvalidTokenDefault([]byte("$")), 0, 2),
+				withPos(newTokenDefault(2, 2, []byte(" ")), 0, 3),
+				withPos(newTokenDefault(1, 1, []byte("b")), 0, 4),
+				withPos(newInvalidTokenDefault([]byte("%")), 0, 5),
+				withPos(newTokenDefault(1, 1, []byte("c")), 0, 6),
+				withPos(newInvalidTokenDefault([]byte("&")), 0, 7),
+				withPos(newInvalidTokenDefault([]byte("*")), 0, 8),
+				withPos(newInvalidTokenDefault([]byte("!")), 0, 9),
+				withPos(newEOFTokenDefault(), 0, 10),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, expected := range tt.expected {
+				tok, err := lexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				testToken(t, expected, tok, true)
+			}
+		})
+	}
+}
+
+func TestLexer_Next_InitialMode(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "word", `[^\u{000A}]+`, "", false),
+			newLexEntry([]string{"header"}, "field", `[a-z]+`, "", false),
+			newLexEntry([]string{"header"}, "colon", `:`, "", false),
+			newLexEntry([]string{"header"}, "newline", `\u{000A}`, "", false),
+			newLexEntry([]string{"header"}, "blank_line", `\u{000A}\u{000A}`, "body", false),
+			newLexEntry([]string{"body"}, "body_text", `.+`, "", false),
+		},
+		InitialMode: "header",
+	}
+	for compLv := compiler.CompressionLevelMin; compLv <= compiler.CompressionLevelMax; compLv++ {
+		t.Run(fmt.Sprintf("compression level %v", compLv), func(t *testing.T) {
+			clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compLv))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader("foo:bar\nbaz:qux\n\nhello:world"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name := lexer.spec.ModeName(lexer.Mode()); name != "header" {
+				t.Fatalf("the lexer must start in the initial mode; got: %v", name)
+			}
+			expected := []struct {
+				mode   string
+				kind   string
+				lexeme string
+			}{
+				{mode: "header", kind: "field", lexeme: "foo"},
+				{mode: "header", kind: "colon", lexeme: ":"},
+				{mode: "header", kind: "field", lexeme: "bar"},
+				{mode: "header", kind: "newline", lexeme: "\n"},
+				{mode: "header", kind: "field", lexeme: "baz"},
+				{mode: "header", kind: "colon", lexeme: ":"},
+				{mode: "header", kind: "field", lexeme: "qux"},
+				{mode: "header", kind: "blank_line", lexeme: "\n\n"},
+				{mode: "body", kind: "body_text", lexeme: "hello:world"},
+			}
+			for _, e := range expected {
+				tok, err := lexer.Next()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				_, kind := lexer.spec.KindIDAndName(tok.ModeID, tok.ModeKindID)
+				if lexer.spec.ModeName(tok.ModeID) != e.mode || kind != e.kind || string(tok.Lexeme) != e.lexeme {
+					t.Fatalf("unexpected token; want: %+v, got: %v", e, tok)
+				}
+			}
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tok.EOF {
+				t.Fatalf("expected EOF token; got: %v", tok)
+			}
+		})
+	}
+}
+
+func TestLexer_Next_PartialKind(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+			newLexEntryDefaultNOP("string", `"[^"\u{000A}]*"`),
+			newLexEntryDefaultNOP("arrow", `->`),
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		caption string
+		src     string
+		partial []KindID
+	}{
+		{
+			caption: "the lexer reports a string literal lacking the closing quote",
+			src:     "\"foo",
+			partial: []KindID{2},
+		},
+		{
+			caption: "the lexer reports a string literal interrupted by a line break",
+			src:     "\"foo\nbar",
+			partial: []KindID{2, 0},
+		},
+		{
+			caption: "the lexer reports nothing when it fails at the first byte",
+			src:     "#foo",
+			partial: []KindID{0, 0},
+		},
+		{
+			caption: "the lexer keeps the first hint of merged error tokens",
+			src:     "-#",
+			partial: []KindID{3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var partial []KindID
+			for {
+				tok, err := lexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tok.EOF {
+					break
+				}
+				if !tok.Invalid && tok.PartialKindID != 0 {
+					t.Fatalf("a valid token must not have a partial kind: %v", tok)
+				}
+				partial = append(partial, tok.PartialKindID)
+			}
+			if !reflect.DeepEqual(partial, tt.partial) {
+				t.Fatalf("unexpected partial kinds; want: %v, got: %v", tt.partial, partial)
+			}
+		})
+	}
+}
+
+func TestLexer_Rest(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("foobar", `foobar`),
+			{
+				Kind:    "white_space",
+				Pattern: ` +`,
+				Skip:    true,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		caption string
+		src     string
+		opts    []LexerOption
+		read    func(l *Lexer) error
+		rest    string
+	}{
+		{
+			caption: "Rest returns the whole source before reading tokens",
+			src:     "foo bar",
+			read: func(l *Lexer) error {
+				return nil
+			},
+			rest: "foo bar",
+		},
+		{
+			caption: "Rest returns the source following the last token",
+			src:     "foo bar baz",
+			read: func(l *Lexer) error {
+				_, err := l.Next()
+				if err != nil {
+					return err
+				}
+				_, err = l.Next()
+				return err
+			},
+			rest: " baz",
+		},
+		{
+			caption: "Rest accounts for the bytes the lexer gave back",
+			src:     "foo1",
+			read: func(l *Lexer) error {
+				_, err := l.Next()
+				return err
+			},
+			rest: "1",
+		},
+		{
+			caption: "Rest includes peeked tokens",
+			src:     "foo bar baz",
+			read: func(l *Lexer) error {
+				_, err := l.Next()
+				if err != nil {
+					return err
+				}
+				_, err = l.PeekN(2)
+				return err
+			},
+			rest: " bar baz",
+		},
+		{
+			caption: "Rest includes a token the lexer read to merge invalid tokens",
+			src:     "123foo",
+			read: func(l *Lexer) error {
+				_, err := l.Next()
+				return err
+			},
+			rest: "foo",
+		},
+		{
+			caption: "Rest returns an empty slice at the end of the source",
+			src:     "foo",
+			read: func(l *Lexer) error {
+				_, err := l.Next()
+				if err != nil {
+					return err
+				}
+				_, err = l.Next()
+				return err
+			},
+			rest: "",
+		},
+		{
+			caption: "Rest excludes a stripped BOM",
+			src:     "\xEF\xBB\xBFfoo",
+			opts:    []LexerOption{StripBOM()},
+			read: func(l *Lexer) error {
+				return nil
+			},
+			rest: "foo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(tt.src), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = tt.read(lexer)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			rest := lexer.Rest()
+			if string(rest) != tt.rest {
+				t.Fatalf("unexpected rest; want: %q, got: %q", tt.rest, rest)
+			}
+		})
+	}
+
+	t.Run("Rest returns bytes the lexer has read incrementally", func(t *testing.T) {
+		src := strings.Repeat("foo ", 2*srcChunkSize)
+		r := strings.NewReader(src)
+		lexer, err := NewLexer(NewLexSpec(clspec), r, ReadIncrementally())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i := 0; i < srcChunkSize/2; i++ {
+			_, err := lexer.Next()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		_, err = lexer.PeekN(srcChunkSize / 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		remaining, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rest := string(lexer.Rest()) + string(remaining)
+		if want := src[4*(srcChunkSize/2)-1:]; rest != want {
+			t.Fatalf("unexpected rest; want: %v bytes, got: %v bytes", len(want), len(rest))
+		}
+	})
+}
+
+func TestLexer_Next_ReadIncrementally(t *testing.T) {
+	newSkipEntry := func(kind string, pattern string) *spec.LexEntry {
+		e := newLexEntryDefaultNOP(kind, pattern)
+		e.Skip = true
+		return e
+	}
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("heading", `^#+`),
+			newLexEntryDefaultNOP("semicolon", `;$`),
+			newLexEntryDefaultNOP("baz", `\bbaz\b`),
+			newLexEntryDefaultNOP("inner_b", `\Bb`),
+			newLexEntryDefaultNOP("digits", `[0-9]+`),
+			newLexEntryDefaultNOP("word", `[a-z\u{3042}]+`),
+			newLexEntryDefaultNOP("newline", `\u{000A}`),
+			newLexEntryDefaultNOP("abcde", `abcde`),
+			newLexEntryDefaultNOP("semicolon_and_space", `; `),
+			newSkipEntry("white_space", ` +`),
+		},
+	}
+
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var b strings.Builder
+	for i := 0; b.Len() < 3*srcChunkSize; i++ {
+		fmt.Fprintf(&b, "# foo;\n## bar; baz\n;\x80\xFF\nabcdef abcd %v\nbazbaz;baz _baz 1baz\n", strings.Repeat("\u3042", i%7))
+	}
+	// A token longer than a chunk makes the lexer grow the window.
+	fmt.Fprintf(&b, "%v ##", strings.Repeat("a", 2*srcChunkSize))
+	src := b.String()
+
+	lexAll := func(t *testing.T, src io.Reader, opts ...LexerOption) []*Token {
+		t.Helper()
+		lexer, err := NewLexer(NewLexSpec(clspec), src, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var toks []*Token
+		for {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			toks = append(toks, tok)
+			if tok.EOF {
+				return toks
+			}
+		}
+	}
+
+	expected := lexAll(t, strings.NewReader(src), RecordInvalidSpans())
+
+	tests := []struct {
+		caption string
+		src     io.Reader
+	}{
+		{
+			caption: "the lexer reads the source in chunks",
+			src:     strings.NewReader(src),
+		},
+		{
+			caption: "the lexer reads the source byte by byte",
+			src:     iotest.OneByteReader(strings.NewReader(src)),
+		},
+		{
+			caption: "the lexer reads the source returning EOF with data",
+			src:     iotest.DataErrReader(iotest.HalfReader(strings.NewReader(src))),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			toks := lexAll(t, tt.src, ReadIncrementally(), RecordInvalidSpans())
+			if len(toks) != len(expected) {
+				t.Fatalf("unexpected token count: want: %v, got: %v", len(expected), len(toks))
+			}
+			for i, tok := range toks {
+				testToken(t, expected[i], tok, true)
+				if len(tok.InvalidSpans) != len(expected[i].InvalidSpans) {
+					t.Fatalf("unexpected spans: want: %v, got: %v", expected[i].InvalidSpans, tok.InvalidSpans)
+				}
+				for j, span := range tok.InvalidSpans {
+					if *span != *expected[i].InvalidSpans[j] {
+						t.Fatalf("unexpected span: want: %+v, got: %+v", expected[i].InvalidSpans[j], span)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("the lexer returns an error when reading the source fails", func(t *testing.T) {
+		lexer, err := NewLexer(NewLexSpec(clspec), iotest.TimeoutReader(strings.NewReader(src)), ReadIncrementally())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for {
+			tok, err := lexer.Next()
+			if err != nil {
+				if err != iotest.ErrTimeout {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				break
+			}
+			if tok.EOF {
+				t.Fatalf("expected error didn't occur")
+			}
+		}
+	})
+}
+
+func TestToken_StringAndMarshalJSON(t *testing.T) {
+	tests := []struct {
+		tok  *Token
+		str  string
+		json string
+	}{
+		{
+			tok:  withPos(newTokenDefault(2, 3, []byte("foo")), 1, 4),
+			str:  `{mode: 1, kind: 2, mode_kind: 3, pos: 1:4, lexeme: "foo"}`,
+			json: `{"mode_id":1,"kind_id":2,"mode_kind_id":3,"row":1,"col":4,"lexeme":"foo","eof":false,"invalid":false}`,
+		},
+		{
+			tok:  withPos(newInvalidTokenDefault([]byte("\"@")), 0, 2),
+			str:  `{mode: 1, kind: 0, mode_kind: 0, pos: 0:2, lexeme: "\"@", invalid}`,
+			json: `{"mode_id":1,"kind_id":0,"mode_kind_id":0,"row":0,"col":2,"lexeme":"\"@","eof":false,"invalid":true}`,
+		},
+		{
+			tok:  newEOFTokenDefault(),
+			str:  `{mode: 1, kind: 0, mode_kind: 0, pos: 0:0, lexeme: "", eof}`,
+			json: `{"mode_id":1,"kind_id":0,"mode_kind_id":0,"row":0,"col":0,"lexeme":"","eof":true,"invalid":false}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.str, func(t *testing.T) {
+			if tt.tok.String() != tt.str {
+				t.Fatalf("unexpected string; want: %v, got: %v", tt.str, tt.tok.String())
+			}
+			data, err := json.Marshal(tt.tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.json {
+				t.Fatalf("unexpected JSON; want: %v, got: %v", tt.json, string(data))
+			}
+		})
+	}
+}
+
+func TestLexer_PushModeByName(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "a", `a`, "", false),
+			newLexEntry([]string{"mode_1"}, "b", `b`, "", false),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lexer.CurrentModeName() != "default" {
+		t.Fatalf("unexpected mode: want: default, got: %v", lexer.CurrentModeName())
+	}
+	err = lexer.PushModeByName("mode_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lexer.CurrentModeName() != "mode_1" {
+		t.Fatalf("unexpected mode: want: mode_1, got: %v", lexer.CurrentModeName())
+	}
+	err = lexer.PushModeByName("mode_2")
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+	if lexer.CurrentModeName() != "mode_1" {
+		t.Fatalf("the mode stack must not be changed when an error occurs: got: %v", lexer.CurrentModeName())
+	}
+}
+
+func TestLexer_SetMode(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "a", `a`, "", false),
+			newLexEntry([]string{"mode_1"}, "b", `b`, "", false),
+			newLexEntry([]string{"mode_2"}, "c", `c`, "", false),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lexer.PushModeByName("mode_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lexer.SetModeByName("mode_2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lexer.CurrentModeName() != "mode_2" {
+		t.Fatalf("unexpected mode: want: mode_2, got: %v", lexer.CurrentModeName())
+	}
+	err = lexer.SetModeByName("mode_3")
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+	if lexer.CurrentModeName() != "mode_2" {
+		t.Fatalf("the mode stack must not be changed when an error occurs: got: %v", lexer.CurrentModeName())
+	}
+
+	// SetMode doesn't change the depth of the mode stack.
+	err = lexer.PopMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lexer.CurrentModeName() != "default" {
+		t.Fatalf("unexpected mode: want: default, got: %v", lexer.CurrentModeName())
+	}
+
+	err = lexer.PopMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lexer.SetMode(ModeID(spec.LexModeIDDefault.Int()))
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
+
+func TestLexer_Peek(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "string_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[a-z ]+`, "", false),
+			newLexEntry([]string{"string"}, "string_close", `"`, "", true),
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", ` +`),
+		},
+	}
+	// This source contains mode transitions and consecutive invalid tokens.
+	src := `foo "bar baz" 123 "@@x" qux`
+
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected []*Token
+	{
+		lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected = append(expected, tok)
+			if tok.EOF {
+				break
+			}
+		}
+	}
+
+	for n := 1; n <= len(expected)+1; n++ {
+		t.Run(fmt.Sprintf("peek %v tokens", n), func(t *testing.T) {
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < len(expected); i++ {
+				peeked, err := lexer.PeekN(n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				eToks := expected[i:]
+				if len(eToks) > n {
+					eToks = eToks[:n]
+				}
+				if len(peeked) != len(eToks) {
+					t.Fatalf("unexpected token count: want: %v, got: %v", len(eToks), len(peeked))
+				}
+				for j, tok := range peeked {
+					testToken(t, eToks[j], tok, true)
+				}
+
+				tok, err := lexer.Peek()
+				if err != nil {
+					t.Fatal(err)
+				}
+				testToken(t, expected[i], tok, true)
+
+				tok, err = lexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				testToken(t, expected[i], tok, true)
+			}
+		})
+	}
+}
+
+func TestLexer_Peek_PassiveModeTransition(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader("foo"), DisableModeTransition())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lexer.Peek()
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
+
+func TestLexer_Next_Skip(t *testing.T) {
+	newSkipEntry := func(modes []string, kind string, pattern string, push string, pop bool) *spec.LexEntry {
+		e := newLexEntry(modes, kind, pattern, push, pop)
+		e.Skip = true
+		return e
+	}
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "string_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[a-z ]+`, "", false),
+			newLexEntry([]string{"string"}, "string_close", `"`, "", true),
+			newSkipEntry([]string{"default"}, "comment_open", `/\*`, "comment", false),
+			newSkipEntry([]string{"comment"}, "comment_body", `[^*]+|\*`, "", false),
+			newSkipEntry([]string{"comment"}, "comment_close", `\*/`, "", true),
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newSkipEntry([]string{"default"}, "white_space", ` +`, "", false),
+		},
+	}
+	// The invalid tokens `@` separated by a skipped white space must not be merged.
+	src := `foo /* "x" */ "a b" @ @bar`
+
+	tests := []struct {
+		caption string
+		opts    []LexerOption
+		tokens  []string
+	}{
+		{
+			caption: "the lexer skips tokens of skip kinds but performs their mode transitions",
+			tokens: []string{
+				`word:foo`,
+				`string_open:"`,
+				`char_seq:a b`,
+				`string_close:"`,
+				`:@`,
+				`:@`,
+				`word:bar`,
+			},
+		},
+		{
+			caption: "the lexer returns all tokens when skipping is disabled",
+			opts: []LexerOption{
+				DisableSkip(),
+			},
+			tokens: []string{
+				`word:foo`,
+				`white_space: `,
+				`comment_open:/*`,
+				`comment_body: "x" `,
+				`comment_close:*/`,
+				`white_space: `,
+				`string_open:"`,
+				`char_seq:a b`,
+				`string_close:"`,
+				`white_space: `,
+				`:@`,
+				`white_space: `,
+				`:@`,
+				`word:bar`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			clspec, err, _ := compiler.Compile(lspec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lexer, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			peeked, err := lexer.PeekN(len(tt.tokens) + 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(peeked) != len(tt.tokens)+1 {
+				t.Fatalf("unexpected token count: want: %v, got: %v", len(tt.tokens)+1, len(peeked))
+			}
+			for i, eTok := range tt.tokens {
+				tok, err := lexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				testToken(t, peeked[i], tok, true)
+				tokStr := fmt.Sprintf("%v:%v", clspec.KindNames[tok.KindID], string(tok.Lexeme))
+				if tokStr != eTok {
+					t.Fatalf("unexpected token: want: %v, got: %v", eTok, tokStr)
+				}
+			}
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tok.EOF {
+				t.Fatalf("expected EOF, got: %v", tok)
+			}
+		})
+	}
+}
+
+func TestLexer_KindMeta(t *testing.T) {
+	lspec := &spec.LexSpec{}
+	err := json.Unmarshal([]byte(`
+{
+    "name": "test",
+    "entries": [
+        {
+            "kind": "plus",
+            "pattern": "\\+",
+            "meta": {
+                "category": "operator",
+                "precedence": "1"
+            }
+        },
+        {
+            "kind": "if",
+            "pattern": "if",
+            "meta": {
+                "category": "keyword"
+            }
+        },
+        {
+            "kind": "identifier",
+            "pattern": "[a-z]+"
+        }
+    ]
+}
+`), lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonSpec *spec.CompiledLexSpec
+	{
+		data, err := json.Marshal(clspec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsonSpec = &spec.CompiledLexSpec{}
+		err = json.Unmarshal(data, jsonSpec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var gobSpec *spec.CompiledLexSpec
+	{
+		var b bytes.Buffer
+		err := spec.EncodeCompiledLexSpecGob(&b, clspec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gobSpec, err = spec.DecodeCompiledLexSpecGob(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]map[string]string{
+		"plus": {
+			"category":   "operator",
+			"precedence": "1",
+		},
+		"if": {
+			"category": "keyword",
+		},
+		"identifier": nil,
+	}
+	for _, s := range []*spec.CompiledLexSpec{clspec, jsonSpec, gobSpec} {
+		lexer, err := NewLexer(NewLexSpec(s), strings.NewReader("if+x"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			tok, err := lexer.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tok.EOF {
+				break
+			}
+			kindName := s.KindNames[tok.KindID].String()
+			meta := lexer.KindMeta(tok.KindID)
+			if len(meta) != len(expected[kindName]) {
+				t.Fatalf("unexpected metadata of %v: want: %v, got: %v", kindName, expected[kindName], meta)
+			}
+			for k, v := range expected[kindName] {
+				if meta[k] != v {
+					t.Fatalf("unexpected metadata of %v: want: %v, got: %v", kindName, expected[kindName], meta)
+				}
+			}
+		}
+	}
+}
+
+func TestLexer_Next_GobEncodedSpec(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "string_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[^"]+`, "", false),
+			newLexEntry([]string{"string"}, "string_close", `"`, "", true),
+			newLexEntryDefaultNOP("heading", `^#+`),
+			newLexEntryDefaultNOP("hash", `#+`),
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+		},
+	}
+	src := "# foo \"bar # baz\" #\n## \"\"x"
+
+	for compLv := compiler.CompressionLevelMin; compLv <= compiler.CompressionLevelMax; compLv++ {
+		t.Run(fmt.Sprintf("compression level %v", compLv), func(t *testing.T) {
+			clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compLv))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jsonSpec := &spec.CompiledLexSpec{}
+			{
+				data, err := json.Marshal(clspec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = json.Unmarshal(data, jsonSpec)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			var gobSpec *spec.CompiledLexSpec
+			{
+				var b bytes.Buffer
+				err := spec.EncodeCompiledLexSpecGob(&b, clspec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gobSpec, err = spec.DecodeCompiledLexSpecGob(&b)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			jsonLexer, err := NewLexer(NewLexSpec(jsonSpec), strings.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gobLexer, err := NewLexer(NewLexSpec(gobSpec), strings.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for {
+				eTok, err := jsonLexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tok, err := gobLexer.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				testToken(t, eTok, tok, true)
+				if tok.EOF {
+					break
+				}
+			}
+		})
+	}
+}
+
+func testToken(t *testing.T, expected, actual *Token, checkPosition bool) {
+	t.Helper()
+
+	if actual.ModeID != expected.ModeID ||
+		actual.KindID != expected.KindID ||
+		actual.ModeKindID != expected.ModeKindID ||
+		!bytes.Equal(actual.Lexeme, expected.Lexeme) ||
+		actual.EOF != expected.EOF ||
+		actual.Invalid != expected.Invalid {
+		t.Fatalf(`unexpected token; want: %v ("%#v"), got: %v ("%#v")`, expected, string(expected.Lexeme), actual, string(actual.Lexeme))
+	}
+
+	if checkPosition {
+		if actual.Row != expected.Row || actual.Col != expected.Col {
+			t.Fatalf(`unexpected token; want: %v ("%#v"), got: %v ("%#v")`, expected, string(expected.Lexeme), actual, string(actual.Lexeme))
+		}
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	src := "foo bar\nbaz"
+
+	partial := strings.NewReader(src)
+	partial.Seek(4, io.SeekStart)
+
+	path := filepath.Join(t.TempDir(), "src")
+	err := os.WriteFile(path, []byte(src), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Seek(4, io.SeekStart)
+
+	tests := []struct {
+		caption  string
+		src      io.Reader
+		expected string
+		err      bool
+	}{
+		{
+			caption:  "strings.Reader",
+			src:      strings.NewReader(src),
+			expected: src,
+		},
+		{
+			caption:  "bytes.Reader",
+			src:      bytes.NewReader([]byte(src)),
+			expected: src,
+		},
+		{
+			caption:  "bytes.Buffer",
+			src:      bytes.NewBufferString(src),
+			expected: src,
+		},
+		{
+			caption:  "partially read strings.Reader",
+			src:      partial,
+			expected: src[4:],
+		},
+		{
+			caption:  "file whose offset isn't at the beginning",
+			src:      f,
+			expected: src[4:],
+		},
+		{
+			caption:  "empty source",
+			src:      strings.NewReader(""),
+			expected: "",
+		},
+		{
+			caption:  "reader without size",
+			src:      iotest.OneByteReader(strings.NewReader(src)),
+			expected: src,
+		},
+		{
+			caption: "reader returning an error",
+			src:     iotest.ErrReader(fmt.Errorf("read error")),
+			err:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			b, err := readAll(tt.src)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error didn't occur")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+			if string(b) != tt.expected {
+				t.Fatalf("unexpected source; want: %q, got: %q", tt.expected, string(b))
+			}
+		})
+	}
+}
+
+func BenchmarkNewLexer(b *testing.B) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		b.Fatal(err)
+	}
+	lspecDriver := NewLexSpec(clspec)
+	src := strings.Repeat("foo bar\n", 128*1024)
+
+	b.Run("strings.Reader", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := NewLexer(lspecDriver, strings.NewReader(src))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("NewLexerFromBytes", func(b *testing.B) {
+		srcBytes := []byte(src)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := NewLexerFromBytes(lspecDriver, srcBytes)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("file", func(b *testing.B) {
+		path := filepath.Join(b.TempDir(), "src")
+		err := os.WriteFile(path, []byte(src), 0644)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			f, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, err = NewLexer(lspecDriver, f)
+			f.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
 }
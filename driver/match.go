@@ -0,0 +1,45 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/nihei9/maleeni/spec"
+)
+
+// MatchFull reports whether the whole input is a single token of a specified kind. This is useful when you use a
+// lexical specification as a validator of strings such as identifiers. MatchFull counts a token of a kind whose `skip`
+// is true as well as other tokens, so you can also check such kinds. When the specification doesn't define the kind,
+// MatchFull returns an error.
+func MatchFull(clspec *spec.CompiledLexSpec, kindName string, input []byte) (bool, error) {
+	var kindID KindID
+	for id, name := range clspec.KindNames {
+		if id == spec.LexKindIDNil.Int() {
+			continue
+		}
+		if name.String() == kindName {
+			kindID = KindID(id)
+			break
+		}
+	}
+	if kindID == KindID(spec.LexKindIDNil.Int()) {
+		return false, fmt.Errorf("kind `%v` is undefined", kindName)
+	}
+
+	lex, err := NewLexer(NewLexSpec(clspec), bytes.NewReader(input), DisableSkip())
+	if err != nil {
+		return false, err
+	}
+	tok, err := lex.Next()
+	if err != nil {
+		return false, err
+	}
+	if tok.EOF || tok.Invalid || tok.KindID != kindID {
+		return false, nil
+	}
+	tok, err = lex.Next()
+	if err != nil {
+		return false, err
+	}
+	return tok.EOF, nil
+}
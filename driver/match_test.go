@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/nihei9/maleeni/compiler"
+	"github.com/nihei9/maleeni/spec"
+)
+
+func TestMatchFull(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("keyword", `if`),
+			newLexEntryDefaultNOP("identifier", `[a-z_][0-9a-z_]*`),
+			newLexEntryDefaultNOP("integer", `[0-9]+`),
+			{
+				Kind:    "white_space",
+				Pattern: `[ \u{0009}]+`,
+				Skip:    true,
+			},
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		kind    string
+		input   string
+		matched bool
+	}{
+		{kind: "identifier", input: "foo_1", matched: true},
+		{kind: "integer", input: "123", matched: true},
+		{kind: "white_space", input: " \t ", matched: true},
+		{kind: "keyword", input: "if", matched: true},
+		// `if` is a keyword rather than an identifier because the keyword has the higher priority.
+		{kind: "identifier", input: "if", matched: false},
+		{kind: "identifier", input: "", matched: false},
+		{kind: "identifier", input: "1foo", matched: false},
+		{kind: "identifier", input: "foo bar", matched: false},
+		{kind: "identifier", input: "foo ", matched: false},
+		{kind: "identifier", input: "foo!", matched: false},
+		{kind: "integer", input: "foo", matched: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind+"/"+tt.input, func(t *testing.T) {
+			matched, err := MatchFull(clspec, tt.kind, []byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched != tt.matched {
+				t.Fatalf("unexpected result; want: %v, got: %v", tt.matched, matched)
+			}
+		})
+	}
+
+	_, err = MatchFull(clspec, "undefined_kind", []byte("foo"))
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
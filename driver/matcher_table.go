@@ -0,0 +1,179 @@
+package driver
+
+import (
+	"bytes"
+	"go/format"
+	"text/template"
+
+	"github.com/nihei9/maleeni/spec"
+)
+
+const matcherTableTemplate = `{{ .prologue }}// Code generated by maleeni-go. DO NOT EDIT.
+package {{ .pkgName }}
+
+type ModeID int
+
+func (id ModeID) Int() int {
+	return int(id)
+}
+
+type StateID int
+
+func (id StateID) Int() int {
+	return int(id)
+}
+
+type KindID int
+
+func (id KindID) Int() int {
+	return int(id)
+}
+
+type ModeKindID int
+
+func (id ModeKindID) Int() int {
+	return int(id)
+}
+
+{{ .modeIDsSrc }}
+
+{{ .modeNamesSrc }}
+
+{{ .modeIDToNameSrc }}
+
+{{ .modeNameToIDSrc }}
+
+{{ .kindIDsSrc }}
+
+{{ .kindNamesSrc }}
+
+{{ .kindIDToNameSrc }}
+
+{{ .kindNameToIDSrc }}
+
+// State is a state of the DFA of a mode. Each mode has its own DFA, so a state remembers the mode it belongs to.
+type State struct {
+	Mode ModeID
+	ID   StateID
+}
+
+// InitialState returns the state that the DFA of a mode starts in.
+func InitialState(mode ModeID) State {
+	return State{
+		Mode: mode,
+		ID:   table.initialStates[mode],
+	}
+}
+
+// Step returns the state following a state on a byte. When the DFA has no transition, the second return value is false.
+func Step(state State, b byte) (State, bool) {
+	mode := state.Mode
+	v := int(b)
+{{ if eq .compressionLevel 2 -}}
+	rowNum := table.rowNums[mode][state.ID]
+	d := table.rowDisplacements[mode][rowNum]
+	if table.bounds[mode][d+v] != rowNum {
+		return State{}, false
+	}
+	next := table.entries[mode][d+v]
+{{ else if eq .compressionLevel 1 -}}
+	rowNum := table.rowNums[mode][state.ID]
+	next := table.entries[mode][rowNum*table.originalColCounts[mode]+v]
+{{ else -}}
+	next := table.entries[mode][state.ID.Int()*table.originalColCounts[mode]+v]
+{{ end -}}
+	if next == table.stateIDNil {
+		return State{}, false
+	}
+	return State{
+		Mode: mode,
+		ID:   next,
+	}, true
+}
+
+// Accept returns the kind of a token ending in a state. When the state isn't an accepting state, the second return
+// value is false. Accept doesn't check anchors such as ` + "`^`" + ` and ` + "`\\b`" + `.
+func Accept(state State) (KindID, bool) {
+	modeKind := table.acceptances[state.Mode][state.ID]
+	if modeKind == table.modeKindIDNil {
+		return KindIDNil, false
+	}
+	return table.kindIDs[state.Mode][modeKind], true
+}
+
+type matcherTable struct {
+	initialStates []StateID
+	acceptances   [][]ModeKindID
+	kindIDs       [][]KindID
+	modeKindIDNil ModeKindID
+	stateIDNil    StateID
+
+	rowNums           [][]int
+	rowDisplacements  [][]int
+	bounds            [][]int
+	entries           [][]StateID
+	originalColCounts []int
+}
+
+var table = &matcherTable{
+	initialStates: {{ genInitialStateTable }},
+	acceptances: {{ genAcceptTable }},
+	kindIDs: {{ genKindIDTable }},
+	modeKindIDNil: {{ .modeKindIDNil }},
+	stateIDNil: {{ .stateIDNil }},
+
+	rowNums: {{ genRowNums }},
+	rowDisplacements: {{ genRowDisplacements }},
+	bounds: {{ genBounds }},
+	entries: {{ genEntries }},
+	originalColCounts: {{ genOriginalColCounts }},
+}
+`
+
+// GenMatcherTable generates a source code containing only the transition tables of the DFAs and functions stepping
+// through them: InitialState, Step, and Accept. The code doesn't contain the Lexer and its mode stack, so you can
+// write your own lightweight runtime on top of it. The code declares the same types and constants as a lexer that
+// GenLexer generates, so put them in different packages. GenMatcherTable accepts the same options as GenLexer but
+// uses only GenBuildConstraint and GenHeader.
+func GenMatcherTable(clspec *spec.CompiledLexSpec, pkgName string, opts ...GenLexerOption) ([]byte, error) {
+	config := &genLexerConfig{}
+	for _, opt := range opts {
+		err := opt(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := validateGoIdentifiers(clspec)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New("").Funcs(genTemplateFuncs(clspec)).Parse(matcherTableTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	idSrcs := genIDSrcs(clspec)
+	var b bytes.Buffer
+	err = t.Execute(&b, map[string]interface{}{
+		"prologue":         config.prologue(),
+		"pkgName":          pkgName,
+		"modeIDsSrc":       idSrcs.modeIDs,
+		"modeNamesSrc":     idSrcs.modeNames,
+		"modeIDToNameSrc":  idSrcs.modeIDToName,
+		"modeNameToIDSrc":  idSrcs.modeNameToID,
+		"kindIDsSrc":       idSrcs.kindIDs,
+		"kindNamesSrc":     idSrcs.kindNames,
+		"kindIDToNameSrc":  idSrcs.kindIDToName,
+		"kindNameToIDSrc":  idSrcs.kindNameToID,
+		"modeKindIDNil":    spec.LexModeKindIDNil,
+		"stateIDNil":       spec.StateIDNil,
+		"compressionLevel": clspec.CompressionLevel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(b.Bytes())
+}
@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/nihei9/maleeni/compiler"
+	"github.com/nihei9/maleeni/spec"
+)
+
+// stepMatcherSrc steps through generated matcher tables for each line `<mode> <input>` of the standard input and
+// prints the kind that the last state accepts.
+const stepMatcherSrc = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), " ", 2)
+		mode, ok := ModeIDFromName(fields[0])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown mode: %v\n", fields[0])
+			os.Exit(1)
+		}
+		state := InitialState(mode)
+		for _, b := range []byte(fields[1]) {
+			state, ok = Step(state, b)
+			if !ok {
+				break
+			}
+		}
+		kind := "none"
+		if ok {
+			if id, ok := Accept(state); ok {
+				kind = KindIDToName(id)
+			}
+		}
+		fmt.Println(kind)
+	}
+}
+`
+
+func TestGenMatcherTable(t *testing.T) {
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is unavailable")
+	}
+
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+			newLexEntry([]string{"default"}, "integer", `0|[1-9][0-9]*`, "", false),
+			newLexEntry([]string{"default"}, "string_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[^"]+`, "", false),
+			newLexEntry([]string{"string"}, "string_close", `"`, "", true),
+		},
+	}
+	tests := []struct {
+		mode  string
+		input string
+		kind  string
+	}{
+		{mode: "default", input: "foo", kind: "word"},
+		{mode: "default", input: "123", kind: "integer"},
+		{mode: "default", input: `"`, kind: "string_open"},
+		{mode: "default", input: "01", kind: "none"},
+		{mode: "default", input: "foo bar", kind: "none"},
+		{mode: "default", input: "", kind: "none"},
+		{mode: "string", input: "foo bar", kind: "char_seq"},
+		{mode: "string", input: `"`, kind: "string_close"},
+		{mode: "string", input: `foo"`, kind: "none"},
+	}
+	var input strings.Builder
+	var expected strings.Builder
+	for _, tt := range tests {
+		fmt.Fprintf(&input, "%v %v\n", tt.mode, tt.input)
+		fmt.Fprintf(&expected, "%v\n", tt.kind)
+	}
+	for _, compLv := range []int{0, 1, 2} {
+		t.Run(fmt.Sprintf("compression level %v", compLv), func(t *testing.T) {
+			clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compLv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := GenMatcherTable(clspec, "main")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(src), "NewLexer") {
+				t.Fatalf("the matcher table must not contain the lexer")
+			}
+			actual := runGeneratedSrc(t, goCmd, src, stepMatcherSrc, strings.NewReader(input.String()))
+			if actual != expected.String() {
+				t.Fatalf("unexpected kinds;\nwant:\n%v\ngot:\n%v", expected.String(), actual)
+			}
+		})
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"github.com/nihei9/maleeni/spec"
 )
 
+// lexSpec implements the LexSpec interface on top of a compiled lexical specification. Lexers generated by
+// maleeni-go embed their own implementation of the same interface instead of this one.
 type lexSpec struct {
 	spec *spec.CompiledLexSpec
 }
 
+var _ LexSpec = &lexSpec{}
+
 func NewLexSpec(spec *spec.CompiledLexSpec) *lexSpec {
 	return &lexSpec{
 		spec: spec,
@@ -31,6 +35,18 @@ func (s *lexSpec) ModeName(mode ModeID) string {
 	return s.spec.ModeNames[mode].String()
 }
 
+func (s *lexSpec) ModeIDByName(name string) (ModeID, bool) {
+	for id, n := range s.spec.ModeNames {
+		if id == spec.LexModeIDNil.Int() {
+			continue
+		}
+		if n.String() == name {
+			return ModeID(id), true
+		}
+	}
+	return ModeID(spec.LexModeIDNil.Int()), false
+}
+
 func (s *lexSpec) InitialState(mode ModeID) StateID {
 	return StateID(s.spec.Specs[mode].DFA.InitialStateID.Int())
 }
@@ -39,15 +55,23 @@ func (s *lexSpec) NextState(mode ModeID, state StateID, v int) (StateID, bool) {
 	switch s.spec.CompressionLevel {
 	case 2:
 		tran := s.spec.Specs[mode].DFA.Transition
+		ue := tran.UniqueEntries
+		if s.spec.SharedTransition != nil {
+			ue = s.spec.SharedTransition.UniqueEntries
+		}
 		rowNum := tran.RowNums[state]
-		d := tran.UniqueEntries.RowDisplacement[rowNum]
-		if tran.UniqueEntries.Bounds[d+v] != rowNum {
-			return StateID(tran.UniqueEntries.EmptyValue.Int()), false
+		d := ue.RowDisplacement[rowNum]
+		if ue.Bounds[d+v] != rowNum {
+			return StateID(ue.EmptyValue.Int()), false
 		}
-		return StateID(tran.UniqueEntries.Entries[d+v].Int()), true
+		return StateID(ue.Entries[d+v].Int()), true
 	case 1:
 		tran := s.spec.Specs[mode].DFA.Transition
-		next := tran.UncompressedUniqueEntries[tran.RowNums[state]*tran.OriginalColCount+v]
+		entries := tran.UncompressedUniqueEntries
+		if s.spec.SharedTransition != nil {
+			entries = s.spec.SharedTransition.UncompressedUniqueEntries
+		}
+		next := entries[tran.RowNums[state]*tran.OriginalColCount+v]
 		if next == spec.StateIDNil {
 			return StateID(spec.StateIDNil.Int()), false
 		}
@@ -67,6 +91,59 @@ func (s *lexSpec) Accept(mode ModeID, state StateID) (ModeKindID, bool) {
 	return ModeKindID(modeKindID.Int()), modeKindID != spec.LexModeKindIDNil
 }
 
+func (s *lexSpec) AcceptCandidates(mode ModeID, state StateID) []ModeKindID {
+	cands := s.spec.Specs[mode].DFA.AcceptingCandidates
+	if cands == nil {
+		return nil
+	}
+	ids := make([]ModeKindID, len(cands[state]))
+	for i, id := range cands[state] {
+		ids[i] = ModeKindID(id.Int())
+	}
+	return ids
+}
+
+func (s *lexSpec) PartialKind(mode ModeID, state StateID) (ModeKindID, bool) {
+	kinds := s.spec.Specs[mode].DFA.PartialKinds
+	if kinds == nil {
+		return ModeKindID(spec.LexModeKindIDNil.Int()), false
+	}
+	return ModeKindID(kinds[state].Int()), kinds[state] != spec.LexModeKindIDNil
+}
+
+func (s *lexSpec) Anchor(mode ModeID, modeKind ModeKindID) Anchor {
+	anchors := s.spec.Specs[mode].Anchors
+	if anchors == nil {
+		return AnchorNil
+	}
+	return Anchor(anchors[modeKind].Int())
+}
+
+func (s *lexSpec) Skip(mode ModeID, modeKind ModeKindID) bool {
+	skip := s.spec.Specs[mode].Skip
+	if skip == nil {
+		return false
+	}
+	return skip[modeKind] == 1
+}
+
+func (s *lexSpec) ErrorKind(mode ModeID) (ModeKindID, bool) {
+	id := s.spec.Specs[mode].ErrorKind
+	return ModeKindID(id.Int()), id != spec.LexModeKindIDNil
+}
+
+func (s *lexSpec) EOFKind(mode ModeID) (ModeKindID, bool) {
+	id := s.spec.Specs[mode].EOFKind
+	return ModeKindID(id.Int()), id != spec.LexModeKindIDNil
+}
+
+func (s *lexSpec) KindMeta(kind KindID) map[string]string {
+	if s.spec.KindMeta == nil {
+		return nil
+	}
+	return s.spec.KindMeta[kind]
+}
+
 func (s *lexSpec) KindIDAndName(mode ModeID, modeKind ModeKindID) (KindID, string) {
 	kindID := s.spec.KindIDs[mode][modeKind]
 	return KindID(kindID.Int()), s.spec.KindNames[kindID].String()
@@ -5,9 +5,12 @@ import (
 	_ "embed"
 	"fmt"
 	"go/ast"
+	"go/build/constraint"
 	"go/format"
 	"go/parser"
 	"go/token"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -17,8 +20,99 @@ import (
 //go:embed lexer.go
 var lexerCoreSrc string
 
-func GenLexer(clspec *spec.CompiledLexSpec, pkgName string) ([]byte, error) {
+type GenLexerOption func(c *genLexerConfig) error
+
+// GenStreamingLexer makes a generated lexer read the source incrementally by default. See also ReadIncrementally.
+func GenStreamingLexer() GenLexerOption {
+	return func(c *genLexerConfig) error {
+		c.streaming = true
+		return nil
+	}
+}
+
+// GenBuildConstraint adds a `//go:build` line to a generated file. `expr` is a build constraint expression such as
+// `linux && !cgo`.
+func GenBuildConstraint(expr string) GenLexerOption {
+	return func(c *genLexerConfig) error {
+		_, err := constraint.Parse("//go:build " + expr)
+		if err != nil {
+			return fmt.Errorf("invalid build constraint: %v: %w", expr, err)
+		}
+		c.buildConstraint = expr
+		return nil
+	}
+}
+
+// GenHeader adds a comment, such as a license notice, to the top of a generated file. Each line of `header` becomes
+// a line comment unless it is already a line comment. The generated file still has the "DO NOT EDIT" banner following
+// the header.
+func GenHeader(header string) GenLexerOption {
+	return func(c *genLexerConfig) error {
+		c.header = header
+		return nil
+	}
+}
+
+// GenLineDirectives puts `//line lexer.go:NN` directives in a generated file so that stack traces of panics in
+// the driver code refer to lines of lexer.go of the driver package instead of lines of the generated file.
+// `fileName` is the name of the generated file; a directive following the driver code restores positions of
+// the rest of the file with it.
+func GenLineDirectives(fileName string) GenLexerOption {
+	return func(c *genLexerConfig) error {
+		if fileName == "" {
+			return fmt.Errorf("a file name must be non-empty")
+		}
+		c.lineDirectiveFile = fileName
+		return nil
+	}
+}
+
+type genLexerConfig struct {
+	streaming         bool
+	buildConstraint   string
+	header            string
+	lineDirectiveFile string
+}
+
+// prologue returns the lines preceding the "DO NOT EDIT" banner of a generated file.
+func (c *genLexerConfig) prologue() string {
+	var b strings.Builder
+	if c.header != "" {
+		for _, line := range strings.Split(strings.TrimRight(c.header, "\r\n"), "\n") {
+			line = strings.TrimRight(line, "\r")
+			switch {
+			case strings.HasPrefix(line, "//"):
+				fmt.Fprintf(&b, "%v\n", line)
+			case line == "":
+				fmt.Fprintf(&b, "//\n")
+			default:
+				fmt.Fprintf(&b, "// %v\n", line)
+			}
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+	if c.buildConstraint != "" {
+		fmt.Fprintf(&b, "//go:build %v\n\n", c.buildConstraint)
+	}
+	return b.String()
+}
+
+func GenLexer(clspec *spec.CompiledLexSpec, pkgName string, opts ...GenLexerOption) ([]byte, error) {
+	config := &genLexerConfig{}
+	for _, opt := range opts {
+		err := opt(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := validateGoIdentifiers(clspec)
+	if err != nil {
+		return nil, err
+	}
+
 	var lexerSrc string
+	var lexerDeclLines []int
 	{
 		fset := token.NewFileSet()
 		f, err := parser.ParseFile(fset, "lexer.go", lexerCoreSrc, parser.ParseComments)
@@ -26,6 +120,13 @@ func GenLexer(clspec *spec.CompiledLexSpec, pkgName string) ([]byte, error) {
 			return nil, err
 		}
 
+		if config.streaming {
+			err := setBoolConst(f, "readIncrementallyByDefault", true)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		var b strings.Builder
 		err = format.Node(&b, fset, f)
 		if err != nil {
@@ -33,9 +134,119 @@ func GenLexer(clspec *spec.CompiledLexSpec, pkgName string) ([]byte, error) {
 		}
 
 		lexerSrc = b.String()
+		lexerDeclLines = declLines(fset, f)
 	}
 
-	var modeIDsSrc string
+	idSrcs := genIDSrcs(clspec)
+
+	var specSrc string
+	{
+		t, err := template.New("").Funcs(genTemplateFuncs(clspec)).Parse(lexSpecTemplate)
+		if err != nil {
+			return nil, err
+		}
+
+		var b strings.Builder
+		err = t.Execute(&b, map[string]interface{}{
+			"initialModeID":    "ModeID" + spec.SnakeCaseToUpperCamelCase(clspec.ModeNames[clspec.InitialModeID].String()),
+			"modeIDNil":        "ModeIDNil",
+			"modeKindIDNil":    spec.LexModeKindIDNil,
+			"stateIDNil":       spec.StateIDNil,
+			"compressionLevel": clspec.CompressionLevel,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		specSrc = b.String()
+	}
+
+	var src string
+	{
+		tmpl := `{{ .prologue }}// Code generated by maleeni-go. DO NOT EDIT.
+{{ .lexerSrc }}
+
+{{ .modeIDsSrc }}
+
+{{ .modeNamesSrc }}
+
+{{ .modeIDToNameSrc }}
+
+{{ .modeNameToIDSrc }}
+
+{{ .kindIDsSrc }}
+
+{{ .kindNamesSrc }}
+
+{{ .kindIDToNameSrc }}
+
+{{ .kindNameToIDSrc }}
+
+{{ .specSrc }}
+`
+
+		t, err := template.New("").Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+
+		var b strings.Builder
+		err = t.Execute(&b, map[string]string{
+			"prologue":        config.prologue(),
+			"lexerSrc":        lexerSrc,
+			"modeIDsSrc":      idSrcs.modeIDs,
+			"modeNamesSrc":    idSrcs.modeNames,
+			"modeIDToNameSrc": idSrcs.modeIDToName,
+			"modeNameToIDSrc": idSrcs.modeNameToID,
+			"kindIDsSrc":      idSrcs.kindIDs,
+			"kindNamesSrc":    idSrcs.kindNames,
+			"kindIDToNameSrc": idSrcs.kindIDToName,
+			"kindNameToIDSrc": idSrcs.kindNameToID,
+			"specSrc":         specSrc,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		src = b.String()
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Name = ast.NewIdent(pkgName)
+
+	var b bytes.Buffer
+	err = format.Node(&b, fset, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.lineDirectiveFile != "" {
+		return insertLineDirectives(b.Bytes(), lexerDeclLines, config.lineDirectiveFile)
+	}
+
+	return b.Bytes(), nil
+}
+
+// idSrcs holds declarations of constants and functions converting between IDs and names of modes and kinds.
+type idSrcs struct {
+	modeIDs      string
+	modeNames    string
+	modeIDToName string
+	modeNameToID string
+	kindIDs      string
+	kindNames    string
+	kindIDToName string
+	kindNameToID string
+}
+
+func genIDSrcs(clspec *spec.CompiledLexSpec) *idSrcs {
+	srcs := &idSrcs{}
+
 	{
 		var b strings.Builder
 		fmt.Fprintf(&b, "const (\n")
@@ -48,10 +259,9 @@ func GenLexer(clspec *spec.CompiledLexSpec, pkgName string) ([]byte, error) {
 		}
 		fmt.Fprintf(&b, ")")
 
-		modeIDsSrc = b.String()
+		srcs.modeIDs = b.String()
 	}
 
-	var modeNamesSrc string
 	{
 		var b strings.Builder
 		fmt.Fprintf(&b, "const (\n")
@@ -64,10 +274,9 @@ func GenLexer(clspec *spec.CompiledLexSpec, pkgName string) ([]byte, error) {
 		}
 		fmt.Fprintf(&b, ")")
 
-		modeNamesSrc = b.String()
+		srcs.modeNames = b.String()
 	}
 
-	var modeIDToNameSrc string
 	{
 		var b strings.Builder
 		fmt.Fprintf(&b, `
@@ -92,10 +301,33 @@ func ModeIDToName(id ModeID) string {
 }
 `)
 
-		modeIDToNameSrc = b.String()
+		srcs.modeIDToName = b.String()
+	}
+
+	{
+		var b strings.Builder
+		fmt.Fprintf(&b, `
+// ModeIDFromName converts a mode name to an ID. When the mode is undefined, the second return value is false.
+func ModeIDFromName(name string) (ModeID, bool) {
+    switch name {`)
+		for i, k := range clspec.ModeNames {
+			if i == spec.LexModeIDNil.Int() {
+				continue
+			}
+			name := spec.SnakeCaseToUpperCamelCase(k.String())
+			fmt.Fprintf(&b, `
+    case ModeName%v:
+        return ModeID%v, true`, name, name)
+		}
+		fmt.Fprintf(&b, `
+    }
+    return ModeIDNil, false
+}
+`)
+
+		srcs.modeNameToID = b.String()
 	}
 
-	var kindIDsSrc string
 	{
 		var b strings.Builder
 		fmt.Fprintf(&b, "const (\n")
@@ -108,10 +340,9 @@ func ModeIDToName(id ModeID) string {
 		}
 		fmt.Fprintf(&b, ")")
 
-		kindIDsSrc = b.String()
+		srcs.kindIDs = b.String()
 	}
 
-	var kindNamesSrc string
 	{
 		var b strings.Builder
 		fmt.Fprintf(&b, "const (\n")
@@ -121,10 +352,9 @@ func ModeIDToName(id ModeID) string {
 		}
 		fmt.Fprintf(&b, ")")
 
-		kindNamesSrc = b.String()
+		srcs.kindNames = b.String()
 	}
 
-	var kindIDToNameSrc string
 	{
 		var b strings.Builder
 		fmt.Fprintf(&b, `
@@ -149,89 +379,207 @@ func KindIDToName(id KindID) string {
 }
 `)
 
-		kindIDToNameSrc = b.String()
+		srcs.kindIDToName = b.String()
 	}
 
-	var specSrc string
 	{
-		t, err := template.New("").Funcs(genTemplateFuncs(clspec)).Parse(lexSpecTemplate)
-		if err != nil {
-			return nil, err
-		}
-
 		var b strings.Builder
-		err = t.Execute(&b, map[string]interface{}{
-			"initialModeID":    "ModeID" + spec.SnakeCaseToUpperCamelCase(clspec.ModeNames[clspec.InitialModeID].String()),
-			"modeIDNil":        "ModeIDNil",
-			"modeKindIDNil":    spec.LexModeKindIDNil,
-			"stateIDNil":       spec.StateIDNil,
-			"compressionLevel": clspec.CompressionLevel,
-		})
-		if err != nil {
-			return nil, err
+		fmt.Fprintf(&b, `
+// KindIDFromName converts a kind name to an ID. When the kind is undefined, the second return value is false.
+func KindIDFromName(name string) (KindID, bool) {
+    switch name {`)
+		for i, k := range clspec.KindNames {
+			if i == spec.LexKindIDNil.Int() {
+				continue
+			}
+			name := spec.SnakeCaseToUpperCamelCase(k.String())
+			fmt.Fprintf(&b, `
+    case KindName%v:
+        return KindID%v, true`, name, name)
 		}
+		fmt.Fprintf(&b, `
+    }
+    return KindIDNil, false
+}
+`)
 
-		specSrc = b.String()
+		srcs.kindNameToID = b.String()
 	}
 
-	var src string
-	{
-		tmpl := `// Code generated by maleeni-go. DO NOT EDIT.
-{{ .lexerSrc }}
+	return srcs
+}
 
-{{ .modeIDsSrc }}
+// validateGoIdentifiers checks that the names of kinds and modes don't collide after the conversion into
+// UpperCamelCase because the generated code declares constants such as KindIDLeftParen for them. The compiler
+// accepts such names when it treats spelling inconsistencies as warnings.
+func validateGoIdentifiers(clspec *spec.CompiledLexSpec) error {
+	var names []string
+	for name := range clspec.KindNameSet() {
+		names = append(names, name)
+	}
+	if dups := spec.FindSpellingInconsistencies(names); len(dups) > 0 {
+		return fmt.Errorf("kinds %v have the same name in UpperCamelCase; please use the same spelling", strings.Join(dups[0], ", "))
+	}
+	names = nil
+	for name := range clspec.ModeNameSet() {
+		names = append(names, name)
+	}
+	if dups := spec.FindSpellingInconsistencies(names); len(dups) > 0 {
+		return fmt.Errorf("modes %v have the same name in UpperCamelCase; please use the same spelling", strings.Join(dups[0], ", "))
+	}
+	return nil
+}
 
-{{ .modeNamesSrc }}
+// declLines returns the line numbers where the top-level declarations of a file begin. When a declaration has
+// a doc comment, the declaration begins at the comment.
+func declLines(fset *token.FileSet, f *ast.File) []int {
+	lines := make([]int, len(f.Decls))
+	for i, decl := range f.Decls {
+		pos := decl.Pos()
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+		}
+		lines[i] = fset.Position(pos).Line
+	}
+	return lines
+}
 
-{{ .modeIDToNameSrc }}
+// insertLineDirectives puts a `//line lexer.go:NN` directive before each declaration that comes from lexer.go.
+// `lexerDeclLines` contains the line numbers of the declarations in lexer.go, and the generated file begins with
+// the declarations in the same order. A directive following them maps the rest of the file to its actual lines of
+// `fileName`.
+func insertLineDirectives(src []byte, lexerDeclLines []int, fileName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	lines := declLines(fset, f)
+	if len(lines) < len(lexerDeclLines) {
+		return nil, fmt.Errorf("the generated file lacks declarations of the driver")
+	}
 
-{{ .kindIDsSrc }}
+	// directives maps a line number of `src` to the line number of lexer.go that the line comes from. 0 means
+	// the line of the generated file itself.
+	directives := map[int]int{}
+	for i, line := range lexerDeclLines {
+		directives[lines[i]] = line
+	}
+	if len(lines) > len(lexerDeclLines) {
+		directives[lines[len(lexerDeclLines)]] = 0
+	}
 
-{{ .kindNamesSrc }}
+	var b bytes.Buffer
+	outLine := 0
+	for i, line := range strings.SplitAfter(string(src), "\n") {
+		if orig, ok := directives[i+1]; ok {
+			if orig > 0 {
+				fmt.Fprintf(&b, "//line lexer.go:%v\n", orig)
+			} else {
+				// The line following this directive is the line `outLine+2` of the generated file.
+				fmt.Fprintf(&b, "//line %v:%v\n", fileName, outLine+2)
+			}
+			outLine++
+		}
+		b.WriteString(line)
+		outLine++
+	}
+	return b.Bytes(), nil
+}
 
-{{ .kindIDToNameSrc }}
+const benchmarkTemplate = `{{ .prologue }}// Code generated by maleeni-go. DO NOT EDIT.
+package {{ .pkgName }}
 
-{{ .specSrc }}
-`
+import (
+	"bytes"
+	"testing"
+)
 
-		t, err := template.New("").Parse(tmpl)
+// benchmarkSrc is the sample input that BenchmarkLexer tokenizes.
+const benchmarkSrc = {{ .src }}
+
+// BenchmarkLexer measures the throughput of the lexer tokenizing benchmarkSrc. Run it using ` + "`go test -bench Lexer`" + `.
+func BenchmarkLexer(b *testing.B) {
+	src := []byte(benchmarkSrc)
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lex, err := NewLexer(NewLexSpec(), bytes.NewReader(src))
 		if err != nil {
-			return nil, err
+			b.Fatal(err)
+		}
+		for {
+			tok, err := lex.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if tok.EOF {
+				break
+			}
 		}
+	}
+}
+`
 
-		var b strings.Builder
-		err = t.Execute(&b, map[string]string{
-			"lexerSrc":        lexerSrc,
-			"modeIDsSrc":      modeIDsSrc,
-			"modeNamesSrc":    modeNamesSrc,
-			"modeIDToNameSrc": modeIDToNameSrc,
-			"kindIDsSrc":      kindIDsSrc,
-			"kindNamesSrc":    kindNamesSrc,
-			"kindIDToNameSrc": kindIDToNameSrc,
-			"specSrc":         specSrc,
-		})
+// GenBenchmark generates a test file containing a benchmark of a lexer that GenLexer generates. The benchmark
+// tokenizes `src` embedded in the file, so the file must be in the same package as the lexer. Pass the same
+// GenBuildConstraint and GenHeader options as the lexer so that the benchmark builds under the same constraint.
+func GenBenchmark(pkgName string, src []byte, opts ...GenLexerOption) ([]byte, error) {
+	config := &genLexerConfig{}
+	for _, opt := range opts {
+		err := opt(config)
 		if err != nil {
 			return nil, err
 		}
-
-		src = b.String()
 	}
 
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	t, err := template.New("").Parse(benchmarkTemplate)
 	if err != nil {
 		return nil, err
 	}
 
-	f.Name = ast.NewIdent(pkgName)
-
 	var b bytes.Buffer
-	err = format.Node(&b, fset, f)
+	err = t.Execute(&b, map[string]string{
+		"prologue": config.prologue(),
+		"pkgName":  pkgName,
+		"src":      strconv.Quote(string(src)),
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return b.Bytes(), nil
+	return format.Source(b.Bytes())
+}
+
+// setBoolConst replaces the value of a boolean constant declared at the top level of a file.
+func setBoolConst(f *ast.File, name string, v bool) error {
+	for _, decl := range f.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.CONST {
+			continue
+		}
+		for _, s := range d.Specs {
+			vs := s.(*ast.ValueSpec)
+			for i, n := range vs.Names {
+				if n.Name != name {
+					continue
+				}
+				vs.Values[i] = &ast.Ident{
+					NamePos: vs.Values[i].Pos(),
+					Name:    fmt.Sprintf("%v", v),
+				}
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("constant %v is not found", name)
 }
 
 const lexSpecTemplate = `
@@ -241,8 +589,15 @@ type lexSpec struct {
 	modeNames     []string
 	initialStates []StateID
 	acceptances   [][]ModeKindID
+	candidates    [][][]ModeKindID
+	partialKinds  [][]ModeKindID
+	anchors       [][]Anchor
+	skip          [][]bool
+	errorKinds    []ModeKindID
+	eofKinds      []ModeKindID
 	kindIDs       [][]KindID
 	kindNames     []string
+	kindMeta      []map[string]string
 	initialModeID ModeID
 	modeIDNil     ModeID
 	modeKindIDNil ModeKindID
@@ -255,6 +610,10 @@ type lexSpec struct {
 	originalColCounts []int
 }
 
+// The lexer depends only on the LexSpec interface, so the generated specification must satisfy it as well as
+// the one wrapping a compiled specification.
+var _ LexSpec = &lexSpec{}
+
 func NewLexSpec() *lexSpec {
 	return &lexSpec{
 		pop: {{ genPopTable }},
@@ -262,8 +621,15 @@ func NewLexSpec() *lexSpec {
 		modeNames: {{ genModeNameTable }},
 		initialStates: {{ genInitialStateTable }},
 		acceptances: {{ genAcceptTable }},
+		candidates: {{ genAcceptCandidateTable }},
+		partialKinds: {{ genPartialKindTable }},
+		anchors: {{ genAnchorTable }},
+		skip: {{ genSkipTable }},
+		errorKinds: {{ genErrorKindTable }},
+		eofKinds: {{ genEOFKindTable }},
 		kindIDs: {{ genKindIDTable }},
 		kindNames: {{ genKindNameTable }},
+		kindMeta: {{ genKindMetaTable }},
 		initialModeID: {{ .initialModeID }},
 		modeIDNil: {{ .modeIDNil }},
 		modeKindIDNil: {{ .modeKindIDNil }},
@@ -294,6 +660,18 @@ func (s *lexSpec) ModeName(mode ModeID) string {
 	return s.modeNames[mode]
 }
 
+func (s *lexSpec) ModeIDByName(name string) (ModeID, bool) {
+	for id, n := range s.modeNames {
+		if ModeID(id) == s.modeIDNil {
+			continue
+		}
+		if n == name {
+			return ModeID(id), true
+		}
+	}
+	return s.modeIDNil, false
+}
+
 func (s *lexSpec) InitialState(mode ModeID) StateID {
 	return s.initialStates[mode]
 }
@@ -329,10 +707,56 @@ func (s *lexSpec) Accept(mode ModeID, state StateID) (ModeKindID, bool) {
 	return id, id != s.modeKindIDNil
 }
 
+func (s *lexSpec) AcceptCandidates(mode ModeID, state StateID) []ModeKindID {
+	if s.candidates[mode] == nil {
+		return nil
+	}
+	return s.candidates[mode][state]
+}
+
+func (s *lexSpec) PartialKind(mode ModeID, state StateID) (ModeKindID, bool) {
+	if s.partialKinds[mode] == nil {
+		return s.modeKindIDNil, false
+	}
+	id := s.partialKinds[mode][state]
+	return id, id != s.modeKindIDNil
+}
+
+func (s *lexSpec) Anchor(mode ModeID, modeKind ModeKindID) Anchor {
+	if s.anchors[mode] == nil {
+		return AnchorNil
+	}
+	return s.anchors[mode][modeKind]
+}
+
+func (s *lexSpec) Skip(mode ModeID, modeKind ModeKindID) bool {
+	if s.skip[mode] == nil {
+		return false
+	}
+	return s.skip[mode][modeKind]
+}
+
+func (s *lexSpec) ErrorKind(mode ModeID) (ModeKindID, bool) {
+	id := s.errorKinds[mode]
+	return id, id != s.modeKindIDNil
+}
+
+func (s *lexSpec) EOFKind(mode ModeID) (ModeKindID, bool) {
+	id := s.eofKinds[mode]
+	return id, id != s.modeKindIDNil
+}
+
 func (s *lexSpec) KindIDAndName(mode ModeID, modeKind ModeKindID) (KindID, string) {
 	id := s.kindIDs[mode][modeKind]
 	return id, s.kindNames[id]
 }
+
+func (s *lexSpec) KindMeta(kind KindID) map[string]string {
+	if s.kindMeta == nil {
+		return nil
+	}
+	return s.kindMeta[kind]
+}
 `
 
 func genTemplateFuncs(clspec *spec.CompiledLexSpec) template.FuncMap {
@@ -451,6 +875,148 @@ func genTemplateFuncs(clspec *spec.CompiledLexSpec) template.FuncMap {
 			fmt.Fprintf(&b, "}")
 			return b.String()
 		},
+		"genAcceptCandidateTable": func() string {
+			var b strings.Builder
+			fmt.Fprintf(&b, "[][][]ModeKindID{\n")
+			for i, s := range clspec.Specs {
+				if i == spec.LexModeIDNil.Int() || s.DFA.AcceptingCandidates == nil {
+					fmt.Fprintf(&b, "nil,\n")
+					continue
+				}
+
+				fmt.Fprintf(&b, "{\n")
+				for _, ids := range s.DFA.AcceptingCandidates {
+					if ids == nil {
+						fmt.Fprintf(&b, "nil,\n")
+						continue
+					}
+
+					fmt.Fprintf(&b, "{")
+					for _, id := range ids {
+						fmt.Fprintf(&b, "%v,", id)
+					}
+					fmt.Fprintf(&b, "},\n")
+				}
+				fmt.Fprintf(&b, "},\n")
+			}
+			fmt.Fprintf(&b, "}")
+			return b.String()
+		},
+		"genPartialKindTable": func() string {
+			var b strings.Builder
+			fmt.Fprintf(&b, "[][]ModeKindID{\n")
+			for i, s := range clspec.Specs {
+				if i == spec.LexModeIDNil.Int() || s.DFA.PartialKinds == nil {
+					fmt.Fprintf(&b, "nil,\n")
+					continue
+				}
+
+				c := 1
+				fmt.Fprintf(&b, "{\n")
+				for _, v := range s.DFA.PartialKinds {
+					fmt.Fprintf(&b, "%v,", v)
+
+					if c == 20 {
+						fmt.Fprintf(&b, "\n")
+						c = 1
+					} else {
+						c++
+					}
+				}
+				if c > 1 {
+					fmt.Fprintf(&b, "\n")
+				}
+				fmt.Fprintf(&b, "},\n")
+			}
+			fmt.Fprintf(&b, "}")
+			return b.String()
+		},
+		"genAnchorTable": func() string {
+			var b strings.Builder
+			fmt.Fprintf(&b, "[][]Anchor{\n")
+			for i, s := range clspec.Specs {
+				if i == spec.LexModeIDNil.Int() || s.Anchors == nil {
+					fmt.Fprintf(&b, "nil,\n")
+					continue
+				}
+
+				c := 1
+				fmt.Fprintf(&b, "{\n")
+				for _, v := range s.Anchors {
+					fmt.Fprintf(&b, "%v,", v)
+
+					if c == 20 {
+						fmt.Fprintf(&b, "\n")
+						c = 1
+					} else {
+						c++
+					}
+				}
+				if c > 1 {
+					fmt.Fprintf(&b, "\n")
+				}
+				fmt.Fprintf(&b, "},\n")
+			}
+			fmt.Fprintf(&b, "}")
+			return b.String()
+		},
+		"genSkipTable": func() string {
+			var b strings.Builder
+			fmt.Fprintf(&b, "[][]bool{\n")
+			for i, s := range clspec.Specs {
+				if i == spec.LexModeIDNil.Int() || s.Skip == nil {
+					fmt.Fprintf(&b, "nil,\n")
+					continue
+				}
+
+				c := 1
+				fmt.Fprintf(&b, "{\n")
+				for _, v := range s.Skip {
+					fmt.Fprintf(&b, "%v, ", v != 0)
+
+					if c == 20 {
+						fmt.Fprintf(&b, "\n")
+						c = 1
+					} else {
+						c++
+					}
+				}
+				if c > 1 {
+					fmt.Fprintf(&b, "\n")
+				}
+				fmt.Fprintf(&b, "},\n")
+			}
+			fmt.Fprintf(&b, "}")
+			return b.String()
+		},
+		"genErrorKindTable": func() string {
+			var b strings.Builder
+			fmt.Fprintf(&b, "[]ModeKindID{\n")
+			for i, s := range clspec.Specs {
+				if i == spec.LexModeIDNil.Int() {
+					fmt.Fprintf(&b, "%v,\n", spec.LexModeKindIDNil)
+					continue
+				}
+
+				fmt.Fprintf(&b, "%v,\n", s.ErrorKind)
+			}
+			fmt.Fprintf(&b, "}")
+			return b.String()
+		},
+		"genEOFKindTable": func() string {
+			var b strings.Builder
+			fmt.Fprintf(&b, "[]ModeKindID{\n")
+			for i, s := range clspec.Specs {
+				if i == spec.LexModeIDNil.Int() {
+					fmt.Fprintf(&b, "%v,\n", spec.LexModeKindIDNil)
+					continue
+				}
+
+				fmt.Fprintf(&b, "%v,\n", s.EOFKind)
+			}
+			fmt.Fprintf(&b, "}")
+			return b.String()
+		},
 		"genKindIDTable": func() string {
 			var b strings.Builder
 			fmt.Fprintf(&b, "[][]KindID{\n")
@@ -486,6 +1052,31 @@ func genTemplateFuncs(clspec *spec.CompiledLexSpec) template.FuncMap {
 			fmt.Fprintf(&b, "}")
 			return b.String()
 		},
+		"genKindMetaTable": func() string {
+			if clspec.KindMeta == nil {
+				return "nil"
+			}
+			var b strings.Builder
+			fmt.Fprintf(&b, "[]map[string]string{\n")
+			for _, meta := range clspec.KindMeta {
+				if len(meta) == 0 {
+					fmt.Fprintf(&b, "nil,\n")
+					continue
+				}
+				var keys []string
+				for k := range meta {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				fmt.Fprintf(&b, "{\n")
+				for _, k := range keys {
+					fmt.Fprintf(&b, "%q: %q,\n", k, meta[k])
+				}
+				fmt.Fprintf(&b, "},\n")
+			}
+			fmt.Fprintf(&b, "}")
+			return b.String()
+		},
 	}
 
 	switch clspec.CompressionLevel {
@@ -521,6 +1112,9 @@ func genTemplateFuncs(clspec *spec.CompiledLexSpec) template.FuncMap {
 		}
 
 		fns["genRowDisplacements"] = func() string {
+			if clspec.SharedTransition != nil {
+				return genSharedTable(clspec, "int", clspec.SharedTransition.UniqueEntries.RowDisplacement)
+			}
 			var b strings.Builder
 			fmt.Fprintf(&b, "[][]int{\n")
 			for i, s := range clspec.Specs {
@@ -551,6 +1145,9 @@ func genTemplateFuncs(clspec *spec.CompiledLexSpec) template.FuncMap {
 		}
 
 		fns["genBounds"] = func() string {
+			if clspec.SharedTransition != nil {
+				return genSharedTable(clspec, "int", clspec.SharedTransition.UniqueEntries.Bounds)
+			}
 			var b strings.Builder
 			fmt.Fprintf(&b, "[][]int{\n")
 			for i, s := range clspec.Specs {
@@ -581,6 +1178,9 @@ func genTemplateFuncs(clspec *spec.CompiledLexSpec) template.FuncMap {
 		}
 
 		fns["genEntries"] = func() string {
+			if clspec.SharedTransition != nil {
+				return genSharedTable(clspec, "StateID", stateIDsToInts(clspec.SharedTransition.UniqueEntries.Entries))
+			}
 			var b strings.Builder
 			fmt.Fprintf(&b, "[][]StateID{\n")
 			for i, s := range clspec.Specs {
@@ -653,6 +1253,9 @@ func genTemplateFuncs(clspec *spec.CompiledLexSpec) template.FuncMap {
 		}
 
 		fns["genEntries"] = func() string {
+			if clspec.SharedTransition != nil {
+				return genSharedTable(clspec, "StateID", stateIDsToInts(clspec.SharedTransition.UncompressedUniqueEntries))
+			}
 			var b strings.Builder
 			fmt.Fprintf(&b, "[][]StateID{\n")
 			for i, s := range clspec.Specs {
@@ -757,3 +1360,45 @@ func genTemplateFuncs(clspec *spec.CompiledLexSpec) template.FuncMap {
 
 	return fns
 }
+
+// genSharedTable generates an expression of a table shared by all modes. The table appears only once in the generated
+// code, and the elements for all modes except the nil mode refer to it.
+func genSharedTable(clspec *spec.CompiledLexSpec, elemType string, vals []int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "func() [][]%v {\n", elemType)
+	fmt.Fprintf(&b, "shared := []%v{\n", elemType)
+	c := 1
+	for _, v := range vals {
+		fmt.Fprintf(&b, "%v,", v)
+
+		if c == 20 {
+			fmt.Fprintf(&b, "\n")
+			c = 1
+		} else {
+			c++
+		}
+	}
+	if c > 1 {
+		fmt.Fprintf(&b, "\n")
+	}
+	fmt.Fprintf(&b, "}\n")
+	fmt.Fprintf(&b, "return [][]%v{\n", elemType)
+	for i := range clspec.Specs {
+		if i == spec.LexModeIDNil.Int() {
+			fmt.Fprintf(&b, "nil,\n")
+			continue
+		}
+		fmt.Fprintf(&b, "shared,\n")
+	}
+	fmt.Fprintf(&b, "}\n")
+	fmt.Fprintf(&b, "}()")
+	return b.String()
+}
+
+func stateIDsToInts(ids []spec.StateID) []int {
+	vals := make([]int, len(ids))
+	for i, id := range ids {
+		vals[i] = id.Int()
+	}
+	return vals
+}
@@ -0,0 +1,598 @@
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nihei9/maleeni/compiler"
+	"github.com/nihei9/maleeni/spec"
+)
+
+// runGeneratedLexer generates a lexer in a temporary module and runs `mainSrc` in the same package.
+func runGeneratedLexer(t *testing.T, clspec *spec.CompiledLexSpec, mainSrc string, stdin io.Reader, opts ...GenLexerOption) string {
+	t.Helper()
+
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is unavailable")
+	}
+
+	src, err := GenLexer(clspec, "main", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runGeneratedSrc(t, goCmd, src, mainSrc, stdin)
+}
+
+// runGeneratedSrc runs generated code `src` and `mainSrc` in the same package of a temporary module.
+func runGeneratedSrc(t *testing.T, goCmd string, src []byte, mainSrc string, stdin io.Reader) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":   "module test\n\ngo 1.16\n",
+		"lexer.go": string(src),
+		"main.go":  mainSrc,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goCmd, "run", ".")
+	cmd.Dir = dir
+	cmd.Stdin = stdin
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("failed to run the generated lexer: %v\n%v", err, stderr.String())
+	}
+	return string(out)
+}
+
+// printTokensSrc prints tokens that a generated lexer returns in the same format as printTokens.
+const printTokensSrc = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+func main() {
+	lex, err := NewLexer(NewLexSpec(), os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(w, "%v %v %v %q %v %v\n", KindIDToName(tok.KindID), tok.Row, tok.Col, tok.Lexeme, tok.Invalid, KindIDToName(tok.PartialKindID))
+		if tok.EOF {
+			break
+		}
+	}
+}
+`
+
+func printTokens(t *testing.T, clspec *spec.CompiledLexSpec, src io.Reader) string {
+	t.Helper()
+
+	lex, err := NewLexer(NewLexSpec(clspec), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(w, "%v %v %v %q %v %v\n", clspec.KindNames[tok.KindID], tok.Row, tok.Col, tok.Lexeme, tok.Invalid, clspec.KindNames[tok.PartialKindID])
+		if tok.EOF {
+			break
+		}
+	}
+	w.Flush()
+	return b.String()
+}
+
+func TestGenLexer_Streaming(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("heading", `^#+`),
+			newLexEntryDefaultNOP("semicolon", `;$`),
+			newLexEntryDefaultNOP("word", `[a-z\u{3042}]+`),
+			newLexEntryDefaultNOP("newline", `\u{000A}`),
+			newLexEntryDefaultNOP("white_space", ` +`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := GenLexer(clspec, "main", GenStreamingLexer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "const readIncrementallyByDefault = true") {
+		t.Fatalf("the generated lexer must read the source incrementally by default")
+	}
+
+	var b strings.Builder
+	for i := 0; b.Len() < 256*1024; i++ {
+		fmt.Fprintf(&b, "# foo;\n## bar; baz\n;@\n%v\n", strings.Repeat("あ", i%7))
+	}
+	input := b.String()
+
+	expected := printTokens(t, clspec, strings.NewReader(input))
+	actual := runGeneratedLexer(t, clspec, printTokensSrc, strings.NewReader(input), GenStreamingLexer())
+	if actual != expected {
+		t.Fatalf("the generated lexer must return the same tokens as the driver")
+	}
+}
+
+func TestGenLexer_SharedTransitionRows(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default", "string"}, "white_space", ` +`, "", false),
+			newLexEntry([]string{"default"}, "string_open", `"`, "string", false),
+			newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+			newLexEntry([]string{"string"}, "string_close", `"`, "", true),
+			newLexEntry([]string{"string"}, "char_seq", `[^" ]+`, "", false),
+		},
+	}
+	for _, compLv := range []int{1, 2} {
+		t.Run(fmt.Sprintf("compression level %v", compLv), func(t *testing.T) {
+			clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compLv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clspec.SharedTransition == nil {
+				t.Fatalf("the modes must share transition rows")
+			}
+
+			input := `foo "bar baz" qux "" "x`
+			expected := printTokens(t, clspec, strings.NewReader(input))
+			actual := runGeneratedLexer(t, clspec, printTokensSrc, strings.NewReader(input))
+			if actual != expected {
+				t.Fatalf("the generated lexer must return the same tokens as the driver;\nwant:\n%v\ngot:\n%v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestGenLexer_ErrorKind(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "string_open", `"`, "string", false),
+			newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+			newLexEntry([]string{"string"}, "string_close", `"`, "", true),
+			newLexEntry([]string{"string"}, "char_seq", `[a-z]+`, "", false),
+		},
+		ErrorKinds: map[spec.LexModeName]spec.LexKindName{
+			"default": "error",
+			"string":  "string_error",
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `foo#"bar$"#`
+	expected := printTokens(t, clspec, strings.NewReader(input))
+	if !strings.Contains(expected, `error 0 3 "#" true`) || !strings.Contains(expected, `string_error 0 8 "$" true`) {
+		t.Fatalf("error tokens must have the error kinds:\n%v", expected)
+	}
+	actual := runGeneratedLexer(t, clspec, printTokensSrc, strings.NewReader(input))
+	if actual != expected {
+		t.Fatalf("the generated lexer must return the same tokens as the driver;\nwant:\n%v\ngot:\n%v", expected, actual)
+	}
+}
+
+func TestGenLexer_EOFKind(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "string_open", `"`, "string", false),
+			newLexEntry([]string{"default"}, "word", `[a-z]+`, "", false),
+			newLexEntry([]string{"string"}, "string_close", `"`, "", true),
+			newLexEntry([]string{"string"}, "char_seq", `[a-z]+`, "", false),
+		},
+		EOFKind: "eof",
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{`foo`, `foo"bar`} {
+		expected := printTokens(t, clspec, strings.NewReader(input))
+		if !strings.Contains(expected, `eof 0 `) {
+			t.Fatalf("the EOF token must have the EOF kind:\n%v", expected)
+		}
+		actual := runGeneratedLexer(t, clspec, printTokensSrc, strings.NewReader(input))
+		if actual != expected {
+			t.Fatalf("the generated lexer must return the same tokens as the driver;\nwant:\n%v\ngot:\n%v", expected, actual)
+		}
+	}
+}
+
+func TestGenLexer_NameToID(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntry([]string{"default"}, "string_open", `"`, "string", false),
+			newLexEntry([]string{"string"}, "char_seq", `[a-z ]+`, "", false),
+			newLexEntry([]string{"string"}, "string_close", `"`, "", true),
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `package main
+
+import "fmt"
+
+func main() {
+	for _, name := range []string{%q, %q, %q, %q, "undefined"} {
+		id, ok := KindIDFromName(name)
+		fmt.Printf("kind %%v %%v %%v\n", name, ok, KindIDToName(id) == name)
+	}
+	for _, name := range []string{%q, %q, "undefined"} {
+		id, ok := ModeIDFromName(name)
+		fmt.Printf("mode %%v %%v %%v\n", name, ok, ModeIDToName(id) == name)
+	}
+}
+`, "string_open", "char_seq", "string_close", "word", "default", "string")
+
+	expected := `kind string_open true true
+kind char_seq true true
+kind string_close true true
+kind word true true
+kind undefined false false
+mode default true true
+mode string true true
+mode undefined false false
+`
+	actual := runGeneratedLexer(t, clspec, b.String(), nil)
+	if actual != expected {
+		t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", expected, actual)
+	}
+}
+
+func TestGenLexer_TokenStringAndMarshalJSON(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mainSrc := `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	lex, err := NewLexer(NewLexSpec(), os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		data, err := json.Marshal(tok)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%v\n%v\n", tok, string(data))
+		if tok.EOF {
+			break
+		}
+	}
+}
+`
+	src := "foo\n@\"bar"
+
+	var b strings.Builder
+	lex, err := NewLexer(NewLexSpec(clspec), strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(tok)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&b, "%v\n%v\n", tok, string(data))
+		if tok.EOF {
+			break
+		}
+	}
+	expected := b.String()
+
+	actual := runGeneratedLexer(t, clspec, mainSrc, strings.NewReader(src))
+	if actual != expected {
+		t.Fatalf("unexpected output:\nwant:\n%v\ngot:\n%v", expected, actual)
+	}
+}
+
+func TestGenBenchmark(t *testing.T) {
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is unavailable")
+	}
+
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+			newLexEntryDefaultNOP("white_space", `[ \u{000A}]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lexerSrc, err := GenLexer(clspec, "lexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sample input contains characters that need to be escaped in a string literal.
+	benchSrc, err := GenBenchmark("lexer", []byte("foo \"bar\"\nbaz\x00`"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":              "module test\n\ngo 1.16\n",
+		"lexer.go":            string(lexerSrc),
+		"lexer_bench_test.go": string(benchSrc),
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goCmd, "test", "-run", "^$", "-bench", "Lexer", "-benchtime", "1x")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to run the generated benchmark: %v\n%v", err, string(out))
+	}
+	if !strings.Contains(string(out), "BenchmarkLexer") {
+		t.Fatalf("the benchmark didn't run:\n%v", string(out))
+	}
+}
+
+func TestGenLexer_SpellingInconsistencies(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("utf_8", "utf-8"),
+			newLexEntryDefaultNOP("utf8", "utf8"),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.SpellingInconsistencies(compiler.SeverityIgnore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both kinds would become KindIDUtf8 in the generated code.
+	_, err = GenLexer(clspec, "main")
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+}
+
+func TestGenLexer_LineDirectives(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := GenLexer(clspec, "main", GenLineDirectives("test_lexer.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each directive must refer to the line following it.
+	coreLines := strings.Split(lexerCoreSrc, "\n")
+	lines := strings.Split(string(src), "\n")
+	var coreDirectiveCount, fileDirectiveCount int
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "//line ") {
+			continue
+		}
+		sep := strings.LastIndex(line, ":")
+		fileName := line[len("//line "):sep]
+		lineNum, err := strconv.Atoi(line[sep+1:])
+		if err != nil {
+			t.Fatalf("invalid directive: %v", line)
+		}
+		switch fileName {
+		case "lexer.go":
+			if coreLines[lineNum-1] != lines[i+1] {
+				t.Fatalf("%v refers to an unexpected line; want: %q, got: %q", line, lines[i+1], coreLines[lineNum-1])
+			}
+			coreDirectiveCount++
+		case "test_lexer.go":
+			if lineNum != i+2 {
+				t.Fatalf("%v refers to an unexpected line; want: %v", line, i+2)
+			}
+			fileDirectiveCount++
+		default:
+			t.Fatalf("unexpected directive: %v", line)
+		}
+	}
+	if coreDirectiveCount == 0 || fileDirectiveCount != 1 {
+		t.Fatalf("unexpected number of directives; lexer.go: %v, test_lexer.go: %v", coreDirectiveCount, fileDirectiveCount)
+	}
+
+	// A stack trace of a panic in the driver code refers to the line of lexer.go.
+	var callLine int
+	for i, line := range coreLines {
+		if strings.HasPrefix(line, "func (l *Lexer) Next() ") {
+			callLine = i + 2
+			break
+		}
+	}
+	if strings.TrimSpace(coreLines[callLine-1]) != "err := l.fill(1)" {
+		t.Fatalf("Lexer.Next has changed; update this test: %q", coreLines[callLine-1])
+	}
+	mainSrc := `package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+func main() {
+	defer func() {
+		recover()
+		pcs := make([]uintptr, 32)
+		frames := runtime.CallersFrames(pcs[:runtime.Callers(0, pcs)])
+		for {
+			f, more := frames.Next()
+			if strings.HasSuffix(f.Function, ".(*Lexer).Next") {
+				fmt.Printf("%v:%v\n", filepath.Base(f.File), f.Line)
+			}
+			if !more {
+				break
+			}
+		}
+	}()
+	var lex *Lexer
+	lex.Next()
+}
+`
+	out := runGeneratedLexer(t, clspec, mainSrc, nil, GenLineDirectives("lexer.go"))
+	if out != fmt.Sprintf("lexer.go:%v\n", callLine) {
+		t.Fatalf("unexpected stack trace; want: lexer.go:%v, got: %v", callLine, out)
+	}
+}
+
+func TestGenLexer_BuildConstraintAndHeader(t *testing.T) {
+	lspec := &spec.LexSpec{
+		Name: "test",
+		Entries: []*spec.LexEntry{
+			newLexEntryDefaultNOP("word", `[a-z]+`),
+		},
+	}
+	clspec, err, _ := compiler.Compile(lspec, compiler.CompressionLevel(compiler.CompressionLevelMax))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []GenLexerOption{
+		GenBuildConstraint("maleeni_test && !maleeni_test_off"),
+		GenHeader("Copyright 2026 The Authors.\n\n// SPDX-License-Identifier: MIT\n"),
+	}
+	lexerSrc, err := GenLexer(clspec, "lexer", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	benchSrc, err := GenBenchmark("lexer", []byte("foo"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPrologue := `// Copyright 2026 The Authors.
+//
+// SPDX-License-Identifier: MIT
+
+//go:build maleeni_test && !maleeni_test_off
+
+// Code generated by maleeni-go. DO NOT EDIT.
+package lexer
+`
+	dir := t.TempDir()
+	for name, src := range map[string][]byte{
+		"lexer.go":            lexerSrc,
+		"lexer_bench_test.go": benchSrc,
+	} {
+		if !strings.HasPrefix(string(src), expectedPrologue) {
+			t.Fatalf("%v has an unexpected prologue:\n%v", name, string(src)[:len(expectedPrologue)])
+		}
+
+		err := os.WriteFile(filepath.Join(dir, name), src, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := build.Default
+		match, err := ctx.MatchFile(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match {
+			t.Fatalf("%v must not match without the build tag", name)
+		}
+		ctx.BuildTags = []string{"maleeni_test"}
+		match, err = ctx.MatchFile(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !match {
+			t.Fatalf("%v must match with the build tag", name)
+		}
+	}
+
+	_, err = GenLexer(clspec, "lexer", GenBuildConstraint("foo &&"))
+	if err == nil {
+		t.Fatal("expected error didn't occur")
+	}
+}
@@ -2,4 +2,8 @@ module github.com/nihei9/maleeni
 
 go 1.16
 
-require github.com/spf13/cobra v1.1.3
+require (
+	github.com/spf13/cobra v1.1.3
+	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v3 v3.0.1
+)
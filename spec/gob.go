@@ -0,0 +1,32 @@
+package spec
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// EncodeCompiledLexSpecGob writes a compiled lexical specification in gob format. Gob format is more compact and faster
+// to decode than JSON.
+func EncodeCompiledLexSpecGob(w io.Writer, clspec *CompiledLexSpec) error {
+	// gob cannot encode nil elements in a slice of pointers, so we omit the nil mode spec at index 0 (LexModeIDNil).
+	c := *clspec
+	c.Specs = clspec.Specs[LexModeIDNil.Int()+1:]
+	return gob.NewEncoder(w).Encode(&c)
+}
+
+// DecodeCompiledLexSpecGob reads a compiled lexical specification written by EncodeCompiledLexSpecGob.
+func DecodeCompiledLexSpecGob(r io.Reader) (*CompiledLexSpec, error) {
+	clspec := &CompiledLexSpec{}
+	err := gob.NewDecoder(r).Decode(clspec)
+	if err != nil {
+		return nil, err
+	}
+	clspec.Specs = append([]*CompiledLexModeSpec{nil}, clspec.Specs...)
+	// gob decodes a nil map in a slice as an empty map, but a kind without metadata must have nil.
+	for i, meta := range clspec.KindMeta {
+		if len(meta) == 0 {
+			clspec.KindMeta[i] = nil
+		}
+	}
+	return clspec, nil
+}
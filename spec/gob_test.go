@@ -0,0 +1,60 @@
+package spec
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCompiledLexSpecGob_KindMeta(t *testing.T) {
+	clspec := &CompiledLexSpec{
+		FormatVersion: CompiledLexSpecFormatVersion,
+		Name:          "test",
+		KindNames: []LexKindName{
+			LexKindNameNil,
+			"foo",
+			"bar",
+			"baz",
+		},
+		Specs: []*CompiledLexModeSpec{
+			nil,
+			{
+				KindNames: []LexKindName{
+					LexKindNameNil,
+					"foo",
+					"bar",
+					"baz",
+				},
+			},
+		},
+		KindMeta: []map[string]string{
+			nil,
+			nil,
+			{
+				"category": "keyword",
+			},
+			nil,
+		},
+	}
+
+	var b bytes.Buffer
+	err := EncodeCompiledLexSpecGob(&b, clspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeCompiledLexSpecGob(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.KindMeta, clspec.KindMeta) {
+		t.Fatalf("unexpected metadata; want: %#v, got: %#v", clspec.KindMeta, decoded.KindMeta)
+	}
+	for i, meta := range decoded.KindMeta {
+		if i != 2 && meta != nil {
+			t.Fatalf("the metadata of a kind without metadata must be nil: %v: %#v", i, meta)
+		}
+	}
+	if decoded.Specs[LexModeIDNil] != nil {
+		t.Fatalf("the nil mode spec must be nil")
+	}
+}
@@ -0,0 +1,144 @@
+package spec
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LexMacroName represents a name of a macro.
+type LexMacroName string
+
+func (m LexMacroName) String() string {
+	return string(m)
+}
+
+func (m LexMacroName) validate() error {
+	err := validateIdentifier(m.String())
+	if err != nil {
+		return fmt.Errorf("invalid macro name: %v", err)
+	}
+	return nil
+}
+
+// macroRefRE matches a macro reference `${name}` or an escape sequence. Matching escape sequences as a whole prevents
+// an escaped `\${` from being treated as a reference, while `\\${name}`, an escaped backslash followed by a reference,
+// still contains the reference.
+var macroRefRE = regexp.MustCompile(`\\(?s:.)|\$\{([^}]*)\}`)
+
+// sortedMacroNames returns the names of macros in ascending order so that errors about macros are deterministic.
+func sortedMacroNames(macros map[LexMacroName]LexPattern) []LexMacroName {
+	var names []LexMacroName
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func validateMacros(macros map[LexMacroName]LexPattern) error {
+	var errs []error
+	for _, name := range sortedMacroNames(macros) {
+		err := name.validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("macro `%v`: %w", name, err))
+			continue
+		}
+		err = macros[name].validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("macro `%v`: %w", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%v", errs[0])
+		for _, err := range errs[1:] {
+			fmt.Fprintf(&b, "\n%v", err)
+		}
+		return fmt.Errorf(b.String())
+	}
+	return nil
+}
+
+// ExpandMacros returns copies of the entries whose macro references `${name}` are replaced with the patterns of
+// the macros. Unlike fragments, macros are expanded textually before the patterns are parsed, so a macro can contain
+// a part of a pattern, such as a repetition operator. Macros can reference other macros, but they are not allowed to
+// contain circular references. Literal patterns are copied as they are. An escaped `\${` is not a reference, so
+// a pattern that EscapePattern returns stays as it is. ExpandMacros expands macros in order of their names, so when
+// macros contain multiple errors, it always reports the same one.
+func (s *LexSpec) ExpandMacros() ([]*LexEntry, error) {
+	expanded := map[LexMacroName]LexPattern{}
+	for _, name := range sortedMacroNames(s.Macros) {
+		_, err := expandMacro(name, s.Macros, expanded, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]*LexEntry, len(s.Entries))
+	for i, e := range s.Entries {
+		// A literal pattern never contains macro references.
+		if e.Literal {
+			c := *e
+			entries[i] = &c
+			continue
+		}
+		pat, err := replaceMacroRefs(e.Pattern, s.Macros, expanded, nil)
+		if err != nil {
+			return nil, fmt.Errorf("entry #%v: %w", i+1, err)
+		}
+		c := *e
+		c.Pattern = pat
+		entries[i] = &c
+	}
+	return entries, nil
+}
+
+func expandMacro(name LexMacroName, macros map[LexMacroName]LexPattern, expanded map[LexMacroName]LexPattern, visiting []LexMacroName) (LexPattern, error) {
+	if pat, ok := expanded[name]; ok {
+		return pat, nil
+	}
+	for i, v := range visiting {
+		if v == name {
+			var b strings.Builder
+			for _, n := range visiting[i:] {
+				fmt.Fprintf(&b, "%v -> ", n)
+			}
+			fmt.Fprintf(&b, "%v", name)
+			return "", fmt.Errorf("macros contain a cycle: %v", b.String())
+		}
+	}
+	pat, ok := macros[name]
+	if !ok {
+		return "", fmt.Errorf("undefined macro: %v", name)
+	}
+	pat, err := replaceMacroRefs(pat, macros, expanded, append(visiting, name))
+	if err != nil {
+		return "", err
+	}
+	expanded[name] = pat
+	return pat, nil
+}
+
+func replaceMacroRefs(pat LexPattern, macros map[LexMacroName]LexPattern, expanded map[LexMacroName]LexPattern, visiting []LexMacroName) (LexPattern, error) {
+	var err error
+	replaced := macroRefRE.ReplaceAllStringFunc(pat.String(), func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		if strings.HasPrefix(ref, "\\") {
+			return ref
+		}
+		name := LexMacroName(macroRefRE.FindStringSubmatch(ref)[1])
+		var p LexPattern
+		p, err = expandMacro(name, macros, expanded, visiting)
+		return p.String()
+	})
+	if err != nil {
+		return "", err
+	}
+	return LexPattern(replaced), nil
+}
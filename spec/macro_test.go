@@ -0,0 +1,290 @@
+package spec
+
+import (
+	"testing"
+)
+
+func TestLexSpec_ExpandMacros(t *testing.T) {
+	tests := []struct {
+		caption  string
+		macros   map[LexMacroName]LexPattern
+		patterns []LexPattern
+		expanded []LexPattern
+		err      bool
+	}{
+		{
+			caption: "a pattern can contain macros",
+			macros: map[LexMacroName]LexPattern{
+				"digit": "[0-9]",
+			},
+			patterns: []LexPattern{
+				"${digit}+",
+				"${digit}${digit}",
+				"[a-z]+",
+			},
+			expanded: []LexPattern{
+				"[0-9]+",
+				"[0-9][0-9]",
+				"[a-z]+",
+			},
+		},
+		{
+			caption: "a macro can contain a part of a pattern",
+			macros: map[LexMacroName]LexPattern{
+				"some": "*",
+			},
+			patterns: []LexPattern{
+				"a${some}",
+			},
+			expanded: []LexPattern{
+				"a*",
+			},
+		},
+		{
+			caption: "macros can be nested",
+			macros: map[LexMacroName]LexPattern{
+				"digit":   "[0-9]",
+				"digits":  "${digit}+",
+				"decimal": "${digits}\\.${digits}",
+			},
+			patterns: []LexPattern{
+				"${decimal}",
+			},
+			expanded: []LexPattern{
+				"[0-9]+\\.[0-9]+",
+			},
+		},
+		{
+			caption: "an escaped ${ is not a macro reference",
+			macros: map[LexMacroName]LexPattern{
+				"a": "x",
+			},
+			patterns: []LexPattern{
+				"\\${a}",
+				"\\\\${a}",
+				"\\\\\\${b}",
+				"[\\${]",
+			},
+			expanded: []LexPattern{
+				"\\${a}",
+				"\\\\x",
+				"\\\\\\${b}",
+				"[\\${]",
+			},
+		},
+		{
+			caption: "a macro cannot reference itself",
+			macros: map[LexMacroName]LexPattern{
+				"a": "a${a}",
+			},
+			patterns: []LexPattern{
+				"${a}",
+			},
+			err: true,
+		},
+		{
+			caption: "macros cannot contain cycles",
+			macros: map[LexMacroName]LexPattern{
+				"a": "a${b}",
+				"b": "b${c}",
+				"c": "c${a}",
+			},
+			patterns: []LexPattern{
+				"x",
+			},
+			err: true,
+		},
+		{
+			caption: "a pattern cannot contain undefined macros",
+			macros: map[LexMacroName]LexPattern{
+				"a": "a",
+			},
+			patterns: []LexPattern{
+				"${b}",
+			},
+			err: true,
+		},
+		{
+			caption: "a macro cannot contain undefined macros",
+			macros: map[LexMacroName]LexPattern{
+				"a": "${b}",
+			},
+			patterns: []LexPattern{
+				"x",
+			},
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			s := &LexSpec{
+				Name:   "test",
+				Macros: tt.macros,
+			}
+			for _, p := range tt.patterns {
+				s.Entries = append(s.Entries, &LexEntry{
+					Kind:    "test",
+					Pattern: p,
+				})
+			}
+			entries, err := s.ExpandMacros()
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error didn't occur")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+			if len(entries) != len(tt.expanded) {
+				t.Fatalf("unexpected entry count: want: %v, got: %v", len(tt.expanded), len(entries))
+			}
+			for i, e := range entries {
+				if e.Pattern != tt.expanded[i] {
+					t.Errorf("unexpected pattern: want: %v, got: %v", tt.expanded[i], e.Pattern)
+				}
+				if s.Entries[i].Pattern != tt.patterns[i] {
+					t.Errorf("ExpandMacros must not modify the original entries")
+				}
+			}
+		})
+	}
+}
+
+func TestLexSpec_ExpandMacros_Error(t *testing.T) {
+	tests := []struct {
+		caption string
+		macros  map[LexMacroName]LexPattern
+		err     string
+	}{
+		{
+			caption: "ExpandMacros reports the cycle containing the smallest macro name",
+			macros: map[LexMacroName]LexPattern{
+				"d": "${c}",
+				"c": "${d}",
+				"b": "${a}",
+				"a": "${b}",
+			},
+			err: "macros contain a cycle: a -> b -> a",
+		},
+		{
+			caption: "ExpandMacros reports the undefined macro that the smallest macro name references",
+			macros: map[LexMacroName]LexPattern{
+				"b": "${undefined_a}",
+				"a": "${undefined_b}",
+			},
+			err: "undefined macro: undefined_b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			s := &LexSpec{
+				Name:   "test",
+				Macros: tt.macros,
+				Entries: []*LexEntry{
+					{
+						Kind:    "test",
+						Pattern: "x",
+					},
+				},
+			}
+			// The iteration order of maps varies from run to run, so repeat the expansion.
+			for i := 0; i < 20; i++ {
+				_, err := s.ExpandMacros()
+				if err == nil {
+					t.Fatalf("expected error didn't occur")
+				}
+				if err.Error() != tt.err {
+					t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLexSpec_Validate_Macros(t *testing.T) {
+	tests := []struct {
+		caption string
+		macros  map[LexMacroName]LexPattern
+		err     bool
+	}{
+		{
+			caption: "valid macros",
+			macros: map[LexMacroName]LexPattern{
+				"digit": "[0-9]",
+			},
+		},
+		{
+			caption: "a macro name must be an identifier",
+			macros: map[LexMacroName]LexPattern{
+				"Digit": "[0-9]",
+			},
+			err: true,
+		},
+		{
+			caption: "a macro cannot be empty",
+			macros: map[LexMacroName]LexPattern{
+				"digit": "",
+			},
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			s := &LexSpec{
+				Name:   "test",
+				Macros: tt.macros,
+				Entries: []*LexEntry{
+					{
+						Kind:    "test",
+						Pattern: "x",
+					},
+				},
+			}
+			err := s.Validate()
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error didn't occur")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error occurred: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLexSpec_ExpandMacros_EscapedPattern(t *testing.T) {
+	s := &LexSpec{
+		Name: "test",
+		Macros: map[LexMacroName]LexPattern{
+			"a": "x",
+		},
+	}
+	var literals []string
+	for _, l := range []string{"${a}", "${b}", "\\${a}", "$${a}}"} {
+		literals = append(literals, l)
+		s.Entries = append(s.Entries, &LexEntry{
+			Kind:    "test",
+			Pattern: LexPattern(EscapePattern(l)),
+		})
+	}
+	entries, err := s.ExpandMacros()
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+	for i, e := range entries {
+		if e.Pattern != s.Entries[i].Pattern {
+			t.Errorf("an escaped pattern must stay as it is; want: %v, got: %v", s.Entries[i].Pattern, e.Pattern)
+		}
+		l, err := UnescapePattern(e.Pattern.String())
+		if err != nil {
+			t.Fatalf("unexpected error occurred: %v", err)
+		}
+		if l != literals[i] {
+			t.Errorf("unexpected literal; want: %v, got: %v", literals[i], l)
+		}
+	}
+}
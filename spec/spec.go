@@ -54,6 +54,10 @@ func (k LexKindName) validate() error {
 // The pattern is written in regular expression.
 type LexPattern string
 
+func (p LexPattern) String() string {
+	return string(p)
+}
+
 func (p LexPattern) validate() error {
 	if p == "" {
 		return fmt.Errorf("pattern doesn't allow to be the empty string")
@@ -128,12 +132,27 @@ func SnakeCaseToUpperCamelCase(snake string) string {
 }
 
 type LexEntry struct {
-	Kind     LexKindName   `json:"kind"`
-	Pattern  LexPattern    `json:"pattern"`
-	Modes    []LexModeName `json:"modes"`
-	Push     LexModeName   `json:"push"`
-	Pop      bool          `json:"pop"`
-	Fragment bool          `json:"fragment"`
+	Kind     LexKindName   `json:"kind" yaml:"kind"`
+	Pattern  LexPattern    `json:"pattern" yaml:"pattern"`
+	Modes    []LexModeName `json:"modes" yaml:"modes"`
+	Push     LexModeName   `json:"push" yaml:"push"`
+	Pop      bool          `json:"pop" yaml:"pop"`
+	Fragment bool          `json:"fragment" yaml:"fragment"`
+	Literal  bool          `json:"literal" yaml:"literal"`
+	Skip     bool          `json:"skip" yaml:"skip"`
+
+	// Emit makes a fragment a kind as well. Other patterns can refer to the fragment, and the lexer also returns tokens
+	// of the fragment like tokens of a non-fragment entry. Only a fragment can set this field.
+	Emit bool `json:"emit" yaml:"emit"`
+
+	// Meta is user-defined metadata of a kind. The driver doesn't interpret it, and you can read it from tokens of
+	// the kind.
+	Meta map[string]string `json:"meta" yaml:"meta"`
+
+	// Priority decides which kind the lexer chooses when patterns of multiple kinds match the same lexeme. The smaller
+	// the value, the higher the priority. When this field is nil, the priority of the n-th entry is n, so an entry
+	// written earlier takes precedence. Entries having the same priority are ordered by their positions.
+	Priority *int `json:"priority,omitempty" yaml:"priority,omitempty"`
 }
 
 func (e *LexEntry) validate() error {
@@ -145,6 +164,36 @@ func (e *LexEntry) validate() error {
 	if err != nil {
 		return err
 	}
+	// A fragment exists to be referenced from other patterns, so its pattern must be a regular expression.
+	if e.Literal && e.Fragment {
+		return fmt.Errorf("a fragment cannot be a literal")
+	}
+	if e.Emit && !e.Fragment {
+		return fmt.Errorf("only a fragment can be emitted")
+	}
+	// A fragment never becomes a token unless it is emitted, so there is nothing to skip.
+	if e.Skip && e.Fragment && !e.Emit {
+		return fmt.Errorf("a fragment cannot be skipped")
+	}
+	if e.Priority != nil && e.Fragment && !e.Emit {
+		return fmt.Errorf("a fragment cannot have a priority")
+	}
+	if len(e.Meta) > 0 {
+		if e.Fragment && !e.Emit {
+			return fmt.Errorf("a fragment cannot have metadata")
+		}
+		var keys []string
+		for k := range e.Meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			err := validateIdentifier(k)
+			if err != nil {
+				return fmt.Errorf("invalid metadata key: %v", err)
+			}
+		}
+	}
 	if len(e.Modes) > 0 {
 		for _, mode := range e.Modes {
 			err = mode.validate()
@@ -157,16 +206,73 @@ func (e *LexEntry) validate() error {
 }
 
 type LexSpec struct {
-	Name    string      `json:"name"`
-	Entries []*LexEntry `json:"entries"`
+	Name    string                      `json:"name" yaml:"name"`
+	Macros  map[LexMacroName]LexPattern `json:"macros" yaml:"macros"`
+	Entries []*LexEntry                 `json:"entries" yaml:"entries"`
+
+	// InitialMode is a mode that the lexer starts in. When this field is empty, the lexer starts in the default mode.
+	InitialMode LexModeName `json:"initial_mode,omitempty" yaml:"initial_mode,omitempty"`
+
+	// ErrorKinds maps modes to kinds that the lexer assigns to error tokens in the modes. An error kind has no pattern,
+	// so only error tokens have the kind. In a mode without an error kind, error tokens have no kind.
+	ErrorKinds map[LexModeName]LexKindName `json:"error_kinds,omitempty" yaml:"error_kinds,omitempty"`
+
+	// EOFKind is a kind that the lexer assigns to the EOF token in all modes. Like an error kind, the EOF kind has no
+	// pattern, so only the EOF token has the kind. The EOF token still has EOF flag. When this field is empty, the EOF
+	// token has no kind.
+	EOFKind LexKindName `json:"eof_kind,omitempty" yaml:"eof_kind,omitempty"`
+
+	// Include lists paths of other specification files whose entries and macros are merged into this specification.
+	// Relative paths are relative to the directory of the including file. The compiler doesn't read this field; a loader
+	// like `maleeni compile` command merges the files and clears it.
+	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
+
+	// NoImplicitDefault makes an entry with empty Modes invalid instead of enabling it in the default mode. It helps to
+	// keep a specification with many modes from putting entries in the default mode by mistake. Fragments that aren't
+	// emitted have no modes, so this field doesn't apply to them.
+	NoImplicitDefault bool `json:"no_implicit_default,omitempty" yaml:"no_implicit_default,omitempty"`
+
+	// StartConditions enables start conditions, a syntax sugar that writes the modes of an entry at the beginning of
+	// its pattern like `<mode1,mode2>pattern`. Like Include, the compiler doesn't read this field; a loader calls
+	// ExtractStartConditions before validating the specification.
+	StartConditions bool `json:"start_conditions,omitempty" yaml:"start_conditions,omitempty"`
+}
+
+// ValidateOption customizes the checks that LexSpec.Validate performs.
+type ValidateOption func(c *validateConfig)
+
+// SkipSpellingCheck makes LexSpec.Validate skip the check of spelling inconsistencies among kind names and among mode
+// names. Use LexSpec.SpellingInconsistencies to find them separately.
+func SkipSpellingCheck() ValidateOption {
+	return func(c *validateConfig) {
+		c.skipSpellingCheck = true
+	}
 }
 
-func (s *LexSpec) Validate() error {
+type validateConfig struct {
+	skipSpellingCheck bool
+}
+
+func (s *LexSpec) Validate(opts ...ValidateOption) error {
+	config := &validateConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
 	err := validateIdentifier(s.Name)
 	if err != nil {
 		return fmt.Errorf("invalid specification name: %v", err)
 	}
 
+	if s.StartConditions {
+		return fmt.Errorf("start conditions must be extracted using ExtractStartConditions before validation")
+	}
+
+	err = validateMacros(s.Macros)
+	if err != nil {
+		return err
+	}
+
 	if len(s.Entries) <= 0 {
 		return fmt.Errorf("the lexical specification must have at least one entry")
 	}
@@ -187,17 +293,51 @@ func (s *LexSpec) Validate() error {
 			return fmt.Errorf(b.String())
 		}
 	}
+	if s.NoImplicitDefault {
+		var errs []error
+		for i, e := range s.Entries {
+			if e.Fragment && !e.Emit {
+				continue
+			}
+			if len(e.Modes) == 0 {
+				errs = append(errs, fmt.Errorf("entry #%v: kind `%v` must have modes because no_implicit_default is enabled", i+1, e.Kind))
+			}
+		}
+		if len(errs) > 0 {
+			var b strings.Builder
+			fmt.Fprintf(&b, "%v", errs[0])
+			for _, err := range errs[1:] {
+				fmt.Fprintf(&b, "\n%v", err)
+			}
+			return fmt.Errorf(b.String())
+		}
+	}
+	{
+		// Fragments that aren't emitted never become tokens, so a specification consisting only of them has no kinds.
+		hasKind := false
+		for _, e := range s.Entries {
+			if !e.Fragment || e.Emit {
+				hasKind = true
+				break
+			}
+		}
+		if !hasKind {
+			return fmt.Errorf("the lexical specification must have at least one entry that isn't a fragment or is an emitted fragment")
+		}
+	}
 	{
 		ks := map[string]struct{}{}
 		fks := map[string]struct{}{}
 		for _, e := range s.Entries {
-			// Allow duplicate names between fragments and non-fragments.
+			// Allow duplicate names between fragments and non-fragments. An emitted fragment is also a kind, so its name
+			// must not collide with the names of non-fragments.
 			if e.Fragment {
 				if _, exist := fks[e.Kind.String()]; exist {
 					return fmt.Errorf("kinds `%v` are duplicates", e.Kind)
 				}
 				fks[e.Kind.String()] = struct{}{}
-			} else {
+			}
+			if !e.Fragment || e.Emit {
 				if _, exist := ks[e.Kind.String()]; exist {
 					return fmt.Errorf("kinds `%v` are duplicates", e.Kind)
 				}
@@ -205,49 +345,241 @@ func (s *LexSpec) Validate() error {
 			}
 		}
 	}
-	{
-		kinds := []string{}
-		modes := []string{
-			LexModeNameDefault.String(), // This is a predefined mode.
+	if !config.skipSpellingCheck {
+		errs := s.SpellingInconsistencies()
+		if len(errs) > 0 {
+			var b strings.Builder
+			fmt.Fprintf(&b, "%v", errs[0])
+			for _, err := range errs[1:] {
+				fmt.Fprintf(&b, "\n%v", err)
+			}
+			return fmt.Errorf(b.String())
 		}
+	}
+	if s.InitialMode != "" {
+		err := s.InitialMode.validate()
+		if err != nil {
+			return fmt.Errorf("invalid initial mode: %v", err)
+		}
+		defined := s.InitialMode == LexModeNameDefault
 		for _, e := range s.Entries {
-			if e.Fragment {
+			if e.Fragment && !e.Emit {
 				continue
 			}
+			for _, m := range e.Modes {
+				if m == s.InitialMode {
+					defined = true
+				}
+			}
+		}
+		if !defined {
+			return fmt.Errorf("initial mode `%v` is undefined", s.InitialMode)
+		}
+	}
+	err = validateModeTransitions(s)
+	if err != nil {
+		return err
+	}
+	err = validateErrorKinds(s)
+	if err != nil {
+		return err
+	}
+	err = validateEOFKind(s)
+	if err != nil {
+		return err
+	}
 
-			kinds = append(kinds, e.Kind.String())
+	return nil
+}
 
-			for _, m := range e.Modes {
-				modes = append(modes, m.String())
+// validateEOFKind checks that the name of the EOF kind differs from the kinds of the entries and the error kinds. The
+// EOF kind belongs to all modes, so it must not collide with any kind of any mode.
+func validateEOFKind(s *LexSpec) error {
+	if s.EOFKind == "" {
+		return nil
+	}
+	err := s.EOFKind.validate()
+	if err != nil {
+		return fmt.Errorf("invalid EOF kind: %v", err)
+	}
+	for _, e := range s.Entries {
+		if e.Fragment && !e.Emit {
+			continue
+		}
+		if e.Kind == s.EOFKind {
+			return fmt.Errorf("EOF kind `%v` duplicates a kind of an entry", s.EOFKind)
+		}
+	}
+	for mode, kind := range s.ErrorKinds {
+		if kind == s.EOFKind {
+			return fmt.Errorf("EOF kind `%v` duplicates the error kind of mode `%v`", s.EOFKind, mode)
+		}
+	}
+	return nil
+}
+
+// validateErrorKinds checks that every error kind belongs to a mode having entries and that its name differs from the
+// kinds of the entries in the mode.
+func validateErrorKinds(s *LexSpec) error {
+	modeKinds := map[LexModeName]map[LexKindName]struct{}{}
+	for _, e := range s.Entries {
+		if e.Fragment && !e.Emit {
+			continue
+		}
+		modes := e.Modes
+		if len(modes) == 0 {
+			modes = []LexModeName{LexModeNameDefault}
+		}
+		for _, m := range modes {
+			if modeKinds[m] == nil {
+				modeKinds[m] = map[LexKindName]struct{}{}
 			}
+			modeKinds[m][e.Kind] = struct{}{}
 		}
+	}
 
-		kindErrs := findSpellingInconsistenciesErrors(kinds, nil)
-		modeErrs := findSpellingInconsistenciesErrors(modes, func(ids []string) error {
-			if SnakeCaseToUpperCamelCase(ids[0]) == SnakeCaseToUpperCamelCase(LexModeNameDefault.String()) {
-				var b strings.Builder
-				fmt.Fprintf(&b, "%+v", ids[0])
-				for _, id := range ids[1:] {
-					fmt.Fprintf(&b, ", %+v", id)
+	var modes []string
+	for m := range s.ErrorKinds {
+		modes = append(modes, m.String())
+	}
+	sort.Strings(modes)
+	var errs []error
+	for _, m := range modes {
+		mode := LexModeName(m)
+		kind := s.ErrorKinds[mode]
+		err := mode.validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid error kind: %v", err))
+			continue
+		}
+		err = kind.validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid error kind of mode `%v`: %v", mode, err))
+			continue
+		}
+		kinds, ok := modeKinds[mode]
+		if !ok {
+			errs = append(errs, fmt.Errorf("error kind `%v` belongs to mode `%v`, but no entry belongs to the mode", kind, mode))
+			continue
+		}
+		if _, ok := kinds[kind]; ok {
+			errs = append(errs, fmt.Errorf("error kind `%v` of mode `%v` duplicates a kind of an entry in the mode", kind, mode))
+		}
+	}
+
+	if len(errs) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%v", errs[0])
+		for _, err := range errs[1:] {
+			fmt.Fprintf(&b, "\n%v", err)
+		}
+		return fmt.Errorf(b.String())
+	}
+	return nil
+}
+
+// validateModeTransitions checks that every push target is a mode having entries and that no entry pops the initial
+// mode off the bottom of the mode stack.
+func validateModeTransitions(s *LexSpec) error {
+	defined := map[LexModeName]struct{}{}
+	pushed := map[LexModeName]struct{}{}
+	for _, e := range s.Entries {
+		if e.Fragment && !e.Emit {
+			continue
+		}
+		if len(e.Modes) == 0 {
+			defined[LexModeNameDefault] = struct{}{}
+		}
+		for _, m := range e.Modes {
+			defined[m] = struct{}{}
+		}
+		if e.Push != "" {
+			pushed[e.Push] = struct{}{}
+		}
+	}
+
+	var errs []error
+	for _, e := range s.Entries {
+		if (e.Fragment && !e.Emit) || e.Push == "" {
+			continue
+		}
+		if _, ok := defined[e.Push]; !ok {
+			errs = append(errs, fmt.Errorf("kind `%v` pushes mode `%v`, but no entry belongs to the mode", e.Kind, e.Push))
+		}
+	}
+
+	// The lexer starts with the initial mode on the mode stack. Unless some entry pushes the initial mode, the initial
+	// mode is always at the bottom of the stack, so popping it empties the stack. An entry that also pushes a mode
+	// replaces the initial mode with the mode, so the stack never stays empty.
+	initialMode := s.InitialMode
+	if initialMode == "" {
+		initialMode = LexModeNameDefault
+	}
+	if _, ok := pushed[initialMode]; !ok {
+		for _, e := range s.Entries {
+			if (e.Fragment && !e.Emit) || !e.Pop || e.Push != "" {
+				continue
+			}
+			inInitialMode := len(e.Modes) == 0 && initialMode == LexModeNameDefault
+			for _, m := range e.Modes {
+				if m == initialMode {
+					inInitialMode = true
 				}
-				return fmt.Errorf("these identifiers are treated as the same. please use the same spelling as predefined '%v': %v", LexModeNameDefault, b.String())
 			}
-			return nil
-		})
-		errs := append(kindErrs, modeErrs...)
-		if len(errs) > 0 {
-			var b strings.Builder
-			fmt.Fprintf(&b, "%v", errs[0])
-			for _, err := range errs[1:] {
-				fmt.Fprintf(&b, "\n%v", err)
+			if inInitialMode {
+				errs = append(errs, fmt.Errorf("kind `%v` pops initial mode `%v`, which would empty the mode stack because no entry pushes the mode", e.Kind, initialMode))
 			}
-			return fmt.Errorf(b.String())
 		}
 	}
 
+	if len(errs) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%v", errs[0])
+		for _, err := range errs[1:] {
+			fmt.Fprintf(&b, "\n%v", err)
+		}
+		return fmt.Errorf(b.String())
+	}
 	return nil
 }
 
+// SpellingInconsistencies returns an error for each group of kind names or mode names that are spelled the same in
+// UpperCamelCase, such as `left_paren` and `LeftParen`. See also FindSpellingInconsistencies.
+func (s *LexSpec) SpellingInconsistencies() []error {
+	kinds := []string{}
+	modes := []string{
+		LexModeNameDefault.String(), // This is a predefined mode.
+	}
+	for _, e := range s.Entries {
+		if e.Fragment && !e.Emit {
+			continue
+		}
+
+		kinds = append(kinds, e.Kind.String())
+
+		for _, m := range e.Modes {
+			modes = append(modes, m.String())
+		}
+	}
+	if s.EOFKind != "" {
+		kinds = append(kinds, s.EOFKind.String())
+	}
+
+	kindErrs := findSpellingInconsistenciesErrors(kinds, nil)
+	modeErrs := findSpellingInconsistenciesErrors(modes, func(ids []string) error {
+		if SnakeCaseToUpperCamelCase(ids[0]) == SnakeCaseToUpperCamelCase(LexModeNameDefault.String()) {
+			var b strings.Builder
+			fmt.Fprintf(&b, "%+v", ids[0])
+			for _, id := range ids[1:] {
+				fmt.Fprintf(&b, ", %+v", id)
+			}
+			return fmt.Errorf("these identifiers are treated as the same. please use the same spelling as predefined '%v': %v", LexModeNameDefault, b.String())
+		}
+		return nil
+	})
+	return append(kindErrs, modeErrs...)
+}
+
 func findSpellingInconsistenciesErrors(ids []string, hook func(ids []string) error) []error {
 	duplicated := FindSpellingInconsistencies(ids)
 	if len(duplicated) == 0 {
@@ -355,9 +687,65 @@ type UniqueEntriesTable struct {
 	EmptyValue                int                   `json:"empty_value"`
 }
 
+// intByteSize is the size of int and StateID in bytes.
+const intByteSize = strconv.IntSize / 8
+
+// ByteSize returns the size of the entries, bounds, displacements, and row numbers that a table holds in bytes.
+func (t *UniqueEntriesTable) ByteSize() int {
+	n := len(t.UncompressedUniqueEntries) + len(t.RowNums)
+	if t.UniqueEntries != nil {
+		n += len(t.UniqueEntries.Entries) + len(t.UniqueEntries.Bounds) + len(t.UniqueEntries.RowDisplacement)
+	}
+	return n * intByteSize
+}
+
+// LexAnchor represents zero-width assertions that a lexeme must satisfy. A value of this type is a set of flags.
+type LexAnchor int
+
+const (
+	// LexAnchorNil means a pattern has no anchors.
+	LexAnchorNil = LexAnchor(0)
+
+	// LexAnchorLineStart means a lexeme must appear at the beginning of a line.
+	LexAnchorLineStart = LexAnchor(1 << 0)
+
+	// LexAnchorLineEnd means a lexeme must be followed by LF or EOF.
+	LexAnchorLineEnd = LexAnchor(1 << 1)
+
+	// LexAnchorWordBoundaryStart means a lexeme must begin at a word boundary. A word boundary is a position between a
+	// word character and a non-word character, where the word characters are ASCII letters, digits, and `_`, and the
+	// beginning and end of the source count as non-word characters.
+	LexAnchorWordBoundaryStart = LexAnchor(1 << 2)
+
+	// LexAnchorWordBoundaryEnd means a lexeme must end at a word boundary.
+	LexAnchorWordBoundaryEnd = LexAnchor(1 << 3)
+
+	// LexAnchorNonWordBoundaryStart means a lexeme must not begin at a word boundary.
+	LexAnchorNonWordBoundaryStart = LexAnchor(1 << 4)
+
+	// LexAnchorNonWordBoundaryEnd means a lexeme must not end at a word boundary.
+	LexAnchorNonWordBoundaryEnd = LexAnchor(1 << 5)
+)
+
+func (a LexAnchor) Int() int {
+	return int(a)
+}
+
 type TransitionTable struct {
-	InitialStateID         StateID             `json:"initial_state_id"`
-	AcceptingStates        []LexModeKindID     `json:"accepting_states"`
+	InitialStateID  StateID         `json:"initial_state_id"`
+	AcceptingStates []LexModeKindID `json:"accepting_states"`
+
+	// AcceptingCandidates holds all kinds that each state accepts in descending order of priority. This table
+	// has entries only for states accepting multiple kinds, and the driver refers to it only when a kind has
+	// anchors. When a lexeme doesn't satisfy the anchors of the kind in AcceptingStates, the driver falls back
+	// to the next candidate.
+	AcceptingCandidates [][]LexModeKindID `json:"accepting_candidates,omitempty"`
+
+	// PartialKinds holds, for each state, the kind with the highest priority among the kinds that the DFA can still
+	// accept after reaching the state. The driver reports it as a hint of an error token. The entry of the initial
+	// state is nil because the lexer hasn't read any part of a lexeme there.
+	PartialKinds []LexModeKindID `json:"partial_kinds,omitempty"`
+
 	RowCount               int                 `json:"row_count"`
 	ColCount               int                 `json:"col_count"`
 	Transition             *UniqueEntriesTable `json:"transition,omitempty"`
@@ -365,13 +753,72 @@ type TransitionTable struct {
 }
 
 type CompiledLexModeSpec struct {
-	KindNames []LexKindName    `json:"kind_names"`
-	Push      []LexModeID      `json:"push"`
-	Pop       []int            `json:"pop"`
-	DFA       *TransitionTable `json:"dfa"`
+	KindNames []LexKindName `json:"kind_names"`
+	Push      []LexModeID   `json:"push"`
+	Pop       []int         `json:"pop"`
+
+	// Anchors holds the anchors of each kind. When no kinds in a mode have anchors, this field is nil.
+	Anchors []LexAnchor `json:"anchors,omitempty"`
+
+	// Skip holds 1 for kinds that the driver skips and 0 for the others. When no kinds in a mode are skipped,
+	// this field is nil.
+	Skip []int `json:"skip,omitempty"`
+
+	// ErrorKind is a kind that the driver assigns to error tokens in the mode. The error kind is always the last kind
+	// of the mode, and no state accepts it. When the mode has no error kind, this field is LexModeKindIDNil.
+	ErrorKind LexModeKindID `json:"error_kind,omitempty"`
+
+	// EOFKind is a kind that the driver assigns to the EOF token in the mode. The EOF kind follows the error kind, and
+	// no state accepts it. When the specification has no EOF kind, this field is LexModeKindIDNil.
+	EOFKind LexModeKindID `json:"eof_kind,omitempty"`
+
+	DFA *TransitionTable `json:"dfa"`
+
+	// InputHash is a digest of the inputs that the compiler built this mode from. The compiler reuses a cached mode
+	// whose hash equals the hash of the current inputs instead of building its DFA again.
+	InputHash string `json:"input_hash,omitempty"`
+
+	// Warnings holds the warnings that the compiler reported while building this mode. The compiler reports them
+	// again when it reuses this mode as a cache.
+	Warnings []*CompiledLexModeWarning `json:"warnings,omitempty"`
 }
 
+// CompiledLexModeWarning is a warning about a kind in a mode that the compiler stores in a compiled specification.
+type CompiledLexModeWarning struct {
+	Kind     LexKindName `json:"kind,omitempty"`
+	Fragment bool        `json:"fragment,omitempty"`
+	Cause    string      `json:"cause"`
+	Detail   string      `json:"detail,omitempty"`
+}
+
+// StateCount returns the number of states of the DFA.
+func (s *CompiledLexModeSpec) StateCount() int {
+	// The first row of a transition table is a placeholder for the nil state.
+	return s.DFA.RowCount - 1
+}
+
+// TransitionByteSize returns the size of the transition table of the DFA in bytes. The size depends on the compression
+// level. When modes share transition rows (see CompiledLexSpec.SharedTransition), the size includes only the row
+// numbers of this mode.
+func (s *CompiledLexModeSpec) TransitionByteSize() int {
+	if s.DFA.Transition == nil {
+		return len(s.DFA.UncompressedTransition) * intByteSize
+	}
+	return s.DFA.Transition.ByteSize()
+}
+
+// CompiledLexSpecFormatVersion is the version of the format of CompiledLexSpec. We increment it whenever we change the
+// format in a way that a driver of another version cannot read correctly.
+//
+// Version 2 added the partial kinds of states, word boundary anchors, error kinds, and the EOF kind. A driver supporting
+// only version 1 would ignore them and tokenize a text incorrectly.
+const CompiledLexSpecFormatVersion = 2
+
 type CompiledLexSpec struct {
+	// FormatVersion is the version of the format that the compiler wrote the specification in. See
+	// CompiledLexSpecFormatVersion.
+	FormatVersion int `json:"format_version"`
+
 	Name             string                 `json:"name"`
 	InitialModeID    LexModeID              `json:"initial_mode_id"`
 	ModeNames        []LexModeName          `json:"mode_names"`
@@ -379,4 +826,49 @@ type CompiledLexSpec struct {
 	KindIDs          [][]LexKindID          `json:"kind_ids"`
 	CompressionLevel int                    `json:"compression_level"`
 	Specs            []*CompiledLexModeSpec `json:"specs"`
+
+	// KindMeta holds the metadata of each kind indexed by kind IDs. When no kinds have metadata, this field is nil.
+	KindMeta []map[string]string `json:"kind_meta,omitempty"`
+
+	// SharedTransition holds the unique transition rows of all modes. When this field isn't nil, the Transition
+	// field of each mode holds only row numbers, and they point to rows of this table.
+	SharedTransition *UniqueEntriesTable `json:"shared_transition,omitempty"`
+}
+
+// ValidateFormatVersion returns an error when the specification isn't written in the format that this version of maleeni
+// supports.
+func (s *CompiledLexSpec) ValidateFormatVersion() error {
+	if s.FormatVersion == CompiledLexSpecFormatVersion {
+		return nil
+	}
+	if s.FormatVersion == 0 {
+		return fmt.Errorf("the compiled lexical specification has no format version; it was compiled by an older version of maleeni, so please compile the lexical specification again")
+	}
+	return fmt.Errorf("the compiled lexical specification has format version %v, but this version of maleeni supports only format version %v; please compile the lexical specification again with the same version of maleeni", s.FormatVersion, CompiledLexSpecFormatVersion)
+}
+
+// KindNameSet returns a set of the names of all kinds. The set doesn't contain the nil kind name at index 0 of
+// KindNames.
+func (s *CompiledLexSpec) KindNameSet() map[string]struct{} {
+	set := make(map[string]struct{}, len(s.KindNames))
+	for id, name := range s.KindNames {
+		if id == LexKindIDNil.Int() {
+			continue
+		}
+		set[name.String()] = struct{}{}
+	}
+	return set
+}
+
+// ModeNameSet returns a set of the names of all modes. The set doesn't contain the nil mode name at index 0 of
+// ModeNames.
+func (s *CompiledLexSpec) ModeNameSet() map[string]struct{} {
+	set := make(map[string]struct{}, len(s.ModeNames))
+	for id, name := range s.ModeNames {
+		if id == LexModeIDNil.Int() {
+			continue
+		}
+		set[name.String()] = struct{}{}
+	}
+	return set
 }
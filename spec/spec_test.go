@@ -2,6 +2,8 @@ package spec
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -198,6 +200,45 @@ func TestFindSpellingInconsistencies(t *testing.T) {
 	}
 }
 
+func TestLexSpec_Validate_SkipSpellingCheck(t *testing.T) {
+	s := &LexSpec{
+		Name: "test",
+		Entries: []*LexEntry{
+			{
+				Kind:    "utf_8",
+				Pattern: "utf-8",
+			},
+			{
+				Modes:   []LexModeName{"mode_1"},
+				Kind:    "utf8",
+				Pattern: "utf8",
+			},
+			{
+				Modes:   []LexModeName{"mode1"},
+				Kind:    "ascii",
+				Pattern: "ascii",
+			},
+		},
+	}
+	err := s.Validate()
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	err = s.Validate(SkipSpellingCheck())
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+	errs := s.SpellingInconsistencies()
+	if len(errs) != 2 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for i, ids := range []string{"utf8, utf_8", "mode1, mode_1"} {
+		if !strings.Contains(errs[i].Error(), ids) {
+			t.Fatalf("unexpected error; want: %v, got: %v", ids, errs[i])
+		}
+	}
+}
+
 func TestLexSpec_Validate(t *testing.T) {
 	// We expect that the spelling inconsistency error will occur.
 	spec := &LexSpec{
@@ -217,3 +258,650 @@ func TestLexSpec_Validate(t *testing.T) {
 		t.Fatalf("expected error didn't occur")
 	}
 }
+
+func TestLexSpec_Validate_FragmentsOnly(t *testing.T) {
+	spec := &LexSpec{
+		Name: "test",
+		Entries: []*LexEntry{
+			{
+				Kind:     "digit",
+				Pattern:  "[0-9]",
+				Fragment: true,
+			},
+			{
+				Kind:     "integer",
+				Pattern:  `\f{digit}+`,
+				Fragment: true,
+			},
+		},
+	}
+	err := spec.Validate()
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if !strings.Contains(err.Error(), "at least one entry that isn't a fragment") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// An emitted fragment is a kind, so the specification is valid.
+	spec.Entries[1].Emit = true
+	err = spec.Validate()
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+}
+
+func TestLexSpec_Validate_NoImplicitDefault(t *testing.T) {
+	newSpec := func(noImplicitDefault bool) *LexSpec {
+		return &LexSpec{
+			Name:              "test",
+			NoImplicitDefault: noImplicitDefault,
+			Entries: []*LexEntry{
+				{
+					Kind:     "digit",
+					Pattern:  "[0-9]",
+					Fragment: true,
+				},
+				{
+					Kind:    "integer",
+					Pattern: `\f{digit}+`,
+				},
+				{
+					Modes:   []LexModeName{"default"},
+					Kind:    "white_space",
+					Pattern: " +",
+				},
+			},
+		}
+	}
+
+	// Without the setting, an entry with empty modes belongs to the default mode.
+	err := newSpec(false).Validate()
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+
+	// With the setting, the entry must list its modes. The fragment needs no modes.
+	err = newSpec(true).Validate()
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if !strings.Contains(err.Error(), "entry #2: kind `integer` must have modes") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := newSpec(true)
+	s.Entries[1].Modes = []LexModeName{"default"}
+	err = s.Validate()
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+
+	// An emitted fragment is a kind, so it must also list its modes.
+	s.Entries[0].Emit = true
+	err = s.Validate()
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	if !strings.Contains(err.Error(), "entry #1: kind `digit` must have modes") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLexSpec_Validate_InitialMode(t *testing.T) {
+	tests := []struct {
+		initialMode LexModeName
+		err         bool
+	}{
+		{
+			initialMode: "",
+		},
+		{
+			initialMode: "default",
+		},
+		{
+			initialMode: "string",
+		},
+		{
+			initialMode: "comment",
+			err:         true,
+		},
+		{
+			initialMode: "String",
+			err:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.initialMode.String(), func(t *testing.T) {
+			spec := &LexSpec{
+				Name: "test",
+				Entries: []*LexEntry{
+					{
+						Kind:    "foo",
+						Pattern: "foo",
+					},
+					{
+						Modes: []LexModeName{
+							"string",
+						},
+						Kind:    "bar",
+						Pattern: "bar",
+					},
+					{
+						Kind:     "comment",
+						Pattern:  "baz",
+						Fragment: true,
+					},
+				},
+				InitialMode: tt.initialMode,
+			}
+			err := spec.Validate()
+			if tt.err && err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+		})
+	}
+}
+
+func TestLexSpec_Validate_Priority(t *testing.T) {
+	priority := 1
+	spec := &LexSpec{
+		Name: "test",
+		Entries: []*LexEntry{
+			{
+				Kind:     "foo",
+				Pattern:  "foo",
+				Priority: &priority,
+			},
+		},
+	}
+	err := spec.Validate()
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+
+	spec.Entries[0].Fragment = true
+	err = spec.Validate()
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+}
+
+func TestLexSpec_Validate_ModeTransitions(t *testing.T) {
+	tests := []struct {
+		caption     string
+		entries     []*LexEntry
+		initialMode LexModeName
+		err         bool
+	}{
+		{
+			caption: "an entry pushes a mode having entries",
+			entries: []*LexEntry{
+				{
+					Kind:    "quote_open",
+					Pattern: "\"",
+					Push:    "string",
+				},
+				{
+					Modes:   []LexModeName{"string"},
+					Kind:    "quote_close",
+					Pattern: "\"",
+					Pop:     true,
+				},
+			},
+		},
+		{
+			caption: "an entry pushes an unknown mode",
+			entries: []*LexEntry{
+				{
+					Kind:    "quote_open",
+					Pattern: "\"",
+					Push:    "string",
+				},
+			},
+			err: true,
+		},
+		{
+			caption: "an entry pushes a mode that only a fragment mentions",
+			entries: []*LexEntry{
+				{
+					Kind:    "quote_open",
+					Pattern: "\"",
+					Push:    "string",
+				},
+				{
+					Modes:    []LexModeName{"string"},
+					Kind:     "char",
+					Pattern:  ".",
+					Fragment: true,
+				},
+			},
+			err: true,
+		},
+		{
+			caption: "an entry pushes the default mode implicitly having entries",
+			entries: []*LexEntry{
+				{
+					Kind:    "foo",
+					Pattern: "foo",
+					Push:    "default",
+				},
+			},
+		},
+		{
+			caption: "an entry pops the default mode that no entry pushes",
+			entries: []*LexEntry{
+				{
+					Kind:    "foo",
+					Pattern: "foo",
+					Pop:     true,
+				},
+			},
+			err: true,
+		},
+		{
+			caption: "an entry pops the default mode and pushes another mode",
+			entries: []*LexEntry{
+				{
+					Kind:    "go_b",
+					Pattern: "b",
+					Pop:     true,
+					Push:    "b",
+				},
+				{
+					Modes:   []LexModeName{"b"},
+					Kind:    "foo",
+					Pattern: "foo",
+				},
+			},
+		},
+		{
+			caption: "an entry pops the initial mode that no entry pushes",
+			entries: []*LexEntry{
+				{
+					Kind:    "foo",
+					Pattern: "foo",
+				},
+				{
+					Modes:   []LexModeName{"bar_mode"},
+					Kind:    "bar",
+					Pattern: "bar",
+					Pop:     true,
+				},
+			},
+			initialMode: "bar_mode",
+			err:         true,
+		},
+		{
+			caption: "an entry pops the default mode that another entry pushes",
+			entries: []*LexEntry{
+				{
+					Kind:    "foo",
+					Pattern: "foo",
+					Pop:     true,
+				},
+				{
+					Modes:   []LexModeName{"bar_mode"},
+					Kind:    "bar",
+					Pattern: "bar",
+					Push:    "default",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			spec := &LexSpec{
+				Name:        "test",
+				Entries:     tt.entries,
+				InitialMode: tt.initialMode,
+			}
+			err := spec.Validate()
+			if tt.err && err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+		})
+	}
+}
+
+func TestLexSpec_Validate_EOFKind(t *testing.T) {
+	entries := []*LexEntry{
+		{
+			Kind:    "word",
+			Pattern: "[a-z]+",
+		},
+		{
+			Kind:     "digit",
+			Pattern:  "[0-9]",
+			Fragment: true,
+		},
+		{
+			Modes:   []LexModeName{"string"},
+			Kind:    "char_seq",
+			Pattern: "[a-z]+",
+		},
+	}
+	tests := []struct {
+		caption string
+		eofKind LexKindName
+		err     bool
+	}{
+		{
+			caption: "an EOF kind",
+			eofKind: "eof",
+		},
+		{
+			caption: "an EOF kind can have the same name as a fragment",
+			eofKind: "digit",
+		},
+		{
+			caption: "an EOF kind duplicates a kind of an entry",
+			eofKind: "word",
+			err:     true,
+		},
+		{
+			caption: "an EOF kind duplicates a kind of an entry in another mode",
+			eofKind: "char_seq",
+			err:     true,
+		},
+		{
+			caption: "an EOF kind duplicates an error kind",
+			eofKind: "error",
+			err:     true,
+		},
+		{
+			caption: "an EOF kind has an invalid name",
+			eofKind: "EOF",
+			err:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			spec := &LexSpec{
+				Name:    "test",
+				Entries: entries,
+				ErrorKinds: map[LexModeName]LexKindName{
+					"default": "error",
+				},
+				EOFKind: tt.eofKind,
+			}
+			err := spec.Validate()
+			if tt.err && err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+		})
+	}
+}
+
+func TestLexSpec_Validate_ErrorKinds(t *testing.T) {
+	entries := []*LexEntry{
+		{
+			Kind:    "word",
+			Pattern: "[a-z]+",
+		},
+		{
+			Kind:     "digit",
+			Pattern:  "[0-9]",
+			Fragment: true,
+		},
+		{
+			Modes:   []LexModeName{"string"},
+			Kind:    "char_seq",
+			Pattern: "[a-z]+",
+		},
+	}
+	tests := []struct {
+		caption    string
+		errorKinds map[LexModeName]LexKindName
+		err        bool
+	}{
+		{
+			caption: "error kinds of modes having entries",
+			errorKinds: map[LexModeName]LexKindName{
+				"default": "error",
+				"string":  "error",
+			},
+		},
+		{
+			caption: "an error kind can have the same name as a kind in another mode",
+			errorKinds: map[LexModeName]LexKindName{
+				"string": "word",
+			},
+		},
+		{
+			caption: "an error kind can have the same name as a fragment",
+			errorKinds: map[LexModeName]LexKindName{
+				"default": "digit",
+			},
+		},
+		{
+			caption: "an error kind duplicates a kind in the same mode",
+			errorKinds: map[LexModeName]LexKindName{
+				"default": "word",
+			},
+			err: true,
+		},
+		{
+			caption: "an error kind belongs to an unknown mode",
+			errorKinds: map[LexModeName]LexKindName{
+				"comment": "error",
+			},
+			err: true,
+		},
+		{
+			caption: "an error kind has an invalid name",
+			errorKinds: map[LexModeName]LexKindName{
+				"default": "Error",
+			},
+			err: true,
+		},
+		{
+			caption: "an error kind belongs to a mode having an invalid name",
+			errorKinds: map[LexModeName]LexKindName{
+				"String": "error",
+			},
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			spec := &LexSpec{
+				Name:       "test",
+				Entries:    entries,
+				ErrorKinds: tt.errorKinds,
+			}
+			err := spec.Validate()
+			if tt.err && err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+		})
+	}
+}
+
+func TestLexSpec_Validate_Emit(t *testing.T) {
+	priority := 1
+	tests := []struct {
+		caption string
+		entries []*LexEntry
+		err     bool
+	}{
+		{
+			caption: "an emitted fragment can have the fields of a non-fragment entry",
+			entries: []*LexEntry{
+				{
+					Modes:    []LexModeName{"default"},
+					Kind:     "white_space",
+					Pattern:  " +",
+					Fragment: true,
+					Emit:     true,
+					Skip:     true,
+					Priority: &priority,
+					Meta: map[string]string{
+						"foo": "bar",
+					},
+				},
+			},
+		},
+		{
+			caption: "a non-fragment entry cannot be emitted",
+			entries: []*LexEntry{
+				{
+					Kind:    "foo",
+					Pattern: "foo",
+					Emit:    true,
+				},
+			},
+			err: true,
+		},
+		{
+			caption: "an emitted fragment cannot be a literal",
+			entries: []*LexEntry{
+				{
+					Kind:     "foo",
+					Pattern:  "foo",
+					Fragment: true,
+					Emit:     true,
+					Literal:  true,
+				},
+			},
+			err: true,
+		},
+		{
+			caption: "a fragment that isn't emitted can have the same name as a non-fragment entry",
+			entries: []*LexEntry{
+				{
+					Kind:    "foo",
+					Pattern: `\f{foo}+`,
+				},
+				{
+					Kind:     "foo",
+					Pattern:  "foo",
+					Fragment: true,
+				},
+			},
+		},
+		{
+			caption: "an emitted fragment cannot have the same name as a non-fragment entry",
+			entries: []*LexEntry{
+				{
+					Kind:    "foo",
+					Pattern: `\f{foo}+`,
+				},
+				{
+					Kind:     "foo",
+					Pattern:  "foo",
+					Fragment: true,
+					Emit:     true,
+				},
+			},
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			spec := &LexSpec{
+				Name:    "test",
+				Entries: tt.entries,
+			}
+			err := spec.Validate()
+			if tt.err && err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+		})
+	}
+}
+
+func TestCompiledLexSpec_NameSets(t *testing.T) {
+	clspec := &CompiledLexSpec{
+		ModeNames: []LexModeName{
+			LexModeNameNil,
+			LexModeNameDefault,
+			"string",
+		},
+		KindNames: []LexKindName{
+			LexKindNameNil,
+			"word",
+			"quote_open",
+			"char_seq",
+			"quote_close",
+		},
+	}
+
+	kinds := clspec.KindNameSet()
+	if !reflect.DeepEqual(kinds, map[string]struct{}{
+		"word":        {},
+		"quote_open":  {},
+		"char_seq":    {},
+		"quote_close": {},
+	}) {
+		t.Fatalf("unexpected kind names: %v", kinds)
+	}
+	if _, ok := kinds[LexKindNameNil.String()]; ok {
+		t.Fatalf("the set must not contain the nil kind name")
+	}
+
+	modes := clspec.ModeNameSet()
+	if !reflect.DeepEqual(modes, map[string]struct{}{
+		"default": {},
+		"string":  {},
+	}) {
+		t.Fatalf("unexpected mode names: %v", modes)
+	}
+	if _, ok := modes[LexModeNameNil.String()]; ok {
+		t.Fatalf("the set must not contain the nil mode name")
+	}
+}
+
+func TestCompiledLexSpec_ValidateFormatVersion(t *testing.T) {
+	tests := []struct {
+		version int
+		err     bool
+	}{
+		{
+			version: CompiledLexSpecFormatVersion,
+		},
+		// A specification without a format version was compiled before the format had versions.
+		{
+			version: 0,
+			err:     true,
+		},
+		// Version 1 lacks partial kinds, word boundary anchors, error kinds, and the EOF kind.
+		{
+			version: 1,
+			err:     true,
+		},
+		{
+			version: CompiledLexSpecFormatVersion + 1,
+			err:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("version %v", tt.version), func(t *testing.T) {
+			s := &CompiledLexSpec{
+				FormatVersion: tt.version,
+			}
+			err := s.ValidateFormatVersion()
+			if tt.err && err == nil {
+				t.Fatalf("expected error didn't occur")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package spec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// startConditionRE matches start conditions `<mode1,mode2>` at the beginning of a pattern. The names are checked
+// separately so that a misspelled name is reported instead of being treated as a part of the pattern.
+var startConditionRE = regexp.MustCompile(`^<([0-9A-Za-z_]+(?:[ ]*,[ ]*[0-9A-Za-z_]+)*)>`)
+
+// ExtractStartConditions moves start conditions written at the beginning of patterns, such as `<string>[^"]+`, to
+// the modes of the entries like start conditions of lex and flex. The specification must enable StartConditions.
+// A pattern that doesn't begin with `<`, a list of names, and `>` is left as it is. To match `<` at the beginning of
+// such a pattern, write `[<]` instead. A literal pattern never has start conditions, so it can begin with `<` as it is.
+// After extracting start conditions, this method disables StartConditions so that
+// extracting them again changes nothing.
+func (s *LexSpec) ExtractStartConditions() error {
+	if !s.StartConditions {
+		return nil
+	}
+
+	var errs []error
+	for i, e := range s.Entries {
+		if e.Literal {
+			continue
+		}
+		modes, pat, ok, err := splitStartConditions(e.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("entry #%v: %w", i+1, err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if e.Fragment && !e.Emit {
+			errs = append(errs, fmt.Errorf("entry #%v: a fragment cannot have start conditions", i+1))
+			continue
+		}
+		if len(e.Modes) > 0 {
+			errs = append(errs, fmt.Errorf("entry #%v: an entry cannot have both start conditions and modes", i+1))
+			continue
+		}
+		e.Modes = modes
+		e.Pattern = pat
+	}
+	if len(errs) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%v", errs[0])
+		for _, err := range errs[1:] {
+			fmt.Fprintf(&b, "\n%v", err)
+		}
+		return fmt.Errorf(b.String())
+	}
+
+	s.StartConditions = false
+	return nil
+}
+
+func splitStartConditions(pat LexPattern) ([]LexModeName, LexPattern, bool, error) {
+	m := startConditionRE.FindStringSubmatch(pat.String())
+	if m == nil {
+		return nil, pat, false, nil
+	}
+	var modes []LexModeName
+	for _, name := range strings.Split(m[1], ",") {
+		mode := LexModeName(strings.TrimSpace(name))
+		err := mode.validate()
+		if err != nil {
+			return nil, pat, false, fmt.Errorf("invalid start condition: %v", err)
+		}
+		modes = append(modes, mode)
+	}
+	return modes, pat[len(m[0]):], true, nil
+}
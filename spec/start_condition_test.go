@@ -0,0 +1,172 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLexSpec_ExtractStartConditions(t *testing.T) {
+	tests := []struct {
+		caption string
+		entries []*LexEntry
+		modes   [][]LexModeName
+		pats    []LexPattern
+		err     string
+	}{
+		{
+			caption: "start conditions become the modes of an entry",
+			entries: []*LexEntry{
+				{Kind: "word", Pattern: "[a-z]+"},
+				{Kind: "char_seq", Pattern: `<string>[^"]+`},
+				{Kind: "white_space", Pattern: "<default, string>[ ]+"},
+			},
+			modes: [][]LexModeName{
+				nil,
+				{"string"},
+				{"default", "string"},
+			},
+			pats: []LexPattern{
+				"[a-z]+",
+				`[^"]+`,
+				"[ ]+",
+			},
+		},
+		{
+			caption: "a pattern that doesn't begin with start conditions is left as it is",
+			entries: []*LexEntry{
+				{Kind: "le", Pattern: "<="},
+				{Kind: "tag", Pattern: "[<]br>"},
+				{Kind: "lt", Pattern: "<"},
+			},
+			modes: [][]LexModeName{
+				nil,
+				nil,
+				nil,
+			},
+			pats: []LexPattern{
+				"<=",
+				"[<]br>",
+				"<",
+			},
+		},
+		{
+			caption: "a literal pattern has no start conditions",
+			entries: []*LexEntry{
+				{Kind: "br", Pattern: "<br>", Literal: true},
+				{Kind: "string_br", Pattern: "<string><br>", Literal: true, Modes: []LexModeName{"string"}},
+			},
+			modes: [][]LexModeName{
+				nil,
+				{"string"},
+			},
+			pats: []LexPattern{
+				"<br>",
+				"<string><br>",
+			},
+		},
+		{
+			caption: "an emitted fragment can have start conditions",
+			entries: []*LexEntry{
+				{Kind: "digit", Pattern: "<number>[0-9]", Fragment: true, Emit: true},
+			},
+			modes: [][]LexModeName{
+				{"number"},
+			},
+			pats: []LexPattern{
+				"[0-9]",
+			},
+		},
+		{
+			caption: "a start condition must be a valid mode name",
+			entries: []*LexEntry{
+				{Kind: "word", Pattern: "<String>[a-z]+"},
+			},
+			err: "entry #1: invalid start condition",
+		},
+		{
+			caption: "a fragment that isn't emitted cannot have start conditions",
+			entries: []*LexEntry{
+				{Kind: "digit", Pattern: "<number>[0-9]", Fragment: true},
+			},
+			err: "entry #1: a fragment cannot have start conditions",
+		},
+		{
+			caption: "an entry cannot have both start conditions and modes",
+			entries: []*LexEntry{
+				{Kind: "word", Pattern: "<string>[a-z]+", Modes: []LexModeName{"string"}},
+			},
+			err: "entry #1: an entry cannot have both start conditions and modes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			s := &LexSpec{
+				Name:            "test",
+				Entries:         tt.entries,
+				StartConditions: true,
+			}
+			err := s.ExtractStartConditions()
+			if tt.err != "" {
+				if err == nil {
+					t.Fatalf("expected error didn't occur")
+				}
+				if !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error occurred: %v", err)
+			}
+			if s.StartConditions {
+				t.Fatalf("StartConditions must be disabled after the extraction")
+			}
+			for i, e := range s.Entries {
+				if fmt.Sprint(e.Modes) != fmt.Sprint(tt.modes[i]) {
+					t.Errorf("unexpected modes of entry #%v; want: %v, got: %v", i+1, tt.modes[i], e.Modes)
+				}
+				if e.Pattern != tt.pats[i] {
+					t.Errorf("unexpected pattern of entry #%v; want: %v, got: %v", i+1, tt.pats[i], e.Pattern)
+				}
+			}
+		})
+	}
+}
+
+func TestLexSpec_ExtractStartConditions_Disabled(t *testing.T) {
+	s := &LexSpec{
+		Name: "test",
+		Entries: []*LexEntry{
+			{Kind: "tag", Pattern: "<br>"},
+		},
+	}
+	err := s.ExtractStartConditions()
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+	if s.Entries[0].Pattern != "<br>" || len(s.Entries[0].Modes) != 0 {
+		t.Fatalf("the entry must be left as it is: %+v", s.Entries[0])
+	}
+
+	// A specification must not be validated before extracting start conditions.
+	s = &LexSpec{
+		Name: "test",
+		Entries: []*LexEntry{
+			{Kind: "word", Pattern: "<default>[a-z]+"},
+		},
+		StartConditions: true,
+	}
+	err = s.Validate()
+	if err == nil {
+		t.Fatalf("expected error didn't occur")
+	}
+	err = s.ExtractStartConditions()
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+	err = s.Validate()
+	if err != nil {
+		t.Fatalf("unexpected error occurred: %v", err)
+	}
+}
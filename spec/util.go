@@ -1,6 +1,12 @@
 package spec
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
+
+// metaChars is the set of the special characters that EscapePattern escapes.
+const metaChars = `.*+?|()[^$\`
 
 var rep = strings.NewReplacer(
 	`.`, `\.`,
@@ -11,6 +17,8 @@ var rep = strings.NewReplacer(
 	`(`, `\(`,
 	`)`, `\)`,
 	`[`, `\[`,
+	`^`, `\^`,
+	`$`, `\$`,
 	`\`, `\\`,
 )
 
@@ -19,3 +27,34 @@ var rep = strings.NewReplacer(
 func EscapePattern(s string) string {
 	return rep.Replace(s)
 }
+
+// UnescapePattern is the inverse of EscapePattern. It returns the literal string that a pattern represents.
+// For example, UnescapePattern(`\+`) returns `+`. UnescapePattern accepts only the patterns that EscapePattern can
+// produce, so it returns an error when a pattern contains an unescaped special character or an escape sequence other
+// than the special characters.
+func UnescapePattern(s string) (string, error) {
+	var b strings.Builder
+	escaped := false
+	for i, c := range s {
+		if escaped {
+			if !strings.ContainsRune(metaChars, c) {
+				return "", fmt.Errorf("`\\%c` at byte offset %v is not an escaped special character", c, i-1)
+			}
+			b.WriteRune(c)
+			escaped = false
+			continue
+		}
+		if c == '\\' {
+			escaped = true
+			continue
+		}
+		if strings.ContainsRune(metaChars, c) {
+			return "", fmt.Errorf("special character `%c` at byte offset %v is not escaped", c, i)
+		}
+		b.WriteRune(c)
+	}
+	if escaped {
+		return "", fmt.Errorf("a pattern must not end with `\\`")
+	}
+	return b.String(), nil
+}
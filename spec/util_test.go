@@ -0,0 +1,61 @@
+package spec
+
+import "testing"
+
+func TestEscapePatternAndUnescapePattern(t *testing.T) {
+	tests := []struct {
+		literal string
+		pattern string
+	}{
+		{literal: `.`, pattern: `\.`},
+		{literal: `*`, pattern: `\*`},
+		{literal: `+`, pattern: `\+`},
+		{literal: `?`, pattern: `\?`},
+		{literal: `|`, pattern: `\|`},
+		{literal: `(`, pattern: `\(`},
+		{literal: `)`, pattern: `\)`},
+		{literal: `[`, pattern: `\[`},
+		{literal: `^`, pattern: `\^`},
+		{literal: `$`, pattern: `\$`},
+		{literal: `\`, pattern: `\\`},
+		{literal: ``, pattern: ``},
+		{literal: `foo`, pattern: `foo`},
+		{literal: `]{}-`, pattern: `]{}-`},
+		{literal: `a.b*c\d`, pattern: `a\.b\*c\\d`},
+		{literal: `あ+い`, pattern: `あ\+い`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			pattern := EscapePattern(tt.literal)
+			if pattern != tt.pattern {
+				t.Fatalf("unexpected pattern; want: %v, got: %v", tt.pattern, pattern)
+			}
+			literal, err := UnescapePattern(pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if literal != tt.literal {
+				t.Fatalf("unexpected literal; want: %v, got: %v", tt.literal, literal)
+			}
+		})
+	}
+}
+
+func TestUnescapePattern_Error(t *testing.T) {
+	tests := []string{
+		`a+`,
+		`(foo)`,
+		`\d`,
+		`\u{0041}`,
+		`\f{foo}`,
+		`foo\`,
+	}
+	for _, pattern := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			_, err := UnescapePattern(pattern)
+			if err == nil {
+				t.Fatal("expected error didn't occur")
+			}
+		})
+	}
+}
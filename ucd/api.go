@@ -5,6 +5,7 @@ package ucd
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -60,7 +61,52 @@ func IsContributoryProperty(propName string) bool {
 	return false
 }
 
+// FindCodePointRanges returns the code point ranges of a character property value. When the second return value is
+// true, the ranges are the complement of the value. The ranges are sorted in ascending order, and overlapping or
+// adjacent ranges are merged, so the result doesn't depend on the order of the tables. The caller may modify the
+// returned ranges.
 func FindCodePointRanges(propName, propVal string) ([]*CodePointRange, bool, error) {
+	ranges, inverse, err := findCodePointRanges(propName, propVal)
+	if err != nil {
+		return nil, false, err
+	}
+	return NormalizeCodePointRanges(ranges), inverse, nil
+}
+
+// NormalizeCodePointRanges returns a copy of code point ranges sorted in ascending order, in which overlapping or
+// adjacent ranges are merged into one. The argument is not modified because it may be a table of this package.
+func NormalizeCodePointRanges(cpRanges []*CodePointRange) []*CodePointRange {
+	if len(cpRanges) == 0 {
+		return nil
+	}
+	sorted := make([]*CodePointRange, len(cpRanges))
+	copy(sorted, cpRanges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].From < sorted[j].From
+	})
+	merged := []*CodePointRange{
+		{
+			From: sorted[0].From,
+			To:   sorted[0].To,
+		},
+	}
+	for _, r := range sorted[1:] {
+		last := merged[len(merged)-1]
+		if r.From <= last.To+1 {
+			if r.To > last.To {
+				last.To = r.To
+			}
+			continue
+		}
+		merged = append(merged, &CodePointRange{
+			From: r.From,
+			To:   r.To,
+		})
+	}
+	return merged
+}
+
+func findCodePointRanges(propName, propVal string) ([]*CodePointRange, bool, error) {
 	if propName == "" {
 		propName = "gc"
 	}
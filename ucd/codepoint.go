@@ -2,7 +2,10 @@
 
 package ucd
 
-// https://www.unicode.org/Public/13.0.0/ucd/PropertyValueAliases.txt
+// UnicodeVersion is the version of the Unicode Character Database that the tables in this file are generated from.
+const UnicodeVersion = "14.0.0"
+
+// https://www.unicode.org/Public/14.0.0/ucd/PropertyValueAliases.txt
 var generalCategoryValueAbbs = map[string]string{
 	"c":                    "c",
 	"casedletter":          "lc",
@@ -86,7 +89,7 @@ var generalCategoryValueAbbs = map[string]string{
 	"zs":                   "zs",
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/PropertyValueAliases.txt
+// https://www.unicode.org/Public/14.0.0/ucd/PropertyValueAliases.txt
 var scriptValueAbbs = map[string]string{
 	"adlam":                 "adlm",
 	"adlm":                  "adlm",
@@ -137,9 +140,11 @@ var scriptValueAbbs = map[string]string{
 	"common":                "zyyy",
 	"copt":                  "copt",
 	"coptic":                "copt",
+	"cpmn":                  "cpmn",
 	"cprt":                  "cprt",
 	"cuneiform":             "xsux",
 	"cypriot":               "cprt",
+	"cyprominoan":           "cpmn",
 	"cyrillic":              "cyrl",
 	"cyrl":                  "cyrl",
 	"deseret":               "dsrt",
@@ -295,6 +300,7 @@ var scriptValueAbbs = map[string]string{
 	"oldsogdian":            "sogo",
 	"oldsoutharabian":       "sarb",
 	"oldturkic":             "orkh",
+	"olduyghur":             "ougr",
 	"oriya":                 "orya",
 	"orkh":                  "orkh",
 	"orya":                  "orya",
@@ -302,6 +308,7 @@ var scriptValueAbbs = map[string]string{
 	"osge":                  "osge",
 	"osma":                  "osma",
 	"osmanya":               "osma",
+	"ougr":                  "ougr",
 	"pahawhhmong":           "hmng",
 	"palm":                  "palm",
 	"palmyrene":             "palm",
@@ -366,6 +373,7 @@ var scriptValueAbbs = map[string]string{
 	"tamil":                 "taml",
 	"taml":                  "taml",
 	"tang":                  "tang",
+	"tangsa":                "tnsa",
 	"tangut":                "tang",
 	"tavt":                  "tavt",
 	"telu":                  "telu",
@@ -380,11 +388,15 @@ var scriptValueAbbs = map[string]string{
 	"tifinagh":              "tfng",
 	"tirh":                  "tirh",
 	"tirhuta":               "tirh",
+	"tnsa":                  "tnsa",
+	"toto":                  "toto",
 	"ugar":                  "ugar",
 	"ugaritic":              "ugar",
 	"unknown":               "zzzz",
 	"vai":                   "vaii",
 	"vaii":                  "vaii",
+	"vith":                  "vith",
+	"vithkuqi":              "vith",
 	"wancho":                "wcho",
 	"wara":                  "wara",
 	"warangciti":            "wara",
@@ -402,7 +414,7 @@ var scriptValueAbbs = map[string]string{
 	"zzzz":                  "zzzz",
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/PropertyValueAliases.txt
+// https://www.unicode.org/Public/14.0.0/ucd/PropertyValueAliases.txt
 var (
 	generalCategoryDefaultRange = &CodePointRange{
 		From: rune(0),
@@ -411,7 +423,7 @@ var (
 	generalCategoryDefaultValue = "unassigned"
 )
 
-// https://www.unicode.org/Public/13.0.0/ucd/UnicodeData.txt
+// https://www.unicode.org/Public/14.0.0/ucd/UnicodeData.txt
 var generalCategoryCodePoints = map[string][]*CodePointRange{
 	"cc": {
 		&CodePointRange{From: rune(0), To: rune(31)},
@@ -423,6 +435,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(1564), To: rune(1564)},
 		&CodePointRange{From: rune(1757), To: rune(1757)},
 		&CodePointRange{From: rune(1807), To: rune(1807)},
+		&CodePointRange{From: rune(2192), To: rune(2193)},
 		&CodePointRange{From: rune(2274), To: rune(2274)},
 		&CodePointRange{From: rune(6158), To: rune(6158)},
 		&CodePointRange{From: rune(8203), To: rune(8207)},
@@ -440,18 +453,12 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(917536), To: rune(917631)},
 	},
 	"co": {
-		&CodePointRange{From: rune(57344), To: rune(57344)},
-		&CodePointRange{From: rune(63743), To: rune(63743)},
-		&CodePointRange{From: rune(983040), To: rune(983040)},
-		&CodePointRange{From: rune(1048573), To: rune(1048573)},
-		&CodePointRange{From: rune(1048576), To: rune(1048576)},
-		&CodePointRange{From: rune(1114109), To: rune(1114109)},
+		&CodePointRange{From: rune(57344), To: rune(63743)},
+		&CodePointRange{From: rune(983040), To: rune(1048573)},
+		&CodePointRange{From: rune(1048576), To: rune(1114109)},
 	},
 	"cs": {
-		&CodePointRange{From: rune(55296), To: rune(55296)},
-		&CodePointRange{From: rune(56191), To: rune(56192)},
-		&CodePointRange{From: rune(56319), To: rune(56320)},
-		&CodePointRange{From: rune(57343), To: rune(57343)},
+		&CodePointRange{From: rune(55296), To: rune(57343)},
 	},
 	"ll": {
 		&CodePointRange{From: rune(97), To: rune(122)},
@@ -885,7 +892,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(8518), To: rune(8521)},
 		&CodePointRange{From: rune(8526), To: rune(8526)},
 		&CodePointRange{From: rune(8580), To: rune(8580)},
-		&CodePointRange{From: rune(11312), To: rune(11358)},
+		&CodePointRange{From: rune(11312), To: rune(11359)},
 		&CodePointRange{From: rune(11361), To: rune(11361)},
 		&CodePointRange{From: rune(11365), To: rune(11366)},
 		&CodePointRange{From: rune(11368), To: rune(11368)},
@@ -1054,9 +1061,15 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(42939), To: rune(42939)},
 		&CodePointRange{From: rune(42941), To: rune(42941)},
 		&CodePointRange{From: rune(42943), To: rune(42943)},
+		&CodePointRange{From: rune(42945), To: rune(42945)},
 		&CodePointRange{From: rune(42947), To: rune(42947)},
 		&CodePointRange{From: rune(42952), To: rune(42952)},
 		&CodePointRange{From: rune(42954), To: rune(42954)},
+		&CodePointRange{From: rune(42961), To: rune(42961)},
+		&CodePointRange{From: rune(42963), To: rune(42963)},
+		&CodePointRange{From: rune(42965), To: rune(42965)},
+		&CodePointRange{From: rune(42967), To: rune(42967)},
+		&CodePointRange{From: rune(42969), To: rune(42969)},
 		&CodePointRange{From: rune(42998), To: rune(42998)},
 		&CodePointRange{From: rune(43002), To: rune(43002)},
 		&CodePointRange{From: rune(43824), To: rune(43866)},
@@ -1067,6 +1080,10 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(65345), To: rune(65370)},
 		&CodePointRange{From: rune(66600), To: rune(66639)},
 		&CodePointRange{From: rune(66776), To: rune(66811)},
+		&CodePointRange{From: rune(66967), To: rune(66977)},
+		&CodePointRange{From: rune(66979), To: rune(66993)},
+		&CodePointRange{From: rune(66995), To: rune(67001)},
+		&CodePointRange{From: rune(67003), To: rune(67004)},
 		&CodePointRange{From: rune(68800), To: rune(68850)},
 		&CodePointRange{From: rune(71872), To: rune(71903)},
 		&CodePointRange{From: rune(93792), To: rune(93823)},
@@ -1098,6 +1115,8 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(120746), To: rune(120770)},
 		&CodePointRange{From: rune(120772), To: rune(120777)},
 		&CodePointRange{From: rune(120779), To: rune(120779)},
+		&CodePointRange{From: rune(122624), To: rune(122633)},
+		&CodePointRange{From: rune(122635), To: rune(122654)},
 		&CodePointRange{From: rune(125218), To: rune(125251)},
 	},
 	"lm": {
@@ -1116,6 +1135,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(2074), To: rune(2074)},
 		&CodePointRange{From: rune(2084), To: rune(2084)},
 		&CodePointRange{From: rune(2088), To: rune(2088)},
+		&CodePointRange{From: rune(2249), To: rune(2249)},
 		&CodePointRange{From: rune(2417), To: rune(2417)},
 		&CodePointRange{From: rune(3654), To: rune(3654)},
 		&CodePointRange{From: rune(3782), To: rune(3782)},
@@ -1146,6 +1166,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(42775), To: rune(42783)},
 		&CodePointRange{From: rune(42864), To: rune(42864)},
 		&CodePointRange{From: rune(42888), To: rune(42888)},
+		&CodePointRange{From: rune(42994), To: rune(42996)},
 		&CodePointRange{From: rune(43000), To: rune(43001)},
 		&CodePointRange{From: rune(43471), To: rune(43471)},
 		&CodePointRange{From: rune(43494), To: rune(43494)},
@@ -1156,10 +1177,16 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(43881), To: rune(43881)},
 		&CodePointRange{From: rune(65392), To: rune(65392)},
 		&CodePointRange{From: rune(65438), To: rune(65439)},
+		&CodePointRange{From: rune(67456), To: rune(67461)},
+		&CodePointRange{From: rune(67463), To: rune(67504)},
+		&CodePointRange{From: rune(67506), To: rune(67514)},
 		&CodePointRange{From: rune(92992), To: rune(92995)},
 		&CodePointRange{From: rune(94099), To: rune(94111)},
 		&CodePointRange{From: rune(94176), To: rune(94177)},
 		&CodePointRange{From: rune(94179), To: rune(94179)},
+		&CodePointRange{From: rune(110576), To: rune(110579)},
+		&CodePointRange{From: rune(110581), To: rune(110587)},
+		&CodePointRange{From: rune(110589), To: rune(110590)},
 		&CodePointRange{From: rune(123191), To: rune(123197)},
 		&CodePointRange{From: rune(125259), To: rune(125259)},
 	},
@@ -1187,8 +1214,9 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(2048), To: rune(2069)},
 		&CodePointRange{From: rune(2112), To: rune(2136)},
 		&CodePointRange{From: rune(2144), To: rune(2154)},
-		&CodePointRange{From: rune(2208), To: rune(2228)},
-		&CodePointRange{From: rune(2230), To: rune(2247)},
+		&CodePointRange{From: rune(2160), To: rune(2183)},
+		&CodePointRange{From: rune(2185), To: rune(2190)},
+		&CodePointRange{From: rune(2208), To: rune(2248)},
 		&CodePointRange{From: rune(2308), To: rune(2361)},
 		&CodePointRange{From: rune(2365), To: rune(2365)},
 		&CodePointRange{From: rune(2384), To: rune(2384)},
@@ -1253,6 +1281,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(3114), To: rune(3129)},
 		&CodePointRange{From: rune(3133), To: rune(3133)},
 		&CodePointRange{From: rune(3160), To: rune(3162)},
+		&CodePointRange{From: rune(3165), To: rune(3165)},
 		&CodePointRange{From: rune(3168), To: rune(3169)},
 		&CodePointRange{From: rune(3200), To: rune(3200)},
 		&CodePointRange{From: rune(3205), To: rune(3212)},
@@ -1261,7 +1290,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(3242), To: rune(3251)},
 		&CodePointRange{From: rune(3253), To: rune(3257)},
 		&CodePointRange{From: rune(3261), To: rune(3261)},
-		&CodePointRange{From: rune(3294), To: rune(3294)},
+		&CodePointRange{From: rune(3293), To: rune(3294)},
 		&CodePointRange{From: rune(3296), To: rune(3297)},
 		&CodePointRange{From: rune(3313), To: rune(3314)},
 		&CodePointRange{From: rune(3332), To: rune(3340)},
@@ -1325,9 +1354,8 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(5761), To: rune(5786)},
 		&CodePointRange{From: rune(5792), To: rune(5866)},
 		&CodePointRange{From: rune(5873), To: rune(5880)},
-		&CodePointRange{From: rune(5888), To: rune(5900)},
-		&CodePointRange{From: rune(5902), To: rune(5905)},
-		&CodePointRange{From: rune(5920), To: rune(5937)},
+		&CodePointRange{From: rune(5888), To: rune(5905)},
+		&CodePointRange{From: rune(5919), To: rune(5937)},
 		&CodePointRange{From: rune(5952), To: rune(5969)},
 		&CodePointRange{From: rune(5984), To: rune(5996)},
 		&CodePointRange{From: rune(5998), To: rune(6000)},
@@ -1347,7 +1375,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(6656), To: rune(6678)},
 		&CodePointRange{From: rune(6688), To: rune(6740)},
 		&CodePointRange{From: rune(6917), To: rune(6963)},
-		&CodePointRange{From: rune(6981), To: rune(6987)},
+		&CodePointRange{From: rune(6981), To: rune(6988)},
 		&CodePointRange{From: rune(7043), To: rune(7072)},
 		&CodePointRange{From: rune(7086), To: rune(7087)},
 		&CodePointRange{From: rune(7098), To: rune(7141)},
@@ -1379,11 +1407,8 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(12593), To: rune(12686)},
 		&CodePointRange{From: rune(12704), To: rune(12735)},
 		&CodePointRange{From: rune(12784), To: rune(12799)},
-		&CodePointRange{From: rune(13312), To: rune(13312)},
-		&CodePointRange{From: rune(19903), To: rune(19903)},
-		&CodePointRange{From: rune(19968), To: rune(19968)},
-		&CodePointRange{From: rune(40956), To: rune(40956)},
-		&CodePointRange{From: rune(40960), To: rune(40980)},
+		&CodePointRange{From: rune(13312), To: rune(19903)},
+		&CodePointRange{From: rune(19968), To: rune(40980)},
 		&CodePointRange{From: rune(40982), To: rune(42124)},
 		&CodePointRange{From: rune(42192), To: rune(42231)},
 		&CodePointRange{From: rune(42240), To: rune(42507)},
@@ -1430,8 +1455,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(43808), To: rune(43814)},
 		&CodePointRange{From: rune(43816), To: rune(43822)},
 		&CodePointRange{From: rune(43968), To: rune(44002)},
-		&CodePointRange{From: rune(44032), To: rune(44032)},
-		&CodePointRange{From: rune(55203), To: rune(55203)},
+		&CodePointRange{From: rune(44032), To: rune(55203)},
 		&CodePointRange{From: rune(55216), To: rune(55238)},
 		&CodePointRange{From: rune(55243), To: rune(55291)},
 		&CodePointRange{From: rune(63744), To: rune(64109)},
@@ -1512,9 +1536,12 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(69376), To: rune(69404)},
 		&CodePointRange{From: rune(69415), To: rune(69415)},
 		&CodePointRange{From: rune(69424), To: rune(69445)},
+		&CodePointRange{From: rune(69488), To: rune(69505)},
 		&CodePointRange{From: rune(69552), To: rune(69572)},
 		&CodePointRange{From: rune(69600), To: rune(69622)},
 		&CodePointRange{From: rune(69635), To: rune(69687)},
+		&CodePointRange{From: rune(69745), To: rune(69746)},
+		&CodePointRange{From: rune(69749), To: rune(69749)},
 		&CodePointRange{From: rune(69763), To: rune(69807)},
 		&CodePointRange{From: rune(69840), To: rune(69864)},
 		&CodePointRange{From: rune(69891), To: rune(69926)},
@@ -1556,6 +1583,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(71296), To: rune(71338)},
 		&CodePointRange{From: rune(71352), To: rune(71352)},
 		&CodePointRange{From: rune(71424), To: rune(71450)},
+		&CodePointRange{From: rune(71488), To: rune(71494)},
 		&CodePointRange{From: rune(71680), To: rune(71723)},
 		&CodePointRange{From: rune(71935), To: rune(71942)},
 		&CodePointRange{From: rune(71945), To: rune(71945)},
@@ -1574,7 +1602,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(72272), To: rune(72272)},
 		&CodePointRange{From: rune(72284), To: rune(72329)},
 		&CodePointRange{From: rune(72349), To: rune(72349)},
-		&CodePointRange{From: rune(72384), To: rune(72440)},
+		&CodePointRange{From: rune(72368), To: rune(72440)},
 		&CodePointRange{From: rune(72704), To: rune(72712)},
 		&CodePointRange{From: rune(72714), To: rune(72750)},
 		&CodePointRange{From: rune(72768), To: rune(72768)},
@@ -1591,22 +1619,22 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(73648), To: rune(73648)},
 		&CodePointRange{From: rune(73728), To: rune(74649)},
 		&CodePointRange{From: rune(74880), To: rune(75075)},
+		&CodePointRange{From: rune(77712), To: rune(77808)},
 		&CodePointRange{From: rune(77824), To: rune(78894)},
 		&CodePointRange{From: rune(82944), To: rune(83526)},
 		&CodePointRange{From: rune(92160), To: rune(92728)},
 		&CodePointRange{From: rune(92736), To: rune(92766)},
+		&CodePointRange{From: rune(92784), To: rune(92862)},
 		&CodePointRange{From: rune(92880), To: rune(92909)},
 		&CodePointRange{From: rune(92928), To: rune(92975)},
 		&CodePointRange{From: rune(93027), To: rune(93047)},
 		&CodePointRange{From: rune(93053), To: rune(93071)},
 		&CodePointRange{From: rune(93952), To: rune(94026)},
 		&CodePointRange{From: rune(94032), To: rune(94032)},
-		&CodePointRange{From: rune(94208), To: rune(94208)},
-		&CodePointRange{From: rune(100343), To: rune(100343)},
+		&CodePointRange{From: rune(94208), To: rune(100343)},
 		&CodePointRange{From: rune(100352), To: rune(101589)},
-		&CodePointRange{From: rune(101632), To: rune(101632)},
-		&CodePointRange{From: rune(101640), To: rune(101640)},
-		&CodePointRange{From: rune(110592), To: rune(110878)},
+		&CodePointRange{From: rune(101632), To: rune(101640)},
+		&CodePointRange{From: rune(110592), To: rune(110882)},
 		&CodePointRange{From: rune(110928), To: rune(110930)},
 		&CodePointRange{From: rune(110948), To: rune(110951)},
 		&CodePointRange{From: rune(110960), To: rune(111355)},
@@ -1614,9 +1642,15 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(113776), To: rune(113788)},
 		&CodePointRange{From: rune(113792), To: rune(113800)},
 		&CodePointRange{From: rune(113808), To: rune(113817)},
+		&CodePointRange{From: rune(122634), To: rune(122634)},
 		&CodePointRange{From: rune(123136), To: rune(123180)},
 		&CodePointRange{From: rune(123214), To: rune(123214)},
+		&CodePointRange{From: rune(123536), To: rune(123565)},
 		&CodePointRange{From: rune(123584), To: rune(123627)},
+		&CodePointRange{From: rune(124896), To: rune(124902)},
+		&CodePointRange{From: rune(124904), To: rune(124907)},
+		&CodePointRange{From: rune(124909), To: rune(124910)},
+		&CodePointRange{From: rune(124912), To: rune(124926)},
 		&CodePointRange{From: rune(124928), To: rune(125124)},
 		&CodePointRange{From: rune(126464), To: rune(126467)},
 		&CodePointRange{From: rune(126469), To: rune(126495)},
@@ -1651,19 +1685,13 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(126625), To: rune(126627)},
 		&CodePointRange{From: rune(126629), To: rune(126633)},
 		&CodePointRange{From: rune(126635), To: rune(126651)},
-		&CodePointRange{From: rune(131072), To: rune(131072)},
-		&CodePointRange{From: rune(173789), To: rune(173789)},
-		&CodePointRange{From: rune(173824), To: rune(173824)},
-		&CodePointRange{From: rune(177972), To: rune(177972)},
-		&CodePointRange{From: rune(177984), To: rune(177984)},
-		&CodePointRange{From: rune(178205), To: rune(178205)},
-		&CodePointRange{From: rune(178208), To: rune(178208)},
-		&CodePointRange{From: rune(183969), To: rune(183969)},
-		&CodePointRange{From: rune(183984), To: rune(183984)},
-		&CodePointRange{From: rune(191456), To: rune(191456)},
+		&CodePointRange{From: rune(131072), To: rune(173791)},
+		&CodePointRange{From: rune(173824), To: rune(177976)},
+		&CodePointRange{From: rune(177984), To: rune(178205)},
+		&CodePointRange{From: rune(178208), To: rune(183969)},
+		&CodePointRange{From: rune(183984), To: rune(191456)},
 		&CodePointRange{From: rune(194560), To: rune(195101)},
-		&CodePointRange{From: rune(196608), To: rune(196608)},
-		&CodePointRange{From: rune(201546), To: rune(201546)},
+		&CodePointRange{From: rune(196608), To: rune(201546)},
 	},
 	"lt": {
 		&CodePointRange{From: rune(453), To: rune(453)},
@@ -2109,7 +2137,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(8510), To: rune(8511)},
 		&CodePointRange{From: rune(8517), To: rune(8517)},
 		&CodePointRange{From: rune(8579), To: rune(8579)},
-		&CodePointRange{From: rune(11264), To: rune(11310)},
+		&CodePointRange{From: rune(11264), To: rune(11311)},
 		&CodePointRange{From: rune(11360), To: rune(11360)},
 		&CodePointRange{From: rune(11362), To: rune(11364)},
 		&CodePointRange{From: rune(11367), To: rune(11367)},
@@ -2274,13 +2302,21 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(42938), To: rune(42938)},
 		&CodePointRange{From: rune(42940), To: rune(42940)},
 		&CodePointRange{From: rune(42942), To: rune(42942)},
+		&CodePointRange{From: rune(42944), To: rune(42944)},
 		&CodePointRange{From: rune(42946), To: rune(42946)},
 		&CodePointRange{From: rune(42948), To: rune(42951)},
 		&CodePointRange{From: rune(42953), To: rune(42953)},
+		&CodePointRange{From: rune(42960), To: rune(42960)},
+		&CodePointRange{From: rune(42966), To: rune(42966)},
+		&CodePointRange{From: rune(42968), To: rune(42968)},
 		&CodePointRange{From: rune(42997), To: rune(42997)},
 		&CodePointRange{From: rune(65313), To: rune(65338)},
 		&CodePointRange{From: rune(66560), To: rune(66599)},
 		&CodePointRange{From: rune(66736), To: rune(66771)},
+		&CodePointRange{From: rune(66928), To: rune(66938)},
+		&CodePointRange{From: rune(66940), To: rune(66954)},
+		&CodePointRange{From: rune(66956), To: rune(66962)},
+		&CodePointRange{From: rune(66964), To: rune(66965)},
 		&CodePointRange{From: rune(68736), To: rune(68786)},
 		&CodePointRange{From: rune(71840), To: rune(71871)},
 		&CodePointRange{From: rune(93760), To: rune(93791)},
@@ -2375,6 +2411,8 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(4231), To: rune(4236)},
 		&CodePointRange{From: rune(4239), To: rune(4239)},
 		&CodePointRange{From: rune(4250), To: rune(4252)},
+		&CodePointRange{From: rune(5909), To: rune(5909)},
+		&CodePointRange{From: rune(5940), To: rune(5940)},
 		&CodePointRange{From: rune(6070), To: rune(6070)},
 		&CodePointRange{From: rune(6078), To: rune(6085)},
 		&CodePointRange{From: rune(6087), To: rune(6088)},
@@ -2526,7 +2564,8 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(2085), To: rune(2087)},
 		&CodePointRange{From: rune(2089), To: rune(2093)},
 		&CodePointRange{From: rune(2137), To: rune(2139)},
-		&CodePointRange{From: rune(2259), To: rune(2273)},
+		&CodePointRange{From: rune(2200), To: rune(2207)},
+		&CodePointRange{From: rune(2250), To: rune(2273)},
 		&CodePointRange{From: rune(2275), To: rune(2306)},
 		&CodePointRange{From: rune(2362), To: rune(2362)},
 		&CodePointRange{From: rune(2364), To: rune(2364)},
@@ -2567,6 +2606,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(3021), To: rune(3021)},
 		&CodePointRange{From: rune(3072), To: rune(3072)},
 		&CodePointRange{From: rune(3076), To: rune(3076)},
+		&CodePointRange{From: rune(3132), To: rune(3132)},
 		&CodePointRange{From: rune(3134), To: rune(3136)},
 		&CodePointRange{From: rune(3142), To: rune(3144)},
 		&CodePointRange{From: rune(3146), To: rune(3149)},
@@ -2616,7 +2656,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(4253), To: rune(4253)},
 		&CodePointRange{From: rune(4957), To: rune(4959)},
 		&CodePointRange{From: rune(5906), To: rune(5908)},
-		&CodePointRange{From: rune(5938), To: rune(5940)},
+		&CodePointRange{From: rune(5938), To: rune(5939)},
 		&CodePointRange{From: rune(5970), To: rune(5971)},
 		&CodePointRange{From: rune(6002), To: rune(6003)},
 		&CodePointRange{From: rune(6068), To: rune(6069)},
@@ -2625,6 +2665,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(6089), To: rune(6099)},
 		&CodePointRange{From: rune(6109), To: rune(6109)},
 		&CodePointRange{From: rune(6155), To: rune(6157)},
+		&CodePointRange{From: rune(6159), To: rune(6159)},
 		&CodePointRange{From: rune(6277), To: rune(6278)},
 		&CodePointRange{From: rune(6313), To: rune(6313)},
 		&CodePointRange{From: rune(6432), To: rune(6434)},
@@ -2641,7 +2682,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(6771), To: rune(6780)},
 		&CodePointRange{From: rune(6783), To: rune(6783)},
 		&CodePointRange{From: rune(6832), To: rune(6845)},
-		&CodePointRange{From: rune(6847), To: rune(6848)},
+		&CodePointRange{From: rune(6847), To: rune(6862)},
 		&CodePointRange{From: rune(6912), To: rune(6915)},
 		&CodePointRange{From: rune(6964), To: rune(6964)},
 		&CodePointRange{From: rune(6966), To: rune(6970)},
@@ -2664,8 +2705,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(7405), To: rune(7405)},
 		&CodePointRange{From: rune(7412), To: rune(7412)},
 		&CodePointRange{From: rune(7416), To: rune(7417)},
-		&CodePointRange{From: rune(7616), To: rune(7673)},
-		&CodePointRange{From: rune(7675), To: rune(7679)},
+		&CodePointRange{From: rune(7616), To: rune(7679)},
 		&CodePointRange{From: rune(8400), To: rune(8412)},
 		&CodePointRange{From: rune(8417), To: rune(8417)},
 		&CodePointRange{From: rune(8421), To: rune(8432)},
@@ -2724,11 +2764,15 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(68900), To: rune(68903)},
 		&CodePointRange{From: rune(69291), To: rune(69292)},
 		&CodePointRange{From: rune(69446), To: rune(69456)},
+		&CodePointRange{From: rune(69506), To: rune(69509)},
 		&CodePointRange{From: rune(69633), To: rune(69633)},
 		&CodePointRange{From: rune(69688), To: rune(69702)},
+		&CodePointRange{From: rune(69744), To: rune(69744)},
+		&CodePointRange{From: rune(69747), To: rune(69748)},
 		&CodePointRange{From: rune(69759), To: rune(69761)},
 		&CodePointRange{From: rune(69811), To: rune(69814)},
 		&CodePointRange{From: rune(69817), To: rune(69818)},
+		&CodePointRange{From: rune(69826), To: rune(69826)},
 		&CodePointRange{From: rune(69888), To: rune(69890)},
 		&CodePointRange{From: rune(69927), To: rune(69931)},
 		&CodePointRange{From: rune(69933), To: rune(69940)},
@@ -2808,6 +2852,8 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(94095), To: rune(94098)},
 		&CodePointRange{From: rune(94180), To: rune(94180)},
 		&CodePointRange{From: rune(113821), To: rune(113822)},
+		&CodePointRange{From: rune(118528), To: rune(118573)},
+		&CodePointRange{From: rune(118576), To: rune(118598)},
 		&CodePointRange{From: rune(119143), To: rune(119145)},
 		&CodePointRange{From: rune(119163), To: rune(119170)},
 		&CodePointRange{From: rune(119173), To: rune(119179)},
@@ -2825,6 +2871,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(122915), To: rune(122916)},
 		&CodePointRange{From: rune(122918), To: rune(122922)},
 		&CodePointRange{From: rune(123184), To: rune(123190)},
+		&CodePointRange{From: rune(123566), To: rune(123566)},
 		&CodePointRange{From: rune(123628), To: rune(123631)},
 		&CodePointRange{From: rune(125136), To: rune(125142)},
 		&CodePointRange{From: rune(125252), To: rune(125258)},
@@ -2886,6 +2933,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(73040), To: rune(73049)},
 		&CodePointRange{From: rune(73120), To: rune(73129)},
 		&CodePointRange{From: rune(92768), To: rune(92777)},
+		&CodePointRange{From: rune(92864), To: rune(92873)},
 		&CodePointRange{From: rune(93008), To: rune(93017)},
 		&CodePointRange{From: rune(120782), To: rune(120831)},
 		&CodePointRange{From: rune(123200), To: rune(123209)},
@@ -2999,6 +3047,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(11802), To: rune(11802)},
 		&CodePointRange{From: rune(11834), To: rune(11835)},
 		&CodePointRange{From: rune(11840), To: rune(11840)},
+		&CodePointRange{From: rune(11869), To: rune(11869)},
 		&CodePointRange{From: rune(12316), To: rune(12316)},
 		&CodePointRange{From: rune(12336), To: rune(12336)},
 		&CodePointRange{From: rune(12448), To: rune(12448)},
@@ -3052,6 +3101,10 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(11813), To: rune(11813)},
 		&CodePointRange{From: rune(11815), To: rune(11815)},
 		&CodePointRange{From: rune(11817), To: rune(11817)},
+		&CodePointRange{From: rune(11862), To: rune(11862)},
+		&CodePointRange{From: rune(11864), To: rune(11864)},
+		&CodePointRange{From: rune(11866), To: rune(11866)},
+		&CodePointRange{From: rune(11868), To: rune(11868)},
 		&CodePointRange{From: rune(12297), To: rune(12297)},
 		&CodePointRange{From: rune(12299), To: rune(12299)},
 		&CodePointRange{From: rune(12301), To: rune(12301)},
@@ -3131,7 +3184,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(1545), To: rune(1546)},
 		&CodePointRange{From: rune(1548), To: rune(1549)},
 		&CodePointRange{From: rune(1563), To: rune(1563)},
-		&CodePointRange{From: rune(1566), To: rune(1567)},
+		&CodePointRange{From: rune(1565), To: rune(1567)},
 		&CodePointRange{From: rune(1642), To: rune(1645)},
 		&CodePointRange{From: rune(1748), To: rune(1748)},
 		&CodePointRange{From: rune(1792), To: rune(1805)},
@@ -3168,6 +3221,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(6816), To: rune(6822)},
 		&CodePointRange{From: rune(6824), To: rune(6829)},
 		&CodePointRange{From: rune(7002), To: rune(7008)},
+		&CodePointRange{From: rune(7037), To: rune(7038)},
 		&CodePointRange{From: rune(7164), To: rune(7167)},
 		&CodePointRange{From: rune(7227), To: rune(7231)},
 		&CodePointRange{From: rune(7294), To: rune(7295)},
@@ -3196,7 +3250,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(11836), To: rune(11839)},
 		&CodePointRange{From: rune(11841), To: rune(11841)},
 		&CodePointRange{From: rune(11843), To: rune(11855)},
-		&CodePointRange{From: rune(11858), To: rune(11858)},
+		&CodePointRange{From: rune(11858), To: rune(11860)},
 		&CodePointRange{From: rune(12289), To: rune(12291)},
 		&CodePointRange{From: rune(12349), To: rune(12349)},
 		&CodePointRange{From: rune(12539), To: rune(12539)},
@@ -3250,6 +3304,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(68409), To: rune(68415)},
 		&CodePointRange{From: rune(68505), To: rune(68508)},
 		&CodePointRange{From: rune(69461), To: rune(69465)},
+		&CodePointRange{From: rune(69510), To: rune(69513)},
 		&CodePointRange{From: rune(69703), To: rune(69709)},
 		&CodePointRange{From: rune(69819), To: rune(69820)},
 		&CodePointRange{From: rune(69822), To: rune(69825)},
@@ -3268,6 +3323,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(71105), To: rune(71127)},
 		&CodePointRange{From: rune(71233), To: rune(71235)},
 		&CodePointRange{From: rune(71264), To: rune(71276)},
+		&CodePointRange{From: rune(71353), To: rune(71353)},
 		&CodePointRange{From: rune(71484), To: rune(71486)},
 		&CodePointRange{From: rune(71739), To: rune(71739)},
 		&CodePointRange{From: rune(72004), To: rune(72006)},
@@ -3280,6 +3336,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(73463), To: rune(73464)},
 		&CodePointRange{From: rune(73727), To: rune(73727)},
 		&CodePointRange{From: rune(74864), To: rune(74868)},
+		&CodePointRange{From: rune(77809), To: rune(77810)},
 		&CodePointRange{From: rune(92782), To: rune(92783)},
 		&CodePointRange{From: rune(92917), To: rune(92917)},
 		&CodePointRange{From: rune(92983), To: rune(92987)},
@@ -3337,6 +3394,10 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(11814), To: rune(11814)},
 		&CodePointRange{From: rune(11816), To: rune(11816)},
 		&CodePointRange{From: rune(11842), To: rune(11842)},
+		&CodePointRange{From: rune(11861), To: rune(11861)},
+		&CodePointRange{From: rune(11863), To: rune(11863)},
+		&CodePointRange{From: rune(11865), To: rune(11865)},
+		&CodePointRange{From: rune(11867), To: rune(11867)},
 		&CodePointRange{From: rune(12296), To: rune(12296)},
 		&CodePointRange{From: rune(12298), To: rune(12298)},
 		&CodePointRange{From: rune(12300), To: rune(12300)},
@@ -3379,7 +3440,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(3065), To: rune(3065)},
 		&CodePointRange{From: rune(3647), To: rune(3647)},
 		&CodePointRange{From: rune(6107), To: rune(6107)},
-		&CodePointRange{From: rune(8352), To: rune(8383)},
+		&CodePointRange{From: rune(8352), To: rune(8384)},
 		&CodePointRange{From: rune(43064), To: rune(43064)},
 		&CodePointRange{From: rune(65020), To: rune(65020)},
 		&CodePointRange{From: rune(65129), To: rune(65129)},
@@ -3404,6 +3465,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(751), To: rune(767)},
 		&CodePointRange{From: rune(885), To: rune(885)},
 		&CodePointRange{From: rune(900), To: rune(901)},
+		&CodePointRange{From: rune(2184), To: rune(2184)},
 		&CodePointRange{From: rune(8125), To: rune(8125)},
 		&CodePointRange{From: rune(8127), To: rune(8129)},
 		&CodePointRange{From: rune(8141), To: rune(8143)},
@@ -3416,7 +3478,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(42889), To: rune(42890)},
 		&CodePointRange{From: rune(43867), To: rune(43867)},
 		&CodePointRange{From: rune(43882), To: rune(43883)},
-		&CodePointRange{From: rune(64434), To: rune(64449)},
+		&CodePointRange{From: rune(64434), To: rune(64450)},
 		&CodePointRange{From: rune(65342), To: rune(65342)},
 		&CodePointRange{From: rune(65344), To: rune(65344)},
 		&CodePointRange{From: rune(65507), To: rune(65507)},
@@ -3596,7 +3658,9 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(43062), To: rune(43063)},
 		&CodePointRange{From: rune(43065), To: rune(43065)},
 		&CodePointRange{From: rune(43639), To: rune(43641)},
-		&CodePointRange{From: rune(65021), To: rune(65021)},
+		&CodePointRange{From: rune(64832), To: rune(64847)},
+		&CodePointRange{From: rune(64975), To: rune(64975)},
+		&CodePointRange{From: rune(65021), To: rune(65023)},
 		&CodePointRange{From: rune(65508), To: rune(65508)},
 		&CodePointRange{From: rune(65512), To: rune(65512)},
 		&CodePointRange{From: rune(65517), To: rune(65518)},
@@ -3615,13 +3679,14 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(92988), To: rune(92991)},
 		&CodePointRange{From: rune(92997), To: rune(92997)},
 		&CodePointRange{From: rune(113820), To: rune(113820)},
+		&CodePointRange{From: rune(118608), To: rune(118723)},
 		&CodePointRange{From: rune(118784), To: rune(119029)},
 		&CodePointRange{From: rune(119040), To: rune(119078)},
 		&CodePointRange{From: rune(119081), To: rune(119140)},
 		&CodePointRange{From: rune(119146), To: rune(119148)},
 		&CodePointRange{From: rune(119171), To: rune(119172)},
 		&CodePointRange{From: rune(119180), To: rune(119209)},
-		&CodePointRange{From: rune(119214), To: rune(119272)},
+		&CodePointRange{From: rune(119214), To: rune(119274)},
 		&CodePointRange{From: rune(119296), To: rune(119361)},
 		&CodePointRange{From: rune(119365), To: rune(119365)},
 		&CodePointRange{From: rune(119552), To: rune(119638)},
@@ -3647,28 +3712,29 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(127584), To: rune(127589)},
 		&CodePointRange{From: rune(127744), To: rune(127994)},
 		&CodePointRange{From: rune(128000), To: rune(128727)},
-		&CodePointRange{From: rune(128736), To: rune(128748)},
+		&CodePointRange{From: rune(128733), To: rune(128748)},
 		&CodePointRange{From: rune(128752), To: rune(128764)},
 		&CodePointRange{From: rune(128768), To: rune(128883)},
 		&CodePointRange{From: rune(128896), To: rune(128984)},
 		&CodePointRange{From: rune(128992), To: rune(129003)},
+		&CodePointRange{From: rune(129008), To: rune(129008)},
 		&CodePointRange{From: rune(129024), To: rune(129035)},
 		&CodePointRange{From: rune(129040), To: rune(129095)},
 		&CodePointRange{From: rune(129104), To: rune(129113)},
 		&CodePointRange{From: rune(129120), To: rune(129159)},
 		&CodePointRange{From: rune(129168), To: rune(129197)},
 		&CodePointRange{From: rune(129200), To: rune(129201)},
-		&CodePointRange{From: rune(129280), To: rune(129400)},
-		&CodePointRange{From: rune(129402), To: rune(129483)},
-		&CodePointRange{From: rune(129485), To: rune(129619)},
+		&CodePointRange{From: rune(129280), To: rune(129619)},
 		&CodePointRange{From: rune(129632), To: rune(129645)},
 		&CodePointRange{From: rune(129648), To: rune(129652)},
-		&CodePointRange{From: rune(129656), To: rune(129658)},
+		&CodePointRange{From: rune(129656), To: rune(129660)},
 		&CodePointRange{From: rune(129664), To: rune(129670)},
-		&CodePointRange{From: rune(129680), To: rune(129704)},
-		&CodePointRange{From: rune(129712), To: rune(129718)},
-		&CodePointRange{From: rune(129728), To: rune(129730)},
-		&CodePointRange{From: rune(129744), To: rune(129750)},
+		&CodePointRange{From: rune(129680), To: rune(129708)},
+		&CodePointRange{From: rune(129712), To: rune(129722)},
+		&CodePointRange{From: rune(129728), To: rune(129733)},
+		&CodePointRange{From: rune(129744), To: rune(129753)},
+		&CodePointRange{From: rune(129760), To: rune(129767)},
+		&CodePointRange{From: rune(129776), To: rune(129782)},
 		&CodePointRange{From: rune(129792), To: rune(129938)},
 		&CodePointRange{From: rune(129940), To: rune(129994)},
 	},
@@ -3689,7 +3755,7 @@ var generalCategoryCodePoints = map[string][]*CodePointRange{
 	},
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/Scripts.txt
+// https://www.unicode.org/Public/14.0.0/ucd/Scripts.txt
 var (
 	scriptDefaultRange = &CodePointRange{
 		From: rune(0),
@@ -3698,7 +3764,7 @@ var (
 	scriptDefaultValue = "unknown"
 )
 
-// https://www.unicode.org/Public/13.0.0/ucd/Scripts.txt
+// https://www.unicode.org/Public/14.0.0/ucd/Scripts.txt
 var scriptCodepoints = map[string][]*CodePointRange{
 	"adlm": {
 		&CodePointRange{From: rune(125184), To: rune(125251)},
@@ -3722,6 +3788,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(71482), To: rune(71483)},
 		&CodePointRange{From: rune(71484), To: rune(71486)},
 		&CodePointRange{From: rune(71487), To: rune(71487)},
+		&CodePointRange{From: rune(71488), To: rune(71494)},
 	},
 	"arab": {
 		&CodePointRange{From: rune(1536), To: rune(1540)},
@@ -3732,7 +3799,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(1550), To: rune(1551)},
 		&CodePointRange{From: rune(1552), To: rune(1562)},
 		&CodePointRange{From: rune(1564), To: rune(1564)},
-		&CodePointRange{From: rune(1566), To: rune(1566)},
+		&CodePointRange{From: rune(1565), To: rune(1566)},
 		&CodePointRange{From: rune(1568), To: rune(1599)},
 		&CodePointRange{From: rune(1601), To: rune(1610)},
 		&CodePointRange{From: rune(1622), To: rune(1631)},
@@ -3755,18 +3822,25 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(1789), To: rune(1790)},
 		&CodePointRange{From: rune(1791), To: rune(1791)},
 		&CodePointRange{From: rune(1872), To: rune(1919)},
-		&CodePointRange{From: rune(2208), To: rune(2228)},
-		&CodePointRange{From: rune(2230), To: rune(2247)},
-		&CodePointRange{From: rune(2259), To: rune(2273)},
+		&CodePointRange{From: rune(2160), To: rune(2183)},
+		&CodePointRange{From: rune(2184), To: rune(2184)},
+		&CodePointRange{From: rune(2185), To: rune(2190)},
+		&CodePointRange{From: rune(2192), To: rune(2193)},
+		&CodePointRange{From: rune(2200), To: rune(2207)},
+		&CodePointRange{From: rune(2208), To: rune(2248)},
+		&CodePointRange{From: rune(2249), To: rune(2249)},
+		&CodePointRange{From: rune(2250), To: rune(2273)},
 		&CodePointRange{From: rune(2275), To: rune(2303)},
 		&CodePointRange{From: rune(64336), To: rune(64433)},
-		&CodePointRange{From: rune(64434), To: rune(64449)},
+		&CodePointRange{From: rune(64434), To: rune(64450)},
 		&CodePointRange{From: rune(64467), To: rune(64829)},
+		&CodePointRange{From: rune(64832), To: rune(64847)},
 		&CodePointRange{From: rune(64848), To: rune(64911)},
 		&CodePointRange{From: rune(64914), To: rune(64967)},
+		&CodePointRange{From: rune(64975), To: rune(64975)},
 		&CodePointRange{From: rune(65008), To: rune(65019)},
 		&CodePointRange{From: rune(65020), To: rune(65020)},
-		&CodePointRange{From: rune(65021), To: rune(65021)},
+		&CodePointRange{From: rune(65021), To: rune(65023)},
 		&CodePointRange{From: rune(65136), To: rune(65140)},
 		&CodePointRange{From: rune(65142), To: rune(65276)},
 		&CodePointRange{From: rune(69216), To: rune(69246)},
@@ -3837,12 +3911,13 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(6973), To: rune(6977)},
 		&CodePointRange{From: rune(6978), To: rune(6978)},
 		&CodePointRange{From: rune(6979), To: rune(6980)},
-		&CodePointRange{From: rune(6981), To: rune(6987)},
+		&CodePointRange{From: rune(6981), To: rune(6988)},
 		&CodePointRange{From: rune(6992), To: rune(7001)},
 		&CodePointRange{From: rune(7002), To: rune(7008)},
 		&CodePointRange{From: rune(7009), To: rune(7018)},
 		&CodePointRange{From: rune(7019), To: rune(7027)},
 		&CodePointRange{From: rune(7028), To: rune(7036)},
+		&CodePointRange{From: rune(7037), To: rune(7038)},
 	},
 	"bamu": {
 		&CodePointRange{From: rune(42656), To: rune(42725)},
@@ -3927,6 +4002,10 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(69703), To: rune(69709)},
 		&CodePointRange{From: rune(69714), To: rune(69733)},
 		&CodePointRange{From: rune(69734), To: rune(69743)},
+		&CodePointRange{From: rune(69744), To: rune(69744)},
+		&CodePointRange{From: rune(69745), To: rune(69746)},
+		&CodePointRange{From: rune(69747), To: rune(69748)},
+		&CodePointRange{From: rune(69749), To: rune(69749)},
 		&CodePointRange{From: rune(69759), To: rune(69759)},
 	},
 	"brai": {
@@ -3962,6 +4041,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(5742), To: rune(5742)},
 		&CodePointRange{From: rune(5743), To: rune(5759)},
 		&CodePointRange{From: rune(6320), To: rune(6389)},
+		&CodePointRange{From: rune(72368), To: rune(72383)},
 	},
 	"cari": {
 		&CodePointRange{From: rune(66208), To: rune(66256)},
@@ -4001,6 +4081,10 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(11517), To: rune(11517)},
 		&CodePointRange{From: rune(11518), To: rune(11519)},
 	},
+	"cpmn": {
+		&CodePointRange{From: rune(77712), To: rune(77808)},
+		&CodePointRange{From: rune(77809), To: rune(77810)},
+	},
 	"cprt": {
 		&CodePointRange{From: rune(67584), To: rune(67589)},
 		&CodePointRange{From: rune(67592), To: rune(67592)},
@@ -4147,6 +4231,10 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(43793), To: rune(43798)},
 		&CodePointRange{From: rune(43808), To: rune(43814)},
 		&CodePointRange{From: rune(43816), To: rune(43822)},
+		&CodePointRange{From: rune(124896), To: rune(124902)},
+		&CodePointRange{From: rune(124904), To: rune(124907)},
+		&CodePointRange{From: rune(124909), To: rune(124910)},
+		&CodePointRange{From: rune(124912), To: rune(124926)},
 	},
 	"geor": {
 		&CodePointRange{From: rune(4256), To: rune(4293)},
@@ -4162,8 +4250,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(11565), To: rune(11565)},
 	},
 	"glag": {
-		&CodePointRange{From: rune(11264), To: rune(11310)},
-		&CodePointRange{From: rune(11312), To: rune(11358)},
+		&CodePointRange{From: rune(11264), To: rune(11359)},
 		&CodePointRange{From: rune(122880), To: rune(122886)},
 		&CodePointRange{From: rune(122888), To: rune(122904)},
 		&CodePointRange{From: rune(122907), To: rune(122913)},
@@ -4357,12 +4444,14 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(12344), To: rune(12346)},
 		&CodePointRange{From: rune(12347), To: rune(12347)},
 		&CodePointRange{From: rune(13312), To: rune(19903)},
-		&CodePointRange{From: rune(19968), To: rune(40956)},
+		&CodePointRange{From: rune(19968), To: rune(40959)},
 		&CodePointRange{From: rune(63744), To: rune(64109)},
 		&CodePointRange{From: rune(64112), To: rune(64217)},
+		&CodePointRange{From: rune(94178), To: rune(94178)},
+		&CodePointRange{From: rune(94179), To: rune(94179)},
 		&CodePointRange{From: rune(94192), To: rune(94193)},
-		&CodePointRange{From: rune(131072), To: rune(173789)},
-		&CodePointRange{From: rune(173824), To: rune(177972)},
+		&CodePointRange{From: rune(131072), To: rune(173791)},
+		&CodePointRange{From: rune(173824), To: rune(177976)},
 		&CodePointRange{From: rune(177984), To: rune(178205)},
 		&CodePointRange{From: rune(178208), To: rune(183969)},
 		&CodePointRange{From: rune(183984), To: rune(191456)},
@@ -4371,7 +4460,8 @@ var scriptCodepoints = map[string][]*CodePointRange{
 	},
 	"hano": {
 		&CodePointRange{From: rune(5920), To: rune(5937)},
-		&CodePointRange{From: rune(5938), To: rune(5940)},
+		&CodePointRange{From: rune(5938), To: rune(5939)},
+		&CodePointRange{From: rune(5940), To: rune(5940)},
 	},
 	"hatr": {
 		&CodePointRange{From: rune(67808), To: rune(67826)},
@@ -4406,7 +4496,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(12353), To: rune(12438)},
 		&CodePointRange{From: rune(12445), To: rune(12446)},
 		&CodePointRange{From: rune(12447), To: rune(12447)},
-		&CodePointRange{From: rune(110593), To: rune(110878)},
+		&CodePointRange{From: rune(110593), To: rune(110879)},
 		&CodePointRange{From: rune(110928), To: rune(110930)},
 		&CodePointRange{From: rune(127488), To: rune(127488)},
 	},
@@ -4473,7 +4563,11 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(13056), To: rune(13143)},
 		&CodePointRange{From: rune(65382), To: rune(65391)},
 		&CodePointRange{From: rune(65393), To: rune(65437)},
+		&CodePointRange{From: rune(110576), To: rune(110579)},
+		&CodePointRange{From: rune(110581), To: rune(110587)},
+		&CodePointRange{From: rune(110589), To: rune(110590)},
 		&CodePointRange{From: rune(110592), To: rune(110592)},
+		&CodePointRange{From: rune(110880), To: rune(110882)},
 		&CodePointRange{From: rune(110948), To: rune(110951)},
 	},
 	"khar": {
@@ -4544,7 +4638,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(3274), To: rune(3275)},
 		&CodePointRange{From: rune(3276), To: rune(3277)},
 		&CodePointRange{From: rune(3285), To: rune(3286)},
-		&CodePointRange{From: rune(3294), To: rune(3294)},
+		&CodePointRange{From: rune(3293), To: rune(3294)},
 		&CodePointRange{From: rune(3296), To: rune(3297)},
 		&CodePointRange{From: rune(3298), To: rune(3299)},
 		&CodePointRange{From: rune(3302), To: rune(3311)},
@@ -4561,6 +4655,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(69819), To: rune(69820)},
 		&CodePointRange{From: rune(69821), To: rune(69821)},
 		&CodePointRange{From: rune(69822), To: rune(69825)},
+		&CodePointRange{From: rune(69826), To: rune(69826)},
 		&CodePointRange{From: rune(69837), To: rune(69837)},
 	},
 	"lana": {
@@ -4640,8 +4735,11 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(42865), To: rune(42887)},
 		&CodePointRange{From: rune(42891), To: rune(42894)},
 		&CodePointRange{From: rune(42895), To: rune(42895)},
-		&CodePointRange{From: rune(42896), To: rune(42943)},
-		&CodePointRange{From: rune(42946), To: rune(42954)},
+		&CodePointRange{From: rune(42896), To: rune(42954)},
+		&CodePointRange{From: rune(42960), To: rune(42961)},
+		&CodePointRange{From: rune(42963), To: rune(42963)},
+		&CodePointRange{From: rune(42965), To: rune(42969)},
+		&CodePointRange{From: rune(42994), To: rune(42996)},
 		&CodePointRange{From: rune(42997), To: rune(42998)},
 		&CodePointRange{From: rune(42999), To: rune(42999)},
 		&CodePointRange{From: rune(43000), To: rune(43001)},
@@ -4655,6 +4753,12 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(64256), To: rune(64262)},
 		&CodePointRange{From: rune(65313), To: rune(65338)},
 		&CodePointRange{From: rune(65345), To: rune(65370)},
+		&CodePointRange{From: rune(67456), To: rune(67461)},
+		&CodePointRange{From: rune(67463), To: rune(67504)},
+		&CodePointRange{From: rune(67506), To: rune(67514)},
+		&CodePointRange{From: rune(122624), To: rune(122633)},
+		&CodePointRange{From: rune(122634), To: rune(122634)},
+		&CodePointRange{From: rune(122635), To: rune(122654)},
 	},
 	"lepc": {
 		&CodePointRange{From: rune(7168), To: rune(7203)},
@@ -4807,6 +4911,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(6151), To: rune(6154)},
 		&CodePointRange{From: rune(6155), To: rune(6157)},
 		&CodePointRange{From: rune(6158), To: rune(6158)},
+		&CodePointRange{From: rune(6159), To: rune(6159)},
 		&CodePointRange{From: rune(6160), To: rune(6169)},
 		&CodePointRange{From: rune(6176), To: rune(6210)},
 		&CodePointRange{From: rune(6211), To: rune(6211)},
@@ -5007,6 +5112,11 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(66688), To: rune(66717)},
 		&CodePointRange{From: rune(66720), To: rune(66729)},
 	},
+	"ougr": {
+		&CodePointRange{From: rune(69488), To: rune(69505)},
+		&CodePointRange{From: rune(69506), To: rune(69509)},
+		&CodePointRange{From: rune(69510), To: rune(69513)},
+	},
 	"palm": {
 		&CodePointRange{From: rune(67680), To: rune(67702)},
 		&CodePointRange{From: rune(67703), To: rune(67704)},
@@ -5244,6 +5354,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(71350), To: rune(71350)},
 		&CodePointRange{From: rune(71351), To: rune(71351)},
 		&CodePointRange{From: rune(71352), To: rune(71352)},
+		&CodePointRange{From: rune(71353), To: rune(71353)},
 		&CodePointRange{From: rune(71360), To: rune(71369)},
 	},
 	"tale": {
@@ -5318,6 +5429,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(3086), To: rune(3088)},
 		&CodePointRange{From: rune(3090), To: rune(3112)},
 		&CodePointRange{From: rune(3114), To: rune(3129)},
+		&CodePointRange{From: rune(3132), To: rune(3132)},
 		&CodePointRange{From: rune(3133), To: rune(3133)},
 		&CodePointRange{From: rune(3134), To: rune(3136)},
 		&CodePointRange{From: rune(3137), To: rune(3140)},
@@ -5325,6 +5437,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(3146), To: rune(3149)},
 		&CodePointRange{From: rune(3157), To: rune(3158)},
 		&CodePointRange{From: rune(3160), To: rune(3162)},
+		&CodePointRange{From: rune(3165), To: rune(3165)},
 		&CodePointRange{From: rune(3168), To: rune(3169)},
 		&CodePointRange{From: rune(3170), To: rune(3171)},
 		&CodePointRange{From: rune(3174), To: rune(3183)},
@@ -5339,9 +5452,10 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(11647), To: rune(11647)},
 	},
 	"tglg": {
-		&CodePointRange{From: rune(5888), To: rune(5900)},
-		&CodePointRange{From: rune(5902), To: rune(5905)},
+		&CodePointRange{From: rune(5888), To: rune(5905)},
 		&CodePointRange{From: rune(5906), To: rune(5908)},
+		&CodePointRange{From: rune(5909), To: rune(5909)},
+		&CodePointRange{From: rune(5919), To: rune(5919)},
 	},
 	"thaa": {
 		&CodePointRange{From: rune(1920), To: rune(1957)},
@@ -5414,6 +5528,14 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(70855), To: rune(70855)},
 		&CodePointRange{From: rune(70864), To: rune(70873)},
 	},
+	"tnsa": {
+		&CodePointRange{From: rune(92784), To: rune(92862)},
+		&CodePointRange{From: rune(92864), To: rune(92873)},
+	},
+	"toto": {
+		&CodePointRange{From: rune(123536), To: rune(123565)},
+		&CodePointRange{From: rune(123566), To: rune(123566)},
+	},
 	"ugar": {
 		&CodePointRange{From: rune(66432), To: rune(66461)},
 		&CodePointRange{From: rune(66463), To: rune(66463)},
@@ -5426,6 +5548,16 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(42528), To: rune(42537)},
 		&CodePointRange{From: rune(42538), To: rune(42539)},
 	},
+	"vith": {
+		&CodePointRange{From: rune(66928), To: rune(66938)},
+		&CodePointRange{From: rune(66940), To: rune(66954)},
+		&CodePointRange{From: rune(66956), To: rune(66962)},
+		&CodePointRange{From: rune(66964), To: rune(66965)},
+		&CodePointRange{From: rune(66967), To: rune(66977)},
+		&CodePointRange{From: rune(66979), To: rune(66993)},
+		&CodePointRange{From: rune(66995), To: rune(67001)},
+		&CodePointRange{From: rune(67003), To: rune(67004)},
+	},
 	"wara": {
 		&CodePointRange{From: rune(71840), To: rune(71903)},
 		&CodePointRange{From: rune(71904), To: rune(71913)},
@@ -5481,15 +5613,14 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(2385), To: rune(2388)},
 		&CodePointRange{From: rune(6832), To: rune(6845)},
 		&CodePointRange{From: rune(6846), To: rune(6846)},
-		&CodePointRange{From: rune(6847), To: rune(6848)},
+		&CodePointRange{From: rune(6847), To: rune(6862)},
 		&CodePointRange{From: rune(7376), To: rune(7378)},
 		&CodePointRange{From: rune(7380), To: rune(7392)},
 		&CodePointRange{From: rune(7394), To: rune(7400)},
 		&CodePointRange{From: rune(7405), To: rune(7405)},
 		&CodePointRange{From: rune(7412), To: rune(7412)},
 		&CodePointRange{From: rune(7416), To: rune(7417)},
-		&CodePointRange{From: rune(7616), To: rune(7673)},
-		&CodePointRange{From: rune(7675), To: rune(7679)},
+		&CodePointRange{From: rune(7616), To: rune(7679)},
 		&CodePointRange{From: rune(8204), To: rune(8205)},
 		&CodePointRange{From: rune(8400), To: rune(8412)},
 		&CodePointRange{From: rune(8413), To: rune(8416)},
@@ -5503,6 +5634,8 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(66045), To: rune(66045)},
 		&CodePointRange{From: rune(66272), To: rune(66272)},
 		&CodePointRange{From: rune(70459), To: rune(70459)},
+		&CodePointRange{From: rune(118528), To: rune(118573)},
+		&CodePointRange{From: rune(118576), To: rune(118598)},
 		&CodePointRange{From: rune(119143), To: rune(119145)},
 		&CodePointRange{From: rune(119163), To: rune(119170)},
 		&CodePointRange{From: rune(119173), To: rune(119179)},
@@ -5640,7 +5773,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(8330), To: rune(8332)},
 		&CodePointRange{From: rune(8333), To: rune(8333)},
 		&CodePointRange{From: rune(8334), To: rune(8334)},
-		&CodePointRange{From: rune(8352), To: rune(8383)},
+		&CodePointRange{From: rune(8352), To: rune(8384)},
 		&CodePointRange{From: rune(8448), To: rune(8449)},
 		&CodePointRange{From: rune(8450), To: rune(8450)},
 		&CodePointRange{From: rune(8451), To: rune(8454)},
@@ -5833,7 +5966,16 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(11842), To: rune(11842)},
 		&CodePointRange{From: rune(11843), To: rune(11855)},
 		&CodePointRange{From: rune(11856), To: rune(11857)},
-		&CodePointRange{From: rune(11858), To: rune(11858)},
+		&CodePointRange{From: rune(11858), To: rune(11860)},
+		&CodePointRange{From: rune(11861), To: rune(11861)},
+		&CodePointRange{From: rune(11862), To: rune(11862)},
+		&CodePointRange{From: rune(11863), To: rune(11863)},
+		&CodePointRange{From: rune(11864), To: rune(11864)},
+		&CodePointRange{From: rune(11865), To: rune(11865)},
+		&CodePointRange{From: rune(11866), To: rune(11866)},
+		&CodePointRange{From: rune(11867), To: rune(11867)},
+		&CodePointRange{From: rune(11868), To: rune(11868)},
+		&CodePointRange{From: rune(11869), To: rune(11869)},
 		&CodePointRange{From: rune(12272), To: rune(12283)},
 		&CodePointRange{From: rune(12288), To: rune(12288)},
 		&CodePointRange{From: rune(12289), To: rune(12291)},
@@ -5997,9 +6139,8 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(65936), To: rune(65948)},
 		&CodePointRange{From: rune(66000), To: rune(66044)},
 		&CodePointRange{From: rune(66273), To: rune(66299)},
-		&CodePointRange{From: rune(94178), To: rune(94178)},
-		&CodePointRange{From: rune(94179), To: rune(94179)},
 		&CodePointRange{From: rune(113824), To: rune(113827)},
+		&CodePointRange{From: rune(118608), To: rune(118723)},
 		&CodePointRange{From: rune(118784), To: rune(119029)},
 		&CodePointRange{From: rune(119040), To: rune(119078)},
 		&CodePointRange{From: rune(119081), To: rune(119140)},
@@ -6009,7 +6150,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(119155), To: rune(119162)},
 		&CodePointRange{From: rune(119171), To: rune(119172)},
 		&CodePointRange{From: rune(119180), To: rune(119209)},
-		&CodePointRange{From: rune(119214), To: rune(119272)},
+		&CodePointRange{From: rune(119214), To: rune(119274)},
 		&CodePointRange{From: rune(119520), To: rune(119539)},
 		&CodePointRange{From: rune(119552), To: rune(119638)},
 		&CodePointRange{From: rune(119648), To: rune(119672)},
@@ -6079,28 +6220,29 @@ var scriptCodepoints = map[string][]*CodePointRange{
 		&CodePointRange{From: rune(127744), To: rune(127994)},
 		&CodePointRange{From: rune(127995), To: rune(127999)},
 		&CodePointRange{From: rune(128000), To: rune(128727)},
-		&CodePointRange{From: rune(128736), To: rune(128748)},
+		&CodePointRange{From: rune(128733), To: rune(128748)},
 		&CodePointRange{From: rune(128752), To: rune(128764)},
 		&CodePointRange{From: rune(128768), To: rune(128883)},
 		&CodePointRange{From: rune(128896), To: rune(128984)},
 		&CodePointRange{From: rune(128992), To: rune(129003)},
+		&CodePointRange{From: rune(129008), To: rune(129008)},
 		&CodePointRange{From: rune(129024), To: rune(129035)},
 		&CodePointRange{From: rune(129040), To: rune(129095)},
 		&CodePointRange{From: rune(129104), To: rune(129113)},
 		&CodePointRange{From: rune(129120), To: rune(129159)},
 		&CodePointRange{From: rune(129168), To: rune(129197)},
 		&CodePointRange{From: rune(129200), To: rune(129201)},
-		&CodePointRange{From: rune(129280), To: rune(129400)},
-		&CodePointRange{From: rune(129402), To: rune(129483)},
-		&CodePointRange{From: rune(129485), To: rune(129619)},
+		&CodePointRange{From: rune(129280), To: rune(129619)},
 		&CodePointRange{From: rune(129632), To: rune(129645)},
 		&CodePointRange{From: rune(129648), To: rune(129652)},
-		&CodePointRange{From: rune(129656), To: rune(129658)},
+		&CodePointRange{From: rune(129656), To: rune(129660)},
 		&CodePointRange{From: rune(129664), To: rune(129670)},
-		&CodePointRange{From: rune(129680), To: rune(129704)},
-		&CodePointRange{From: rune(129712), To: rune(129718)},
-		&CodePointRange{From: rune(129728), To: rune(129730)},
-		&CodePointRange{From: rune(129744), To: rune(129750)},
+		&CodePointRange{From: rune(129680), To: rune(129708)},
+		&CodePointRange{From: rune(129712), To: rune(129722)},
+		&CodePointRange{From: rune(129728), To: rune(129733)},
+		&CodePointRange{From: rune(129744), To: rune(129753)},
+		&CodePointRange{From: rune(129760), To: rune(129767)},
+		&CodePointRange{From: rune(129776), To: rune(129782)},
 		&CodePointRange{From: rune(129792), To: rune(129938)},
 		&CodePointRange{From: rune(129940), To: rune(129994)},
 		&CodePointRange{From: rune(130032), To: rune(130041)},
@@ -6109,7 +6251,7 @@ var scriptCodepoints = map[string][]*CodePointRange{
 	},
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/PropList.txt
+// https://www.unicode.org/Public/14.0.0/ucd/PropList.txt
 var otherAlphabeticCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(837), To: rune(837)},
 	&CodePointRange{From: rune(1456), To: rune(1469)},
@@ -6287,6 +6429,7 @@ var otherAlphabeticCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(6765), To: rune(6770)},
 	&CodePointRange{From: rune(6771), To: rune(6772)},
 	&CodePointRange{From: rune(6847), To: rune(6848)},
+	&CodePointRange{From: rune(6860), To: rune(6862)},
 	&CodePointRange{From: rune(6912), To: rune(6915)},
 	&CodePointRange{From: rune(6916), To: rune(6916)},
 	&CodePointRange{From: rune(6965), To: rune(6965)},
@@ -6373,10 +6516,12 @@ var otherAlphabeticCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(69633), To: rune(69633)},
 	&CodePointRange{From: rune(69634), To: rune(69634)},
 	&CodePointRange{From: rune(69688), To: rune(69701)},
+	&CodePointRange{From: rune(69747), To: rune(69748)},
 	&CodePointRange{From: rune(69762), To: rune(69762)},
 	&CodePointRange{From: rune(69808), To: rune(69810)},
 	&CodePointRange{From: rune(69811), To: rune(69814)},
 	&CodePointRange{From: rune(69815), To: rune(69816)},
+	&CodePointRange{From: rune(69826), To: rune(69826)},
 	&CodePointRange{From: rune(69888), To: rune(69890)},
 	&CodePointRange{From: rune(69927), To: rune(69931)},
 	&CodePointRange{From: rune(69932), To: rune(69932)},
@@ -6503,7 +6648,7 @@ var otherAlphabeticCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(127344), To: rune(127369)},
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/PropList.txt
+// https://www.unicode.org/Public/14.0.0/ucd/PropList.txt
 var otherLowercaseCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(170), To: rune(170)},
 	&CodePointRange{From: rune(186), To: rune(186)},
@@ -6525,9 +6670,13 @@ var otherLowercaseCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(42864), To: rune(42864)},
 	&CodePointRange{From: rune(43000), To: rune(43001)},
 	&CodePointRange{From: rune(43868), To: rune(43871)},
+	&CodePointRange{From: rune(67456), To: rune(67456)},
+	&CodePointRange{From: rune(67459), To: rune(67461)},
+	&CodePointRange{From: rune(67463), To: rune(67504)},
+	&CodePointRange{From: rune(67506), To: rune(67514)},
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/PropList.txt
+// https://www.unicode.org/Public/14.0.0/ucd/PropList.txt
 var otherUppercaseCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(8544), To: rune(8559)},
 	&CodePointRange{From: rune(9398), To: rune(9423)},
@@ -6536,7 +6685,7 @@ var otherUppercaseCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(127344), To: rune(127369)},
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/PropList.txt
+// https://www.unicode.org/Public/14.0.0/ucd/PropList.txt
 var whiteSpaceCodePoints = []*CodePointRange{
 	&CodePointRange{From: rune(9), To: rune(13)},
 	&CodePointRange{From: rune(32), To: rune(32)},
@@ -0,0 +1,33 @@
+package ucd
+
+import "io"
+
+type DerivedAge struct {
+	Age map[string][]*CodePointRange
+}
+
+// ParseDerivedAge parses the DerivedAge.txt.
+func ParseDerivedAge(r io.Reader) (*DerivedAge, error) {
+	age := map[string][]*CodePointRange{}
+	p := newParser(r)
+	for p.parse() {
+		if len(p.fields) == 0 {
+			continue
+		}
+
+		cp, err := p.fields[0].codePointRange()
+		if err != nil {
+			return nil, err
+		}
+
+		v := p.fields[1].symbol()
+		age[v] = append(age[v], cp)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return &DerivedAge{
+		Age: age,
+	}, nil
+}
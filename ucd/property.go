@@ -34,7 +34,7 @@ var compositGeneralCategories = map[string][]string{
 	"c": {"cc", "cf", "cs", "co", "cn"},
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/DerivedCoreProperties.txt
+// https://www.unicode.org/Public/14.0.0/ucd/DerivedCoreProperties.txt
 var derivedCoreProperties = map[string][]string{
 	// Alphabetic
 	"alpha": {
@@ -58,7 +58,7 @@ var derivedCoreProperties = map[string][]string{
 	},
 }
 
-// https://www.unicode.org/Public/13.0.0/ucd/PropertyAliases.txt
+// https://www.unicode.org/Public/14.0.0/ucd/PropertyAliases.txt
 var propertyNameAbbs = map[string]string{
 	"generalcategory": "gc",
 	"gc":              "gc",
@@ -57,7 +57,7 @@ func ParsePropertyValueAliases(r io.Reader) (*PropertyValueAliases, error) {
 		// > Pattern #2 is used in PropertyValueAliases.txt and in DerivedNormalizationProps.txt, both of which
 		// > contain values associated with many properties. For example:
 		// >     # @missing: 0000..10FFFF; NFD_QC; Yes
-		if len(p.defaultFields) > 0 && p.defaultFields[1].symbol() == "General_Category" {
+		if len(p.defaultFields) > 2 && p.defaultFields[1].symbol() == "General_Category" {
 			var err error
 			defaultGCCPRange, err = p.defaultFields[0].codePointRange()
 			if err != nil {
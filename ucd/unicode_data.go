@@ -1,6 +1,10 @@
 package ucd
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"strings"
+)
 
 type UnicodeData struct {
 	GeneralCategory map[string][]*CodePointRange
@@ -15,6 +19,7 @@ func ParseUnicodeData(r io.Reader, propValAliases *PropertyValueAliases) (*Unico
 		propValAliases:  propValAliases,
 	}
 
+	var rangeFirst *CodePointRange
 	p := newParser(r)
 	for p.parse() {
 		if len(p.fields) == 0 {
@@ -24,6 +29,27 @@ func ParseUnicodeData(r io.Reader, propValAliases *PropertyValueAliases) (*Unico
 		if err != nil {
 			return nil, err
 		}
+
+		// https://www.unicode.org/reports/tr44/#Code_Point_Ranges
+		// UnicodeData.txt specifies a range of code points that have the same properties, such as CJK ideographs,
+		// using a pair of records. The name field of the first record has the form `<..., First>` and that of
+		// the last record has the form `<..., Last>`.
+		name := p.fields[1].symbol()
+		if strings.HasSuffix(name, ", First>") {
+			rangeFirst = cp
+			continue
+		}
+		if strings.HasSuffix(name, ", Last>") {
+			if rangeFirst == nil {
+				return nil, fmt.Errorf("the last code point of a range appeared without the first one: %v", name)
+			}
+			cp = &CodePointRange{
+				From: rangeFirst.From,
+				To:   cp.To,
+			}
+			rangeFirst = nil
+		}
+
 		gc := p.fields[2].normalizedSymbol()
 		unicodeData.addGC(gc, cp)
 	}
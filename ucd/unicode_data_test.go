@@ -0,0 +1,162 @@
+package ucd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUnicodeData(t *testing.T) {
+	src := `4DFF;HEXAGRAM FOR BEFORE COMPLETION;So;0;ON;;;;;N;;;;;
+4E00;<CJK Ideograph, First>;Lo;0;L;;;;;N;;;;;
+9FFC;<CJK Ideograph, Last>;Lo;0;L;;;;;N;;;;;
+A000;YI SYLLABLE IT;Lo;0;L;;;;;N;;;;;
+`
+	propValAliases := &PropertyValueAliases{
+		GeneralCategory: map[string]string{
+			"so": "so",
+			"lo": "lo",
+		},
+	}
+	unicodeData, err := ParseUnicodeData(strings.NewReader(src), propValAliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]*CodePointRange{
+		"so": {
+			{From: 0x4DFF, To: 0x4DFF},
+		},
+		"lo": {
+			{From: 0x4E00, To: 0x9FFC},
+			{From: 0xA000, To: 0xA000},
+		},
+	}
+	if len(unicodeData.GeneralCategory) != len(expected) {
+		t.Fatalf("unexpected general categories; want: %v, got: %v", len(expected), len(unicodeData.GeneralCategory))
+	}
+	for gc, eCPs := range expected {
+		cps := unicodeData.GeneralCategory[gc]
+		if len(cps) != len(eCPs) {
+			t.Fatalf("unexpected code point ranges of %v; want: %v, got: %v", gc, len(eCPs), len(cps))
+		}
+		for i, eCP := range eCPs {
+			if *cps[i] != *eCP {
+				t.Fatalf("unexpected code point range of %v; want: %+v, got: %+v", gc, eCP, cps[i])
+			}
+		}
+	}
+}
+
+func TestFindCodePointRanges_CoversCodePointsOfUnicodeVersion(t *testing.T) {
+	if UnicodeVersion != "14.0.0" {
+		t.Skipf("the code points in this test are introduced in Unicode 14.0.0 (the tables are generated from %v)", UnicodeVersion)
+	}
+	tests := []struct {
+		propName string
+		propVal  string
+		cps      []rune
+	}{
+		// CJK Unified Ideographs
+		{propName: "gc", propVal: "Lo", cps: []rune{0x9FFD, 0x9FFF}},
+		// CJK Unified Ideographs Extension B
+		{propName: "gc", propVal: "Lo", cps: []rune{0x2A6DE, 0x2A6DF}},
+		// Transport and Map Symbols
+		{propName: "gc", propVal: "So", cps: []rune{0x1F6DD}},
+		{propName: "gc", propVal: "Lu", cps: []rune{0x10570}},
+		{propName: "sc", propVal: "Vithkuqi", cps: []rune{0x10570, 0x105BC}},
+		{propName: "sc", propVal: "Toto", cps: []rune{0x1E290, 0x1E2AE}},
+		{propName: "sc", propVal: "Tangsa", cps: []rune{0x16A70, 0x16AC9}},
+		{propName: "sc", propVal: "Cypro_Minoan", cps: []rune{0x12F90, 0x12FF2}},
+		{propName: "sc", propVal: "Old_Uyghur", cps: []rune{0x10F70, 0x10F89}},
+	}
+	for _, tt := range tests {
+		ranges, inverse, err := FindCodePointRanges(tt.propName, tt.propVal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inverse {
+			t.Fatalf("%v=%v must not be inverse", tt.propName, tt.propVal)
+		}
+		for _, cp := range tt.cps {
+			found := false
+			for _, r := range ranges {
+				if cp >= r.From && cp <= r.To {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("%v=%v doesn't contain U+%04X", tt.propName, tt.propVal, cp)
+			}
+		}
+	}
+}
+
+func TestFindCodePointRanges_SortedAndDisjoint(t *testing.T) {
+	tests := []struct {
+		propName string
+		propVal  string
+	}{
+		// A composite category consists of the ranges of several categories.
+		{propName: "gc", propVal: "L"},
+		{propName: "gc", propVal: "Letter"},
+		// The default values are the complements of all the other values.
+		{propName: "gc", propVal: "Cn"},
+		{propName: "sc", propVal: "Zzzz"},
+		{propName: "sc", propVal: "Latin"},
+		{propName: "wspace", propVal: "yes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.propName+"="+tt.propVal, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				ranges, _, err := FindCodePointRanges(tt.propName, tt.propVal)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(ranges) == 0 {
+					t.Fatalf("no ranges")
+				}
+				for j, r := range ranges {
+					if r.From > r.To {
+						t.Fatalf("invalid range: %X..%X", r.From, r.To)
+					}
+					if j == 0 {
+						continue
+					}
+					// Adjacent ranges must be merged as well as overlapping ones.
+					if prev := ranges[j-1]; r.From <= prev.To+1 {
+						t.Fatalf("ranges must be sorted and disjoint: %X..%X, %X..%X", prev.From, prev.To, r.From, r.To)
+					}
+				}
+				// Modifying the result must not affect the tables.
+				ranges[0].From = codePointMax
+			}
+		})
+	}
+}
+
+func TestNormalizeCodePointRanges(t *testing.T) {
+	src := []*CodePointRange{
+		{From: 0x30, To: 0x39},
+		{From: 0x10, To: 0x1F},
+		{From: 0x20, To: 0x25},
+		{From: 0x38, To: 0x40},
+		{From: 0x50, To: 0x50},
+		{From: 0x22, To: 0x23},
+	}
+	expected := []*CodePointRange{
+		{From: 0x10, To: 0x25},
+		{From: 0x30, To: 0x40},
+		{From: 0x50, To: 0x50},
+	}
+	actual := NormalizeCodePointRanges(src)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("unexpected ranges; want: %v, got: %v", expected, actual)
+	}
+	if src[0].From != 0x30 || src[0].To != 0x39 || src[1].From != 0x10 {
+		t.Fatalf("the source ranges must not be modified")
+	}
+	if NormalizeCodePointRanges(nil) != nil {
+		t.Fatalf("the result of no ranges must be nil")
+	}
+}
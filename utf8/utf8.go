@@ -5,6 +5,8 @@ import (
 	"strings"
 )
 
+// CharBlock represents a range of UTF-8 byte sequences <From..To>. From and To have the same length, and each byte of
+// a byte sequence in the block is within the range of the corresponding bytes of From and To.
 type CharBlock struct {
 	From []byte
 	To   []byte
@@ -26,6 +28,10 @@ func (b *CharBlock) String() string {
 	return s.String()
 }
 
+// GenCharBlocks converts a code point range <from..to> into blocks of UTF-8 byte sequences. This function encodes
+// the code points into UTF-8 itself, so you can use it to see what byte sequences a character class matches.
+// For instance, GenCharBlocks('\u0000', '\u07ff') returns <00..7F> and <C2 80..DF BF>. See splitCodePoint for
+// more details.
 func GenCharBlocks(from, to rune) ([]*CharBlock, error) {
 	rs, err := splitCodePoint(from, to)
 	if err != nil {